@@ -4,12 +4,14 @@ go 1.22.0
 
 require (
 	github.com/google/go-cmp v0.6.0
+	github.com/pelletier/go-toml/v2 v2.2.2
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/pflag v1.0.5
+	golang.org/x/sys v0.20.0
 	golang.org/x/term v0.20.0
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	golang.org/x/sys v0.20.0 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -59,3 +59,21 @@ func unsetIf(key string) {
 		}
 	}
 }
+
+// SetEnabled explicitly enables or disables ANSI formatting. This overrides
+// any decision made from the NOCOLOR or NO_COLOR environment variables.
+func SetEnabled(value bool) {
+	enabled = value
+	if value {
+		createFormatFunc = ansiFormat
+		formatFunc = fmt.Sprintf
+		return
+	}
+	createFormatFunc = noFormat
+	formatFunc = stripSprintf
+}
+
+// Enabled returns whether ANSI formatting is currently enabled.
+func Enabled() bool {
+	return enabled
+}
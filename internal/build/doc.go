@@ -0,0 +1,10 @@
+/*
+Package build plans and runs the protoc invocations that generate the outputs
+of protobuild targets.
+
+A [Plan] is computed from the loaded workspace configuration, and describes
+every target that must be generated, in dependency order, along with the
+exact protoc arguments for each of their outputs. Planning is separate from
+running, so that plans may be inspected and tested without protoc installed.
+*/
+package build
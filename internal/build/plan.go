@@ -0,0 +1,309 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+)
+
+// ProjectResolver returns the local directory containing the sources of a
+// registry project.
+type ProjectResolver func(project *config.Project, registry *config.Registry) (string, error)
+
+// Planner computes generation plans for the targets of a workspace.
+type Planner struct {
+	// Workspace is the workspace that the targets belong to.
+	Workspace *config.Workspace
+
+	// Graph is the dependency graph of the targets in the workspace.
+	Graph *graph.Graph
+
+	// ResolveProject locates the sources of registry projects that are
+	// depended on. If nil, depending on a registry project is an error.
+	ResolveProject ProjectResolver
+
+	// Includes are directories that imports are resolved against after
+	// those of every target and project, such as the directory of the
+	// well-known types of protoc.
+	Includes []string
+}
+
+// Plan is the ordered set of generation steps needed to generate a target.
+type Plan struct {
+	// Target is the target that was planned.
+	Target *config.Target
+
+	// Dependencies are the names of every target and registry project that
+	// the target depends on, directly or transitively, in dependency order.
+	Dependencies []string
+
+	// Steps are the generation steps of the target and each of the workspace
+	// targets that it depends on, in dependency order. The last step is
+	// always the planned target.
+	Steps []*Step
+}
+
+// Step is the generation of every output of a single target.
+type Step struct {
+	// Target is the target being generated.
+	Target *config.Target
+
+	// Dependencies are the names of the targets whose steps must complete
+	// before this step may start, sorted by name.
+	Dependencies []string
+
+	// Includes are the directories that the imports of the target are
+	// resolved against, in the order that they are searched.
+	Includes []string
+
+	// Files are the source files of the target, relative to the includes.
+	Files []string
+
+	// Invocations are the protoc invocations for each output of the target,
+	// in the order that the outputs are defined.
+	Invocations []*Invocation
+}
+
+// Invocation is a single protoc invocation that generates one output.
+type Invocation struct {
+	// Output is the output being generated.
+	Output config.Output
+
+	// Dir is the directory that the output is generated into.
+	Dir string
+
+	// Includes are the directories that imports are resolved against, in
+	// the order that they are searched.
+	Includes []string
+
+	// Files are the proto files to generate, relative to the includes.
+	Files []string
+
+	// Options are the options passed to the plugin.
+	Options []string
+}
+
+// Args returns the arguments passed to protoc to generate the output into
+// the directory out.
+func (i *Invocation) Args(out string) []string {
+	args := make([]string, 0, len(i.Includes)+len(i.Files)+2)
+	for _, include := range i.Includes {
+		args = append(args, "--proto_path="+include)
+	}
+	args = append(args, fmt.Sprintf("--%s_out=%s", i.Output.Plugin, out))
+	if len(i.Options) > 0 {
+		args = append(args, fmt.Sprintf("--%s_opt=%s", i.Output.Plugin, strings.Join(i.Options, ",")))
+	}
+	return append(args, i.Files...)
+}
+
+// Check traces the imports of the files of every step, and returns an error
+// for every file that cannot be parsed and every import that cannot be
+// found, joined with errors.Join, so that they are reported before protoc is
+// run. Errors in files that are shared between steps are only returned once.
+func (p *Plan) Check() error {
+	var errs []error
+	seen := make(map[string]bool)
+	add := func(err error) {
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
+		}
+	}
+	for _, step := range p.Steps {
+		if len(step.Invocations) == 0 {
+			continue
+		}
+		imports, err := protofile.Trace(step.Includes, step.Files)
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, err := range joined.Unwrap() {
+				add(err)
+			}
+		} else if err != nil {
+			add(err)
+		}
+		for _, err := range imports.Missing() {
+			add(err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Plan computes the plan for generating the target with the specified name.
+// Dependencies are planned in the topological order of the graph.
+func (p *Planner) Plan(name string) (*Plan, error) {
+	order, err := p.Graph.Order(name)
+	if err != nil {
+		return nil, err
+	}
+	node := order[len(order)-1]
+	if node.Kind != graph.KindTarget {
+		return nil, fmt.Errorf("%q is a registry project, not a target", name)
+	}
+
+	s := &planState{
+		planner: p,
+		roots:   make(map[string][]string, len(order)),
+		targets: make(map[string][]string, len(order)),
+	}
+	plan := &Plan{Target: node.Target}
+	for _, node := range order {
+		if err := s.resolve(node); err != nil {
+			return nil, err
+		}
+		if node.Name != name {
+			plan.Dependencies = append(plan.Dependencies, node.Name)
+		}
+		if node.Kind != graph.KindTarget {
+			continue
+		}
+		step, err := s.step(node.Target)
+		if err != nil {
+			return nil, err
+		}
+		for _, dependency := range node.Dependencies {
+			step.Dependencies = appendUnique(step.Dependencies, s.targets[dependency]...)
+		}
+		slices.Sort(step.Dependencies)
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// planState is the state accumulated while planning the nodes of a graph in
+// topological order.
+type planState struct {
+	planner *Planner
+
+	// roots are the resolved include directories that each node provides,
+	// including those of its own dependencies.
+	roots map[string][]string
+
+	// targets are the targets that each node is, or depends on through
+	// registry projects, whose steps must complete before its dependents.
+	targets map[string][]string
+}
+
+// resolve computes the include directories provided by the node. Every
+// dependency of the node must already have been resolved.
+func (s *planState) resolve(node *graph.Node) error {
+	var roots, targets []string
+	if node.Kind == graph.KindTarget {
+		roots = s.targetRoots(node.Target)
+		targets = []string{node.Name}
+	} else {
+		projectRoots, err := s.projectRoots(node.Project, node.Registry)
+		if err != nil {
+			return err
+		}
+		roots = projectRoots
+	}
+	for _, dependency := range node.Dependencies {
+		roots = appendUnique(roots, s.roots[dependency]...)
+		if node.Kind != graph.KindTarget {
+			targets = appendUnique(targets, s.targets[dependency]...)
+		}
+	}
+	s.roots[node.Name] = roots
+	s.targets[node.Name] = targets
+	return nil
+}
+
+// targetRoots returns the resolved import roots of a workspace target.
+func (s *planState) targetRoots(target *config.Target) []string {
+	root := s.planner.Workspace.RootDir()
+	if len(target.ImportRoots) == 0 {
+		return []string{root}
+	}
+	roots := make([]string, 0, len(target.ImportRoots))
+	for _, dir := range target.ImportRoots {
+		roots = appendUnique(roots, resolve(root, dir))
+	}
+	return roots
+}
+
+// projectRoots returns the resolved proto roots of a registry project.
+func (s *planState) projectRoots(project *config.Project, registry *config.Registry) ([]string, error) {
+	if s.planner.ResolveProject == nil {
+		return nil, fmt.Errorf("project %q cannot be resolved to local sources", project.Name)
+	}
+	dir, err := s.planner.ResolveProject(project, registry)
+	if err != nil {
+		return nil, fmt.Errorf("project %q: %w", project.Name, err)
+	}
+	if len(project.ProtoRoots) == 0 {
+		return []string{dir}, nil
+	}
+	roots := make([]string, 0, len(project.ProtoRoots))
+	for _, root := range project.ProtoRoots {
+		roots = appendUnique(roots, resolve(dir, root))
+	}
+	return roots, nil
+}
+
+// step computes the protoc invocations for every output of the target.
+func (s *planState) step(target *config.Target) (*Step, error) {
+	includes := appendUnique(slices.Clone(s.roots[target.Name]), s.planner.Includes...)
+	own := s.targetRoots(target)
+
+	var files []string
+	for _, path := range target.SourceFiles() {
+		file, ok := relativeTo(own, path)
+		if !ok {
+			return nil, fmt.Errorf("target %q: source %s is not under any of its import roots", target.Name, path)
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("target %q: source patterns do not match any files", target.Name)
+	}
+
+	workspace := s.planner.Workspace
+	step := &Step{Target: target, Includes: includes, Files: files}
+	for _, output := range target.Outputs {
+		step.Invocations = append(step.Invocations, &Invocation{
+			Output:   output,
+			Dir:      filepath.Join(workspace.OutputDir(), output.Out),
+			Includes: includes,
+			Files:    files,
+			Options:  slices.Concat(workspace.PluginOptions[output.Plugin], output.Options),
+		})
+	}
+	return step, nil
+}
+
+// relativeTo returns the path relative to the first root that contains it,
+// using forward slashes as protoc expects.
+func relativeTo(roots []string, path string) (string, bool) {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.ToSlash(rel), true
+	}
+	return "", false
+}
+
+func resolve(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+func appendUnique(values []string, add ...string) []string {
+	for _, value := range add {
+		if !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
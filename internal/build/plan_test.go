@@ -0,0 +1,219 @@
+package build_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/build"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// newPlanner creates a planner over a workspace with targets "a", and "b"
+// which depends on "a" and on the registry project "acme/types".
+func newPlanner(t *testing.T) (*build.Planner, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "proto", "a", "a.proto"), `syntax = "proto3";`)
+	writeFile(t, filepath.Join(dir, "proto", "b", "b.proto"), `syntax = "proto3";`)
+
+	workspace := &config.Workspace{
+		Root:          "proto",
+		Output:        "gen",
+		PluginOptions: map[string][]string{"go": {"paths=source_relative"}},
+		Path:          filepath.Join(dir, "protobuild.yaml"),
+	}
+	a := &config.Target{
+		Name:    "a",
+		Sources: glob.NewPatterns("*.proto"),
+		Outputs: []config.Output{{Plugin: "go", Out: "go"}},
+		Path:    filepath.Join(dir, "proto", "a", "protobuild-target.yaml"),
+	}
+	b := &config.Target{
+		Name:         "b",
+		Sources:      glob.NewPatterns("*.proto"),
+		Dependencies: []string{"a", "acme/types"},
+		Outputs: []config.Output{
+			{Plugin: "go", Out: "go-b"},
+			{Name: "c++", Plugin: "cpp", Out: "cpp", Options: []string{"lite"}},
+		},
+		Path: filepath.Join(dir, "proto", "b", "protobuild-target.yaml"),
+	}
+	registry := &config.Registry{
+		Projects: []config.Project{{
+			Name:       "acme/types",
+			ProtoRoots: []string{"proto"},
+		}},
+		Name: "acme",
+		Path: filepath.Join(dir, "registry", "protobuild-registry.yaml"),
+	}
+	g, err := graph.New([]*config.Target{b, a}, config.NewIndex(registry))
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+	planner := &build.Planner{
+		Workspace: workspace,
+		Graph:     g,
+		ResolveProject: func(project *config.Project, _ *config.Registry) (string, error) {
+			return filepath.Join(dir, "external", project.Name), nil
+		},
+	}
+	return planner, dir
+}
+
+func TestPlannerPlan_TargetWithDependencies_ReturnsStepsInOrder(t *testing.T) {
+	planner, dir := newPlanner(t)
+	proto := filepath.Join(dir, "proto")
+	external := filepath.Join(dir, "external", "acme", "types", "proto")
+
+	got, err := planner.Plan("b")
+	if err != nil {
+		t.Fatalf("Planner.Plan: unexpected error: %v", err)
+	}
+
+	if got, want := got.Dependencies, []string{"a", "acme/types"}; !cmp.Equal(got, want) {
+		t.Errorf("Planner.Plan: Dependencies = %v, want %v", got, want)
+	}
+	var steps []string
+	for _, step := range got.Steps {
+		steps = append(steps, step.Target.Name)
+	}
+	if want := []string{"a", "b"}; !cmp.Equal(steps, want) {
+		t.Errorf("Planner.Plan: Steps = %v, want %v", steps, want)
+	}
+	if got, want := got.Steps[1].Dependencies, []string{"a"}; !cmp.Equal(got, want) {
+		t.Errorf("Planner.Plan: Steps[1].Dependencies = %v, want %v", got, want)
+	}
+	if got, want := got.Steps[1].Includes, []string{proto, external}; !cmp.Equal(got, want) {
+		t.Errorf("Planner.Plan: Steps[1].Includes = %v, want %v", got, want)
+	}
+	if got, want := got.Steps[1].Files, []string{"b/b.proto"}; !cmp.Equal(got, want) {
+		t.Errorf("Planner.Plan: Steps[1].Files = %v, want %v", got, want)
+	}
+
+	want := []*build.Invocation{
+		{
+			Output:   config.Output{Plugin: "go", Out: "go-b"},
+			Dir:      filepath.Join(dir, "gen", "go-b"),
+			Includes: []string{proto, external},
+			Files:    []string{"b/b.proto"},
+			Options:  []string{"paths=source_relative"},
+		}, {
+			Output:   config.Output{Name: "c++", Plugin: "cpp", Out: "cpp", Options: []string{"lite"}},
+			Dir:      filepath.Join(dir, "gen", "cpp"),
+			Includes: []string{proto, external},
+			Files:    []string{"b/b.proto"},
+			Options:  []string{"lite"},
+		},
+	}
+	if got := got.Steps[len(got.Steps)-1].Invocations; !cmp.Equal(got, want) {
+		t.Errorf("Planner.Plan: Invocations (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestPlannerPlan_Includes_SearchesIncludesLast(t *testing.T) {
+	planner, dir := newPlanner(t)
+	wkt := filepath.Join(dir, "protoc", "include")
+	planner.Includes = []string{wkt}
+
+	got, err := planner.Plan("a")
+	if err != nil {
+		t.Fatalf("Planner.Plan: unexpected error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "proto"), wkt}
+	if got := got.Steps[0].Invocations[0].Includes; !cmp.Equal(got, want) {
+		t.Errorf("Planner.Plan: Includes = %v, want %v", got, want)
+	}
+}
+
+func TestPlanCheck_Imports_ReportsMissingImportsOnce(t *testing.T) {
+	planner, dir := newPlanner(t)
+	a := filepath.Join(dir, "proto", "a", "a.proto")
+	writeFile(t, a, "syntax = \"proto3\";\nimport \"missing.proto\";")
+	writeFile(t, filepath.Join(dir, "proto", "b", "b.proto"), `import "a/a.proto";`)
+	writeFile(t, filepath.Join(dir, "external", "acme", "types", "proto", "types.proto"), ``)
+	plan, err := planner.Plan("b")
+	if err != nil {
+		t.Fatalf("Planner.Plan: unexpected error: %v", err)
+	}
+
+	err = plan.Check()
+
+	want := a + `:2:1: Import "missing.proto" was not found.`
+	if err == nil || err.Error() != want {
+		t.Errorf("Plan.Check: got error %v, want %q", err, want)
+	}
+}
+
+func TestPlannerPlan_InvalidTarget_ReturnsError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		target string
+		modify func(*build.Planner)
+	}{
+		{
+			name:   "unknown target",
+			target: "c",
+		}, {
+			name:   "unresolvable project",
+			target: "b",
+			modify: func(p *build.Planner) { p.ResolveProject = nil },
+		}, {
+			name:   "registry project",
+			target: "acme/types",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			planner, _ := newPlanner(t)
+			if tc.modify != nil {
+				tc.modify(planner)
+			}
+
+			_, err := planner.Plan(tc.target)
+
+			if err == nil {
+				t.Errorf("Planner.Plan: got nil error, want error")
+			}
+		})
+	}
+}
+
+func TestInvocationArgs_WithOptions_ReturnsProtocArgs(t *testing.T) {
+	invocation := &build.Invocation{
+		Output:   config.Output{Plugin: "go"},
+		Dir:      "gen/go",
+		Includes: []string{"proto", "third_party"},
+		Files:    []string{"a/a.proto", "a/b.proto"},
+		Options:  []string{"paths=source_relative", "module=example.com"},
+	}
+	want := []string{
+		"--proto_path=proto",
+		"--proto_path=third_party",
+		"--go_out=out",
+		"--go_opt=paths=source_relative,module=example.com",
+		"a/a.proto",
+		"a/b.proto",
+	}
+
+	got := invocation.Args("out")
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Invocation.Args: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
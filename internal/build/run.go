@@ -0,0 +1,272 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/bitwizeshift/protobuild/internal/cache"
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+)
+
+// DefaultProtoc is the name of the protoc executable that is searched for in
+// the PATH when no other is specified.
+const DefaultProtoc = "protoc"
+
+// builtinPlugins are the generators that are built into protoc, rather than
+// provided by a protoc-gen-* executable.
+var builtinPlugins = map[string]bool{
+	"cpp":    true,
+	"csharp": true,
+	"java":   true,
+	"kotlin": true,
+	"objc":   true,
+	"php":    true,
+	"pyi":    true,
+	"python": true,
+	"ruby":   true,
+	"rust":   true,
+}
+
+// IsBuiltin reports whether the plugin is built into protoc, rather than
+// provided by a protoc-gen-* executable.
+func IsBuiltin(plugin string) bool {
+	return builtinPlugins[plugin]
+}
+
+// Runner runs protoc invocations.
+type Runner struct {
+	// Protoc is the path or name of the protoc executable. Defaults to
+	// DefaultProtoc.
+	Protoc string
+
+	// Dir is the working directory that protoc is run in. Defaults to the
+	// current directory.
+	Dir string
+
+	// Cache stores the outputs of each invocation, keyed by everything that
+	// can affect them, so that unchanged outputs are restored rather than
+	// generated again. If nil, every invocation is generated.
+	Cache *cache.Cache
+
+	// Plugins are the paths of plugin executables, keyed by the name of the
+	// plugin, that are used instead of searching the PATH for them.
+	Plugins map[string]string
+
+	mu      sync.Mutex
+	digests map[string]string
+}
+
+// Run generates the output of the invocation, and reports whether it was
+// restored from the cache instead of being generated.
+//
+// If protoc fails, the returned error contains its output. If the context is
+// cancelled while protoc is running, protoc is killed and the error of the
+// context is returned.
+func (r *Runner) Run(ctx context.Context, invocation *Invocation) (bool, error) {
+	if r.Cache == nil {
+		return false, r.generate(ctx, invocation, invocation.Dir)
+	}
+
+	// Invocations that cannot be keyed, such as those of plugins that are not
+	// installed, are generated without the cache so that protoc reports the
+	// underlying problem.
+	key, err := r.Key(ctx, invocation)
+	if err != nil {
+		return false, r.generate(ctx, invocation, invocation.Dir)
+	}
+	if manifest, err := r.Cache.Lookup(ctx, key); err == nil {
+		return true, r.Cache.Restore(manifest, invocation.Dir)
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		return false, err
+	}
+
+	tmp, err := r.Cache.TempDir()
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := r.generate(ctx, invocation, tmp); err != nil {
+		return false, err
+	}
+	manifest, err := r.Cache.Store(ctx, key, tmp)
+	if err != nil {
+		return false, err
+	}
+	return false, r.Cache.Restore(manifest, invocation.Dir)
+}
+
+// Key returns the cache key of the invocation. The key covers the version of
+// protoc, the plugin executable, the plugin options, the files being
+// generated, and the contents of every file that they transitively import.
+// Other files in the include paths are not part of the key, and neither are
+// the include paths themselves, so equivalent checkouts share entries.
+// Invocations with imports that cannot be resolved have no key.
+func (r *Runner) Key(ctx context.Context, invocation *Invocation) (string, error) {
+	version, err := r.protocVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	plugin, err := r.pluginDigest(invocation.Output.Plugin)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	write := func(field string, values ...string) {
+		fmt.Fprintf(hash, "%s %d\n", field, len(values))
+		for _, value := range values {
+			fmt.Fprintf(hash, "%q\n", value)
+		}
+	}
+	write("protoc", version)
+	write("plugin", invocation.Output.Plugin, plugin)
+	write("options", invocation.Options...)
+	write("files", invocation.Files...)
+
+	imports, err := protofile.Trace(invocation.Includes, invocation.Files)
+	if err != nil {
+		return "", err
+	}
+	if missing := imports.Missing(); len(missing) > 0 {
+		return "", missing[0]
+	}
+	for _, node := range imports.Nodes() {
+		filename := node.Filename()
+		digest, err := r.memoize("file:"+filename, func() (string, error) {
+			return cache.DigestFile(filename)
+		})
+		if err != nil {
+			return "", err
+		}
+		write("import", node.File.Path, digest)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// generate creates the output directory, and runs protoc to generate the
+// output of the invocation into it.
+func (r *Runner) generate(ctx context.Context, invocation *Invocation, out string) error {
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, r.protoc(), r.Args(invocation, out)...)
+	cmd.Dir = r.Dir
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.As(err, &exitErr) && output.Len() > 0:
+		return &ProtocError{Output: strings.TrimSpace(output.String()), Err: err}
+	default:
+		return err
+	}
+}
+
+// Args returns the arguments that protoc is run with to generate the output
+// of the invocation into out. Plugins with a path in Plugins are passed to
+// protoc explicitly.
+func (r *Runner) Args(invocation *Invocation, out string) []string {
+	args := invocation.Args(out)
+	if path, ok := r.Plugins[invocation.Output.Plugin]; ok {
+		plugin := fmt.Sprintf("--plugin=protoc-gen-%s=%s", invocation.Output.Plugin, path)
+		args = append([]string{plugin}, args...)
+	}
+	return args
+}
+
+func (r *Runner) protoc() string {
+	if r.Protoc == "" {
+		return DefaultProtoc
+	}
+	return r.Protoc
+}
+
+// protocVersion returns the version reported by protoc, which is only
+// queried until it succeeds. The query is not cancelled with the context, so
+// that the cancellation of the invocation that happens to query it first
+// does not fail the others.
+func (r *Runner) protocVersion(ctx context.Context) (string, error) {
+	return r.memoize("protoc", func() (string, error) {
+		cmd := exec.CommandContext(context.WithoutCancel(ctx), r.protoc(), "--version")
+		cmd.Dir = r.Dir
+		output, err := cmd.Output()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(output)), nil
+	})
+}
+
+// pluginDigest returns the digest of the executable of the plugin. Plugins
+// that are built into protoc are identified by the protoc version instead.
+func (r *Runner) pluginDigest(plugin string) (string, error) {
+	if builtinPlugins[plugin] {
+		return "builtin", nil
+	}
+	path, ok := r.Plugins[plugin]
+	if !ok {
+		var err error
+		if path, err = exec.LookPath("protoc-gen-" + plugin); err != nil {
+			return "", err
+		}
+	}
+	return r.memoize("plugin:"+path, func() (string, error) {
+		return cache.DigestFile(path)
+	})
+}
+
+// memoize returns the value computed for the key, computing it if it has not
+// been computed yet. Errors are not memoized.
+func (r *Runner) memoize(key string, compute func() (string, error)) (string, error) {
+	r.mu.Lock()
+	value, ok := r.digests[key]
+	r.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+	value, err := compute()
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.digests == nil {
+		r.digests = make(map[string]string)
+	}
+	r.digests[key] = value
+	return value, nil
+}
+
+// ProtocError is the error returned when protoc exits unsuccessfully.
+type ProtocError struct {
+	// Output is the combined output written by protoc.
+	Output string
+
+	// Err is the underlying error from running protoc.
+	Err error
+}
+
+func (e *ProtocError) Error() string {
+	return fmt.Sprintf("protoc %v:\n%s", e.Err, e.Output)
+}
+
+func (e *ProtocError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,143 @@
+package build_test
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/build"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunnerKey_IncludeContents_DeterminesKey(t *testing.T) {
+	// Any executable that succeeds with --version stands in for protoc.
+	protoc, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo is not available to stand in for protoc")
+	}
+	key := func(dir string) string {
+		t.Helper()
+		runner := &build.Runner{Protoc: protoc}
+		got, err := runner.Key(context.Background(), &build.Invocation{
+			Output:   config.Output{Plugin: "cpp"},
+			Includes: []string{dir},
+			Files:    []string{"a.proto"},
+		})
+		if err != nil {
+			t.Fatalf("Runner.Key: unexpected error: %v", err)
+		}
+		return got
+	}
+	first, second, changed := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(first, "a.proto"), `syntax = "proto3";`)
+	writeFile(t, filepath.Join(second, "a.proto"), `syntax = "proto3";`)
+	writeFile(t, filepath.Join(changed, "a.proto"), `syntax = "proto2";`)
+
+	if key(first) != key(second) {
+		t.Errorf("Runner.Key: keys differ for identical include trees in different directories")
+	}
+	if key(first) == key(changed) {
+		t.Errorf("Runner.Key: keys match for include trees with different contents")
+	}
+}
+
+func TestRunnerKey_UnimportedFile_DoesNotDetermineKey(t *testing.T) {
+	protoc, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo is not available to stand in for protoc")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.proto"), `import "b.proto";`)
+	writeFile(t, filepath.Join(dir, "b.proto"), `syntax = "proto3";`)
+	key := func() string {
+		t.Helper()
+		runner := &build.Runner{Protoc: protoc}
+		got, err := runner.Key(context.Background(), &build.Invocation{
+			Output:   config.Output{Plugin: "cpp"},
+			Includes: []string{dir},
+			Files:    []string{"a.proto"},
+		})
+		if err != nil {
+			t.Fatalf("Runner.Key: unexpected error: %v", err)
+		}
+		return got
+	}
+	before := key()
+
+	writeFile(t, filepath.Join(dir, "unrelated.proto"), `syntax = "proto3";`)
+	unrelated := key()
+	writeFile(t, filepath.Join(dir, "b.proto"), `syntax = "proto2";`)
+	imported := key()
+
+	if before != unrelated {
+		t.Errorf("Runner.Key: key changed when a file that is not imported was added")
+	}
+	if before == imported {
+		t.Errorf("Runner.Key: key did not change when an imported file changed")
+	}
+}
+
+func TestRunnerKey_CancelledContext_QueriesVersion(t *testing.T) {
+	protoc, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo is not available to stand in for protoc")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.proto"), `syntax = "proto3";`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := &build.Runner{Protoc: protoc}
+
+	_, err = runner.Key(ctx, &build.Invocation{
+		Output:   config.Output{Plugin: "cpp"},
+		Includes: []string{dir},
+		Files:    []string{"a.proto"},
+	})
+
+	if err != nil {
+		t.Errorf("Runner.Key: unexpected error: %v", err)
+	}
+}
+
+func TestRunnerKey_FailedVersion_IsQueriedAgain(t *testing.T) {
+	protoc, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo is not available to stand in for protoc")
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.proto"), `syntax = "proto3";`)
+	invocation := &build.Invocation{
+		Output:   config.Output{Plugin: "cpp"},
+		Includes: []string{dir},
+		Files:    []string{"a.proto"},
+	}
+	runner := &build.Runner{Protoc: filepath.Join(dir, "missing-protoc")}
+	if _, err := runner.Key(context.Background(), invocation); err == nil {
+		t.Fatalf("Runner.Key: got nil error, want an error for a missing protoc")
+	}
+
+	runner.Protoc = protoc
+	_, err = runner.Key(context.Background(), invocation)
+
+	if err != nil {
+		t.Errorf("Runner.Key: unexpected error: %v", err)
+	}
+}
+
+func TestRunnerArgs_InstalledPlugin_PassesPlugin(t *testing.T) {
+	runner := &build.Runner{Plugins: map[string]string{"go": "/bin/protoc-gen-go"}}
+	invocation := &build.Invocation{
+		Output:   config.Output{Plugin: "go"},
+		Includes: []string{"protos"},
+		Files:    []string{"a.proto"},
+	}
+
+	got := runner.Args(invocation, "out")
+
+	want := []string{"--plugin=protoc-gen-go=/bin/protoc-gen-go", "--proto_path=protos", "--go_out=out", "a.proto"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Runner.Args: mismatch (-want +got):\n%s", diff)
+	}
+}
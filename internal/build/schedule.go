@@ -0,0 +1,178 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrDependencyFailed is the error of invocations that were not run because
+// a step that they depend on failed.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// Observer is notified of the progress of a schedule. Calls are always made
+// from a single goroutine, in the order of the plan, regardless of the order
+// that the invocations actually complete in.
+type Observer interface {
+	// Waiting is called before waiting for the result of an invocation.
+	Waiting(step *Step, invocation *Invocation)
+
+	// Finished is called with the result of an invocation.
+	Finished(result *Result)
+}
+
+// Result is the outcome of a single invocation.
+type Result struct {
+	// Step is the step that the invocation belongs to.
+	Step *Step
+
+	// Invocation is the invocation that was run.
+	Invocation *Invocation
+
+	// Cached is whether the output was restored from the cache rather than
+	// being generated.
+	Cached bool
+
+	// Err is the error of the invocation, or nil if it succeeded. This is
+	// context.Canceled if the invocation was cancelled because another
+	// failed, or wraps ErrDependencyFailed if it was never run.
+	Err error
+}
+
+// Scheduler runs the invocations of a plan concurrently. Steps start as soon
+// as the steps they depend on complete, and the outputs of a step are
+// generated concurrently with each other.
+type Scheduler struct {
+	// Jobs is the maximum number of invocations run at once. Defaults to the
+	// number of CPUs.
+	Jobs int
+
+	// KeepGoing continues running every invocation that does not depend on a
+	// failed step, instead of cancelling everything on the first failure.
+	KeepGoing bool
+
+	// Run runs a single invocation, and reports whether its output was
+	// restored from the cache.
+	Run func(ctx context.Context, invocation *Invocation) (bool, error)
+}
+
+// Schedule runs every invocation in the plan, notifying the observer of each
+// result in plan order. The returned error joins the errors of every
+// invocation that failed, excluding those that were cancelled or skipped as
+// a consequence.
+func (s *Scheduler) Schedule(ctx context.Context, plan *Plan, observer Observer) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := s.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	sem := make(chan struct{}, jobs)
+
+	type pending struct {
+		result Result
+		done   chan struct{}
+	}
+	type stepState struct {
+		done    chan struct{}
+		failed  bool
+		pending []*pending
+	}
+	states := make(map[string]*stepState, len(plan.Steps))
+	for _, step := range plan.Steps {
+		state := &stepState{done: make(chan struct{})}
+		for _, invocation := range step.Invocations {
+			state.pending = append(state.pending, &pending{
+				result: Result{Step: step, Invocation: invocation},
+				done:   make(chan struct{}),
+			})
+		}
+		states[step.Target.Name] = state
+	}
+
+	run := func(p *pending) (bool, error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		defer func() { <-sem }()
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return s.Run(ctx, p.result.Invocation)
+	}
+
+	var wg sync.WaitGroup
+	for _, step := range plan.Steps {
+		state := states[step.Target.Name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(state.done)
+
+			var failed string
+			for _, dependency := range step.Dependencies {
+				if dep, ok := states[dependency]; ok {
+					<-dep.done
+					if dep.failed && failed == "" {
+						failed = dependency
+					}
+				}
+			}
+
+			var mu sync.Mutex
+			var inner sync.WaitGroup
+			for _, p := range state.pending {
+				if failed != "" {
+					p.result.Err = fmt.Errorf("%w: target %q", ErrDependencyFailed, failed)
+					state.failed = true
+					close(p.done)
+					continue
+				}
+				inner.Add(1)
+				go func() {
+					defer inner.Done()
+					defer close(p.done)
+					p.result.Cached, p.result.Err = run(p)
+					if p.result.Err == nil {
+						return
+					}
+					mu.Lock()
+					state.failed = true
+					mu.Unlock()
+					if !s.KeepGoing {
+						cancel()
+					}
+				}()
+			}
+			inner.Wait()
+		}()
+	}
+
+	var errs []error
+	for _, step := range plan.Steps {
+		for _, p := range states[step.Target.Name].pending {
+			observer.Waiting(step, p.result.Invocation)
+			<-p.done
+			observer.Finished(&p.result)
+
+			err := p.result.Err
+			if err == nil || errors.Is(err, ErrDependencyFailed) || (errors.Is(err, context.Canceled) && ctx.Err() != nil) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("generating %s of target %q: %w",
+				p.result.Invocation.Output.Label(), step.Target.Name, err,
+			))
+		}
+	}
+	wg.Wait()
+	if err := parent.Err(); err != nil && len(errs) == 0 {
+		return err
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+package build_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/build"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+// recorder is an observer that records the order of results.
+type recorder struct {
+	results []string
+	errs    map[string]error
+}
+
+func (r *recorder) Waiting(*build.Step, *build.Invocation) {}
+
+func (r *recorder) Finished(result *build.Result) {
+	key := result.Step.Target.Name + "/" + result.Invocation.Output.Label()
+	r.results = append(r.results, key)
+	if r.errs == nil {
+		r.errs = make(map[string]error)
+	}
+	r.errs[key] = result.Err
+}
+
+// newPlan creates a plan where "app" depends on "lib", and "other" is
+// independent of both.
+func newPlan() *build.Plan {
+	step := func(name string, dependencies []string, plugins ...string) *build.Step {
+		step := &build.Step{Target: &config.Target{Name: name}, Dependencies: dependencies}
+		for _, plugin := range plugins {
+			step.Invocations = append(step.Invocations, &build.Invocation{
+				Output: config.Output{Plugin: plugin},
+			})
+		}
+		return step
+	}
+	return &build.Plan{
+		Steps: []*build.Step{
+			step("lib", nil, "go", "python"),
+			step("other", nil, "go"),
+			step("app", []string{"lib"}, "go", "cpp"),
+		},
+	}
+}
+
+func TestSchedulerSchedule_AllSucceed_ReportsInPlanOrder(t *testing.T) {
+	var running, peak atomic.Int32
+	scheduler := &build.Scheduler{
+		Jobs: 2,
+		Run: func(context.Context, *build.Invocation) (bool, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for current := peak.Load(); n > current && !peak.CompareAndSwap(current, n); {
+				current = peak.Load()
+			}
+			return false, nil
+		},
+	}
+	observer := &recorder{}
+	want := []string{"lib/go", "lib/python", "other/go", "app/go", "app/cpp"}
+
+	err := scheduler.Schedule(context.Background(), newPlan(), observer)
+	if err != nil {
+		t.Fatalf("Scheduler.Schedule: unexpected error: %v", err)
+	}
+
+	if got := observer.results; !cmp.Equal(got, want) {
+		t.Errorf("Scheduler.Schedule: results = %v, want %v", got, want)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("Scheduler.Schedule: ran %d invocations at once, want at most 2", got)
+	}
+}
+
+func TestSchedulerSchedule_Failure_CancelsSiblings(t *testing.T) {
+	errFailed := errors.New("failed")
+	started := make(chan struct{})
+	scheduler := &build.Scheduler{
+		Jobs: 4,
+		Run: func(ctx context.Context, invocation *build.Invocation) (bool, error) {
+			if invocation.Output.Plugin == "python" {
+				<-started
+				return false, errFailed
+			}
+			close(started)
+			<-ctx.Done()
+			return false, ctx.Err()
+		},
+	}
+	plan := newPlan()
+	plan.Steps = plan.Steps[:1]
+	plan.Steps = append(plan.Steps, &build.Step{
+		Target:       &config.Target{Name: "app"},
+		Dependencies: []string{"lib"},
+		Invocations:  []*build.Invocation{{Output: config.Output{Plugin: "cpp"}}},
+	})
+	observer := &recorder{}
+
+	err := scheduler.Schedule(context.Background(), plan, observer)
+
+	if !errors.Is(err, errFailed) {
+		t.Errorf("Scheduler.Schedule: got error %v, want %v", err, errFailed)
+	}
+	if got := observer.errs["lib/go"]; !errors.Is(got, context.Canceled) {
+		t.Errorf("Scheduler.Schedule: lib/go error = %v, want %v", got, context.Canceled)
+	}
+	if got := observer.errs["app/cpp"]; !errors.Is(got, build.ErrDependencyFailed) {
+		t.Errorf("Scheduler.Schedule: app/cpp error = %v, want %v", got, build.ErrDependencyFailed)
+	}
+}
+
+func TestSchedulerSchedule_KeepGoing_RunsIndependentSteps(t *testing.T) {
+	errFailed := errors.New("failed")
+	scheduler := &build.Scheduler{
+		KeepGoing: true,
+		Run: func(_ context.Context, invocation *build.Invocation) (bool, error) {
+			if invocation.Output.Plugin == "python" {
+				return false, errFailed
+			}
+			return false, nil
+		},
+	}
+	observer := &recorder{}
+	want := map[string]error{
+		"lib/go":     nil,
+		"lib/python": errFailed,
+		"other/go":   nil,
+		"app/go":     build.ErrDependencyFailed,
+		"app/cpp":    build.ErrDependencyFailed,
+	}
+
+	err := scheduler.Schedule(context.Background(), newPlan(), observer)
+
+	if !errors.Is(err, errFailed) {
+		t.Errorf("Scheduler.Schedule: got error %v, want %v", err, errFailed)
+	}
+	for key, want := range want {
+		if got := observer.errs[key]; !errors.Is(got, want) || (want == nil && got != nil) {
+			t.Errorf("Scheduler.Schedule: %s error = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Kind is the kind of an entry stored in a backend.
+type Kind string
+
+const (
+	// KindManifest is a manifest, addressed by the key of the generation
+	// that produced it.
+	KindManifest Kind = "ac"
+
+	// KindObject is the contents of a generated file, addressed by the hex
+	// SHA-256 hash of its contents.
+	KindObject Kind = "cas"
+)
+
+// Backend is a store of cache entries that are addressed by hash.
+type Backend interface {
+	// Get returns the contents of the entry. If there is no such entry, the
+	// returned error wraps ErrNotFound.
+	Get(ctx context.Context, kind Kind, hash string) (io.ReadCloser, error)
+
+	// Put stores the contents of the entry, replacing any existing entry.
+	Put(ctx context.Context, kind Kind, hash string, data io.Reader, size int64) error
+}
+
+// NewBackend creates the backend for the URL. The "http" and "https" schemes
+// are served by an HTTP backend, and the "file" scheme by a disk backend.
+func NewBackend(rawURL string) (Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTP{URL: u}, nil
+	case "file":
+		return NewDisk(filepath.FromSlash(u.Path)), nil
+	}
+	return nil, fmt.Errorf("unsupported cache URL %q; must be an http, https, or file URL", rawURL)
+}
+
+// Disk is a backend that stores entries in a directory on the local
+// filesystem, such as a directory shared over a network filesystem.
+type Disk struct {
+	dir string
+}
+
+var _ Backend = (*Disk)(nil)
+
+// NewDisk creates a backend that stores entries in dir.
+func NewDisk(dir string) *Disk {
+	return &Disk{dir: dir}
+}
+
+// Get returns the contents of the entry.
+func (d *Disk) Get(_ context.Context, kind Kind, hash string) (io.ReadCloser, error) {
+	f, err := os.Open(d.path(kind, hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s %s %w", kind, hash, ErrNotFound)
+	}
+	return f, err
+}
+
+// Put stores the contents of the entry. Entries are written atomically, so
+// that concurrent readers never observe partially written entries.
+func (d *Disk) Put(_ context.Context, kind Kind, hash string, data io.Reader, _ int64) error {
+	path := d.path(kind, hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// path returns the path that the entry is stored at. Entries are sharded by
+// the first two characters of their hash, which keeps the number of entries
+// in a single directory manageable.
+func (d *Disk) path(kind Kind, hash string) string {
+	shard := "_"
+	if len(hash) >= 2 {
+		shard = hash[:2]
+	}
+	if kind == KindManifest {
+		return filepath.Join(d.dir, manifestsDir, shard, hash+".json")
+	}
+	return filepath.Join(d.dir, objectsDir, shard, hash)
+}
+
+// HTTP is a backend that stores entries on an HTTP server, at the path
+// "<kind>/<hash>" relative to its URL. Entries are read with GET and written
+// with PUT, which is compatible with simple static file servers that accept
+// uploads, and with bazel-remote style endpoints.
+type HTTP struct {
+	// URL is the base URL of the cache. Credentials in the URL are sent with
+	// basic authentication.
+	URL *url.URL
+
+	// Client is the client used to make requests. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+var _ Backend = (*HTTP)(nil)
+
+// Get returns the contents of the entry.
+func (h *HTTP) Get(ctx context.Context, kind Kind, hash string) (io.ReadCloser, error) {
+	req, err := h.request(ctx, http.MethodGet, kind, hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s %w", kind, hash, ErrNotFound)
+	case resp.StatusCode/100 != 2:
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", redacted(req.URL), resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Put stores the contents of the entry.
+func (h *HTTP) Put(ctx context.Context, kind Kind, hash string, data io.Reader, size int64) error {
+	req, err := h.request(ctx, http.MethodPut, kind, hash, data)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := h.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("PUT %s: %s", redacted(req.URL), resp.Status)
+	}
+	return nil
+}
+
+func (h *HTTP) request(ctx context.Context, method string, kind Kind, hash string, body io.Reader) (*http.Request, error) {
+	u := h.URL.JoinPath(string(kind), hash)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if user := h.URL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	return req, nil
+}
+
+func (h *HTTP) client() *http.Client {
+	if h.Client != nil {
+		return h.Client
+	}
+	return http.DefaultClient
+}
+
+// redacted returns the URL without any credentials, for use in errors.
+func redacted(u *url.URL) string {
+	clone := *u
+	clone.User = nil
+	return clone.String()
+}
@@ -0,0 +1,235 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/cache"
+)
+
+// server is a minimal in-memory HTTP cache, which stores the body of every
+// PUT and serves it with GET.
+type server struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	puts    int
+}
+
+func newServer(t *testing.T) (*server, *url.URL) {
+	t.Helper()
+	s := &server{entries: make(map[string][]byte)}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL + "/cache")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	return s, u
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, password, _ := r.BasicAuth(); user != "" && password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := s.entries[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.entries[r.URL.Path] = data
+		s.puts++
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) set(path string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[path] = data
+}
+
+func TestHTTP_PutThenGet_ReturnsContents(t *testing.T) {
+	s, u := newServer(t)
+	backend := &cache.HTTP{URL: u}
+	ctx := context.Background()
+
+	err := backend.Put(ctx, cache.KindObject, "abc", strings.NewReader("content"), 7)
+	if err != nil {
+		t.Fatalf("HTTP.Put: unexpected error: %v", err)
+	}
+	body, err := backend.Get(ctx, cache.KindObject, "abc")
+	if err != nil {
+		t.Fatalf("HTTP.Get: unexpected error: %v", err)
+	}
+	defer body.Close()
+	got, _ := io.ReadAll(body)
+
+	if want := "content"; string(got) != want {
+		t.Errorf("HTTP.Get: got %q, want %q", got, want)
+	}
+	if _, ok := s.entries["/cache/cas/abc"]; !ok {
+		t.Errorf("HTTP.Put: entry not stored at /cache/cas/abc")
+	}
+}
+
+func TestHTTPGet_MissingEntry_ReturnsErrNotFound(t *testing.T) {
+	_, u := newServer(t)
+	backend := &cache.HTTP{URL: u}
+
+	_, err := backend.Get(context.Background(), cache.KindManifest, "missing")
+
+	if !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("HTTP.Get: got error %v, want %v", err, cache.ErrNotFound)
+	}
+}
+
+func TestHTTPGet_WrongCredentials_ReturnsError(t *testing.T) {
+	_, u := newServer(t)
+	u.User = url.UserPassword("ci", "wrong")
+	backend := &cache.HTTP{URL: u}
+
+	_, err := backend.Get(context.Background(), cache.KindManifest, "key")
+
+	if err == nil || errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("HTTP.Get: got error %v, want unauthorized error", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "wrong") {
+		t.Errorf("HTTP.Get: error %q contains credentials", err)
+	}
+}
+
+func TestCacheLookup_RemoteEntry_RestoresFromRemote(t *testing.T) {
+	_, u := newServer(t)
+	u.User = url.UserPassword("ci", "secret")
+	ctx := context.Background()
+	generated := t.TempDir()
+	writeFile(t, filepath.Join(generated, "a.pb.go"), "package a")
+
+	ci := openCache(t)
+	ci.SetRemote(&cache.Remote{Backend: &cache.HTTP{URL: u}})
+	if _, err := ci.Store(ctx, "key", generated); err != nil {
+		t.Fatalf("Cache.Store: unexpected error: %v", err)
+	}
+	developer := openCache(t)
+	developer.SetRemote(&cache.Remote{Backend: &cache.HTTP{URL: u}, ReadOnly: true})
+
+	manifest, err := developer.Lookup(ctx, "key")
+	if err != nil {
+		t.Fatalf("Cache.Lookup: unexpected error: %v", err)
+	}
+	out := t.TempDir()
+	if err := developer.Restore(manifest, out); err != nil {
+		t.Fatalf("Cache.Restore: unexpected error: %v", err)
+	}
+
+	if got, want := readFile(t, filepath.Join(out, "a.pb.go")), "package a"; got != want {
+		t.Errorf("Cache.Restore: a.pb.go = %q, want %q", got, want)
+	}
+}
+
+func TestCacheStore_ReadOnlyRemote_DoesNotUpload(t *testing.T) {
+	s, u := newServer(t)
+	generated := t.TempDir()
+	writeFile(t, filepath.Join(generated, "a.pb.go"), "package a")
+	c := openCache(t)
+	c.SetRemote(&cache.Remote{Backend: &cache.HTTP{URL: u}, ReadOnly: true})
+
+	if _, err := c.Store(context.Background(), "key", generated); err != nil {
+		t.Fatalf("Cache.Store: unexpected error: %v", err)
+	}
+
+	if s.puts != 0 {
+		t.Errorf("Cache.Store: made %d uploads to a read-only remote, want 0", s.puts)
+	}
+}
+
+func TestCacheLookup_CorruptRemoteObject_ReportsError(t *testing.T) {
+	s, u := newServer(t)
+	ctx := context.Background()
+	generated := t.TempDir()
+	writeFile(t, filepath.Join(generated, "a.pb.go"), "package a")
+	ci := openCache(t)
+	ci.SetRemote(&cache.Remote{Backend: &cache.HTTP{URL: u}})
+	manifest, err := ci.Store(ctx, "key", generated)
+	if err != nil {
+		t.Fatalf("Cache.Store: unexpected error: %v", err)
+	}
+	hash := strings.TrimPrefix(manifest.Files[0].Digest, cache.DigestPrefix)
+	s.set("/cache/cas/"+hash, bytes.Repeat([]byte("x"), 9))
+
+	var remoteErr error
+	developer := openCache(t)
+	developer.SetRemote(&cache.Remote{
+		Backend: &cache.HTTP{URL: u},
+		OnError: func(err error) { remoteErr = err },
+	})
+	_, err = developer.Lookup(ctx, "key")
+
+	if !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Cache.Lookup: got error %v, want %v", err, cache.ErrNotFound)
+	}
+	if remoteErr == nil {
+		t.Errorf("Cache.Lookup: corrupt remote object was not reported")
+	}
+}
+
+func TestCacheLookup_RemoteManifestWithInvalidDigest_ReportsError(t *testing.T) {
+	s, u := newServer(t)
+	s.set("/cache/ac/key", []byte(`{"key": "key", "files": [{"path": "a.pb.go", "digest": "sha256-../../../../etc/passwd", "mode": 420}]}`))
+
+	var remoteErr error
+	developer := openCache(t)
+	developer.SetRemote(&cache.Remote{
+		Backend: &cache.HTTP{URL: u},
+		OnError: func(err error) { remoteErr = err },
+	})
+	_, err := developer.Lookup(context.Background(), "key")
+
+	if !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Cache.Lookup: got error %v, want %v", err, cache.ErrNotFound)
+	}
+	if remoteErr == nil || !strings.Contains(remoteErr.Error(), "invalid digest") {
+		t.Errorf("Cache.Lookup: got remote error %v, want an invalid digest", remoteErr)
+	}
+}
+
+func TestNewBackend_FileURL_ReturnsDisk(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := cache.NewBackend("file://" + filepath.ToSlash(dir))
+	if err != nil {
+		t.Fatalf("NewBackend: unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := backend.Put(ctx, cache.KindObject, "abc", strings.NewReader("content"), 7); err != nil {
+		t.Fatalf("Disk.Put: unexpected error: %v", err)
+	}
+	body, err := backend.Get(ctx, cache.KindObject, "abc")
+	if err != nil {
+		t.Fatalf("Disk.Get: unexpected error: %v", err)
+	}
+	defer body.Close()
+
+	if got, _ := io.ReadAll(body); string(got) != "content" {
+		t.Errorf("Disk.Get: got %q, want %q", got, "content")
+	}
+}
@@ -0,0 +1,366 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotFound is returned when an entry is not in the cache.
+var ErrNotFound = errors.New("not found in cache")
+
+// DigestPrefix is the prefix of every digest of a stored object.
+const DigestPrefix = "sha256-"
+
+// The names of the files and directories that make up the cache.
+const (
+	lockFileName  = "lock"
+	statsFileName = "stats.json"
+	manifestsDir  = "manifests"
+	objectsDir    = "objects"
+	tmpDir        = "tmp"
+)
+
+// Cache is a content-addressed store of generated outputs in a directory.
+//
+// An open cache holds a lock on its directory until it is closed. Caches
+// opened with Open hold shared locks, and may be used by many processes at
+// once; caches opened with OpenExclusive exclude every other process, which
+// is required to remove entries.
+type Cache struct {
+	dir       string
+	disk      *Disk
+	remote    *Remote
+	lock      *fileLock
+	exclusive bool
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Remote is a backend that entries are shared through, in addition to the
+// directory of the cache.
+type Remote struct {
+	// Backend is the backend that entries are shared through.
+	Backend Backend
+
+	// ReadOnly only reads entries from the backend, without storing the
+	// outputs of new generations in it.
+	ReadOnly bool
+
+	// OnError is called with every error from the backend. Errors from the
+	// backend never cause lookups or stores to fail, since the remote is only
+	// an optimization.
+	OnError func(error)
+}
+
+func (r *Remote) error(err error) {
+	if r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// Manifest lists the files generated by a single generation.
+type Manifest struct {
+	// Key is the key of the generation that produced the files.
+	Key string `json:"key"`
+
+	// Files are the generated files, sorted by path.
+	Files []File `json:"files"`
+
+	// Created is the time that the manifest was stored.
+	Created time.Time `json:"created"`
+}
+
+// File is a single generated file.
+type File struct {
+	// Path is the slash-separated path of the file, relative to the output
+	// directory.
+	Path string `json:"path"`
+
+	// Digest is the digest of the contents of the file.
+	Digest string `json:"digest"`
+
+	// Mode is the permission bits of the file.
+	Mode fs.FileMode `json:"mode"`
+}
+
+// Open opens the cache stored in dir for generation, creating the directory
+// if it does not exist.
+func Open(dir string) (*Cache, error) {
+	return open(dir, false)
+}
+
+// OpenExclusive opens the cache stored in dir for maintenance, waiting until
+// no other process has the cache open.
+func OpenExclusive(dir string) (*Cache, error) {
+	return open(dir, true)
+}
+
+func open(dir string, exclusive bool) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := lockFile(filepath.Join(dir, lockFileName), exclusive)
+	if err != nil {
+		return nil, fmt.Errorf("locking cache: %w", err)
+	}
+	return &Cache{dir: dir, disk: NewDisk(dir), lock: lock, exclusive: exclusive}, nil
+}
+
+// Close records the hits and misses of the cache in its statistics, and
+// releases the lock on the cache.
+func (c *Cache) Close() error {
+	err := c.recordStats(c.hits.Swap(0), c.misses.Swap(0))
+	if uerr := c.lock.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// SetRemote sets the remote that entries are shared through. Lookups that
+// miss in the directory of the cache are retrieved from the remote, and
+// unless it is read-only, stored entries are also stored in the remote.
+func (c *Cache) SetRemote(remote *Remote) {
+	c.remote = remote
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// TempDir creates a new temporary directory inside the cache, which is on
+// the same filesystem as the stored entries. The caller is responsible for
+// removing it.
+func (c *Cache) TempDir() (string, error) {
+	dir := filepath.Join(c.dir, tmpDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, "")
+}
+
+// Lookup returns the manifest stored for the key. If there is no manifest
+// for the key, or any of its files are missing from the cache, the entry is
+// retrieved from the remote, if any. If the entry is not found, the returned
+// error wraps ErrNotFound.
+func (c *Cache) Lookup(ctx context.Context, key string) (*Manifest, error) {
+	manifest, err := c.lookup(key)
+	if errors.Is(err, ErrNotFound) && c.remote != nil {
+		if fetched, ferr := c.fetch(ctx, key); ferr == nil {
+			manifest, err = fetched, nil
+		} else if !errors.Is(ferr, ErrNotFound) {
+			c.remote.error(ferr)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.misses.Add(1)
+		}
+		return nil, err
+	}
+	c.hits.Add(1)
+
+	// The modification time of a manifest is the time it was last used, which
+	// is what least-recently-used pruning is based on.
+	now := time.Now()
+	if err := os.Chtimes(c.manifestPath(key), now, now); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (c *Cache) lookup(key string) (*Manifest, error) {
+	manifest, err := c.readManifest(c.manifestPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("manifest %s %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range manifest.Files {
+		if _, err := os.Stat(c.objectPath(file.Digest)); err != nil {
+			return nil, fmt.Errorf("object %s of manifest %s %w", file.Digest, key, ErrNotFound)
+		}
+	}
+	return manifest, nil
+}
+
+// readManifest reads and decodes the manifest file at path.
+func (c *Cache) readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Store stores every file under dir as the outputs of the generation with
+// the specified key, and returns the stored manifest. Unless the remote is
+// read-only, the entry is also stored in the remote.
+func (c *Cache) Store(ctx context.Context, key, dir string) (*Manifest, error) {
+	manifest := &Manifest{Key: key, Created: time.Now().UTC()}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return fmt.Errorf("generated file %s is not a regular file", path)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		digest, err := c.storeObject(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, File{
+			Path:   filepath.ToSlash(rel),
+			Digest: digest,
+			Mode:   info.Mode().Perm(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(manifest.Files, func(lhs, rhs File) int {
+		return strings.Compare(lhs.Path, rhs.Path)
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writeFile(c.manifestPath(key), data); err != nil {
+		return nil, err
+	}
+	if c.remote != nil && !c.remote.ReadOnly {
+		if err := c.upload(ctx, manifest, data); err != nil {
+			c.remote.error(err)
+		}
+	}
+	return manifest, nil
+}
+
+// Restore writes the files of the manifest into dir. Files that already have
+// the stored contents are left untouched, so that their modification times
+// are preserved.
+func (c *Cache) Restore(manifest *Manifest, dir string) error {
+	for _, file := range manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return fmt.Errorf("manifest %s: file %q is outside of the output directory", manifest.Key, file.Path)
+		}
+		path := filepath.Join(dir, filepath.FromSlash(file.Path))
+		if digest, err := DigestFile(path); err == nil && digest == file.Digest {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := copyFile(c.objectPath(file.Digest), path, file.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeObject copies the file into the object store, and returns its
+// digest.
+func (c *Cache) storeObject(path string) (string, error) {
+	digest, err := DigestFile(path)
+	if err != nil {
+		return "", err
+	}
+	object := c.objectPath(digest)
+	if _, err := os.Stat(object); err == nil {
+		return digest, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return digest, c.writeFile(object, data)
+}
+
+// writeFile atomically writes the data to the path, so that concurrent
+// readers never observe partially written entries.
+func (c *Cache) writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) manifestPath(key string) string {
+	return c.disk.path(KindManifest, key)
+}
+
+func (c *Cache) objectPath(digest string) string {
+	return c.disk.path(KindObject, strings.TrimPrefix(digest, DigestPrefix))
+}
+
+// DigestFile returns the digest of the contents of the file at path, in
+// "sha256-<hex>" form.
+func DigestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return DigestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
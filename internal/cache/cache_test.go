@@ -0,0 +1,108 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/cache"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return string(data)
+}
+
+func openCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	c, err := cache.OpenExclusive(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatalf("cache.OpenExclusive: unexpected error: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCache_StoreThenRestore_RestoresFiles(t *testing.T) {
+	c := openCache(t)
+	generated := t.TempDir()
+	writeFile(t, filepath.Join(generated, "a", "a.pb.go"), "package a")
+	writeFile(t, filepath.Join(generated, "b.pb.go"), "package b")
+	want := []cache.File{
+		{Path: "a/a.pb.go", Mode: 0o644},
+		{Path: "b.pb.go", Mode: 0o644},
+	}
+
+	stored, err := c.Store(context.Background(), "key", generated)
+	if err != nil {
+		t.Fatalf("Cache.Store: unexpected error: %v", err)
+	}
+	got, err := c.Lookup(context.Background(), "key")
+	if err != nil {
+		t.Fatalf("Cache.Lookup: unexpected error: %v", err)
+	}
+	out := t.TempDir()
+	if err := c.Restore(got, out); err != nil {
+		t.Fatalf("Cache.Restore: unexpected error: %v", err)
+	}
+
+	opts := cmpopts.IgnoreFields(cache.File{}, "Digest")
+	if !cmp.Equal(got.Files, want, opts) {
+		t.Errorf("Cache.Lookup: (-got +want):\n%s", cmp.Diff(got.Files, want, opts))
+	}
+	if !cmp.Equal(got, stored, cmpopts.EquateApproxTime(0)) {
+		t.Errorf("Cache.Lookup: (-got +want):\n%s", cmp.Diff(got, stored))
+	}
+	if got, want := readFile(t, filepath.Join(out, "a", "a.pb.go")), "package a"; got != want {
+		t.Errorf("Cache.Restore: a/a.pb.go = %q, want %q", got, want)
+	}
+	if got, want := readFile(t, filepath.Join(out, "b.pb.go")), "package b"; got != want {
+		t.Errorf("Cache.Restore: b.pb.go = %q, want %q", got, want)
+	}
+}
+
+func TestCacheLookup_UnknownKey_ReturnsErrNotFound(t *testing.T) {
+	c := openCache(t)
+
+	_, err := c.Lookup(context.Background(), "missing")
+
+	if !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Cache.Lookup: got error %v, want %v", err, cache.ErrNotFound)
+	}
+}
+
+func TestCacheLookup_MissingObject_ReturnsErrNotFound(t *testing.T) {
+	c := openCache(t)
+	generated := t.TempDir()
+	writeFile(t, filepath.Join(generated, "a.pb.go"), "package a")
+	if _, err := c.Store(context.Background(), "key", generated); err != nil {
+		t.Fatalf("Cache.Store: unexpected error: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(c.Dir(), "objects")); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+
+	_, err := c.Lookup(context.Background(), "key")
+
+	if !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Cache.Lookup: got error %v, want %v", err, cache.ErrNotFound)
+	}
+}
@@ -0,0 +1,12 @@
+/*
+Package cache provides a content-addressed store for the outputs of
+generation.
+
+The cache holds two kinds of entries: objects, which are the contents of
+generated files addressed by their digest, and manifests, which are addressed
+by the key of the generation that produced them and list the files that it
+generated. Because objects are shared between manifests, restoring the
+outputs of a previous generation, such as after switching branches, only
+requires copying files that are already stored.
+*/
+package cache
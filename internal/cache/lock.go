@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"os"
+)
+
+// fileLock is an advisory lock on a file, which coordinates access to the
+// cache between protobuild processes.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile acquires a lock on the file at path, creating it if it does not
+// exist, and blocks until the lock is acquired. Exclusive locks exclude every
+// other lock; shared locks only exclude exclusive locks.
+func lockFile(path string, exclusive bool) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(f, exclusive); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &fileLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *fileLock) Unlock() error {
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
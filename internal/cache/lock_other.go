@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package cache
+
+import (
+	"os"
+)
+
+// Platforms without file locking rely on entries being written atomically,
+// and do not protect maintenance from running concurrently with generation.
+
+func lock(*os.File, bool) error {
+	return nil
+}
+
+func unlock(*os.File) error {
+	return nil
+}
@@ -0,0 +1,368 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotExclusive is returned when removing entries from a cache that was not
+// opened with OpenExclusive.
+var ErrNotExclusive = errors.New("cache is not open for exclusive access")
+
+// Stats are the statistics of a cache.
+type Stats struct {
+	// Manifests is the number of stored manifests.
+	Manifests int
+
+	// Objects is the number of stored objects.
+	Objects int
+
+	// Size is the total size of every stored entry, in bytes.
+	Size int64
+
+	// Hits is the number of lookups that found their manifest.
+	Hits int64
+
+	// Misses is the number of lookups that did not find their manifest.
+	Misses int64
+}
+
+// HitRate returns the fraction of lookups that were hits, or 0 if there have
+// been no lookups.
+func (s *Stats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// PruneOptions are the limits that entries are pruned to.
+type PruneOptions struct {
+	// MaxSize is the maximum total size of the cache, in bytes. The least
+	// recently used manifests are evicted until the cache fits. Zero means no
+	// limit.
+	MaxSize int64
+
+	// OlderThan evicts every manifest that has not been used for the
+	// duration. Zero means no limit.
+	OlderThan time.Duration
+}
+
+// Removed counts the entries removed from a cache.
+type Removed struct {
+	// Manifests is the number of manifests removed.
+	Manifests int
+
+	// Objects is the number of objects removed.
+	Objects int
+
+	// Size is the total size of the removed entries, in bytes.
+	Size int64
+}
+
+// savedStats is the format of the statistics that are persisted between
+// processes.
+type savedStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// Stats returns the statistics of the cache.
+func (c *Cache) Stats() (*Stats, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := c.readStats()
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{
+		Manifests: len(entries.manifests),
+		Objects:   len(entries.objects),
+		Hits:      saved.Hits + c.hits.Load(),
+		Misses:    saved.Misses + c.misses.Load(),
+	}
+	for _, manifest := range entries.manifests {
+		stats.Size += manifest.size
+	}
+	for _, object := range entries.objects {
+		stats.Size += object.size
+	}
+	return stats, nil
+}
+
+// Prune evicts manifests that exceed the limits of the options, along with
+// every object that is no longer referenced by a manifest.
+func (c *Cache) Prune(opts PruneOptions) (*Removed, error) {
+	if !c.exclusive {
+		return nil, ErrNotExclusive
+	}
+	if err := os.RemoveAll(filepath.Join(c.dir, tmpDir)); err != nil {
+		return nil, err
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	removed := &Removed{}
+
+	// Manifests are evicted from least to most recently used.
+	slices.SortFunc(entries.manifests, func(lhs, rhs *manifestEntry) int {
+		return lhs.used.Compare(rhs.used)
+	})
+	cutoff := time.Now().Add(-opts.OlderThan)
+	for _, manifest := range entries.manifests {
+		if manifest.manifest != nil && (opts.OlderThan <= 0 || !manifest.used.Before(cutoff)) {
+			continue
+		}
+		if err := entries.evict(manifest, removed); err != nil {
+			return removed, err
+		}
+	}
+	for digest, object := range entries.objects {
+		if object.refs > 0 {
+			continue
+		}
+		if err := entries.remove(digest, removed); err != nil {
+			return removed, err
+		}
+	}
+	for _, manifest := range entries.manifests {
+		if opts.MaxSize <= 0 || entries.size <= opts.MaxSize {
+			break
+		}
+		if manifest.evicted {
+			continue
+		}
+		if err := entries.evict(manifest, removed); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Verify rehashes every object in the cache, and evicts the objects whose
+// contents do not match their digest, along with every manifest that is
+// unreadable or references a missing object.
+func (c *Cache) Verify() (*Removed, error) {
+	if !c.exclusive {
+		return nil, ErrNotExclusive
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	removed := &Removed{}
+	for digest, object := range entries.objects {
+		if got, err := DigestFile(object.path); err == nil && got == digest {
+			continue
+		}
+		if err := entries.remove(digest, removed); err != nil {
+			return removed, err
+		}
+	}
+	for _, manifest := range entries.manifests {
+		if manifest.manifest != nil && !slices.ContainsFunc(manifest.manifest.Files, func(file File) bool {
+			return entries.objects[file.Digest] == nil
+		}) {
+			continue
+		}
+		if err := entries.evict(manifest, removed); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// Clean removes every entry and statistic from the cache.
+func (c *Cache) Clean() (*Removed, error) {
+	if !c.exclusive {
+		return nil, ErrNotExclusive
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{manifestsDir, objectsDir, tmpDir, statsFileName} {
+		if err := os.RemoveAll(filepath.Join(c.dir, name)); err != nil {
+			return nil, err
+		}
+	}
+	c.hits.Store(0)
+	c.misses.Store(0)
+	return &Removed{Manifests: stats.Manifests, Objects: stats.Objects, Size: stats.Size}, nil
+}
+
+// recordStats adds the hits and misses to the persisted statistics.
+func (c *Cache) recordStats(hits, misses int64) error {
+	if hits == 0 && misses == 0 {
+		return nil
+	}
+	lock, err := lockFile(filepath.Join(c.dir, statsFileName+".lock"), true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	saved, err := c.readStats()
+	if err != nil {
+		return err
+	}
+	saved.Hits += hits
+	saved.Misses += misses
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(filepath.Join(c.dir, statsFileName), data)
+}
+
+// readStats reads the persisted statistics. Missing or unreadable statistics
+// are treated as empty, since they are only informational.
+func (c *Cache) readStats() (*savedStats, error) {
+	saved := &savedStats{}
+	data, err := os.ReadFile(filepath.Join(c.dir, statsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return saved, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return &savedStats{}, nil
+	}
+	return saved, nil
+}
+
+// entries is a snapshot of every entry in the cache, which tracks the
+// references between manifests and objects as entries are removed.
+type entries struct {
+	manifests []*manifestEntry
+	objects   map[string]*objectEntry
+	size      int64
+}
+
+type manifestEntry struct {
+	path string
+	size int64
+	used time.Time
+
+	// manifest is the decoded manifest, or nil if it could not be read.
+	manifest *Manifest
+	evicted  bool
+}
+
+type objectEntry struct {
+	path string
+	size int64
+	refs int
+}
+
+// entries lists every manifest and object in the cache.
+func (c *Cache) entries() (*entries, error) {
+	result := &entries{objects: make(map[string]*objectEntry)}
+	err := walkFiles(filepath.Join(c.dir, objectsDir), func(path string, info fs.FileInfo) {
+		if strings.HasPrefix(info.Name(), ".tmp-") {
+			return
+		}
+		result.objects[DigestPrefix+info.Name()] = &objectEntry{path: path, size: info.Size()}
+		result.size += info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = walkFiles(filepath.Join(c.dir, manifestsDir), func(path string, info fs.FileInfo) {
+		if filepath.Ext(path) != ".json" || strings.HasPrefix(info.Name(), ".tmp-") {
+			return
+		}
+		entry := &manifestEntry{path: path, size: info.Size(), used: info.ModTime()}
+		if manifest, err := c.readManifest(path); err == nil {
+			entry.manifest = manifest
+			for _, file := range manifest.Files {
+				if object := result.objects[file.Digest]; object != nil {
+					object.refs++
+				}
+			}
+		}
+		result.manifests = append(result.manifests, entry)
+		result.size += info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// evict removes the manifest, and every object that it was the last
+// reference to.
+func (e *entries) evict(manifest *manifestEntry, removed *Removed) error {
+	if manifest.evicted {
+		return nil
+	}
+	if err := os.Remove(manifest.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	manifest.evicted = true
+	e.size -= manifest.size
+	removed.Manifests++
+	removed.Size += manifest.size
+	if manifest.manifest == nil {
+		return nil
+	}
+	for _, file := range manifest.manifest.Files {
+		object := e.objects[file.Digest]
+		if object == nil {
+			continue
+		}
+		if object.refs--; object.refs == 0 {
+			if err := e.remove(file.Digest, removed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remove removes the object.
+func (e *entries) remove(digest string, removed *Removed) error {
+	object := e.objects[digest]
+	if object == nil {
+		return nil
+	}
+	if err := os.Remove(object.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing object %s: %w", digest, err)
+	}
+	delete(e.objects, digest)
+	e.size -= object.size
+	removed.Objects++
+	removed.Size += object.size
+	return nil
+}
+
+// walkFiles calls fn for every regular file under dir. A missing directory
+// contains no files.
+func walkFiles(dir string, fn func(path string, info fs.FileInfo)) error {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fn(path, info)
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitwizeshift/protobuild/internal/cache"
+)
+
+// storeEntry stores a manifest with a single file with the given content,
+// last used at the specified time.
+func storeEntry(t *testing.T, c *cache.Cache, key, content string, used time.Time) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, key+".pb.go"), content)
+	if _, err := c.Store(context.Background(), key, dir); err != nil {
+		t.Fatalf("Cache.Store: unexpected error: %v", err)
+	}
+	path := filepath.Join(c.Dir(), "manifests", key[:2], key+".json")
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+}
+
+func exists(c *cache.Cache, key string) bool {
+	_, err := c.Lookup(context.Background(), key)
+	return err == nil
+}
+
+func TestCachePrune_MaxSize_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := openCache(t)
+	now := time.Now()
+	storeEntry(t, c, "aaa", "oldest", now.Add(-3*time.Hour))
+	storeEntry(t, c, "bbb", "middle", now.Add(-2*time.Hour))
+	storeEntry(t, c, "ccc", "newest", now.Add(-1*time.Hour))
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Cache.Stats: unexpected error: %v", err)
+	}
+
+	removed, err := c.Prune(cache.PruneOptions{MaxSize: stats.Size - 1})
+	if err != nil {
+		t.Fatalf("Cache.Prune: unexpected error: %v", err)
+	}
+
+	if got, want := removed.Manifests, 1; got != want {
+		t.Errorf("Cache.Prune: removed %d manifests, want %d", got, want)
+	}
+	if exists(c, "aaa") {
+		t.Errorf("Cache.Prune: least recently used entry was not evicted")
+	}
+	if !exists(c, "bbb") || !exists(c, "ccc") {
+		t.Errorf("Cache.Prune: recently used entries were evicted")
+	}
+}
+
+func TestCachePrune_OlderThan_EvictsUnusedEntries(t *testing.T) {
+	c := openCache(t)
+	now := time.Now()
+	storeEntry(t, c, "aaa", "shared", now.Add(-48*time.Hour))
+	storeEntry(t, c, "bbb", "shared", now)
+
+	removed, err := c.Prune(cache.PruneOptions{OlderThan: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Cache.Prune: unexpected error: %v", err)
+	}
+
+	if got, want := removed.Objects, 0; got != want {
+		t.Errorf("Cache.Prune: removed %d objects still referenced, want %d", got, want)
+	}
+	if exists(c, "aaa") {
+		t.Errorf("Cache.Prune: unused entry was not evicted")
+	}
+	if !exists(c, "bbb") {
+		t.Errorf("Cache.Prune: recently used entry was evicted")
+	}
+}
+
+func TestCacheVerify_CorruptObject_EvictsEntry(t *testing.T) {
+	c := openCache(t)
+	storeEntry(t, c, "aaa", "package a", time.Now())
+	storeEntry(t, c, "bbb", "package b", time.Now())
+	manifest, err := c.Lookup(context.Background(), "aaa")
+	if err != nil {
+		t.Fatalf("Cache.Lookup: unexpected error: %v", err)
+	}
+	hash := manifest.Files[0].Digest[len(cache.DigestPrefix):]
+	writeFile(t, filepath.Join(c.Dir(), "objects", hash[:2], hash), "corrupt")
+
+	removed, err := c.Verify()
+	if err != nil {
+		t.Fatalf("Cache.Verify: unexpected error: %v", err)
+	}
+
+	if got, want := removed.Manifests, 1; got != want {
+		t.Errorf("Cache.Verify: removed %d manifests, want %d", got, want)
+	}
+	if exists(c, "aaa") {
+		t.Errorf("Cache.Verify: corrupt entry was not evicted")
+	}
+	if !exists(c, "bbb") {
+		t.Errorf("Cache.Verify: intact entry was evicted")
+	}
+}
+
+func TestCacheStats_AfterLookups_ReportsHitRate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := cache.Open(dir)
+	if err != nil {
+		t.Fatalf("cache.Open: unexpected error: %v", err)
+	}
+	storeEntry(t, c, "aaa", "package a", time.Now())
+	c.Lookup(context.Background(), "aaa")
+	c.Lookup(context.Background(), "aaa")
+	c.Lookup(context.Background(), "aaa")
+	c.Lookup(context.Background(), "missing")
+	if err := c.Close(); err != nil {
+		t.Fatalf("Cache.Close: unexpected error: %v", err)
+	}
+	c, err = cache.Open(dir)
+	if err != nil {
+		t.Fatalf("cache.Open: unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Cache.Stats: unexpected error: %v", err)
+	}
+
+	if got, want := stats.HitRate(), 0.75; got != want {
+		t.Errorf("Stats.HitRate: got %v, want %v", got, want)
+	}
+	if got, want := stats.Manifests, 1; got != want {
+		t.Errorf("Cache.Stats: Manifests = %d, want %d", got, want)
+	}
+}
+
+func TestCachePrune_SharedCache_ReturnsErrNotExclusive(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("cache.Open: unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	_, err = c.Prune(cache.PruneOptions{MaxSize: 1})
+
+	if !errors.Is(err, cache.ErrNotExclusive) {
+		t.Errorf("Cache.Prune: got error %v, want %v", err, cache.ErrNotExclusive)
+	}
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// maxManifestSize is the largest manifest that is accepted from a remote.
+const maxManifestSize = 16 << 20
+
+// maxObjectSize is the largest object that is accepted from a remote.
+const maxObjectSize = 1 << 30
+
+// validDigest matches the digests that objects are stored by, so that the
+// digests of a remote manifest can only refer to objects of the store.
+var validDigest = regexp.MustCompile(`^` + DigestPrefix + `[0-9a-f]{64}$`)
+
+// fetch retrieves the manifest for the key, and every object that it
+// references, from the remote into the directory of the cache. A manifest
+// with any invalid digest is rejected, and every object is limited in size
+// and verified against its digest before it is stored.
+func (c *Cache) fetch(ctx context.Context, key string) (*Manifest, error) {
+	data, err := c.get(ctx, KindManifest, key, maxManifestSize)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("remote manifest %s: %w", key, err)
+	}
+	if manifest.Key != key {
+		return nil, fmt.Errorf("remote manifest %s has mismatched key %s", key, manifest.Key)
+	}
+
+	for _, file := range manifest.Files {
+		if !validDigest.MatchString(file.Digest) {
+			return nil, fmt.Errorf("remote manifest %s has invalid digest %q", key, file.Digest)
+		}
+	}
+
+	for _, file := range manifest.Files {
+		if _, err := os.Stat(c.objectPath(file.Digest)); err == nil {
+			continue
+		}
+		hash := strings.TrimPrefix(file.Digest, DigestPrefix)
+		object, err := c.get(ctx, KindObject, hash, maxObjectSize+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(object) > maxObjectSize {
+			return nil, fmt.Errorf("remote object %s is larger than %d bytes", hash, maxObjectSize)
+		}
+		if sum := sha256.Sum256(object); hex.EncodeToString(sum[:]) != hash {
+			return nil, fmt.Errorf("remote object %s does not match its digest", hash)
+		}
+		if err := c.disk.Put(ctx, KindObject, hash, bytes.NewReader(object), int64(len(object))); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.writeFile(c.manifestPath(key), data); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// upload stores the objects of the manifest, followed by the manifest itself,
+// in the remote. Objects are stored first, so that other clients never find
+// a manifest whose objects are missing.
+func (c *Cache) upload(ctx context.Context, manifest *Manifest, data []byte) error {
+	for _, file := range manifest.Files {
+		f, err := os.Open(c.objectPath(file.Digest))
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err == nil {
+			hash := strings.TrimPrefix(file.Digest, DigestPrefix)
+			err = c.remote.Backend.Put(ctx, KindObject, hash, f, info.Size())
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return c.remote.Backend.Put(ctx, KindManifest, manifest.Key, bytes.NewReader(data), int64(len(data)))
+}
+
+// get reads an entry from the remote, up to limit bytes.
+func (c *Cache) get(ctx context.Context, kind Kind, hash string, limit int64) ([]byte, error) {
+	body, err := c.remote.Backend.Get(ctx, kind, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(io.LimitReader(body, limit))
+}
@@ -64,6 +64,13 @@ func NoticePrefix() string {
 	)
 }
 
+func DebugPrefix() string {
+	return ansi.Sprintf("%sdebug:%s",
+		ansi.FGGray,
+		ansi.Reset,
+	)
+}
+
 var (
 	//go:embed usage.template
 	usageTemplate string
@@ -85,6 +92,16 @@ func SetDefaults(cmd *cobra.Command) {
 	cmd.SetVersionTemplate(versionTemplate)
 }
 
+// RenderVersion writes the version information of the specified command to
+// the writer, using the same `version.template` as the --version flag.
+func RenderVersion(wr io.Writer, cmd *cobra.Command) error {
+	template, err := template.New("version").Funcs(funcs).Parse(versionTemplate)
+	if err != nil {
+		return err
+	}
+	return template.Execute(wr, cmd)
+}
+
 type payload struct {
 	Error      string
 	StackTrace []string
@@ -1,16 +1,50 @@
 package cli
 
 import (
+	"io"
 	"os"
+	"sync/atomic"
 
 	"github.com/bitwizeshift/protobuild/internal/ansi"
 )
 
+var (
+	output    atomic.Pointer[io.Writer]
+	verbosity atomic.Int32
+)
+
+func init() {
+	var w io.Writer = os.Stderr
+	output.Store(&w)
+}
+
+// Output returns the writer that diagnostic messages are written to. This is
+// os.Stderr unless it has been replaced with SetOutput.
+func Output() io.Writer {
+	return *output.Load()
+}
+
+// SetOutput sets the writer that diagnostic messages are written to.
+func SetOutput(w io.Writer) {
+	output.Store(&w)
+}
+
+// Verbosity returns the current verbosity level. A level of 0 is the default,
+// negative levels are quieter, and positive levels are more verbose.
+func Verbosity() int {
+	return int(verbosity.Load())
+}
+
+// SetVerbosity sets the current verbosity level.
+func SetVerbosity(level int) {
+	verbosity.Store(int32(level))
+}
+
 func Error(args ...any) {
 	prefix := ErrorPrefix() + " "
 	args = append([]any{prefix}, args...)
 	args = append(args, "\n")
-	ansi.Fprint(os.Stderr, args...)
+	ansi.Fprint(Output(), args...)
 }
 
 func Errorf(format string, args ...any) {
@@ -21,24 +55,45 @@ func Warning(args ...any) {
 	prefix := WarningPrefix() + " "
 	args = append([]any{prefix}, args...)
 	args = append(args, "\n")
-	ansi.Fprint(os.Stderr, args...)
+	ansi.Fprint(Output(), args...)
 }
 
 func Warningf(format string, args ...any) {
 	Warning(ansi.Sprintf(format, args...))
 }
 
+// Notice writes an informational message. Notices are suppressed when the
+// verbosity is below the default level.
 func Notice(args ...any) {
+	if Verbosity() < 0 {
+		return
+	}
 	prefix := NoticePrefix() + " "
 	args = append([]any{prefix}, args...)
 	args = append(args, "\n")
-	ansi.Fprint(os.Stderr, args...)
+	ansi.Fprint(Output(), args...)
 }
 
 func Noticef(format string, args ...any) {
 	Notice(ansi.Sprintf(format, args...))
 }
 
+// Debug writes a diagnostic message that is only shown when the verbosity is
+// above the default level.
+func Debug(args ...any) {
+	if Verbosity() <= 0 {
+		return
+	}
+	prefix := DebugPrefix() + " "
+	args = append([]any{prefix}, args...)
+	args = append(args, "\n")
+	ansi.Fprint(Output(), args...)
+}
+
+func Debugf(format string, args ...any) {
+	Debug(ansi.Sprintf(format, args...))
+}
+
 func Fatal(args ...any) {
 	Error(args...)
 	os.Exit(1)
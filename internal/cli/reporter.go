@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/bitwizeshift/protobuild/internal/ansi"
+	"golang.org/x/term"
+)
+
+// Reporter renders the progress of tasks, grouped into sections, as status
+// lines with dot leaders:
+//
+//	my-project
+//	  go .............................. ✔
+//	  python .......................... ✔ (skipped)
+//
+// Each line is written once its task finishes.
+//
+// A Reporter is safe for concurrent use.
+type Reporter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	column   int
+	sections int
+}
+
+// Task is a single task whose status is shown on one line of a Reporter.
+type Task struct {
+	r     *Reporter
+	label string
+	done  bool
+}
+
+// NewReporter creates a reporter that writes to w. Statuses are aligned to a
+// column that is a third of the width of the terminal that w writes to, or of
+// the widest terminal that is supported if w is not a terminal.
+func NewReporter(w io.Writer) *Reporter {
+	r := &Reporter{w: w}
+	width := maxTermWidth
+	if f, ok := w.(*os.File); ok {
+		if cols, _, err := term.GetSize(int(f.Fd())); err == nil {
+			width = max(minTermWidth, min(maxTermWidth, cols))
+		}
+	}
+	r.SetWidth(width)
+	return r
+}
+
+// SetWidth aligns the statuses of the reporter for a terminal of the given
+// width.
+func (r *Reporter) SetWidth(width int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.column = width / 3
+}
+
+// Printf writes a message, followed by a newline.
+func (r *Reporter) Printf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeLine(ansi.Sprintf(format, args...))
+}
+
+// Section starts a new section with the given title. Sections are separated
+// from each other by a blank line.
+func (r *Reporter) Section(title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sections > 0 {
+		r.writeLine("")
+	}
+	r.sections++
+	r.writeLine(FormatStrong.Format("%s", title))
+}
+
+// Start starts a task with the given label in the current section.
+func (r *Reporter) Start(label string) *Task {
+	return &Task{r: r, label: label}
+}
+
+// Succeed marks the task as successful. The note, if not empty, is shown
+// alongside the status, such as "skipped".
+func (t *Task) Succeed(note string) {
+	t.finish(FormatCommand.Format("✔"), note)
+}
+
+// Fail marks the task as failed. The note, if not empty, is shown alongside
+// the status.
+func (t *Task) Fail(note string) {
+	t.finish(FormatError.Format("✘"), note)
+}
+
+func (t *Task) finish(mark, note string) {
+	r := t.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+
+	if note != "" {
+		mark += " " + FormatQuote.Format("(%s)", note)
+	}
+	r.writeLine(r.status(t.label, mark))
+}
+
+// status formats a status line for a task, aligning the status to the
+// column of the reporter with a dot leader.
+func (r *Reporter) status(label, status string) string {
+	dots := max(r.column-utf8.RuneCountInString(label)-1, 3)
+	return fmt.Sprintf("  %s %s %s", label, FormatQuote.Format("%s", strings.Repeat(".", dots)), status)
+}
+
+// writeLine writes the content followed by a newline.
+func (r *Reporter) writeLine(content string) {
+	ansi.Fprintln(r.w, content)
+}
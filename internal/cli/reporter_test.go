@@ -0,0 +1,68 @@
+package cli_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReporter_PlainWriter_AppendsFinishedLines(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := cli.NewReporter(&buf)
+	reporter.SetWidth(100)
+	want := strings.Join([]string{
+		"my-project",
+		"  go .............................. ✔",
+		"  python .......................... ✔ (skipped)",
+		"",
+		"other",
+		"  c++ ............................. ✘",
+		"",
+	}, "\n")
+
+	reporter.Section("my-project")
+	goTask := reporter.Start("go")
+	pythonTask := reporter.Start("python")
+	goTask.Succeed("")
+	pythonTask.Succeed("skipped")
+	reporter.Section("other")
+	reporter.Start("c++").Fail("")
+
+	if got := buf.String(); !cmp.Equal(got, want) {
+		t.Errorf("Reporter: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestReporter_Width_AlignsStatusToThirdOfWidth(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := cli.NewReporter(&buf)
+	reporter.SetWidth(60)
+
+	reporter.Start("go").Succeed("")
+
+	if got, want := buf.String(), "  go ................. ✔\n"; !cmp.Equal(got, want) {
+		t.Errorf("Reporter: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestReporter_TasksFinishedOutOfOrder_AppendsLinesAsFinished(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := cli.NewReporter(&buf)
+	reporter.SetWidth(100)
+
+	first := reporter.Start("go")
+	reporter.Start("python").Succeed("")
+	first.Fail("")
+
+	want := strings.Join([]string{
+		"  python .......................... ✔",
+		"  go .............................. ✘",
+		"",
+	}, "\n")
+	if got := buf.String(); !cmp.Equal(got, want) {
+		t.Errorf("Reporter: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
@@ -77,20 +77,33 @@ func errorMessage(format string, args ...any) string {
 	return fmt.Sprintf("%s %s", ErrorPrefix(), fmt.Sprintf(format, args...))
 }
 
+// The bounds that the width of the terminal is clamped to when fitting
+// content to it.
+const (
+	minTermWidth = 60
+	maxTermWidth = 100
+)
+
 func fitTerm(content string) string {
-	width, _, err := term.GetSize(0)
-	if err != nil {
+	width, ok := termWidth()
+	if !ok {
 		return content
 	}
-	const (
-		minWidth = 60
-		maxWidth = 100
-	)
-	width = max(minWidth, width)
-	width = min(maxWidth, width)
 	return fitColumns(width, content)
 }
 
+// termWidth returns the width of the terminal, clamped between minTermWidth
+// and maxTermWidth. If the width cannot be determined, this returns false.
+func termWidth() (int, bool) {
+	width, _, err := term.GetSize(0)
+	if err != nil {
+		return 0, false
+	}
+	width = max(minTermWidth, width)
+	width = min(maxTermWidth, width)
+	return width, true
+}
+
 func fitColumns(columns int, content string) string {
 	contentLines := strings.Split(content, "\n")
 	var lines []string
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitwizeshift/protobuild/internal/cache"
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/spf13/cobra"
+)
+
+// generateCacheDir returns the directory of the cache of generated outputs.
+func generateCacheDir() (string, error) {
+	dir, err := env.CachePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "generate"), nil
+}
+
+func newCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and maintain the cache of generated outputs",
+		Long: dedent.String(`
+			Inspects and maintains the cache of generated outputs, which is
+			stored under the protobuild cache path. Commands that remove
+			entries wait until no other protobuild process is using the
+			cache.
+		`),
+		GroupID: groupUtility,
+	}
+	cmd.AddCommand(
+		newCacheStatsCommand(),
+		newCachePruneCommand(),
+		newCacheCleanCommand(),
+		newCacheVerifyCommand(),
+	)
+	return cmd
+}
+
+func newCacheStatsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show the size, entries, and hit rate of the cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			dir, err := generateCacheDir()
+			if err != nil {
+				return err
+			}
+			c, err := cache.Open(dir)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			stats, err := c.Stats()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "path:     %s\n", c.Dir())
+			fmt.Fprintf(out, "entries:  %d (%d objects)\n", stats.Manifests, stats.Objects)
+			fmt.Fprintf(out, "size:     %s\n", formatSize(stats.Size))
+			fmt.Fprintf(out, "hit rate: %.1f%% (%d hits, %d misses)\n", stats.HitRate()*100, stats.Hits, stats.Misses)
+			return nil
+		},
+	}
+}
+
+func newCachePruneCommand() *cobra.Command {
+	var maxSize, olderThan string
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Evict the least recently used entries from the cache",
+		Long: dedent.String(`
+			Evicts entries from the cache, starting with those that were used
+			least recently. Entries that have not been used within the
+			--older-than age are always evicted, and further entries are
+			evicted until the cache fits within --max-size.
+		`),
+		Example: "protobuild cache prune --max-size 500MB --older-than 30d",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var opts cache.PruneOptions
+			var err error
+			if maxSize != "" {
+				if opts.MaxSize, err = parseSize(maxSize); err != nil {
+					return fmt.Errorf("invalid --max-size: %w", err)
+				}
+			}
+			if olderThan != "" {
+				if opts.OlderThan, err = parseAge(olderThan); err != nil {
+					return fmt.Errorf("invalid --older-than: %w", err)
+				}
+			}
+			if opts.MaxSize == 0 && opts.OlderThan == 0 {
+				return fmt.Errorf("at least one of --max-size or --older-than is required")
+			}
+			return maintainCache(cmd.OutOrStdout(), func(c *cache.Cache) (*cache.Removed, error) {
+				return c.Prune(opts)
+			})
+		},
+	}
+	fs := flagset.New("prune")
+	fs.StringVar(&maxSize, "max-size", "", "evict entries until the cache is at most `size`, such as 500MB")
+	fs.StringVar(&olderThan, "older-than", "", "evict entries unused for `age`, such as 30d or 12h")
+	fs.RegisterFlags(cmd)
+	return cmd
+}
+
+func newCacheCleanCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clean",
+		Short: "Remove every entry from the cache",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return maintainCache(cmd.OutOrStdout(), (*cache.Cache).Clean)
+		},
+	}
+}
+
+func newCacheVerifyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify",
+		Short: "Rehash every entry in the cache, and evict corrupt entries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return maintainCache(cmd.OutOrStdout(), (*cache.Cache).Verify)
+		},
+	}
+}
+
+// maintainCache opens the cache exclusively, runs the maintenance operation,
+// and reports what was removed.
+func maintainCache(out io.Writer, maintain func(*cache.Cache) (*cache.Removed, error)) error {
+	dir, err := generateCacheDir()
+	if err != nil {
+		return err
+	}
+	cli.Debugf("waiting for exclusive access to %s", dir)
+	c, err := cache.OpenExclusive(dir)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	removed, err := maintain(c)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "removed %d entries (%d objects), freeing %s\n",
+		removed.Manifests, removed.Objects, formatSize(removed.Size),
+	)
+	return nil
+}
+
+// sizeUnits are the suffixes accepted by parseSize, from largest to
+// smallest so that the longest suffix matches first.
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize parses a size in bytes with an optional unit suffix, such as
+// "500MB" or "2GiB".
+func parseSize(value string) (int64, error) {
+	number, scale := value, int64(1)
+	for _, unit := range sizeUnits {
+		if trimmed, ok := strings.CutSuffix(value, unit.suffix); ok {
+			number, scale = trimmed, unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size, such as 500MB", value)
+	}
+	return int64(n * float64(scale)), nil
+}
+
+// parseAge parses a duration, additionally accepting a number of days with
+// a "d" suffix, such as "30d".
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not an age, such as 30d or 12h", value)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not an age, such as 30d or 12h", value)
+	}
+	return d, nil
+}
+
+// formatSize formats a size in bytes with a binary unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/bitwizeshift/protobuild/internal/build"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/compiler"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+type describeOptions struct {
+	format         string
+	includeImports bool
+	locked         bool
+}
+
+// Formats accepted by the --format flag of the describe command.
+const (
+	describeJSON   = "json"
+	describeText   = "text"
+	describeBinary = "binary"
+)
+
+func newDescribeCommand(g *globals) *cobra.Command {
+	opts := &describeOptions{}
+	cmd := &cobra.Command{
+		Use:   "describe <target>",
+		Short: "Print the descriptors of the proto files of a target",
+		Long: dedent.String(`
+			Compiles the proto files of a target, and prints the
+			FileDescriptorSet that describes them, which is the same set that
+			protoc writes with --descriptor_set_out. With --include-imports,
+			the set also describes every file that they import.
+
+			The files are compiled in-process, so protoc does not need to be
+			installed. Imports are resolved in the same way as by the
+			generate command, and the well-known types are built in. Errors
+			are reported in the same format, and with the same messages, as
+			protoc.
+
+			The set is printed as JSON by default; use --format to print it
+			as text format, or as the binary encoding that protoc writes.
+		`),
+		Example: "protobuild describe my-project --include-imports --format binary > my-project.binpb",
+		GroupID: groupBuild,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDescribe(cmd, g, opts, args[0])
+		},
+	}
+	opts.flagSet().RegisterFlags(cmd)
+	return cmd
+}
+
+// flagSet creates the flagset that holds the flags of the describe command.
+func (opts *describeOptions) flagSet() *flagset.FlagSet {
+	fs := flagset.New("describe")
+	fs.StringVar(&opts.format, "format", describeJSON, "the `format` to print the descriptors in: json, text, or binary")
+	fs.BoolVar(&opts.includeImports, "include-imports", false, "also describe every file that the files of the target import")
+	fs.BoolVar(&opts.locked, "locked", false, "fail if protobuild.lock is out of date, instead of updating it")
+	return fs
+}
+
+func runDescribe(cmd *cobra.Command, g *globals, opts *describeOptions, name string) error {
+	var marshal func(proto.Message) ([]byte, error)
+	switch opts.format {
+	case describeJSON:
+		marshal = protojson.MarshalOptions{Multiline: true}.Marshal
+	case describeText:
+		marshal = prototext.MarshalOptions{Multiline: true}.Marshal
+	case describeBinary:
+		marshal = proto.MarshalOptions{Deterministic: true}.Marshal
+	default:
+		return fmt.Errorf("invalid --format %q; must be one of json, text, or binary", opts.format)
+	}
+
+	workspace, err := g.loadWorkspace()
+	if err != nil {
+		return reportErrors(err)
+	}
+	index, err := g.loadIndex(workspace)
+	if err != nil {
+		return reportErrors(err)
+	}
+	targets, err := loadTargets(workspace, index)
+	if err != nil {
+		return reportErrors(err)
+	}
+	dependencies, err := graph.New(targets, index)
+	if err != nil {
+		return err
+	}
+	warnShadowed(dependencies, index)
+	nodes, err := dependencies.Dependencies(name)
+	if err != nil {
+		return err
+	}
+	sources, err := newProjectSources(workspace, dependencies, opts.locked)
+	if err != nil {
+		return reportErrors(err)
+	}
+	if opts.locked {
+		all, err := dependencies.Order()
+		if err != nil {
+			return err
+		}
+		if err := sources.check(all); err != nil {
+			return err
+		}
+	}
+	if err := sources.fetch(cmd.Context(), nodes); err != nil {
+		return err
+	}
+	if _, err := sources.save(false); err != nil {
+		return err
+	}
+
+	planner := &build.Planner{
+		Workspace:      workspace,
+		Graph:          dependencies,
+		ResolveProject: sources.resolve,
+	}
+	plan, err := planner.Plan(name)
+	if err != nil {
+		return err
+	}
+	step := plan.Steps[len(plan.Steps)-1]
+	c := &compiler.Compiler{Includes: step.Includes, IncludeImports: opts.includeImports}
+	set, err := c.Compile(step.Files...)
+	if err != nil {
+		return reportErrors(err)
+	}
+
+	data, err := marshal(set)
+	if err != nil {
+		return err
+	}
+	if opts.format != describeBinary {
+		data = append(data, '\n')
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
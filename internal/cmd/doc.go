@@ -0,0 +1,9 @@
+/*
+Package cmd provides the cobra commands that make up the protobuild
+command-line interface.
+
+Every command is constructed from a shared set of global options, which are
+registered as persistent flags on the root command so that they are available
+to, and documented on, every sub-command.
+*/
+package cmd
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bitwizeshift/protobuild/internal/build"
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/bitwizeshift/protobuild/internal/git"
+	"github.com/bitwizeshift/protobuild/internal/plugin"
+	"github.com/spf13/cobra"
+)
+
+// reachableTimeout is how long each registry is given to respond when
+// checking that it can be fetched.
+const reachableTimeout = 30 * time.Second
+
+func newDoctorCommand(g *globals) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose problems with the protobuild environment",
+		Long: dedent.String(`
+			Checks the environment that protobuild runs in, and reports each
+			check as a notice when it passes, a warning when it may cause
+			problems, or an error when it fails:
+
+			  * the protobuild paths, what they were set by, and whether
+			    they can be written to;
+			  * the version of protoc, and whether the version that the
+			    workspace pins has been installed;
+			  * the plugins of every output of the workspace, and whether
+			    they are installed at the newest version of their recipe;
+			    and
+			  * whether every added registry can be fetched from.
+
+			Fails if any check fails.
+		`),
+		GroupID: groupUtility,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			d := &doctor{}
+			d.checkPaths()
+
+			workspace, err := g.loadWorkspace()
+			switch {
+			case errors.Is(err, config.ErrNotFound):
+				cli.Noticef("not in a workspace; skipping the checks of its protoc and plugins")
+				workspace = nil
+			case err != nil:
+				reportErrors(err)
+				d.fail("workspace could not be loaded")
+				workspace = nil
+			}
+			index, err := g.loadIndex(workspace)
+			if err != nil {
+				reportErrors(err)
+				d.fail("registries could not be loaded")
+				index = config.NewIndex()
+			}
+
+			d.checkProtoc(cmd.Context(), workspace)
+			if workspace != nil {
+				d.checkPlugins(workspace, index)
+			}
+			d.checkRegistries(cmd.Context())
+
+			switch d.failures {
+			case 0:
+				return nil
+			case 1:
+				return fmt.Errorf("1 check failed")
+			}
+			return fmt.Errorf("%d checks failed", d.failures)
+		},
+	}
+}
+
+// doctor reports the results of the checks of the environment.
+type doctor struct {
+	failures int
+}
+
+func (d *doctor) pass(format string, args ...any) {
+	cli.Noticef(format, args...)
+}
+
+func (d *doctor) warn(format string, args ...any) {
+	cli.Warningf(format, args...)
+}
+
+func (d *doctor) fail(format string, args ...any) {
+	d.failures++
+	cli.Errorf(format, args...)
+}
+
+// checkPaths checks that every protobuild path can be determined, and that
+// it can be written to.
+func (d *doctor) checkPaths() {
+	paths := []struct {
+		name     string
+		variable string
+		path     func() (string, error)
+	}{
+		{name: "protobuild path", variable: "PROTOBUILD_PATH", path: env.ConfigPath},
+		{name: "bin path", variable: "PROTOBUILD_BIN", path: env.BinPath},
+		{name: "registry path", variable: "PROTOBUILD_REGISTRY", path: env.RegistryPath},
+		{name: "cache path", variable: "PROTOBUILD_CACHE", path: env.CachePath},
+	}
+	for _, p := range paths {
+		dir, err := p.path()
+		if err != nil {
+			d.fail("%s: %v", p.name, err)
+			continue
+		}
+		origin := env.Origin(p.variable)
+		switch exists, err := writable(dir); {
+		case err != nil:
+			d.fail("%s %s (from %s) is not writable: %v", p.name, dir, origin, err)
+		case !exists:
+			d.warn("%s %s (from %s) does not exist yet, and will be created", p.name, dir, origin)
+		default:
+			d.pass("%s %s (from %s) is writable", p.name, dir, origin)
+		}
+	}
+}
+
+// writable reports whether dir exists, and checks that it, or the nearest
+// directory above it that exists, can be written to.
+func writable(dir string) (bool, error) {
+	exists := true
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return exists, fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return exists, err
+		}
+		exists = false
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return exists, err
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".protobuild-doctor-*")
+	if err != nil {
+		return exists, err
+	}
+	f.Close()
+	return exists, os.Remove(f.Name())
+}
+
+// checkProtoc checks that the version of protoc that the workspace pins has
+// been installed, or that protoc is in the PATH if it pins no version.
+func (d *doctor) checkProtoc(ctx context.Context, workspace *config.Workspace) {
+	if workspace == nil || workspace.Protoc == nil {
+		path, err := exec.LookPath(build.DefaultProtoc)
+		if err != nil {
+			d.fail("protoc was not found in the PATH, and the workspace does not pin a version")
+			return
+		}
+		version, err := protocVersion(ctx, path)
+		if err != nil {
+			d.fail("protoc %s: %v", path, err)
+			return
+		}
+		d.pass("%s %s found in the PATH", version, path)
+		return
+	}
+
+	pin := workspace.Protoc
+	installer, err := newProtocInstaller()
+	if err != nil {
+		d.fail("protoc %s: %v", pin.Version, err)
+		return
+	}
+	if _, err := installer.URL(pin); err != nil {
+		d.fail("protoc %s: %v", pin.Version, err)
+		return
+	}
+	toolchain := installer.Installed(pin)
+	if toolchain == nil {
+		platform := runtime.GOOS + "/" + runtime.GOARCH
+		if _, ok := pin.Checksums[platform]; !ok {
+			d.fail("protoc %s is pinned by the workspace, but has no checksum for %s", pin.Version, platform)
+			return
+		}
+		d.warn("protoc %s is pinned by the workspace, but is not installed; it will be downloaded when generating", pin.Version)
+		return
+	}
+	version, err := protocVersion(ctx, toolchain.Executable())
+	if err != nil {
+		d.fail("protoc %s at %s: %v", pin.Version, toolchain.Executable(), err)
+		return
+	}
+	if fields := strings.Fields(version); len(fields) == 0 || fields[len(fields)-1] != pin.Version {
+		d.fail("protoc at %s reports %q, but the workspace pins %s", toolchain.Executable(), version, pin.Version)
+		return
+	}
+	d.pass("protoc %s is installed at %s", pin.Version, toolchain.Executable())
+}
+
+// protocVersion returns the version that the protoc executable reports, such
+// as "libprotoc 27.1".
+func protocVersion(ctx context.Context, path string) (string, error) {
+	output, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// checkPlugins checks that the plugin of every output of the workspace is
+// either installed at the newest version of its recipe, or in the PATH.
+func (d *doctor) checkPlugins(workspace *config.Workspace, index *config.Index) {
+	targets, err := loadTargets(workspace, index)
+	if err != nil {
+		reportErrors(err)
+		d.fail("targets of the workspace could not be loaded")
+		return
+	}
+	var names []string
+	for _, target := range targets {
+		for _, output := range target.Outputs {
+			if !build.IsBuiltin(output.Plugin) && !slices.Contains(names, output.Plugin) {
+				names = append(names, output.Plugin)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	manager, err := newPluginManager()
+	if err != nil {
+		d.fail("installed plugins could not be loaded: %v", err)
+		return
+	}
+	for _, name := range names {
+		d.checkPlugin(name, manager, index)
+	}
+}
+
+func (d *doctor) checkPlugin(name string, manager *plugin.Manager, index *config.Index) {
+	recipe, registry := index.Plugin(name)
+	installed := manager.Lookup(name)
+	if installed == nil {
+		path, err := exec.LookPath("protoc-gen-" + name)
+		switch {
+		case err != nil && recipe != nil:
+			d.fail("plugin %s is not installed; install it with %s", name,
+				cli.FormatCommand.Format("protobuild plugin install %s", name),
+			)
+		case err != nil:
+			d.fail("plugin %s was not found in the PATH, and no registry defines a recipe for it", name)
+		case recipe != nil:
+			d.warn("plugin %s is not installed, so %s is used; install it with %s", name, path,
+				cli.FormatCommand.Format("protobuild plugin install %s", name),
+			)
+		default:
+			d.pass("plugin %s found in the PATH at %s", name, path)
+		}
+		return
+	}
+
+	path := manager.Executable(installed)
+	if _, err := os.Stat(path); err != nil {
+		d.fail("plugin %s %s is installed, but its executable is missing: %v", name, installed.Version, err)
+		return
+	}
+	switch {
+	case recipe == nil:
+		d.warn("plugin %s %s is installed, but no registry defines a recipe for it anymore", name, installed.Version)
+	case recipe.Version(installed.Version) == nil:
+		d.warn("plugin %s %s is installed, but registry %s no longer provides that version", name, installed.Version, registry.Name)
+	case recipe.Versions[0].Version != installed.Version:
+		d.warn("plugin %s %s is installed, but %s is the newest version of registry %s", name, installed.Version, recipe.Versions[0].Version, registry.Name)
+	default:
+		d.pass("plugin %s %s is installed at %s", name, installed.Version, path)
+	}
+}
+
+// checkRegistries checks that every added registry can be fetched from the
+// location that it was added from.
+func (d *doctor) checkRegistries(ctx context.Context) {
+	manager, err := newRegistryManager()
+	if err != nil {
+		d.fail("registries could not be loaded: %v", err)
+		return
+	}
+	if len(manager.User.Registries) == 0 {
+		d.warn("no registries have been added; add one with %s",
+			cli.FormatCommand.Format("%s", "protobuild registry add <name> <url>"),
+		)
+		return
+	}
+	for _, ref := range manager.User.Registries {
+		ctx, cancel := context.WithTimeout(ctx, reachableTimeout)
+		err := git.Reachable(ctx, ref.URL)
+		cancel()
+		if err != nil {
+			d.fail("registry %s cannot be fetched from %s: %v", ref.Name, ref.URL, err)
+			continue
+		}
+		d.pass("registry %s can be fetched from %s", ref.Name, ref.URL)
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/config"
+)
+
+// reportErrors reports each error in a collection of configuration errors, or
+// of errors joined with errors.Join, individually, and returns a single
+// summarizing error in their place. Any other error is returned unchanged.
+func reportErrors(err error) error {
+	var errs config.Errors
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &errs) {
+		var cerr *config.Error
+		switch {
+		case errors.As(err, &joined) && len(joined.Unwrap()) > 1:
+			errs = joined.Unwrap()
+		case errors.As(err, &cerr):
+			errs = config.Errors{cerr}
+		default:
+			return err
+		}
+	}
+	for _, err := range errs {
+		reportError(err)
+	}
+	if len(errs) == 1 {
+		return fmt.Errorf("found 1 error")
+	}
+	return fmt.Errorf("found %d errors", len(errs))
+}
+
+// reportError reports a single configuration error. Schema violations are
+// rendered with the JSON pointer and value that caused them highlighted.
+func reportError(err error) {
+	var cerr *config.Error
+	var serr *config.SchemaError
+	if !errors.As(err, &cerr) || !errors.As(cerr.Err, &serr) {
+		cli.Error(err)
+		return
+	}
+	location := cerr.File
+	if cerr.Position.IsValid() {
+		location += ":" + cerr.Position.String()
+	}
+	pointer := serr.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	if serr.Value == "" {
+		cli.Errorf("%s: %s: %s", location, cli.FormatKeyword.Format(pointer), serr.Message)
+		return
+	}
+	cli.Errorf("%s: %s: %s (value: %s)",
+		location,
+		cli.FormatKeyword.Format(pointer),
+		serr.Message,
+		cli.FormatQuote.Format(serr.Value),
+	)
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/bitwizeshift/protobuild/internal/build"
+	"github.com/bitwizeshift/protobuild/internal/cache"
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/bitwizeshift/protobuild/internal/plugin"
+	"github.com/bitwizeshift/protobuild/internal/protoc"
+	"github.com/spf13/cobra"
+)
+
+type generateOptions struct {
+	protoc     string
+	jobs       int
+	keepGoing  bool
+	noCache    bool
+	remoteMode string
+	locked     bool
+}
+
+// Modes accepted by the --remote-cache flag.
+const (
+	remoteAuto      = "auto"
+	remoteReadOnly  = "read-only"
+	remoteReadWrite = "read-write"
+	remoteOff       = "off"
+)
+
+// remoteCache returns the remote cache of the workspace, in the mode selected
+// by the --remote-cache flag, or nil if there is no remote cache to use.
+func (o *generateOptions) remoteCache(workspace *config.Workspace) (*cache.Remote, error) {
+	switch o.remoteMode {
+	case remoteAuto, remoteReadOnly, remoteReadWrite:
+	case remoteOff:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid --remote-cache mode %q; must be one of auto, read-only, read-write, or off", o.remoteMode)
+	}
+	if workspace.Cache == nil {
+		return nil, nil
+	}
+	backend, err := cache.NewBackend(workspace.Cache.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remote errors are reported once rather than for every output, since a
+	// remote that is unavailable fails every request in the same way.
+	var once sync.Once
+	return &cache.Remote{
+		Backend:  backend,
+		ReadOnly: o.remoteMode == remoteReadOnly || (o.remoteMode == remoteAuto && workspace.Cache.ReadOnly),
+		OnError: func(err error) {
+			once.Do(func() { cli.Warningf("remote cache: %v", err) })
+		},
+	}, nil
+}
+
+func newGenerateCommand(g *globals) *cobra.Command {
+	opts := &generateOptions{}
+	cmd := &cobra.Command{
+		Use:   "generate <target>",
+		Short: "Generate the outputs of a target and its dependencies",
+		Long: dedent.String(`
+			Generates every output of a target by invoking protoc once per
+			output. The targets that it depends on within the workspace are
+			generated first, and the import roots of every dependency are
+			added to the include paths.
+
+			The registry projects that are depended on are resolved to the
+			sources pinned by the protobuild.lock file of the workspace, and
+			projects that are not yet pinned are resolved and added to it;
+			with --locked, a lockfile that is out of date is an error
+			instead. Projects are taken from the vendor directory of the
+			workspace if they are vendored, and are otherwise fetched into
+			the cache and verified against their integrity digests.
+
+			Independent targets and outputs are generated concurrently. By
+			default, the first failure cancels every other invocation; with
+			--keep-going, only the targets that depend on a failure are
+			skipped.
+
+			Before protoc is run, the sources of every target are parsed, and
+			every import that cannot be found in the include paths is
+			reported.
+
+			Generated outputs are stored in a content-addressed cache, keyed
+			by the proto files being generated and every file that they
+			import, the plugin executable and options, and the version of
+			protoc. Outputs whose inputs are unchanged are restored from the
+			cache instead of generated, which is reported as skipped. If the
+			workspace configures a remote cache, outputs are also shared
+			through it.
+
+			Plugins that have been installed with "protobuild plugin install"
+			are used instead of any executable of the same name in the PATH.
+			If the workspace pins a version of protoc, that version is
+			downloaded into the bin path and always used, and the well-known
+			types that it includes are added to the include paths; otherwise,
+			the well-known types installed alongside protoc are.
+		`),
+		Example: "protobuild generate my-project",
+		GroupID: groupBuild,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGenerate(cmd, g, opts, args[0])
+		},
+	}
+	opts.flagSet().RegisterFlags(cmd)
+	return cmd
+}
+
+// flagSet creates the flagset that holds the flags of the generate command.
+func (opts *generateOptions) flagSet() *flagset.FlagSet {
+	fs := flagset.New("generate")
+	fs.StringVar(&opts.protoc, "protoc", build.DefaultProtoc, "the protoc executable to generate with, if the workspace does not pin a version")
+	fs.IntVarP(&opts.jobs, "jobs", "j", runtime.NumCPU(), "run at most `N` protoc invocations at once")
+	fs.BoolVar(&opts.noCache, "no-cache", false, "generate every output, without reading or writing the cache")
+	fs.StringVar(&opts.remoteMode, "remote-cache", remoteAuto, "how to use the remote cache of the workspace: `mode` is one of auto, read-only, read-write, or off")
+	fs.BoolVar(&opts.locked, "locked", false, "fail if protobuild.lock is out of date, instead of updating it")
+	fs.BoolVarP(&opts.keepGoing, "keep-going", "k", false, "keep generating outputs that do not depend on a failure")
+	return fs
+}
+
+// warnUninstalledPlugins warns about every plugin of the plan that has not
+// been installed, but that a registry defines a recipe for. Such plugins are
+// searched for in the PATH instead, which may not have the intended version.
+func warnUninstalledPlugins(plan *build.Plan, plugins *plugin.Manager, index *config.Index) {
+	warned := make(map[string]bool)
+	for _, step := range plan.Steps {
+		for _, invocation := range step.Invocations {
+			name := invocation.Output.Plugin
+			if warned[name] || plugins.Lookup(name) != nil {
+				continue
+			}
+			if recipe, _ := index.Plugin(name); recipe == nil {
+				continue
+			}
+			warned[name] = true
+			cli.Warningf("plugin %s is not installed, and is searched for in the PATH; install it with %s",
+				cli.FormatStrong.Format("%s", name),
+				cli.FormatCommand.Format("protobuild plugin install %s", name),
+			)
+		}
+	}
+}
+
+func runGenerate(cmd *cobra.Command, g *globals, opts *generateOptions, name string) error {
+	workspace, err := g.loadWorkspace()
+	if err != nil {
+		return reportErrors(err)
+	}
+	if workspace.Protoc != nil && cmd.Flags().Changed("protoc") {
+		return fmt.Errorf("the workspace pins protoc %s, which cannot be replaced with --protoc", workspace.Protoc.Version)
+	}
+	index, err := g.loadIndex(workspace)
+	if err != nil {
+		return reportErrors(err)
+	}
+	targets, err := loadTargets(workspace, index)
+	if err != nil {
+		return reportErrors(err)
+	}
+
+	dependencies, err := graph.New(targets, index)
+	if err != nil {
+		return err
+	}
+	warnShadowed(dependencies, index)
+	nodes, err := dependencies.Dependencies(name)
+	if err != nil {
+		return err
+	}
+	sources, err := newProjectSources(workspace, dependencies, opts.locked)
+	if err != nil {
+		return reportErrors(err)
+	}
+	if opts.locked {
+		all, err := dependencies.Order()
+		if err != nil {
+			return err
+		}
+		if err := sources.check(all); err != nil {
+			return err
+		}
+	}
+	if err := sources.fetch(cmd.Context(), nodes); err != nil {
+		return err
+	}
+	if _, err := sources.save(false); err != nil {
+		return err
+	}
+	toolchain, err := installProtoc(cmd.Context(), cmd.OutOrStdout(), workspace)
+	if err != nil {
+		return err
+	}
+	planner := &build.Planner{
+		Workspace:      workspace,
+		Graph:          dependencies,
+		ResolveProject: sources.resolve,
+	}
+	if toolchain != nil {
+		opts.protoc = toolchain.Executable()
+		planner.Includes = []string{toolchain.Include()}
+	} else {
+		planner.Includes = protoc.DefaultIncludes(opts.protoc)
+	}
+	plan, err := planner.Plan(name)
+	if err != nil {
+		return err
+	}
+	if err := plan.Check(); err != nil {
+		return reportErrors(err)
+	}
+
+	out := cmd.OutOrStdout()
+	if cli.Verbosity() < 0 {
+		out = io.Discard
+	}
+	reporter := cli.NewReporter(out)
+	if len(plan.Dependencies) > 0 {
+		reporter.Printf("target %s depends on %s\n",
+			cli.FormatStrong.Format("%s", name),
+			strings.Join(plan.Dependencies, ", "),
+		)
+	}
+
+	plugins, err := newPluginManager()
+	if err != nil {
+		return reportErrors(err)
+	}
+	warnUninstalledPlugins(plan, plugins, index)
+	runner := &build.Runner{Protoc: opts.protoc, Plugins: plugins.Executables()}
+	if !opts.noCache {
+		dir, err := generateCacheDir()
+		if err != nil {
+			return err
+		}
+		if runner.Cache, err = cache.Open(dir); err != nil {
+			return err
+		}
+		defer runner.Cache.Close()
+
+		remote, err := opts.remoteCache(workspace)
+		if err != nil {
+			return err
+		}
+		if remote != nil {
+			runner.Cache.SetRemote(remote)
+		}
+	}
+	scheduler := &build.Scheduler{
+		Jobs:      opts.jobs,
+		KeepGoing: opts.keepGoing,
+		Run: func(ctx context.Context, invocation *build.Invocation) (bool, error) {
+			cli.Debugf("%s %s", opts.protoc, strings.Join(runner.Args(invocation, invocation.Dir), " "))
+			return runner.Run(ctx, invocation)
+		},
+	}
+	observer := &generateObserver{reporter: reporter}
+	if err := scheduler.Schedule(cmd.Context(), plan, observer); err != nil {
+		var joined interface{ Unwrap() []error }
+		if !errors.As(err, &joined) || len(joined.Unwrap()) == 1 {
+			return err
+		}
+		for _, err := range joined.Unwrap() {
+			cli.Error(err)
+		}
+		return fmt.Errorf("generation failed with %d errors", len(joined.Unwrap()))
+	}
+	reporter.Printf("\nGeneration successful")
+	return nil
+}
+
+// generateObserver reports the progress of a generation, grouping the outputs
+// of each target into a section.
+type generateObserver struct {
+	reporter *cli.Reporter
+	section  *build.Step
+	task     *cli.Task
+}
+
+func (o *generateObserver) Waiting(step *build.Step, invocation *build.Invocation) {
+	if o.section != step {
+		o.section = step
+		o.reporter.Section(step.Target.Name)
+	}
+	o.task = o.reporter.Start(invocation.Output.Label())
+}
+
+func (o *generateObserver) Finished(result *build.Result) {
+	switch {
+	case result.Err == nil && result.Cached:
+		o.task.Succeed("skipped")
+	case result.Err == nil:
+		o.task.Succeed("")
+	case errors.Is(result.Err, build.ErrDependencyFailed):
+		o.task.Fail("dependency failed")
+	case errors.Is(result.Err, context.Canceled):
+		o.task.Fail("cancelled")
+	default:
+		o.task.Fail("")
+	}
+}
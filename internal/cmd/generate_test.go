@@ -0,0 +1,36 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/cmd"
+)
+
+func TestGenerate_ProtocWithPinnedProtoc_FailsWithoutWritingLockfile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, filepath.Join(dir, "protobuild.json"), `{
+		"targets": ["**/protobuild-target.json"],
+		"protoc": {
+			"version": "27.1",
+			"checksums": {"linux/amd64": "`+strings.Repeat("0", 64)+`"}
+		}
+	}`)
+	root := cmd.New("")
+	root.SetArgs([]string{"generate", "--config", path, "--protoc", "/usr/bin/protoc", "my-project"})
+
+	err := root.Execute()
+
+	if err == nil || !strings.Contains(err.Error(), "--protoc") {
+		t.Errorf("Execute: got err %v, want an error about --protoc", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Execute: got %d files in the workspace, want only the workspace file", len(entries))
+	}
+}
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/bitwizeshift/protobuild/internal/ansi"
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/spf13/cobra"
+)
+
+// Color modes accepted by the --color flag.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// globals contains the options that are shared across every command.
+type globals struct {
+	verbose int
+	quiet   bool
+	color   string
+	config  string
+}
+
+// flagSet creates the flagset that holds all global flags.
+func (g *globals) flagSet() *flagset.FlagSet {
+	fs := flagset.New("global")
+	fs.CountVarP(&g.verbose, "verbose", "v", "increase output verbosity; may be repeated")
+	fs.BoolVarP(&g.quiet, "quiet", "q", false, "suppress all non-error output")
+	fs.StringVar(&g.color, "color", colorAuto, "when to use colors: `mode` is one of auto, always, or never")
+	fs.StringVarP(&g.config, "config", "C", "", "use the workspace `file` instead of searching for one")
+	fs.BoolP("help", "h", false, "show help for this command")
+	return fs
+}
+
+// apply validates the global options and configures the process to honor
+// them. This is intended to be run before any command executes.
+func (g *globals) apply(*cobra.Command, []string) error {
+	if err := g.applyColor(); err != nil {
+		return err
+	}
+
+	switch {
+	case g.quiet && g.verbose > 0:
+		return fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	case g.quiet:
+		cli.SetVerbosity(-1)
+	default:
+		cli.SetVerbosity(g.verbose)
+	}
+	return nil
+}
+
+// applyColor validates the --color mode and configures the process to honor
+// it.
+func (g *globals) applyColor() error {
+	switch g.color {
+	case colorAuto:
+	case colorAlways:
+		ansi.SetEnabled(true)
+		cli.SetOutput(ansi.ColorWriter(os.Stderr))
+	case colorNever:
+		ansi.SetEnabled(false)
+		cli.SetOutput(ansi.NoColorWriter(os.Stderr))
+	default:
+		return fmt.Errorf("invalid --color mode %q; must be one of auto, always, or never", g.color)
+	}
+	return nil
+}
+
+// help renders help with the --color mode applied, since cobra does not run
+// the pre-run hooks that apply it for --help. Help is written directly, so in
+// auto mode it is only colored when written to a terminal.
+func (g *globals) help(render func(*cobra.Command, []string)) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if err := g.applyColor(); err == nil && g.color == colorAuto && !ansi.IsColorable(cmd.OutOrStdout()) {
+			ansi.SetEnabled(false)
+		}
+		render(cmd, args)
+	}
+}
+
+// workspacePath returns the path of the workspace file, either from the
+// --config flag or by searching from the current directory.
+func (g *globals) workspacePath() (string, error) {
+	if g.config != "" {
+		return g.config, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return config.FindWorkspace(cwd)
+}
+
+// loadWorkspace loads the workspace that commands operate on.
+func (g *globals) loadWorkspace() (*config.Workspace, error) {
+	path, err := g.workspacePath()
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadWorkspace(path)
+}
+
+// loadIndex loads every registry under the registry path into an index. The
+// registries of the workspace, if any, take the highest priority in the order
+// they are listed, followed by the registries of the user configuration, and
+// then any other registries by name.
+func (g *globals) loadIndex(workspace *config.Workspace) (*config.Index, error) {
+	dir, err := env.RegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	// A registry that cannot be loaded is only warned about, so that a broken
+	// registry that the workspace does not use cannot stop it from being
+	// built.
+	registries, err := config.LoadRegistries(dir)
+	var loadErrs config.Errors
+	switch {
+	case errors.As(err, &loadErrs):
+		for _, err := range loadErrs {
+			cli.Warningf("skipping a registry that cannot be loaded: %v", err)
+		}
+	case err != nil:
+		return nil, err
+	}
+	path, err := env.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	user, err := config.LoadUser(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if workspace != nil {
+		for _, ref := range workspace.Registries {
+			if !slices.ContainsFunc(registries, func(registry *config.Registry) bool {
+				return registry.Name == ref.Name
+			}) {
+				cli.Warningf("registry %s of the workspace has not been added; add it with %s",
+					cli.FormatStrong.Format("%s", ref.Name),
+					cli.FormatCommand.Format("protobuild registry add %s %s", ref.Name, ref.URL),
+				)
+			}
+			names = append(names, ref.Name)
+		}
+	}
+	for _, ref := range user.Registries {
+		names = append(names, ref.Name)
+	}
+
+	// A dependency that refers to a project of another registry can only be
+	// checked once every registry is loaded. Such problems are also only
+	// warned about; the dependencies that the workspace does use are
+	// reported when its graph is resolved.
+	index := config.NewIndex(config.PrioritizeRegistries(registries, names...)...)
+	var errs config.Errors
+	if errors.As(index.Validate(), &errs) {
+		for _, err := range errs {
+			cli.Warningf("%v", err)
+		}
+	}
+	return index, nil
+}
+
+// loadTargets loads and validates every target of the workspace, and checks
+// that the sources of every target match files on disk, which the
+// configuration alone cannot tell.
+func loadTargets(workspace *config.Workspace, index *config.Index) ([]*config.Target, error) {
+	targets, err := workspace.LoadTargets(index)
+	if err != nil {
+		return nil, err
+	}
+	var errs config.Errors
+	for _, target := range targets {
+		if len(target.SourceFiles()) == 0 {
+			errs = append(errs, target.Errorf("sources", "source patterns do not match any files in %s", target.Dir()))
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
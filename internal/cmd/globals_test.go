@@ -0,0 +1,112 @@
+package cmd_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/cmd"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll: unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestLoadIndex_UnusedBrokenRegistry_DoesNotFailCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PROTOBUILD_PATH", filepath.Join(dir, "home"))
+	writeFile(t, filepath.Join(dir, "home", "registry", "broken", "protobuild-registry.json"), `{
+		"projects": [{
+			"name": "google/fhir",
+			"source": {"git": "x"},
+			"versions": [{"version": "v1"}],
+			"dependencies": ["google/missing"]
+		}]
+	}`)
+	workspace := writeFile(t, filepath.Join(dir, "protobuild.json"), `{"targets": ["**/protobuild-target.json"]}`)
+	writeFile(t, filepath.Join(dir, "proto", "foo.proto"), `syntax = "proto3";`)
+	writeFile(t, filepath.Join(dir, "proto", "protobuild-target.json"), `{
+		"name": "my-project",
+		"sources": ["*.proto"],
+		"outputs": [{"name": "go", "plugin": "go", "out": "gen"}]
+	}`)
+	protoc := writeFile(t, filepath.Join(dir, "protoc"), "#!/bin/sh\necho libprotoc 27.1\n")
+	if err := os.Chmod(protoc, 0o755); err != nil {
+		t.Fatalf("Chmod: unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		{name: "validate", args: []string{"validate"}},
+		{name: "generate", args: []string{"generate", "--protoc", protoc, "--no-cache", "my-project"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := cmd.New("")
+			root.SetOut(io.Discard)
+			root.SetArgs(append([]string{"--config", workspace}, tc.args...))
+
+			if err := root.Execute(); err != nil {
+				t.Errorf("Execute: unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadIndex_UnparsableRegistry_LoadsOtherRegistries(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PROTOBUILD_PATH", filepath.Join(dir, "home"))
+	writeFile(t, filepath.Join(dir, "home", "registry", "broken", "protobuild-registry.json"), `{`)
+	writeFile(t, filepath.Join(dir, "home", "registry", "public", "protobuild-registry.json"), `{
+		"projects": [{
+			"name": "acme/types",
+			"source": {"git": "x"},
+			"versions": [{"version": "v1.0.0"}]
+		}]
+	}`)
+	workspace := writeFile(t, filepath.Join(dir, "protobuild.json"), `{"targets": ["**/protobuild-target.json"]}`)
+	writeFile(t, filepath.Join(dir, "proto", "foo.proto"), `syntax = "proto3";`)
+	writeFile(t, filepath.Join(dir, "proto", "protobuild-target.json"), `{
+		"name": "my-project",
+		"sources": ["*.proto"],
+		"dependencies": ["acme/types"],
+		"outputs": [{"name": "go", "plugin": "go", "out": "gen"}]
+	}`)
+	root := cmd.New("")
+	root.SetOut(io.Discard)
+	root.SetArgs([]string{"--config", workspace, "validate"})
+
+	if err := root.Execute(); err != nil {
+		t.Errorf("Execute: unexpected error: %v", err)
+	}
+}
+
+func TestLoadTargets_UnmatchedSources_ReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PROTOBUILD_PATH", filepath.Join(dir, "home"))
+	workspace := writeFile(t, filepath.Join(dir, "protobuild.json"), `{"targets": ["**/protobuild-target.json"]}`)
+	writeFile(t, filepath.Join(dir, "proto", "protobuild-target.json"), `{
+		"name": "my-project",
+		"sources": ["*.proto"],
+		"outputs": [{"name": "go", "plugin": "go", "out": "gen"}]
+	}`)
+	root := cmd.New("")
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	root.SetArgs([]string{"--config", workspace, "validate"})
+
+	if err := root.Execute(); err == nil {
+		t.Errorf("Execute: got nil error, want error")
+	}
+}
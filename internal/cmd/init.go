@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/internal/scaffold"
+	"github.com/spf13/cobra"
+)
+
+type initOptions struct {
+	yes     bool
+	format  string
+	plugins []string
+	output  string
+}
+
+func newInitCommand() *cobra.Command {
+	opts := &initOptions{}
+	cmd := &cobra.Command{
+		Use:   "init [dir]",
+		Short: "Create a workspace for the proto files in a directory",
+		Long: dedent.String(`
+			Creates a workspace in the directory, which defaults to the
+			current directory, with targets for the proto files that it
+			already contains. Files that cannot be parsed are warned about,
+			and skipped.
+
+			The import root of each proto file is inferred from its package,
+			since files are conventionally stored in directories that match
+			their packages, and a target is proposed for each import root,
+			depending on the other targets that it imports from. Outputs are
+			proposed for the languages that the file options configure, such
+			as go for go_package.
+
+			Each proposal is confirmed interactively, unless --yes is given,
+			in which case every proposal is accepted. The workspace and
+			target files are written with a $schema of their hosted JSON
+			Schema, so that editors can validate and complete them.
+		`),
+		Example: "protobuild init --yes --plugins go,python",
+		GroupID: groupWorkspace,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := "."
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			return runInit(cmd, opts, dir)
+		},
+	}
+	opts.flagSet().RegisterFlags(cmd)
+	return cmd
+}
+
+// flagSet creates the flagset that holds the flags of the init command.
+func (opts *initOptions) flagSet() *flagset.FlagSet {
+	fs := flagset.New("init")
+	fs.BoolVarP(&opts.yes, "yes", "y", false, "accept every proposal without prompting")
+	fs.StringVar(&opts.format, "format", string(config.FormatJSON), "the `format` of the files: json, yaml, or toml")
+	fs.StringSliceVar(&opts.plugins, "plugins", nil, "the `plugins` to generate outputs with, instead of those proposed")
+	fs.StringVar(&opts.output, "output", scaffold.DefaultOutput, "the `directory` that outputs are generated under")
+	return fs
+}
+
+func runInit(cmd *cobra.Command, opts *initOptions, dir string) error {
+	format := config.Format(opts.format)
+	if !slices.Contains([]config.Format{config.FormatJSON, config.FormatYAML, config.FormatTOML}, format) {
+		return fmt.Errorf("unsupported format %q; must be one of json, yaml, or toml", opts.format)
+	}
+	if existing, err := config.FindWorkspace(dir); err == nil {
+		if abs, _ := filepath.Abs(dir); filepath.Dir(existing) == abs {
+			return fmt.Errorf("%s already exists", existing)
+		}
+		cli.Warningf("creating a workspace nested in the workspace of %s", existing)
+	}
+
+	files, err := scaffold.Scan(dir, glob.NewPatterns(config.DefaultVendor+"/**", opts.output+"/**"))
+	if err != nil {
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, err := range errs {
+			cli.Warningf("skipping a proto file that cannot be scanned: %v", err)
+		}
+	}
+	proposal := scaffold.Propose(dir, format, files)
+	proposal.Workspace.Output = opts.output
+	if len(opts.plugins) > 0 {
+		proposal.SetPlugins(opts.plugins)
+	}
+
+	out := cmd.OutOrStdout()
+	if opts.yes && cli.Verbosity() < 0 {
+		out = io.Discard
+	}
+	p := &prompter{in: bufio.NewReader(cmd.InOrStdin()), out: out, yes: opts.yes}
+	fmt.Fprintf(out, "found %d proto file(s)\n", len(files))
+	if err := confirmTargets(p, proposal); err != nil {
+		return err
+	}
+	if len(opts.plugins) == 0 && len(proposal.Targets) > 0 {
+		answer, err := p.ask("generate outputs with plugins", strings.Join(proposal.Plugins, ","))
+		if err != nil {
+			return err
+		}
+		if plugins := splitList(answer); len(plugins) > 0 {
+			proposal.SetPlugins(plugins)
+		}
+	}
+	importers := make([]string, 0, len(proposal.Unresolved))
+	for file := range proposal.Unresolved {
+		importers = append(importers, file)
+	}
+	slices.Sort(importers)
+	for _, file := range importers {
+		for _, imported := range proposal.Unresolved[file] {
+			cli.Warningf("%s imports %s, which no file of the workspace provides; add a dependency on the registry project that provides it",
+				file, cli.FormatQuote.Format("%s", imported),
+			)
+		}
+	}
+
+	if err := proposal.Write(); err != nil {
+		return err
+	}
+	cli.Noticef("wrote %s with %d target(s); check it with %s",
+		proposal.Workspace.Path,
+		len(proposal.Targets),
+		cli.FormatCommand.Format("%s", "protobuild validate"),
+	)
+	return nil
+}
+
+// confirmTargets describes each proposed target, and removes those that are
+// not confirmed.
+func confirmTargets(p *prompter, proposal *scaffold.Proposal) error {
+	for _, target := range slices.Clone(proposal.Targets) {
+		fmt.Fprintf(p.out, "\ntarget %s: %d file(s) with import root %s\n", target.Name, len(target.Files), target.Root)
+		if len(target.Dependencies) > 0 {
+			fmt.Fprintf(p.out, "  depends on %s\n", strings.Join(target.Dependencies, ", "))
+		}
+		ok, err := p.confirm(fmt.Sprintf("create target %s", target.Name))
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		for _, dependent := range proposal.Targets {
+			if slices.Contains(dependent.Dependencies, target.Name) {
+				cli.Warningf("target %s imports files of target %s, which will not be created", dependent.Name, target.Name)
+			}
+		}
+		proposal.Remove(target.Name)
+	}
+	return nil
+}
+
+// prompter asks questions on the terminal. Questions are answered with
+// their defaults when every proposal is accepted, or when the input ends.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	yes bool
+}
+
+// ask asks a question, and returns the answer, or the default answer if the
+// question is answered with an empty line.
+func (p *prompter) ask(question, answer string) (string, error) {
+	if p.yes {
+		return answer, nil
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, answer)
+	line, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if errors.Is(err, io.EOF) {
+		fmt.Fprintln(p.out)
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		return line, nil
+	}
+	return answer, nil
+}
+
+// confirm asks a yes or no question, which defaults to yes.
+func (p *prompter) confirm(question string) (bool, error) {
+	for {
+		answer, err := p.ask(question+"?", "Y/n")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "y/n", "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(p.out, "please answer yes or no")
+	}
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/bitwizeshift/protobuild/internal/lock"
+	"github.com/spf13/cobra"
+)
+
+type lockOptions struct {
+	update bool
+	locked bool
+}
+
+func newLockCommand(g *globals) *cobra.Command {
+	opts := &lockOptions{}
+	cmd := &cobra.Command{
+		Use:   "lock [project...]",
+		Short: "Pin the registry projects of the workspace in protobuild.lock",
+		Long: dedent.String(`
+			Records the exact sources that every registry project the
+			workspace depends on resolves to in the protobuild.lock file of
+			the workspace: the version, the commit or archive it was
+			retrieved from, and the integrity digest of its sources. The
+			generate and vendor commands use the pinned sources for as long
+			as the lockfile pins them, so commit the lockfile alongside the
+			workspace.
+
+			Without flags, projects that are not yet pinned, or whose pinned
+			version is no longer provided by their registry, are resolved,
+			and projects that are no longer depended on are removed. With
+			--update, the named projects, or every project if none are
+			named, are resolved again to their latest sources.
+
+			With --locked, nothing is resolved; the command fails if the
+			lockfile is out of date, which is intended for continuous
+			integration.
+		`),
+		Example: "protobuild lock --update google/fhir",
+		GroupID: groupWorkspace,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLock(cmd, g, opts, args)
+		},
+	}
+	opts.flagSet().RegisterFlags(cmd)
+	cmd.MarkFlagsMutuallyExclusive("update", "locked")
+	return cmd
+}
+
+// flagSet creates the flagset that holds the flags of the lock command.
+func (opts *lockOptions) flagSet() *flagset.FlagSet {
+	fs := flagset.New("lock")
+	fs.BoolVar(&opts.update, "update", false, "resolve the named projects, or every project, again")
+	fs.BoolVar(&opts.locked, "locked", false, "fail if the lockfile is out of date, instead of updating it")
+	return fs
+}
+
+func runLock(cmd *cobra.Command, g *globals, opts *lockOptions, names []string) error {
+	if len(names) > 0 && !opts.update {
+		return errors.New("projects may only be named with --update")
+	}
+	workspace, err := g.loadWorkspace()
+	if err != nil {
+		return reportErrors(err)
+	}
+	index, err := g.loadIndex(workspace)
+	if err != nil {
+		return reportErrors(err)
+	}
+	targets, err := loadTargets(workspace, index)
+	if err != nil {
+		return reportErrors(err)
+	}
+	dependencies, err := graph.New(targets, index)
+	if err != nil {
+		return err
+	}
+	warnShadowed(dependencies, index)
+	nodes, err := dependencies.Order()
+	if err != nil {
+		return err
+	}
+	sources, err := newProjectSources(workspace, dependencies, opts.locked)
+	if err != nil {
+		return reportErrors(err)
+	}
+	if opts.locked {
+		if err := sources.check(nodes); err != nil {
+			return err
+		}
+		cli.Noticef("%s is up to date", lock.FileName)
+		return nil
+	}
+
+	if opts.update {
+		// Projects are named as they are depended on, which may or may not be
+		// qualified by their registry.
+		update := make([]string, 0, len(names))
+		for _, name := range names {
+			project, registry := index.Lookup(name)
+			if project == nil {
+				return fmt.Errorf("unknown project %q", name)
+			}
+			canonical := index.Name(project, registry)
+			if node := dependencies.Node(canonical); node == nil || node.Kind != graph.KindProject {
+				return fmt.Errorf("project %s is not depended on by the workspace", canonical)
+			}
+			update = append(update, canonical)
+		}
+		sources.update = func(name string) bool {
+			return len(update) == 0 || slices.Contains(update, name)
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	if cli.Verbosity() < 0 {
+		out = io.Discard
+	}
+	reporter := cli.NewReporter(out)
+	for _, node := range nodes {
+		if node.Kind != graph.KindProject || sources.keep(node) {
+			continue
+		}
+		task := reporter.Start(node.Name)
+		source, err := sources.source(cmd.Context(), node)
+		if err != nil {
+			task.Fail("")
+			return err
+		}
+		task.Succeed(source.Version.Version)
+	}
+	changed, err := sources.save(true)
+	if err != nil {
+		return err
+	}
+	if changed {
+		cli.Noticef("updated %s", lock.FileName)
+	} else {
+		cli.Noticef("%s is up to date", lock.FileName)
+	}
+	return nil
+}
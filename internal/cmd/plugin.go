@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/bitwizeshift/protobuild/internal/plugin"
+	"github.com/spf13/cobra"
+)
+
+// newPluginManager loads the plugins installed in the bin path.
+func newPluginManager() (*plugin.Manager, error) {
+	dir, err := env.BinPath()
+	if err != nil {
+		return nil, err
+	}
+	return plugin.Load(dir)
+}
+
+// loadPluginIndex loads the index of registries that plugin recipes are
+// resolved from, in the priority of the current workspace if there is one.
+func (g *globals) loadPluginIndex() (*config.Index, error) {
+	workspace, err := g.loadWorkspace()
+	if errors.Is(err, config.ErrNotFound) {
+		workspace = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return g.loadIndex(workspace)
+}
+
+func newPluginCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Install the protoc plugins that outputs are generated with",
+		Long: dedent.String(`
+			Installs protoc plugins from the recipes that registries define
+			for them. Each plugin is installed into the protobuild bin path,
+			and is used to generate outputs instead of any executable of the
+			same name in the PATH.
+		`),
+		GroupID: groupWorkspace,
+	}
+	cmd.AddCommand(
+		newPluginInstallCommand(g),
+		newPluginListCommand(g),
+		newPluginRemoveCommand(),
+	)
+	return cmd
+}
+
+func newPluginInstallCommand(g *globals) *cobra.Command {
+	return &cobra.Command{
+		Use:   "install <name>[@version]...",
+		Short: "Install plugins from their registry recipes",
+		Long: dedent.String(`
+			Installs each named plugin with the recipe of the registry with
+			the highest priority that defines it. The newest version of the
+			recipe is installed, unless a version is named. Any other
+			installed version of the plugin is replaced.
+		`),
+		Example: "protobuild plugin install go go-grpc@v1.5.1",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := g.loadPluginIndex()
+			if err != nil {
+				return reportErrors(err)
+			}
+			manager, err := newPluginManager()
+			if err != nil {
+				return reportErrors(err)
+			}
+
+			out := cmd.OutOrStdout()
+			if cli.Verbosity() < 0 {
+				out = io.Discard
+			}
+			reporter := cli.NewReporter(out)
+			var errs []error
+			for _, arg := range args {
+				name, version, _ := strings.Cut(arg, "@")
+				recipe, registry := index.Plugin(name)
+				if recipe == nil {
+					errs = append(errs, fmt.Errorf("no registry defines a recipe for plugin %q", name))
+					continue
+				}
+				selected := &recipe.Versions[0]
+				if version != "" {
+					if selected = recipe.Version(version); selected == nil {
+						errs = append(errs, fmt.Errorf("plugin %q of registry %s has no version %q", name, registry.Name, version))
+						continue
+					}
+				}
+
+				task := reporter.Start(fmt.Sprintf("%s %s", name, selected.Version))
+				if _, err := manager.Install(cmd.Context(), recipe, selected, registry); err != nil {
+					task.Fail("")
+					errs = append(errs, err)
+					continue
+				}
+				task.Succeed("installed")
+			}
+			switch len(errs) {
+			case 0:
+				return nil
+			case 1:
+				return errs[0]
+			}
+			for _, err := range errs {
+				cli.Error(err)
+			}
+			return fmt.Errorf("%d plugins failed to install", len(errs))
+		},
+	}
+}
+
+func newPluginListCommand(g *globals) *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the installed plugins",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			manager, err := newPluginManager()
+			if err != nil {
+				return reportErrors(err)
+			}
+			if !all {
+				return listInstalledPlugins(cmd.OutOrStdout(), manager)
+			}
+			index, err := g.loadPluginIndex()
+			if err != nil {
+				return reportErrors(err)
+			}
+			return listPluginRecipes(cmd.OutOrStdout(), manager, index)
+		},
+	}
+	fs := flagset.New("list")
+	fs.BoolVarP(&all, "all", "a", false, "list every plugin that registries define recipes for")
+	fs.RegisterFlags(cmd)
+	return cmd
+}
+
+// listInstalledPlugins writes the name, version, registry, and path of each
+// installed plugin.
+func listInstalledPlugins(out io.Writer, manager *plugin.Manager) error {
+	if len(manager.Installed) == 0 {
+		cli.Noticef("no plugins have been installed; install one with %s",
+			cli.FormatCommand.Format("%s", "protobuild plugin install <name>"),
+		)
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for i := range manager.Installed {
+		installed := &manager.Installed[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			installed.Name,
+			installed.Version,
+			installed.Registry,
+			manager.Executable(installed),
+		)
+	}
+	return w.Flush()
+}
+
+// listPluginRecipes writes each plugin that a registry defines a recipe for,
+// from the registry with the highest priority, with its newest version and
+// the version that is installed.
+func listPluginRecipes(out io.Writer, manager *plugin.Manager, index *config.Index) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	listed := make(map[string]bool)
+	for _, registry := range index.Registries() {
+		for _, recipe := range registry.Plugins {
+			if listed[recipe.Name] {
+				continue
+			}
+			listed[recipe.Name] = true
+			installed := "(not installed)"
+			if current := manager.Lookup(recipe.Name); current != nil {
+				installed = "(installed " + current.Version + ")"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				recipe.Name,
+				recipe.Versions[0].Version,
+				registry.Name,
+				installed,
+				recipe.Description,
+			)
+		}
+	}
+	if len(listed) == 0 {
+		cli.Noticef("no registry defines any plugin recipes")
+		return nil
+	}
+	return w.Flush()
+}
+
+func newPluginRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove an installed plugin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			manager, err := newPluginManager()
+			if err != nil {
+				return reportErrors(err)
+			}
+			if err := manager.Remove(args[0]); err != nil {
+				return err
+			}
+			cli.Noticef("removed plugin %s", args[0])
+			return nil
+		},
+	}
+}
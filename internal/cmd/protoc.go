@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"io"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+	"github.com/bitwizeshift/protobuild/internal/protoc"
+)
+
+// newProtocInstaller creates an installer of pinned versions of protoc into
+// the bin path.
+func newProtocInstaller() (*protoc.Installer, error) {
+	dir, err := env.BinPath()
+	if err != nil {
+		return nil, err
+	}
+	return &protoc.Installer{
+		Dir:     dir,
+		Fetcher: &fetch.Fetcher{},
+		Mirror:  env.ProtocMirror(),
+	}, nil
+}
+
+// installProtoc returns the toolchain of the version of protoc that the
+// workspace pins, installing it first if it has not been installed, or nil
+// if the workspace does not pin a version.
+func installProtoc(ctx context.Context, out io.Writer, workspace *config.Workspace) (*protoc.Toolchain, error) {
+	if workspace.Protoc == nil {
+		return nil, nil
+	}
+	installer, err := newProtocInstaller()
+	if err != nil {
+		return nil, err
+	}
+	if toolchain := installer.Installed(workspace.Protoc); toolchain != nil {
+		return toolchain, nil
+	}
+
+	if cli.Verbosity() < 0 {
+		out = io.Discard
+	}
+	task := cli.NewReporter(out).Start("protoc " + workspace.Protoc.Version)
+	toolchain, err := installer.Install(ctx, workspace.Protoc)
+	if err != nil {
+		task.Fail("")
+		return nil, err
+	}
+	task.Succeed("installed")
+	return toolchain, nil
+}
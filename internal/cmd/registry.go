@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/bitwizeshift/protobuild/internal/registry"
+	"github.com/spf13/cobra"
+)
+
+// newRegistryManager creates a manager of the registries under the registry
+// path, which records them in the user configuration.
+func newRegistryManager() (*registry.Manager, error) {
+	dir, err := env.RegistryPath()
+	if err != nil {
+		return nil, err
+	}
+	path, err := env.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	user, err := config.LoadUser(path)
+	if err != nil {
+		return nil, err
+	}
+	return &registry.Manager{Dir: dir, User: user}, nil
+}
+
+func newRegistryCommand(g *globals) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Manage the registries that projects are resolved from",
+		Long: dedent.String(`
+			Manages the git-driven registries that external projects are
+			resolved from. Registries are cloned into the protobuild registry
+			path, and the locations they were added from are recorded in the
+			user configuration so that they can be updated later.
+
+			Any location that git can clone from may be used, including
+			"file://" URLs and local bare repositories.
+		`),
+		GroupID: groupWorkspace,
+	}
+	cmd.AddCommand(
+		newRegistryAddCommand(),
+		newRegistryListCommand(g),
+		newRegistryUpdateCommand(),
+		newRegistryRemoveCommand(),
+	)
+	return cmd
+}
+
+func newRegistryAddCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "add <name> <url>",
+		Short:   "Clone a registry into the registry path",
+		Example: "protobuild registry add public https://github.com/bitwizeshift/protobuild-registry.git",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newRegistryManager()
+			if err != nil {
+				return err
+			}
+			added, err := manager.Add(cmd.Context(), args[0], args[1])
+			if err != nil {
+				return reportErrors(err)
+			}
+			cli.Noticef("added registry %s with %d project(s)", added.Name, len(added.Projects))
+			return nil
+		},
+	}
+}
+
+func newRegistryListCommand(g *globals) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the registries and the projects they provide",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			manager, err := newRegistryManager()
+			if err != nil {
+				return err
+			}
+
+			// Registries are listed in the priority of the current workspace,
+			// but may equally be listed outside of one.
+			workspace, err := g.loadWorkspace()
+			if errors.Is(err, config.ErrNotFound) {
+				workspace = nil
+			} else if err != nil {
+				return reportErrors(err)
+			}
+			index, err := g.loadIndex(workspace)
+			if err != nil {
+				return reportErrors(err)
+			}
+			return listRegistries(cmd.OutOrStdout(), manager.User, index)
+		},
+	}
+}
+
+// listRegistries writes each registry in the index from highest to lowest
+// priority, followed by the projects that it provides and their latest
+// versions.
+func listRegistries(out io.Writer, user *config.User, index *config.Index) error {
+	if len(index.Registries()) == 0 {
+		cli.Noticef("no registries have been added; add one with %s",
+			cli.FormatCommand.Format("%s", "protobuild registry add <name> <url>"),
+		)
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for i, registry := range index.Registries() {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		url := "(not managed)"
+		if ref := user.Registry(registry.Name); ref != nil {
+			url = ref.URL
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", registry.Name, url, registry.Description)
+		for _, project := range registry.Projects {
+			description := project.Description
+			if name := index.Name(&project, registry); name != project.Name {
+				_, winner := index.Lookup(project.Name)
+				description = fmt.Sprintf("(shadowed by %s) %s", winner.Name, description)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", project.Name, project.Versions[0].Version, description)
+		}
+	}
+	return w.Flush()
+}
+
+// warnShadowed warns about every project that is depended on by its
+// unqualified name, but that is defined by more than one registry. The
+// project of the registry with the highest priority is used, which may not be
+// the one that was intended.
+func warnShadowed(dependencies *graph.Graph, index *config.Index) {
+	warned := make(map[string]bool)
+	for _, name := range dependencies.Names() {
+		var names []string
+		switch node := dependencies.Node(name); node.Kind {
+		case graph.KindTarget:
+			names = node.Target.Dependencies
+		case graph.KindProject:
+			names = node.Project.Dependencies
+		}
+		for _, text := range names {
+			dependency := config.DependencyName(text)
+			shadowed := index.Shadowed(dependency)
+			if warned[dependency] || len(shadowed) == 0 {
+				continue
+			}
+			warned[dependency] = true
+			_, registry := index.Lookup(dependency)
+			cli.Warningf("project %s of registry %s shadows the same project in %s; qualify the dependency, such as %s, to use another",
+				cli.FormatStrong.Format("%s", dependency),
+				registry.Name,
+				registryNames(shadowed),
+				cli.FormatQuote.Format("%s/%s", shadowed[0].Name, dependency),
+			)
+		}
+	}
+}
+
+func registryNames(registries []*config.Registry) string {
+	names := make([]string, len(registries))
+	for i, registry := range registries {
+		names[i] = registry.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func newRegistryUpdateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "update [name...]",
+		Short: "Fetch the latest revision of registries",
+		Long: dedent.String(`
+			Fetches the latest revision of each named registry, or of every
+			added registry if none are named, from the location it was added
+			from. A registry whose latest revision is not valid is left at
+			its current revision.
+		`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := newRegistryManager()
+			if err != nil {
+				return err
+			}
+			names := args
+			if len(names) == 0 {
+				for _, ref := range manager.User.Registries {
+					names = append(names, ref.Name)
+				}
+			}
+
+			out := cmd.OutOrStdout()
+			if cli.Verbosity() < 0 {
+				out = io.Discard
+			}
+			reporter := cli.NewReporter(out)
+			var errs []error
+			for _, name := range names {
+				task := reporter.Start(name)
+				switch changed, err := manager.Update(cmd.Context(), name); {
+				case err != nil:
+					task.Fail("")
+					errs = append(errs, err)
+				case changed:
+					task.Succeed("updated")
+				default:
+					task.Succeed("up-to-date")
+				}
+			}
+			switch len(errs) {
+			case 0:
+				return nil
+			case 1:
+				return reportErrors(errs[0])
+			}
+			for _, err := range errs {
+				cli.Error(err)
+			}
+			return fmt.Errorf("%d registries failed to update", len(errs))
+		},
+	}
+}
+
+func newRegistryRemoveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a registry from the registry path",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			manager, err := newRegistryManager()
+			if err != nil {
+				return err
+			}
+			if err := manager.Remove(args[0]); err != nil {
+				return err
+			}
+			cli.Noticef("removed registry %s", args[0])
+			return nil
+		},
+	}
+}
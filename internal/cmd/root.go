@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"runtime/debug"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/spf13/cobra"
+)
+
+// Command groups used to organize the sub-commands in help output.
+const (
+	groupBuild     = "build"
+	groupWorkspace = "workspace"
+	groupUtility   = "utility"
+)
+
+// New constructs the root protobuild command with all of its sub-commands.
+//
+// The version is displayed by the --version flag; if it is empty, the version
+// of the main module recorded in the build information is used instead.
+func New(version string) *cobra.Command {
+	g := &globals{}
+	root := &cobra.Command{
+		Use:   cli.AppName() + " [command]",
+		Short: "The missing coordinator for protobuf projects",
+		Long: dedent.String(`
+			Protobuild is a data-driven build-system for coordinating the
+			generation of protobuf definitions across many languages, plugins,
+			and dependent projects.
+		`),
+		Version:           resolveVersion(version),
+		SilenceUsage:      true,
+		SilenceErrors:     true,
+		PersistentPreRunE: g.apply,
+	}
+	root.AddGroup(
+		&cobra.Group{ID: groupBuild, Title: "Build"},
+		&cobra.Group{ID: groupWorkspace, Title: "Workspace"},
+		&cobra.Group{ID: groupUtility, Title: "Utility"},
+	)
+	root.AddCommand(
+		newGenerateCommand(g),
+		newDescribeCommand(g),
+		newValidateCommand(g),
+		newInitCommand(),
+		newRegistryCommand(g),
+		newPluginCommand(g),
+		newVendorCommand(g),
+		newLockCommand(g),
+		newCacheCommand(),
+		newDoctorCommand(g),
+		newSchemaCommand(),
+		newVersionCommand(),
+	)
+
+	cli.SetDefaults(root)
+	root.SetHelpFunc(g.help(root.HelpFunc()))
+	g.flagSet().RegisterPersistentFlags(root)
+	return root
+}
+
+func resolveVersion(version string) string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "version",
+		Short:   "Show version and build details",
+		GroupID: groupUtility,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cli.RenderVersion(cmd.OutOrStdout(), cmd.Root())
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package cmd_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/ansi"
+	"github.com/bitwizeshift/protobuild/internal/cmd"
+)
+
+func TestNew_Help_ListsFlagsOfCommand(t *testing.T) {
+	ansi.SetEnabled(false)
+	testCases := []struct {
+		name  string
+		args  []string
+		flags []string
+	}{
+		{
+			name:  "generate",
+			args:  []string{"generate", "--help"},
+			flags: []string{"--protoc", "-j, --jobs", "--no-cache", "--remote-cache", "--locked", "-k, --keep-going"},
+		},
+		{
+			name:  "prune",
+			args:  []string{"cache", "prune", "--help"},
+			flags: []string{"--max-size", "--older-than"},
+		},
+		{
+			name:  "vendor",
+			args:  []string{"vendor", "--help"},
+			flags: []string{"--locked"},
+		},
+		{
+			name:  "lock",
+			args:  []string{"lock", "--help"},
+			flags: []string{"--update", "--locked"},
+		},
+		{
+			name:  "list",
+			args:  []string{"plugin", "list", "--help"},
+			flags: []string{"-a, --all"},
+		},
+		{
+			name:  "init",
+			args:  []string{"init", "--help"},
+			flags: []string{"-y, --yes", "--format", "--plugins", "--output"},
+		},
+		{
+			name:  "describe",
+			args:  []string{"describe", "--help"},
+			flags: []string{"--format", "--include-imports", "--locked"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			root := cmd.New("")
+			root.SetOut(&buf)
+			root.SetArgs(tc.args)
+
+			if err := root.Execute(); err != nil {
+				t.Fatalf("Execute: unexpected error: %v", err)
+			}
+
+			help := buf.String()
+			heading := strings.ToUpper(tc.name) + " FLAGS"
+			if !strings.Contains(help, heading) {
+				t.Errorf("Execute: help does not contain %q:\n%s", heading, help)
+			}
+			for _, flag := range tc.flags {
+				if !strings.Contains(help, flag) {
+					t.Errorf("Execute: help does not list %q:\n%s", flag, help)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_Help_HonorsColorMode(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		{name: "never", args: []string{"--color", "never", "generate", "--help"}},
+		{name: "auto without terminal", args: []string{"generate", "--help"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ansi.SetEnabled(true)
+			t.Cleanup(func() { ansi.SetEnabled(false) })
+			var buf bytes.Buffer
+			root := cmd.New("")
+			root.SetOut(&buf)
+			root.SetArgs(tc.args)
+
+			if err := root.Execute(); err != nil {
+				t.Fatalf("Execute: unexpected error: %v", err)
+			}
+
+			if help := buf.String(); strings.Contains(help, "\033[") {
+				t.Errorf("Execute: help contains escape sequences:\n%q", help)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/spf13/cobra"
+)
+
+func newSchemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "schema",
+		Short:   "Work with the JSON Schemas of the configuration files",
+		GroupID: groupUtility,
+	}
+	cmd.AddCommand(newSchemaPrintCommand())
+	return cmd
+}
+
+func newSchemaPrintCommand() *cobra.Command {
+	kinds := config.SchemaKinds()
+	return &cobra.Command{
+		Use:   fmt.Sprintf("print <%s>", strings.Join(kinds, "|")),
+		Short: "Print the JSON Schema of a kind of configuration file",
+		Long: dedent.String(`
+			Prints the JSON Schema of a kind of configuration file, generated
+			from the definitions that protobuild itself decodes. This is the
+			same schema that is embedded in the binary and that is hosted with
+			the documentation.
+		`),
+		Example:   "protobuild schema print workspace > protobuild-workspace-v1.json",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: kinds,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema, err := config.GenerateSchema(args[0])
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(schema)
+			return err
+		},
+	}
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/env"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/bitwizeshift/protobuild/internal/lock"
+)
+
+// newFetcher creates a fetcher of project sources into the cache path.
+func newFetcher() (*fetch.Fetcher, error) {
+	dir, err := env.CachePath()
+	if err != nil {
+		return nil, err
+	}
+	return &fetch.Fetcher{Dir: filepath.Join(dir, "projects")}, nil
+}
+
+// pinnedVersion returns the version of the project that the locked entry pins
+// it to, or nil if the entry no longer applies to the project as its registry
+// defines it. Versions of git projects are pinned to the locked commit, and
+// every version is pinned to the locked integrity digest.
+func pinnedVersion(project *config.Project, entry *lock.Project) *config.Version {
+	i := slices.IndexFunc(project.Versions, func(version config.Version) bool {
+		return version.Version == entry.Version
+	})
+	if i < 0 {
+		return nil
+	}
+	version := project.Versions[i]
+	if version.Integrity != "" && version.Integrity != entry.Integrity {
+		return nil
+	}
+	switch {
+	case project.Source.Git != "":
+		if entry.Commit == "" {
+			return nil
+		}
+		version.Ref = entry.Commit
+	case project.Source.Archive != "":
+		if entry.Archive != fetch.ArchiveURL(project, &version) {
+			return nil
+		}
+	}
+	version.Integrity = entry.Integrity
+	return &version
+}
+
+// lockEntry returns the entry of the lockfile that pins a project to the
+// sources.
+func lockEntry(source *fetch.Source) lock.Project {
+	entry := lock.Project{
+		Name:      source.Project.Name,
+		Registry:  source.Registry.Name,
+		Version:   source.Version.Version,
+		Commit:    source.Commit,
+		Integrity: source.Integrity,
+	}
+	if source.Project.Source.Archive != "" {
+		entry.Archive = source.URL
+	}
+	return entry
+}
+
+// staleError returns an error for a lockfile that does not pin a project that
+// the workspace depends on.
+func staleError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s; run 'protobuild lock'", lock.ErrStale, fmt.Sprintf(format, args...))
+}
+
+// projectSources locates the sources of the registry projects of a workspace.
+// Projects are resolved to the versions pinned by the lockfile of the
+// workspace, and vendored copies of projects are preferred over fetching them.
+type projectSources struct {
+	fetcher  *fetch.Fetcher
+	vendor   *fetch.Vendor
+	lockfile *lock.File
+
+	// locked fails instead of resolving projects that the lockfile does not
+	// pin.
+	locked bool
+
+	// update reports whether a project should be resolved again, even though
+	// the lockfile pins it. If nil, no project is resolved again.
+	update func(name string) bool
+
+	selected map[string]*config.Version
+	dirs     map[*config.Project]string
+	resolved map[*config.Project]lock.Project
+}
+
+// newProjectSources creates the sources of the registry projects of the
+// graph, which selects the versions of projects that are not pinned by the
+// lockfile with the strategy of the workspace.
+func newProjectSources(workspace *config.Workspace, dependencies *graph.Graph, locked bool) (*projectSources, error) {
+	strategy := graph.Highest
+	if workspace.VersionSelection == config.SelectMinimal {
+		strategy = graph.Minimal
+	}
+	selected, err := dependencies.SelectVersions(strategy)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := newFetcher()
+	if err != nil {
+		return nil, err
+	}
+	vendor, err := fetch.LoadVendor(workspace.VendorDir())
+	if err != nil {
+		return nil, err
+	}
+	lockfile, err := lock.Load(lock.Path(workspace))
+	if err != nil {
+		return nil, err
+	}
+	return &projectSources{
+		fetcher:  fetcher,
+		vendor:   vendor,
+		lockfile: lockfile,
+		locked:   locked,
+		selected: selected,
+		dirs:     make(map[*config.Project]string),
+		resolved: make(map[*config.Project]lock.Project),
+	}, nil
+}
+
+// pin returns the version that the lockfile pins the project of the node to,
+// or nil if it must be resolved.
+func (s *projectSources) pin(node *graph.Node) *config.Version {
+	if s.update != nil && s.update(node.Name) {
+		return nil
+	}
+	entry := s.lockfile.Lookup(node.Registry.Name, node.Project.Name)
+	if entry == nil {
+		return nil
+	}
+	version := pinnedVersion(node.Project, entry)
+	if version == nil || !node.Accepts(version) {
+		return nil
+	}
+	return version
+}
+
+// check checks that the lockfile pins every registry project among the
+// nodes, and nothing else.
+func (s *projectSources) check(nodes []*graph.Node) error {
+	var projects []lock.Project
+	for _, node := range nodes {
+		if node.Kind != graph.KindProject {
+			continue
+		}
+		entry := s.lockfile.Lookup(node.Registry.Name, node.Project.Name)
+		if entry == nil {
+			return staleError("project %s is not locked", node.Name)
+		}
+		if version := pinnedVersion(node.Project, entry); version == nil {
+			return staleError("project %s is locked to %s, which registry %s no longer provides",
+				node.Name, entry.Version, node.Registry.Name,
+			)
+		} else if !node.Accepts(version) {
+			return staleError("project %s is locked to %s, which does not satisfy its version constraints",
+				node.Name, entry.Version,
+			)
+		}
+		projects = append(projects, *entry)
+	}
+	for _, entry := range s.lockfile.Projects {
+		if !slices.Contains(projects, entry) {
+			return staleError("project %s of registry %s is locked, but is not depended on", entry.Name, entry.Registry)
+		}
+	}
+	return nil
+}
+
+// keep records the locked entry of the project of the node as resolved,
+// without fetching its sources, and returns whether the lockfile pins it.
+func (s *projectSources) keep(node *graph.Node) bool {
+	if s.pin(node) == nil {
+		return false
+	}
+	s.resolved[node.Project] = *s.lockfile.Lookup(node.Registry.Name, node.Project.Name)
+	return true
+}
+
+// source returns the sources of the registry project of the node, at the
+// version pinned by the lockfile, fetching them if they have not been
+// fetched before.
+func (s *projectSources) source(ctx context.Context, node *graph.Node) (*fetch.Source, error) {
+	version := s.pin(node)
+	if version == nil {
+		if s.locked {
+			return nil, staleError("project %s is not locked", node.Name)
+		}
+		version = s.selected[node.Name]
+	}
+	refetch := s.update != nil && s.update(node.Name)
+	source, err := s.fetcher.Fetch(ctx, node.Project, node.Registry, version, refetch)
+	if err != nil {
+		return nil, err
+	}
+	s.resolved[node.Project] = lockEntry(source)
+	return source, nil
+}
+
+// fetch locates the sources of every registry project among the nodes,
+// fetching those that are neither vendored nor already fetched.
+func (s *projectSources) fetch(ctx context.Context, nodes []*graph.Node) error {
+	for _, node := range nodes {
+		if node.Kind != graph.KindProject {
+			continue
+		}
+		version := s.pin(node)
+		if version == nil && !s.locked {
+			version = s.selected[node.Name]
+		}
+		if version != nil {
+			if source := s.vendor.Source(node.Project, node.Registry, version); source != nil {
+				s.dirs[node.Project] = source.Dir
+				s.resolved[node.Project] = lockEntry(source)
+				continue
+			}
+			switch vendored := s.vendor.Lookup(node.Project, node.Registry); {
+			case vendored == nil:
+			case vendored.Version == version.Version:
+				cli.Warningf("project %s is vendored with sources that differ from %s; run %s",
+					node.Name, lock.FileName, cli.FormatCommand.Format("%s", "protobuild vendor"),
+				)
+			default:
+				cli.Warningf("project %s is vendored at %s, but %s is required; run %s",
+					node.Name, vendored.Version, version.Version, cli.FormatCommand.Format("%s", "protobuild vendor"),
+				)
+			}
+		}
+		source, err := s.source(ctx, node)
+		if err != nil {
+			return err
+		}
+		if source.Fetched {
+			cli.Noticef("fetched %s %s", node.Name, source.Version.Version)
+		}
+		s.dirs[node.Project] = source.Dir
+	}
+	return nil
+}
+
+// resolve resolves a registry project to the sources located by fetch.
+func (s *projectSources) resolve(project *config.Project, _ *config.Registry) (string, error) {
+	dir, ok := s.dirs[project]
+	if !ok {
+		return "", fmt.Errorf("sources have not been fetched")
+	}
+	return dir, nil
+}
+
+// save records the resolved projects in the lockfile, and returns whether it
+// changed. Locked projects that were not resolved are kept, unless prune is
+// set. With locked, a lockfile that would change is an error instead.
+func (s *projectSources) save(prune bool) (bool, error) {
+	var projects []lock.Project
+	for _, entry := range s.resolved {
+		projects = append(projects, entry)
+	}
+	if !prune {
+		for _, entry := range s.lockfile.Projects {
+			if !slices.ContainsFunc(projects, func(project lock.Project) bool {
+				return project.Registry == entry.Registry && project.Name == entry.Name
+			}) {
+				projects = append(projects, entry)
+			}
+		}
+	}
+	old := s.lockfile.Projects
+	if !s.lockfile.Set(projects) {
+		return false, nil
+	}
+	if s.locked {
+		s.lockfile.Projects = old
+		return false, staleError("the resolved projects differ from the locked projects")
+	}
+	return true, s.lockfile.Save()
+}
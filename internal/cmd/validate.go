@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+func newValidateCommand(g *globals) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the workspace and its targets for errors",
+		Long: dedent.String(`
+			Loads the workspace, every target it selects, and every installed
+			registry, reporting every error that is found, such as malformed
+			files, unknown dependencies, dependency cycles, version
+			constraints that no version of a project satisfies, or outputs
+			that overlap between targets.
+		`),
+		GroupID: groupWorkspace,
+		Args:    cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			workspace, err := g.loadWorkspace()
+			if err != nil {
+				return reportErrors(err)
+			}
+			index, err := g.loadIndex(workspace)
+			if err != nil {
+				return reportErrors(err)
+			}
+			targets, err := loadTargets(workspace, index)
+			if err != nil {
+				return reportErrors(err)
+			}
+			dependencies, err := graph.New(targets, index)
+			if err != nil {
+				return err
+			}
+			warnShadowed(dependencies, index)
+			if _, err := dependencies.Order(); err != nil {
+				return err
+			}
+			if _, err := dependencies.SelectVersions(graph.Highest); err != nil {
+				return reportErrors(err)
+			}
+			cli.Noticef("workspace %s is valid with %d target(s)", workspace.Path, len(targets))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cli/flagset"
+	"github.com/bitwizeshift/protobuild/internal/dedent"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+func newVendorCommand(g *globals) *cobra.Command {
+	var locked bool
+	cmd := &cobra.Command{
+		Use:   "vendor",
+		Short: "Copy the sources of registry projects into the workspace",
+		Long: dedent.String(`
+			Copies the sources of every registry project that the targets of
+			the workspace depend on into the vendor directory of the
+			workspace, so that generation is hermetic and does not need the
+			network. Projects that are no longer depended on are removed.
+
+			Projects are vendored at the sources pinned by the
+			protobuild.lock file of the workspace, which is updated with any
+			project that is not yet pinned. Vendored projects are used by
+			generate for as long as they match the pinned sources; run vendor
+			again after changing dependencies or updating the lockfile.
+		`),
+		GroupID: groupWorkspace,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			workspace, err := g.loadWorkspace()
+			if err != nil {
+				return reportErrors(err)
+			}
+			index, err := g.loadIndex(workspace)
+			if err != nil {
+				return reportErrors(err)
+			}
+			targets, err := loadTargets(workspace, index)
+			if err != nil {
+				return reportErrors(err)
+			}
+			dependencies, err := graph.New(targets, index)
+			if err != nil {
+				return err
+			}
+			warnShadowed(dependencies, index)
+			nodes, err := dependencies.Order()
+			if err != nil {
+				return err
+			}
+
+			sources, err := newProjectSources(workspace, dependencies, locked)
+			if err != nil {
+				return reportErrors(err)
+			}
+			if locked {
+				if err := sources.check(nodes); err != nil {
+					return err
+				}
+			}
+			out := cmd.OutOrStdout()
+			if cli.Verbosity() < 0 {
+				out = io.Discard
+			}
+			reporter := cli.NewReporter(out)
+			var vendored []*fetch.Source
+			for _, node := range nodes {
+				if node.Kind != graph.KindProject {
+					continue
+				}
+				task := reporter.Start(node.Name)
+				source, err := sources.source(cmd.Context(), node)
+				if err != nil {
+					task.Fail("")
+					return err
+				}
+				task.Succeed(source.Version.Version)
+				vendored = append(vendored, source)
+			}
+			if _, err := sources.save(true); err != nil {
+				return err
+			}
+			if err := sources.vendor.Write(vendored); err != nil {
+				return err
+			}
+			cli.Noticef("vendored %d project(s) into %s", len(vendored), sources.vendor.Dir)
+			return nil
+		},
+	}
+	fs := flagset.New("vendor")
+	fs.BoolVar(&locked, "locked", false, "fail if protobuild.lock is out of date, instead of updating it")
+	fs.RegisterFlags(cmd)
+	return cmd
+}
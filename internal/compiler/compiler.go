@@ -0,0 +1,366 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// The well-known types are registered by their packages, so that they are
+	// built in.
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/apipb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/sourcecontextpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/typepb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+	_ "google.golang.org/protobuf/types/pluginpb"
+)
+
+// Compiler compiles proto files into descriptors.
+type Compiler struct {
+	// Includes are the directories that the paths of files, and of their
+	// imports, are resolved against, in order.
+	Includes []string
+
+	// IncludeImports includes every file that the compiled files transitively
+	// import in the result, as with protoc's --include_imports.
+	IncludeImports bool
+}
+
+// Compile compiles the files at the paths, relative to the include
+// directories, and every file that they import.
+//
+// Every error is returned, joined with errors.Join, as a *protofile.Error
+// that reports it in the same format as protoc. A file that cannot be
+// compiled also fails every file that imports it.
+func (c *Compiler) Compile(paths ...string) (*descriptorpb.FileDescriptorSet, error) {
+	s := &session{
+		includes:   c.Includes,
+		files:      make(map[string]*file),
+		failed:     make(map[string]bool),
+		symbols:    make(map[string]*symbol),
+		extensions: make(map[string]map[int32]*extension),
+		registry:   new(protoregistry.Files),
+	}
+	var files []*file
+	for _, path := range paths {
+		if f := s.build(path); f != nil && !slices.Contains(files, f) {
+			files = append(files, f)
+		}
+	}
+	if len(s.errs) > 0 {
+		return nil, errors.Join(s.errs...)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if !c.IncludeImports {
+		for _, f := range files {
+			set.File = append(set.File, f.proto)
+		}
+		return set, nil
+	}
+	added := make(map[*file]bool)
+	var add func(*file)
+	add = func(f *file) {
+		if added[f] {
+			return
+		}
+		added[f] = true
+		for _, dep := range f.deps {
+			add(dep)
+		}
+		set.File = append(set.File, f.proto)
+	}
+	for _, f := range files {
+		add(f)
+	}
+	return set, nil
+}
+
+// errFailed is the error of a file that has already failed to build, whose
+// errors have already been reported.
+var errFailed = errors.New("file failed to build")
+
+// session is the state of a single compilation, which is shared by every
+// file that is built.
+type session struct {
+	includes []string
+
+	// files are the files that have been built, by path, and failed are the
+	// paths of the files that could not be.
+	files  map[string]*file
+	failed map[string]bool
+
+	// pending are the paths of the files that are being built, which are
+	// the files that import the file being built, in order.
+	pending []string
+
+	// symbols are the definitions of every file that has been linked, by
+	// fully qualified name.
+	symbols map[string]*symbol
+
+	// extensions are the extensions of every file that has been linked, by
+	// the fully qualified name of the message that they extend and number.
+	extensions map[string]map[int32]*extension
+
+	// registry contains the descriptor of every file that has been built.
+	registry *protoregistry.Files
+
+	errs []error
+}
+
+// build builds the file at the path, after the files that it imports. It
+// returns nil if the file could not be built, and records its errors.
+func (s *session) build(path string) *file {
+	f, err := s.buildFile(path)
+	switch {
+	case errors.Is(err, errFailed):
+	case err != nil:
+		s.failed[path] = true
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			s.errs = append(s.errs, joined.Unwrap()...)
+		} else {
+			s.errs = append(s.errs, err)
+		}
+	}
+	return f
+}
+
+func (s *session) buildFile(path string) (*file, error) {
+	if f, ok := s.files[path]; ok {
+		return f, nil
+	}
+	if s.failed[path] {
+		return nil, errFailed
+	}
+	f, err := s.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.pending = append(s.pending, path)
+	defer func() {
+		s.pending = s.pending[:len(s.pending)-1]
+	}()
+	var errs []error
+	for i, imported := range f.proto.Dependency {
+		position := protofile.Position{}
+		if i < len(f.imports) {
+			position = f.imports[i]
+		}
+		if start := slices.Index(s.pending, imported); start >= 0 {
+			cycle := append(slices.Clone(s.pending[start:]), imported)
+			errs = append(errs, &protofile.Error{
+				File:     f.filename,
+				Position: position,
+				Message:  "File recursively imports itself: " + strings.Join(cycle, " -> "),
+			})
+			continue
+		}
+		dep := s.build(imported)
+		if dep == nil {
+			errs = append(errs, &protofile.Error{
+				File:     f.filename,
+				Position: position,
+				Message:  fmt.Sprintf("Import \"%s\" was not found or had errors.", imported),
+			})
+			continue
+		}
+		f.deps = append(f.deps, dep)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := s.link(f); err != nil {
+		return nil, err
+	}
+	s.files[path] = f
+	return f, nil
+}
+
+// load parses the file at the path, relative to the first include directory
+// that contains it. Well-known types that are not in any include directory
+// are built in.
+func (s *session) load(path string) (*file, error) {
+	for _, include := range s.includes {
+		filename := filepath.Join(include, filepath.FromSlash(path))
+		data, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parse(path, filename, data)
+	}
+	if strings.HasPrefix(path, "google/protobuf/") {
+		if fd, err := protoregistry.GlobalFiles.FindFileByPath(path); err == nil {
+			return &file{
+				path:     path,
+				filename: path,
+				proto:    protodesc.ToFileDescriptorProto(fd),
+				builtin:  true,
+				desc:     fd,
+			}, nil
+		}
+	}
+	return nil, &protofile.Error{File: path, Message: "File not found."}
+}
+
+// link links a file whose imports have been built, and registers its
+// descriptor. The definitions of a file that fails to link are removed, so
+// that they do not conflict with those of other files.
+func (s *session) link(f *file) error {
+	l := newLinker(s, f)
+	l.link()
+	if len(l.errs) > 0 {
+		s.rollback(f)
+		return errors.Join(l.errs...)
+	}
+	if err := s.registry.RegisterFile(f.desc); err != nil {
+		s.rollback(f)
+		return &protofile.Error{File: f.filename, Message: err.Error()}
+	}
+	return nil
+}
+
+// rollback removes the definitions of a file from the symbol table.
+func (s *session) rollback(f *file) {
+	for name, symbol := range s.symbols {
+		if symbol.file == f {
+			delete(s.symbols, name)
+		}
+	}
+	for _, numbers := range s.extensions {
+		for number, extension := range numbers {
+			if extension.file == f {
+				delete(numbers, number)
+			}
+		}
+	}
+}
+
+// link runs each stage of linking the file in turn, stopping at the first
+// stage that reports errors, and builds its descriptor.
+func (l *linker) link() {
+	if !l.file.builtin {
+		l.checkImports()
+	}
+	l.addSymbols()
+	if len(l.errs) > 0 {
+		return
+	}
+	l.resolve()
+	if len(l.errs) > 0 || l.file.builtin {
+		return
+	}
+	l.interpretOptions(false, nil)
+	if len(l.errs) > 0 {
+		return
+	}
+	l.validate()
+	if len(l.errs) > 0 {
+		return
+	}
+
+	desc, err := l.newFile()
+	if err != nil || !l.hasCustomOptions() {
+		l.file.desc = desc
+		return
+	}
+	// Custom options may be defined by the file itself, so they are
+	// interpreted against its descriptor, before it is built again with
+	// them.
+	files := new(protoregistry.Files)
+	l.registry.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		files.RegisterFile(fd)
+		return true
+	})
+	if err := files.RegisterFile(desc); err != nil {
+		l.errorAt(protofile.Position{}, "%s", err)
+		return
+	}
+	l.interpretOptions(true, files)
+	if len(l.errs) > 0 {
+		return
+	}
+	l.file.desc, _ = l.newFile()
+}
+
+// newFile builds the descriptor of the file. The checks of the earlier
+// stages make it unlikely to fail, but any error is reported, rather than
+// producing an invalid descriptor.
+func (l *linker) newFile() (protoreflect.FileDescriptor, error) {
+	desc, err := protodesc.NewFile(withoutMessageSets(l.file.proto), l.registry)
+	if err != nil {
+		l.errorAt(protofile.Position{}, "%s", err)
+	}
+	return desc, err
+}
+
+// withoutMessageSets returns the descriptor of a file with its MessageSets
+// made into ordinary messages, and without the extensions whose numbers are
+// only valid for MessageSets. protoc still supports MessageSets, but
+// protodesc only does when built with the protolegacy tag, and the
+// descriptors that it builds are only needed to look up definitions.
+func withoutMessageSets(fd *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorProto {
+	legacy := false
+	var find func([]*descriptorpb.DescriptorProto, []*descriptorpb.FieldDescriptorProto)
+	find = func(messages []*descriptorpb.DescriptorProto, extensions []*descriptorpb.FieldDescriptorProto) {
+		for _, field := range extensions {
+			legacy = legacy || field.GetNumber() > maxFieldNumber
+		}
+		for _, message := range messages {
+			legacy = legacy || message.GetOptions().GetMessageSetWireFormat()
+			find(message.NestedType, message.Extension)
+		}
+	}
+	find(fd.MessageType, fd.Extension)
+	if !legacy {
+		return fd
+	}
+
+	clone := proto.Clone(fd).(*descriptorpb.FileDescriptorProto)
+	valid := func(extensions []*descriptorpb.FieldDescriptorProto) []*descriptorpb.FieldDescriptorProto {
+		var fields []*descriptorpb.FieldDescriptorProto
+		for _, field := range extensions {
+			if field.GetNumber() <= maxFieldNumber {
+				fields = append(fields, field)
+			}
+		}
+		return fields
+	}
+	var clear func([]*descriptorpb.DescriptorProto)
+	clear = func(messages []*descriptorpb.DescriptorProto) {
+		for _, message := range messages {
+			if message.GetOptions().GetMessageSetWireFormat() {
+				message.Options.MessageSetWireFormat = nil
+				for _, extensionRange := range message.ExtensionRange {
+					extensionRange.End = proto.Int32(min(extensionRange.GetEnd(), maxFieldNumber+1))
+				}
+			}
+			message.Extension = valid(message.Extension)
+			clear(message.NestedType)
+		}
+	}
+	clone.Extension = valid(clone.Extension)
+	clear(clone.MessageType)
+	return clone
+}
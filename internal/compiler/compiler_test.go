@@ -0,0 +1,302 @@
+package compiler_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/compiler"
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+}
+
+// compile compiles the files at the paths from a directory that contains
+// the files, and returns the messages of the errors, relative to it.
+func compile(t *testing.T, files map[string]string, paths ...string) (*descriptorpb.FileDescriptorSet, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+
+	c := &compiler.Compiler{Includes: []string{dir}}
+	set, err := c.Compile(paths...)
+
+	var messages []string
+	if err != nil {
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok {
+			t.Fatalf("Compile: got error %v, want errors joined with errors.Join", err)
+		}
+		for _, err := range joined.Unwrap() {
+			var perr *protofile.Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Compile: got error %v, want *protofile.Error", err)
+			}
+			messages = append(messages, strings.TrimPrefix(err.Error(), dir+string(filepath.Separator)))
+		}
+	}
+	return set, messages
+}
+
+// compileErrors compiles a single file, a.proto, and returns the messages of
+// its errors.
+func compileErrors(t *testing.T, src string) []string {
+	t.Helper()
+	_, messages := compile(t, map[string]string{"a.proto": src}, "a.proto")
+	return messages
+}
+
+// compileFile compiles a single file, a.proto, and returns its descriptor.
+func compileFile(t *testing.T, src string) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+	set, messages := compile(t, map[string]string{"a.proto": src}, "a.proto")
+	if len(messages) > 0 {
+		t.Fatalf("Compile: unexpected errors: %v", messages)
+	}
+	return set.File[0]
+}
+
+func names(set *descriptorpb.FileDescriptorSet) []string {
+	var names []string
+	for _, fd := range set.File {
+		names = append(names, fd.GetName())
+	}
+	return names
+}
+
+func TestCompile_Imports_LinksToImportedDefinitions(t *testing.T) {
+	set, messages := compile(t, map[string]string{
+		"acme/a.proto": `
+			syntax = "proto3";
+			package acme;
+			import "acme/b.proto";
+			import "google/protobuf/timestamp.proto";
+			message A {
+				B b = 1;
+				google.protobuf.Timestamp at = 2;
+				types.Money money = 3;
+			}
+		`,
+		"acme/b.proto": `
+			syntax = "proto3";
+			package acme;
+			import public "acme/types/money.proto";
+			message B {}
+		`,
+		"acme/types/money.proto": `
+			syntax = "proto3";
+			package acme.types;
+			message Money { int64 units = 1; }
+		`,
+	}, "acme/a.proto")
+	if len(messages) > 0 {
+		t.Fatalf("Compile: unexpected errors: %v", messages)
+	}
+
+	var got []string
+	for _, field := range set.File[0].MessageType[0].Field {
+		got = append(got, field.GetTypeName())
+	}
+	want := []string{".acme.B", ".google.protobuf.Timestamp", ".acme.types.Money"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Compile: type names mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"acme/a.proto"}, names(set)); diff != "" {
+		t.Errorf("Compile: files mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_IncludeImports_ContainsImportsFirst(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.proto": `import "b.proto"; import "c.proto";`,
+		"b.proto": `import "c.proto";`,
+		"c.proto": `import "google/protobuf/empty.proto";`,
+		"d.proto": `import "b.proto";`,
+	})
+	c := &compiler.Compiler{Includes: []string{dir}, IncludeImports: true}
+
+	set, err := c.Compile("a.proto", "d.proto")
+	if err != nil {
+		t.Fatalf("Compile: unexpected error: %v", err)
+	}
+
+	want := []string{"google/protobuf/empty.proto", "c.proto", "b.proto", "a.proto", "d.proto"}
+	if diff := cmp.Diff(want, names(set)); diff != "" {
+		t.Errorf("Compile: files mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_WellKnownType_IsBuiltIn(t *testing.T) {
+	set, messages := compile(t, map[string]string{}, "google/protobuf/duration.proto")
+	if len(messages) > 0 {
+		t.Fatalf("Compile: unexpected errors: %v", messages)
+	}
+
+	got := set.File[0]
+	if got.GetPackage() != "google.protobuf" || len(got.MessageType) != 1 || got.MessageType[0].GetName() != "Duration" {
+		t.Errorf("Compile: got %v, want the descriptor of google/protobuf/duration.proto", got)
+	}
+}
+
+func TestCompile_IncludedWellKnownType_OverridesBuiltIn(t *testing.T) {
+	set, messages := compile(t, map[string]string{
+		"google/protobuf/duration.proto": `package google.protobuf; message Override {}`,
+	}, "google/protobuf/duration.proto")
+	if len(messages) > 0 {
+		t.Fatalf("Compile: unexpected errors: %v", messages)
+	}
+
+	if got := set.File[0].MessageType[0].GetName(); got != "Override" {
+		t.Errorf("Compile: got message %q, want the file from the include directory", got)
+	}
+}
+
+func TestCompile_Descriptor_MatchesProtoc(t *testing.T) {
+	got := compileFile(t, `
+		syntax = "proto3";
+		package acme.v1;
+		option go_package = "example.com/acme/v1";
+		message Order {
+			string order_id = 1 [json_name = "id"];
+			map<string, int64> counts = 2;
+			optional double total = 3;
+		}
+	`)
+
+	want := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("a.proto"),
+		Package: proto.String("acme.v1"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("order_id"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					JsonName: proto.String("id"),
+				},
+				{
+					Name:     proto.String("counts"),
+					Number:   proto.Int32(2),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".acme.v1.Order.CountsEntry"),
+					JsonName: proto.String("counts"),
+				},
+				{
+					Name:           proto.String("total"),
+					Number:         proto.Int32(3),
+					Label:          descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:           descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(),
+					JsonName:       proto.String("total"),
+					OneofIndex:     proto.Int32(0),
+					Proto3Optional: proto.Bool(true),
+				},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("CountsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("key"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						JsonName: proto.String("key"),
+					},
+					{
+						Name:     proto.String("value"),
+						Number:   proto.Int32(2),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+						JsonName: proto.String("value"),
+					},
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+			OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_total")}},
+		}},
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/acme/v1")},
+		Syntax:  proto.String("proto3"),
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("Compile: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_MissingImport_ReportsImporter(t *testing.T) {
+	_, messages := compile(t, map[string]string{
+		"a.proto": "syntax = \"proto3\";\nimport \"missing.proto\";",
+	}, "a.proto", "nonexistent.proto")
+
+	want := []string{
+		"missing.proto: File not found.",
+		`a.proto:2:1: Import "missing.proto" was not found or had errors.`,
+		"nonexistent.proto: File not found.",
+	}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_ImportWithErrors_ReportsErrorsOnce(t *testing.T) {
+	_, messages := compile(t, map[string]string{
+		"a.proto": `import "c.proto";`,
+		"b.proto": `import "c.proto";`,
+		"c.proto": `message C { optional Missing m = 1; }`,
+	}, "a.proto", "b.proto")
+
+	want := []string{
+		`c.proto:1:22: "Missing" is not defined.`,
+		`a.proto:1:1: Import "c.proto" was not found or had errors.`,
+		`b.proto:1:1: Import "c.proto" was not found or had errors.`,
+	}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_ImportCycle_ReportsCycle(t *testing.T) {
+	_, messages := compile(t, map[string]string{
+		"a.proto": `import "b.proto";`,
+		"b.proto": `import "a.proto";`,
+	}, "a.proto")
+
+	want := []string{
+		"b.proto:1:1: File recursively imports itself: a.proto -> b.proto -> a.proto",
+		`a.proto:1:1: Import "b.proto" was not found or had errors.`,
+	}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_FailedFile_DoesNotDefineSymbols(t *testing.T) {
+	_, messages := compile(t, map[string]string{
+		"a.proto": `message Dup {} message Broken { optional Missing m = 1; }`,
+		"b.proto": `message Dup {}`,
+	}, "a.proto", "b.proto")
+
+	want := []string{`a.proto:1:42: "Missing" is not defined.`}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+	}
+}
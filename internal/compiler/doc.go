@@ -0,0 +1,21 @@
+/*
+Package compiler compiles proto files into descriptors in-process, without
+running protoc.
+
+A [Compiler] parses each file into a FileDescriptorProto, links the type
+names that it references to the definitions of the files that it imports,
+and interprets its options, including custom options that are defined by
+extensions. The result is the same FileDescriptorSet that protoc
+writes with --descriptor_set_out, so that tools which inspect the schema,
+such as linters and breaking-change checks, do not need protoc installed.
+
+Files are resolved against include directories in the same way as protoc.
+The well-known types, such as google/protobuf/timestamp.proto and
+google/protobuf/descriptor.proto, are built in, and are only read from the
+include directories if one of them provides them.
+
+Errors are reported in the same "file:line:column: message" format as
+protoc, with the same messages for the errors that protoc reports, so that
+editors and scripts that understand the errors of protoc understand them too.
+*/
+package compiler
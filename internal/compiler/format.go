@@ -0,0 +1,135 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// simpleDtoa formats a double in the shortest of 15 or 17 significant digits
+// that converts back to the same value, as protoc formats the default values
+// of double fields.
+func simpleDtoa(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	s := strconv.FormatFloat(value, 'g', 15, 64)
+	if parsed, _ := strconv.ParseFloat(s, 64); parsed != value {
+		s = strconv.FormatFloat(value, 'g', 17, 64)
+	}
+	return s
+}
+
+// simpleFtoa formats a float in the shortest of 6 or 9 significant digits
+// that converts back to the same value, as protoc formats the default values
+// of float fields.
+func simpleFtoa(value float32) string {
+	switch {
+	case math.IsInf(float64(value), 1):
+		return "inf"
+	case math.IsInf(float64(value), -1):
+		return "-inf"
+	case math.IsNaN(float64(value)):
+		return "nan"
+	}
+	s := strconv.FormatFloat(float64(value), 'g', 6, 32)
+	if parsed, _ := strconv.ParseFloat(s, 32); float32(parsed) != value {
+		s = strconv.FormatFloat(float64(value), 'g', 9, 32)
+	}
+	return s
+}
+
+// parseFloat parses a value formatted by simpleDtoa, or with a leading "-".
+func parseFloat(s string) (float64, error) {
+	negative := strings.HasPrefix(s, "-")
+	value, err := strconv.ParseFloat(strings.TrimPrefix(s, "-"), 64)
+	if err != nil && !isRangeError(err) {
+		return 0, err
+	}
+	if negative {
+		value = -value
+	}
+	return value, nil
+}
+
+// isRangeError reports whether err is a strconv error for a value that is
+// out of range, which parses to an infinity or zero, as it does in C.
+func isRangeError(err error) bool {
+	return errors.Is(err, strconv.ErrRange)
+}
+
+// cEscape escapes the bytes of s in the same way as C string literals, as
+// protoc escapes the default values of bytes fields.
+func cEscape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < ' ' || c >= 0x7f {
+				fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// jsonName returns the default JSON name of a field, which is its name in
+// lower camel case.
+func jsonName(name string) string {
+	var sb strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '_':
+			upper = true
+		case upper:
+			sb.WriteString(strings.ToUpper(string(c)))
+			upper = false
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// mapEntryName returns the name of the message of the entries of a map
+// field, which is the name of the field in upper camel case, followed by
+// "Entry".
+func mapEntryName(field string) string {
+	var sb strings.Builder
+	upper := true
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; {
+		case c == '_':
+			upper = true
+		case upper:
+			sb.WriteString(strings.ToUpper(string(c)))
+			upper = false
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteString("Entry")
+	return sb.String()
+}
@@ -0,0 +1,509 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// symbolKind is the kind of definition that a symbol names.
+type symbolKind int
+
+const (
+	symbolPackage symbolKind = iota
+	symbolMessage
+	symbolEnum
+	symbolEnumValue
+	symbolField
+	symbolOneof
+	symbolService
+	symbolMethod
+)
+
+// symbol is a fully qualified name that is defined by a file.
+type symbol struct {
+	kind symbolKind
+	name string
+	file *file
+
+	// element is the descriptor of the definition, which is nil for
+	// packages.
+	element proto.Message
+
+	// parent is the enum of an enum value.
+	parent *descriptorpb.EnumDescriptorProto
+}
+
+// isType reports whether the symbol can be the type of a field.
+func (s *symbol) isType() bool {
+	return s.kind == symbolMessage || s.kind == symbolEnum
+}
+
+// isAggregate reports whether the symbol can contain other symbols.
+func (s *symbol) isAggregate() bool {
+	switch s.kind {
+	case symbolPackage, symbolMessage, symbolEnum, symbolService:
+		return true
+	}
+	return false
+}
+
+// extension is an extension that has been defined for a number of a
+// message.
+type extension struct {
+	name string
+	file *file
+}
+
+// linker links a single file to the definitions of the files that it
+// imports. Errors are collected rather than returned, so that every error of
+// a stage of linking is reported, as with protoc.
+type linker struct {
+	*session
+	file *file
+
+	// visible are the files whose definitions the file may refer to, which
+	// are itself, the files that it imports, and the files that those
+	// publicly import.
+	visible map[*file]bool
+
+	// undeclared and unresolved explain why the last lookup failed: a
+	// symbol that was found in a file that is not imported, and the name
+	// that a partly resolved name was resolved to.
+	undeclared     *file
+	undeclaredName string
+	unresolved     string
+
+	errs []error
+}
+
+func newLinker(s *session, f *file) *linker {
+	l := &linker{session: s, file: f, visible: map[*file]bool{f: true}}
+	var addPublic func(*file)
+	addPublic = func(dep *file) {
+		for _, i := range dep.proto.PublicDependency {
+			if public := dep.deps[i]; !l.visible[public] {
+				l.visible[public] = true
+				addPublic(public)
+			}
+		}
+	}
+	for _, dep := range f.deps {
+		l.visible[dep] = true
+		addPublic(dep)
+	}
+	return l
+}
+
+// errorf records an error at the part of an element of the file.
+func (l *linker) errorf(element proto.Message, part part, format string, args ...any) {
+	l.errorAt(l.file.position(element, part), format, args...)
+}
+
+// errorAt records an error at the position in the file, which may be the
+// zero position if the error is not attributable to a location.
+func (l *linker) errorAt(position protofile.Position, format string, args ...any) {
+	l.errs = append(l.errs, &protofile.Error{
+		File:     l.file.filename,
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkImports checks that no file is imported more than once.
+func (l *linker) checkImports() {
+	seen := make(map[string]bool)
+	for _, dep := range l.file.proto.Dependency {
+		if seen[dep] {
+			l.errorAt(protofile.Position{}, "Import \"%s\" was listed twice.", dep)
+		}
+		seen[dep] = true
+	}
+}
+
+// addSymbols adds every definition of the file to the symbol table, and
+// checks the numbers and ranges that do not depend on other files.
+func (l *linker) addSymbols() {
+	fd := l.file.proto
+	scope := fd.GetPackage()
+	if scope != "" {
+		l.addPackage(scope)
+	}
+	for _, message := range fd.MessageType {
+		l.addMessage(scope, message)
+	}
+	for _, enum := range fd.EnumType {
+		l.addEnum(scope, enum)
+	}
+	for _, service := range fd.Service {
+		name := join(scope, service.GetName())
+		l.addSymbol(name, symbolService, service)
+		for _, method := range service.Method {
+			l.addSymbol(join(name, method.GetName()), symbolMethod, method)
+		}
+	}
+	for _, field := range fd.Extension {
+		l.addField(scope, field)
+	}
+}
+
+func (l *linker) addPackage(name string) {
+	if existing, ok := l.symbols[name]; ok {
+		if existing.kind != symbolPackage {
+			l.errorf(l.file.proto, partName, "\"%s\" is already defined (as something other than a package) in file \"%s\".", name, existing.file.path)
+		}
+		return
+	}
+	l.symbols[name] = &symbol{kind: symbolPackage, name: name, file: l.file}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		l.addPackage(name[:i])
+	}
+}
+
+// addSymbol adds a definition to the symbol table, and reports whether it was
+// added, rather than conflicting with an existing symbol.
+func (l *linker) addSymbol(name string, kind symbolKind, element proto.Message) bool {
+	if existing, ok := l.symbols[name]; ok {
+		switch i := strings.LastIndexByte(name, '.'); {
+		case existing.file != l.file:
+			l.errorf(element, partName, "\"%s\" is already defined in file \"%s\".", name, existing.file.path)
+		case i < 0:
+			l.errorf(element, partName, "\"%s\" is already defined.", name)
+		default:
+			l.errorf(element, partName, "\"%s\" is already defined in \"%s\".", name[i+1:], name[:i])
+		}
+		return false
+	}
+	l.symbols[name] = &symbol{kind: kind, name: name, file: l.file, element: element}
+	return true
+}
+
+func (l *linker) addMessage(scope string, message *descriptorpb.DescriptorProto) {
+	name := join(scope, message.GetName())
+	l.addSymbol(name, symbolMessage, message)
+	for _, oneof := range message.OneofDecl {
+		l.addSymbol(join(name, oneof.GetName()), symbolOneof, oneof)
+	}
+	for _, field := range message.Field {
+		l.addField(name, field)
+	}
+	for _, nested := range message.NestedType {
+		l.addMessage(name, nested)
+	}
+	for _, enum := range message.EnumType {
+		l.addEnum(name, enum)
+	}
+	for _, field := range message.Extension {
+		l.addField(name, field)
+	}
+	l.checkMessage(name, message)
+}
+
+func (l *linker) addField(scope string, field *descriptorpb.FieldDescriptorProto) {
+	name := join(scope, field.GetName())
+	l.addSymbol(name, symbolField, field)
+	l.checkField(name, field)
+}
+
+// addEnum adds an enum and its values, which are siblings of the enum rather
+// than children of it, as they are in C++.
+func (l *linker) addEnum(scope string, enum *descriptorpb.EnumDescriptorProto) {
+	l.addSymbol(join(scope, enum.GetName()), symbolEnum, enum)
+	values := make(map[string]bool)
+	for _, value := range enum.Value {
+		name := join(scope, value.GetName())
+		added := l.addSymbol(name, symbolEnumValue, value)
+		if added {
+			l.symbols[name].parent = enum
+		}
+		if values[value.GetName()] {
+			continue
+		}
+		values[value.GetName()] = true
+		if !added {
+			outer := "the global scope"
+			if scope != "" {
+				outer = "\"" + scope + "\""
+			}
+			l.errorf(value, partName, "Note that enum values use C++ scoping rules, meaning that enum values are siblings of their type, not children of it.  Therefore, \"%s\" must be unique within %s, not just within \"%s\".", value.GetName(), outer, enum.GetName())
+		}
+	}
+	l.checkEnum(enum)
+}
+
+// resolve resolves every type name that the file refers to, and checks the
+// numbers of fields and extensions.
+func (l *linker) resolve() {
+	fd := l.file.proto
+	scope := fd.GetPackage()
+	for _, message := range fd.MessageType {
+		l.resolveMessage(scope, message)
+	}
+	for _, field := range fd.Extension {
+		l.resolveField(scope, field)
+	}
+	for _, service := range fd.Service {
+		name := join(scope, service.GetName())
+		for _, method := range service.Method {
+			l.resolveMethod(join(name, method.GetName()), method)
+		}
+	}
+}
+
+func (l *linker) resolveMessage(scope string, message *descriptorpb.DescriptorProto) {
+	name := join(scope, message.GetName())
+	for _, nested := range message.NestedType {
+		l.resolveMessage(name, nested)
+	}
+	numbers := make(map[int32]*descriptorpb.FieldDescriptorProto)
+	for _, field := range message.Field {
+		l.resolveField(name, field)
+		if existing, ok := numbers[field.GetNumber()]; ok {
+			l.errorf(field, partNumber, "Field number %d has already been used in \"%s\" by field \"%s\".", field.GetNumber(), name, existing.GetName())
+			continue
+		}
+		numbers[field.GetNumber()] = field
+	}
+	for _, field := range message.Extension {
+		l.resolveField(name, field)
+	}
+}
+
+// resolveField resolves the extendee and type of a field, and sets its JSON
+// name and normalizes its default value as protoc does.
+func (l *linker) resolveField(scope string, field *descriptorpb.FieldDescriptorProto) {
+	name := join(scope, field.GetName())
+	if field.JsonName == nil {
+		field.JsonName = proto.String(jsonName(field.GetName()))
+	}
+	if field.Extendee != nil && !l.resolveExtendee(name, field) {
+		return
+	}
+	if field.TypeName != nil && !l.resolveType(name, field) {
+		return
+	}
+
+	if field.DefaultValue == nil {
+		return
+	}
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT:
+		if value, err := parseFloat(field.GetDefaultValue()); err == nil {
+			field.DefaultValue = proto.String(simpleFtoa(float32(value)))
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		if value, err := parseFloat(field.GetDefaultValue()); err == nil {
+			field.DefaultValue = proto.String(simpleDtoa(value))
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		if value, err := strconv.ParseInt(field.GetDefaultValue(), 10, 64); err == nil {
+			field.DefaultValue = proto.String(strconv.FormatInt(value, 10))
+		}
+	}
+}
+
+func (l *linker) resolveExtendee(name string, field *descriptorpb.FieldDescriptorProto) bool {
+	extendee := l.lookup(field.GetExtendee(), name, false)
+	if extendee == nil {
+		l.notDefined(field, partExtendee, field.GetExtendee())
+		return false
+	}
+	if extendee.kind != symbolMessage {
+		l.errorf(field, partExtendee, "\"%s\" is not a message type.", field.GetExtendee())
+		return false
+	}
+	field.Extendee = proto.String("." + extendee.name)
+
+	message := extendee.element.(*descriptorpb.DescriptorProto)
+	number := field.GetNumber()
+	declared := false
+	for _, extensionRange := range message.ExtensionRange {
+		if extensionRange.GetStart() <= number && number < extensionRange.GetEnd() {
+			declared = true
+		}
+	}
+	if !declared {
+		l.errorf(field, partNumber, "\"%s\" does not declare %d as an extension number.", extendee.name, number)
+	}
+
+	numbers := l.extensions[extendee.name]
+	if numbers == nil {
+		numbers = make(map[int32]*extension)
+		l.extensions[extendee.name] = numbers
+	}
+	switch existing := numbers[number]; {
+	case existing == nil:
+		numbers[number] = &extension{name: name, file: l.file}
+	case existing.file == l.file:
+		l.errorf(field, partNumber, "Extension number %d has already been used in \"%s\" by extension \"%s\".", number, extendee.name, existing.name)
+	default:
+		l.errorf(field, partNumber, "Extension number %d has already been used in \"%s\" by extension \"%s\" defined in %s.", number, extendee.name, existing.name, existing.file.path)
+	}
+	return true
+}
+
+func (l *linker) resolveType(name string, field *descriptorpb.FieldDescriptorProto) bool {
+	typ := l.lookup(field.GetTypeName(), name, true)
+	if typ == nil {
+		l.notDefined(field, partType, field.GetTypeName())
+		return false
+	}
+	if field.Type == nil {
+		switch typ.kind {
+		case symbolMessage:
+			field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		case symbolEnum:
+			field.Type = descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum()
+		default:
+			l.errorf(field, partType, "\"%s\" is not a type.", field.GetTypeName())
+			return false
+		}
+	}
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		if typ.kind != symbolMessage {
+			l.errorf(field, partType, "\"%s\" is not a message type.", field.GetTypeName())
+			return false
+		}
+		if field.DefaultValue != nil {
+			l.errorf(field, partDefault, "Messages can't have default values.")
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		if typ.kind != symbolEnum {
+			l.errorf(field, partType, "\"%s\" is not an enum type.", field.GetTypeName())
+			return false
+		}
+		if field.DefaultValue != nil {
+			l.checkEnumDefault(field, typ)
+		}
+	}
+	field.TypeName = proto.String("." + typ.name)
+	return true
+}
+
+// checkEnumDefault checks that the default value of an enum field names a
+// value of its enum.
+func (l *linker) checkEnumDefault(field *descriptorpb.FieldDescriptorProto, enum *symbol) {
+	value := field.GetDefaultValue()
+	if !isIdentifier(value) {
+		l.errorf(field, partDefault, "Default value for an enum field must be an identifier.")
+		return
+	}
+	if symbol := l.lookup(value, enum.name, false); symbol == nil || symbol.parent != enum.element {
+		l.errorf(field, partDefault, "Enum type \"%s\" has no value named \"%s\".", enum.name, value)
+	}
+}
+
+func (l *linker) resolveMethod(name string, method *descriptorpb.MethodDescriptorProto) {
+	for _, part := range []part{partInput, partOutput} {
+		typeName := &method.InputType
+		if part == partOutput {
+			typeName = &method.OutputType
+		}
+		typ := l.lookup(*(*typeName), name, false)
+		switch {
+		case typ == nil:
+			l.notDefined(method, part, *(*typeName))
+		case typ.kind != symbolMessage:
+			l.errorf(method, part, "\"%s\" is not a message type.", *(*typeName))
+		default:
+			*typeName = proto.String("." + typ.name)
+		}
+	}
+}
+
+// lookup looks up a name that is referred to from within the scope of a
+// fully qualified name, as protoc does. Relative names are searched for in
+// the innermost scope first, and the first part of a compound name, such as
+// the Foo of Foo.Bar, determines the scope that the rest of it is searched
+// for in. If types is true, symbols that are not types are skipped when
+// searching for a name with a single part.
+func (l *linker) lookup(name, scope string, types bool) *symbol {
+	l.undeclared, l.undeclaredName, l.unresolved = nil, "", ""
+	if strings.HasPrefix(name, ".") {
+		return l.find(name[1:])
+	}
+	first, _, compound := strings.Cut(name, ".")
+	for {
+		i := strings.LastIndexByte(scope, '.')
+		if i < 0 {
+			return l.find(name)
+		}
+		scope = scope[:i]
+		found := l.find(scope + "." + first)
+		switch {
+		case found == nil:
+		case compound && found.isAggregate():
+			full := scope + "." + name
+			found = l.find(full)
+			if found == nil {
+				l.unresolved = full
+			}
+			return found
+		case !compound && (!types || found.isType()):
+			return found
+		}
+	}
+}
+
+// find returns the symbol with the fully qualified name, if it is defined in
+// a file that is visible to the file being linked.
+func (l *linker) find(name string) *symbol {
+	found := l.symbols[name]
+	if found == nil || l.visible[found.file] {
+		return found
+	}
+	if found.kind == symbolPackage {
+		// A package may be defined by several files, of which only the first
+		// is recorded.
+		for visible := range l.visible {
+			if pkg := visible.proto.GetPackage(); pkg == name || strings.HasPrefix(pkg, name+".") {
+				return found
+			}
+		}
+	}
+	l.undeclared, l.undeclaredName = found.file, name
+	return nil
+}
+
+// notDefined reports that a name could not be resolved, explaining why if the
+// last lookup found a reason.
+func (l *linker) notDefined(element proto.Message, part part, name string) {
+	if l.undeclared == nil && l.unresolved == "" {
+		l.errorf(element, part, "\"%s\" is not defined.", name)
+		return
+	}
+	if l.undeclared != nil {
+		l.errorf(element, part, "\"%s\" seems to be defined in \"%s\", which is not imported by \"%s\".  To use it here, please add the necessary import.", l.undeclaredName, l.undeclared.path, l.file.path)
+	}
+	if l.unresolved != "" {
+		l.errorf(element, part, "\"%s\" is resolved to \"%s\", which is not defined. The innermost scope is searched first in name resolution. Consider using a leading '.'(i.e., \".%s\") to start from the outermost scope.", name, l.unresolved, name)
+	}
+}
+
+// join returns the fully qualified name of a definition within a scope.
+func join(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// isIdentifier reports whether s is a valid identifier.
+func isIdentifier(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompile_LinkErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name:  "undefined type",
+			files: map[string]string{"a.proto": `message Foo { optional Bar b = 1; }`},
+			want:  []string{`a.proto:1:24: "Bar" is not defined.`},
+		},
+		{
+			name:  "not a type",
+			files: map[string]string{"a.proto": `message Foo { optional int32 a = 1; } message Bar { optional Foo.a x = 1; }`},
+			want:  []string{`a.proto:1:62: "Foo.a" is not a type.`},
+		},
+		{
+			name:  "not a message type",
+			files: map[string]string{"a.proto": `service S { rpc M(E) returns (E); } enum E { A = 0; }`},
+			want: []string{
+				`a.proto:1:19: "E" is not a message type.`,
+				`a.proto:1:31: "E" is not a message type.`,
+			},
+		},
+		{
+			name:  "partly resolved name",
+			files: map[string]string{"a.proto": `package p.q; message M {} message p { optional p.M m = 1; }`},
+			want:  []string{`a.proto:1:48: "p.M" is resolved to "p.q.p.M", which is not defined. The innermost scope is searched first in name resolution. Consider using a leading '.'(i.e., ".p.M") to start from the outermost scope.`},
+		},
+		{
+			name: "not imported",
+			files: map[string]string{
+				"a.proto": `import "b.proto"; message A { optional C c = 1; }`,
+				"b.proto": `import "c.proto";`,
+				"c.proto": `message C {}`,
+			},
+			want: []string{`a.proto:1:40: "C" seems to be defined in "c.proto", which is not imported by "a.proto".  To use it here, please add the necessary import.`},
+		},
+		{
+			name:  "already defined",
+			files: map[string]string{"a.proto": `message Foo {} message Foo {}`},
+			want:  []string{`a.proto:1:24: "Foo" is already defined.`},
+		},
+		{
+			name: "already defined in another file",
+			files: map[string]string{
+				"a.proto": `package p; import "b.proto"; message Foo {}`,
+				"b.proto": `package p; message Foo {}`,
+			},
+			want: []string{`a.proto:1:38: "p.Foo" is already defined in file "b.proto".`},
+		},
+		{
+			name:  "enum value scoping",
+			files: map[string]string{"a.proto": `enum E { A = 0; } enum F { A = 0; }`},
+			want: []string{
+				`a.proto:1:28: "A" is already defined.`,
+				`a.proto:1:28: Note that enum values use C++ scoping rules, meaning that enum values are siblings of their type, not children of it.  Therefore, "A" must be unique within the global scope, not just within "F".`,
+			},
+		},
+		{
+			name:  "duplicate field number",
+			files: map[string]string{"a.proto": `message Foo { optional int32 a = 1; optional int32 b = 1; }`},
+			want:  []string{`a.proto:1:56: Field number 1 has already been used in "Foo" by field "a".`},
+		},
+		{
+			name:  "undeclared extension number",
+			files: map[string]string{"a.proto": `message Foo { extensions 10 to 20; } extend Foo { optional int32 x = 30; }`},
+			want:  []string{`a.proto:1:70: "Foo" does not declare 30 as an extension number.`},
+		},
+		{
+			name: "duplicate extension number",
+			files: map[string]string{
+				"a.proto": `import "b.proto"; extend Foo { optional int32 x = 10; }`,
+				"b.proto": `message Foo { extensions 10 to 20; } extend Foo { optional int32 y = 10; }`,
+			},
+			want: []string{`a.proto:1:51: Extension number 10 has already been used in "Foo" by extension "y" defined in b.proto.`},
+		},
+		{
+			name:  "undefined enum default",
+			files: map[string]string{"a.proto": `message Foo { optional E e = 1 [default = B]; } enum E { A = 0; }`},
+			want:  []string{`a.proto:1:43: Enum type "E" has no value named "B".`},
+		},
+		{
+			name:  "message default",
+			files: map[string]string{"a.proto": `message Foo { optional Foo f = 1 [default = 1]; }`},
+			want:  []string{`a.proto:1:45: Messages can't have default values.`},
+		},
+		{
+			name: "duplicate import",
+			files: map[string]string{
+				"a.proto": `import "b.proto"; import "b.proto";`,
+				"b.proto": ``,
+			},
+			want: []string{`a.proto: Import "b.proto" was listed twice.`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, got := compile(t, tc.files, "a.proto")
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
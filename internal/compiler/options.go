@@ -0,0 +1,467 @@
+package compiler
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// optionsTarget is the options of an element of a file, whose uninterpreted
+// options are interpreted into its fields.
+type optionsTarget struct {
+	// options is the options message, such as a *descriptorpb.FieldOptions.
+	options proto.Message
+
+	// scope is the fully qualified name of the element, which the names of
+	// custom options are resolved relative to.
+	scope string
+}
+
+// uninterpreted returns the uninterpreted options of the target.
+func (t *optionsTarget) uninterpreted() []*descriptorpb.UninterpretedOption {
+	return t.options.(interface {
+		GetUninterpretedOption() []*descriptorpb.UninterpretedOption
+	}).GetUninterpretedOption()
+}
+
+// setUninterpreted replaces the uninterpreted options of the target.
+func (t *optionsTarget) setUninterpreted(options []*descriptorpb.UninterpretedOption) {
+	m := t.options.ProtoReflect()
+	field := m.Descriptor().Fields().ByName("uninterpreted_option")
+	m.Clear(field)
+	if len(options) == 0 {
+		return
+	}
+	list := m.Mutable(field).List()
+	for _, option := range options {
+		list.Append(protoreflect.ValueOfMessage(option.ProtoReflect()))
+	}
+}
+
+// optionsTargets returns the options of every element of the file that has
+// any.
+func (l *linker) optionsTargets() []*optionsTarget {
+	var targets []*optionsTarget
+	add := func(options proto.Message, scope string) {
+		if options.ProtoReflect().IsValid() {
+			targets = append(targets, &optionsTarget{options: options, scope: scope})
+		}
+	}
+	var addEnum func(scope string, enum *descriptorpb.EnumDescriptorProto)
+	addEnum = func(scope string, enum *descriptorpb.EnumDescriptorProto) {
+		add(enum.GetOptions(), join(scope, enum.GetName()))
+		for _, value := range enum.Value {
+			add(value.GetOptions(), join(scope, value.GetName()))
+		}
+	}
+	var addMessage func(scope string, message *descriptorpb.DescriptorProto)
+	addMessage = func(scope string, message *descriptorpb.DescriptorProto) {
+		name := join(scope, message.GetName())
+		add(message.GetOptions(), name)
+		for _, field := range message.Field {
+			add(field.GetOptions(), join(name, field.GetName()))
+		}
+		for _, oneof := range message.OneofDecl {
+			add(oneof.GetOptions(), join(name, oneof.GetName()))
+		}
+		for _, extensionRange := range message.ExtensionRange {
+			add(extensionRange.GetOptions(), name)
+		}
+		for _, nested := range message.NestedType {
+			addMessage(name, nested)
+		}
+		for _, enum := range message.EnumType {
+			addEnum(name, enum)
+		}
+		for _, field := range message.Extension {
+			add(field.GetOptions(), join(name, field.GetName()))
+		}
+	}
+
+	fd := l.file.proto
+	scope := fd.GetPackage()
+	// File options are resolved from within the package, as though they were
+	// defined in it.
+	add(fd.GetOptions(), scope+".")
+	for _, message := range fd.MessageType {
+		addMessage(scope, message)
+	}
+	for _, enum := range fd.EnumType {
+		addEnum(scope, enum)
+	}
+	for _, service := range fd.Service {
+		name := join(scope, service.GetName())
+		add(service.GetOptions(), name)
+		for _, method := range service.Method {
+			add(method.GetOptions(), join(name, method.GetName()))
+		}
+	}
+	for _, field := range fd.Extension {
+		add(field.GetOptions(), join(scope, field.GetName()))
+	}
+	return targets
+}
+
+// isCustom reports whether an option refers to an extension in any part of
+// its name.
+func isCustom(option *descriptorpb.UninterpretedOption) bool {
+	for _, part := range option.Name {
+		if part.GetIsExtension() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCustomOptions reports whether any element of the file has custom
+// options, which are left uninterpreted until the file has been built.
+func (l *linker) hasCustomOptions() bool {
+	for _, target := range l.optionsTargets() {
+		for _, option := range target.uninterpreted() {
+			if isCustom(option) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// interpretOptions interprets the uninterpreted options of the file that are
+// either custom or not. Standard options are interpreted first, since the
+// file cannot be built until they are, and custom options are interpreted
+// once it has been, since they may be defined by the file itself.
+//
+// As with protoc, each option is encoded as the fields that it sets, which
+// are then merged into the options. Custom options remain unknown fields, in
+// the order that they are set.
+func (l *linker) interpretOptions(custom bool, files *protoregistry.Files) {
+	interpreter := &interpreter{linker: l, files: files}
+	if files != nil {
+		interpreter.types = dynamicpb.NewTypes(files)
+	}
+	for _, target := range l.optionsTargets() {
+		var remaining []*descriptorpb.UninterpretedOption
+		var encoded []byte
+		set := make(map[string]bool)
+		for _, option := range target.uninterpreted() {
+			if isCustom(option) != custom {
+				remaining = append(remaining, option)
+				continue
+			}
+			encoded = append(encoded, interpreter.interpret(target, option, set)...)
+		}
+		target.setUninterpreted(remaining)
+		unmarshal := proto.UnmarshalOptions{Merge: true, Resolver: new(protoregistry.Types)}
+		if err := unmarshal.Unmarshal(encoded, target.options); err != nil {
+			l.errorf(l.file.proto, partName, "Some options could not be correctly parsed using the proto descriptors compiled into this binary.")
+		}
+	}
+}
+
+// interpreter interprets the uninterpreted options of a file.
+type interpreter struct {
+	*linker
+
+	// files are the files that extensions are looked up in, and types
+	// resolve the extensions and messages of aggregate values. They are nil
+	// when standard options are interpreted.
+	files *protoregistry.Files
+	types *dynamicpb.Types
+}
+
+// interpret returns the encoding of the fields that an option sets, or nil
+// if it is invalid. The paths of the options that have been set are recorded
+// in set, so that setting a singular option twice is an error.
+func (i *interpreter) interpret(target *optionsTarget, option *descriptorpb.UninterpretedOption, set map[string]bool) []byte {
+	if first := option.Name[0]; !first.GetIsExtension() && first.GetNamePart() == "uninterpreted_option" {
+		i.errorf(option, partName, "Option must not use reserved name \"uninterpreted_option\".")
+		return nil
+	}
+
+	message := target.options.ProtoReflect().Descriptor()
+	var path []protoreflect.FieldDescriptor
+	var name strings.Builder
+	for n, part := range option.Name {
+		if n > 0 {
+			name.WriteByte('.')
+		}
+		var field protoreflect.FieldDescriptor
+		i.unresolved = ""
+		if part.GetIsExtension() {
+			name.WriteString("(" + part.GetNamePart() + ")")
+			if symbol := i.lookup(part.GetNamePart(), target.scope, false); symbol != nil && symbol.kind == symbolField {
+				if descriptor, err := i.files.FindDescriptorByName(protoreflect.FullName(symbol.name)); err == nil {
+					field, _ = descriptor.(protoreflect.FieldDescriptor)
+				}
+			}
+		} else {
+			name.WriteString(part.GetNamePart())
+			field = message.Fields().ByName(protoreflect.Name(part.GetNamePart()))
+		}
+
+		switch {
+		case field == nil && i.unresolved != "":
+			i.errorf(option, partName, "Option \"%s\" is resolved to \"(%s)\", which is not defined. The innermost scope is searched first in name resolution. Consider using a leading '.'(i.e., \"(.%s\") to start from the outermost scope.", name.String(), i.unresolved, name.String()[1:])
+			return nil
+		case field == nil:
+			i.errorf(option, partName, "Option \"%s\" unknown. Ensure that your proto definition file imports the proto which defines the option.", name.String())
+			return nil
+		case field.ContainingMessage().FullName() != message.FullName():
+			i.errorf(option, partName, "Option field \"%s\" is not a field or extension of message \"%s\".", name.String(), message.Name())
+			return nil
+		}
+		if n < len(option.Name)-1 {
+			switch {
+			case field.Message() == nil:
+				i.errorf(option, partName, "Option \"%s\" is an atomic type, not a message.", name.String())
+				return nil
+			case field.IsList():
+				i.errorf(option, partName, "Option field \"%s\" is a repeated message. Repeated message options must be initialized using an aggregate value.", name.String())
+				return nil
+			}
+			message = field.Message()
+		}
+		path = append(path, field)
+	}
+
+	field := path[len(path)-1]
+	if !field.IsList() {
+		numbers := make([]string, len(path))
+		for n, f := range path {
+			numbers[n] = strconv.Itoa(int(f.Number()))
+		}
+		key := strings.Join(numbers, ".")
+		for existing := range set {
+			if existing == key || strings.HasPrefix(existing, key+".") {
+				i.errorf(option, partName, "Option \"%s\" was already set.", name.String())
+				return nil
+			}
+		}
+		set[key] = true
+	}
+
+	encoded, ok := i.value(option, field)
+	if !ok {
+		return nil
+	}
+	for n := len(path) - 2; n >= 0; n-- {
+		encoded = appendMessage(nil, path[n], encoded)
+	}
+	return encoded
+}
+
+// value returns the encoding of the field that an option sets to its value.
+func (i *interpreter) value(option *descriptorpb.UninterpretedOption, field protoreflect.FieldDescriptor) ([]byte, bool) {
+	name := field.FullName()
+	number := field.Number()
+	var b []byte
+	switch kind := field.Kind(); kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, ok := i.signed(option, math.MinInt32, math.MaxInt32, "int32", name)
+		if !ok {
+			return nil, false
+		}
+		switch kind {
+		case protoreflect.Int32Kind:
+			b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), uint64(v))
+		case protoreflect.Sint32Kind:
+			b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), protowire.EncodeZigZag(v))
+		default:
+			b = protowire.AppendFixed32(protowire.AppendTag(b, number, protowire.Fixed32Type), uint32(v))
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, ok := i.signed(option, math.MinInt64, math.MaxInt64, "int64", name)
+		if !ok {
+			return nil, false
+		}
+		switch kind {
+		case protoreflect.Int64Kind:
+			b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), uint64(v))
+		case protoreflect.Sint64Kind:
+			b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), protowire.EncodeZigZag(v))
+		default:
+			b = protowire.AppendFixed64(protowire.AppendTag(b, number, protowire.Fixed64Type), uint64(v))
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, ok := i.unsigned(option, math.MaxUint32, "uint32", name)
+		if !ok {
+			return nil, false
+		}
+		if kind == protoreflect.Uint32Kind {
+			b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), v)
+		} else {
+			b = protowire.AppendFixed32(protowire.AppendTag(b, number, protowire.Fixed32Type), uint32(v))
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, ok := i.unsigned(option, math.MaxUint64, "uint64", name)
+		if !ok {
+			return nil, false
+		}
+		if kind == protoreflect.Uint64Kind {
+			b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), v)
+		} else {
+			b = protowire.AppendFixed64(protowire.AppendTag(b, number, protowire.Fixed64Type), v)
+		}
+	case protoreflect.FloatKind:
+		v, ok := i.float(option, "float", name)
+		if !ok {
+			return nil, false
+		}
+		b = protowire.AppendFixed32(protowire.AppendTag(b, number, protowire.Fixed32Type), math.Float32bits(float32(v)))
+	case protoreflect.DoubleKind:
+		v, ok := i.float(option, "double", name)
+		if !ok {
+			return nil, false
+		}
+		b = protowire.AppendFixed64(protowire.AppendTag(b, number, protowire.Fixed64Type), math.Float64bits(v))
+	case protoreflect.BoolKind:
+		var v uint64
+		switch option.GetIdentifierValue() {
+		case "true":
+			v = 1
+		case "false":
+		default:
+			i.errorf(option, partValue, "Value must be \"true\" or \"false\" for boolean option \"%s\".", name)
+			return nil, false
+		}
+		b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), v)
+	case protoreflect.EnumKind:
+		v, ok := i.enum(option, field)
+		if !ok {
+			return nil, false
+		}
+		b = protowire.AppendVarint(protowire.AppendTag(b, number, protowire.VarintType), uint64(v))
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		if option.StringValue == nil {
+			i.errorf(option, partValue, "Value must be quoted string for string option \"%s\".", name)
+			return nil, false
+		}
+		b = protowire.AppendBytes(protowire.AppendTag(b, number, protowire.BytesType), option.GetStringValue())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v, ok := i.aggregate(option, field)
+		if !ok {
+			return nil, false
+		}
+		b = appendMessage(b, field, v)
+	}
+	return b, true
+}
+
+// signed returns the value of an option for a signed integer field, which
+// must be within [min, max].
+func (i *interpreter) signed(option *descriptorpb.UninterpretedOption, min, max int64, typ string, name protoreflect.FullName) (int64, bool) {
+	switch {
+	case option.PositiveIntValue != nil:
+		if option.GetPositiveIntValue() > uint64(max) {
+			i.errorf(option, partValue, "Value out of range for %s option \"%s\".", typ, name)
+			return 0, false
+		}
+		return int64(option.GetPositiveIntValue()), true
+	case option.NegativeIntValue != nil:
+		if option.GetNegativeIntValue() < min {
+			i.errorf(option, partValue, "Value out of range for %s option \"%s\".", typ, name)
+			return 0, false
+		}
+		return option.GetNegativeIntValue(), true
+	}
+	i.errorf(option, partValue, "Value must be integer for %s option \"%s\".", typ, name)
+	return 0, false
+}
+
+// unsigned returns the value of an option for an unsigned integer field,
+// which must be no greater than max.
+func (i *interpreter) unsigned(option *descriptorpb.UninterpretedOption, max uint64, typ string, name protoreflect.FullName) (uint64, bool) {
+	if option.PositiveIntValue == nil {
+		i.errorf(option, partValue, "Value must be non-negative integer for %s option \"%s\".", typ, name)
+		return 0, false
+	}
+	if option.GetPositiveIntValue() > max {
+		i.errorf(option, partValue, "Value out of range for %s option \"%s\".", typ, name)
+		return 0, false
+	}
+	return option.GetPositiveIntValue(), true
+}
+
+// float returns the value of an option for a float or double field.
+func (i *interpreter) float(option *descriptorpb.UninterpretedOption, typ string, name protoreflect.FullName) (float64, bool) {
+	switch {
+	case option.DoubleValue != nil:
+		return option.GetDoubleValue(), true
+	case option.PositiveIntValue != nil:
+		return float64(option.GetPositiveIntValue()), true
+	case option.NegativeIntValue != nil:
+		return float64(option.GetNegativeIntValue()), true
+	case option.GetIdentifierValue() == "inf":
+		return math.Inf(1), true
+	case option.GetIdentifierValue() == "nan":
+		return math.NaN(), true
+	}
+	i.errorf(option, partValue, "Value must be number for %s option \"%s\".", typ, name)
+	return 0, false
+}
+
+// enum returns the number of the value of an option for an enum field.
+func (i *interpreter) enum(option *descriptorpb.UninterpretedOption, field protoreflect.FieldDescriptor) (protoreflect.EnumNumber, bool) {
+	if option.IdentifierValue == nil {
+		i.errorf(option, partValue, "Value must be identifier for enum-valued option \"%s\".", field.FullName())
+		return 0, false
+	}
+	enum := field.Enum()
+	name := option.GetIdentifierValue()
+	if value := enum.Values().ByName(protoreflect.Name(name)); value != nil {
+		return value.Number(), true
+	}
+	// Enum values are siblings of their enum, so a value of another enum in
+	// the same scope has the same fully qualified name that this one would.
+	sibling := enum.FullName().Parent().Append(protoreflect.Name(name))
+	if symbol := i.symbols[string(sibling)]; symbol != nil && symbol.kind == symbolEnumValue {
+		i.errorf(option, partValue, "Enum type \"%s\" has no value named \"%s\" for option \"%s\". This appears to be a value from a sibling type.", enum.FullName(), name, field.FullName())
+		return 0, false
+	}
+	i.errorf(option, partValue, "Enum type \"%s\" has no value named \"%s\" for option \"%s\".", enum.FullName(), name, field.FullName())
+	return 0, false
+}
+
+// aggregate returns the encoding of the message that the text format value of
+// an option for a message field sets.
+func (i *interpreter) aggregate(option *descriptorpb.UninterpretedOption, field protoreflect.FieldDescriptor) ([]byte, bool) {
+	if option.AggregateValue == nil {
+		i.errorf(option, partValue, "Option \"%s\" is a message. To set the entire message, use syntax like \"%s = { <proto text format> }\". To set fields within it, use syntax like \"%s.foo = value\".", field.FullName(), field.Name(), field.Name())
+		return nil, false
+	}
+	message := dynamicpb.NewMessage(field.Message())
+	unmarshal := prototext.UnmarshalOptions{}
+	if i.types != nil {
+		unmarshal.Resolver = i.types
+	}
+	if err := unmarshal.Unmarshal([]byte(option.GetAggregateValue()), message); err != nil {
+		i.errorf(option, partValue, "Error while parsing option value for \"%s\": %s", field.Name(), strings.TrimPrefix(err.Error(), "proto: "))
+		return nil, false
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		i.errorf(option, partValue, "Error while parsing option value for \"%s\": %s", field.Name(), strings.TrimPrefix(err.Error(), "proto: "))
+		return nil, false
+	}
+	return b, true
+}
+
+// appendMessage appends the encoding of a message field, whose contents are
+// already encoded, to b.
+func appendMessage(b []byte, field protoreflect.FieldDescriptor, encoded []byte) []byte {
+	if field.Kind() == protoreflect.GroupKind {
+		b = protowire.AppendTag(b, field.Number(), protowire.StartGroupType)
+		b = append(b, encoded...)
+		return protowire.AppendTag(b, field.Number(), protowire.EndGroupType)
+	}
+	b = protowire.AppendTag(b, field.Number(), protowire.BytesType)
+	return protowire.AppendBytes(b, encoded)
+}
@@ -0,0 +1,179 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCompile_StandardOptions_SetsFields(t *testing.T) {
+	got := compileFile(t, `
+		syntax = "proto3";
+		option java_package = "com.example";
+		option optimize_for = CODE_SIZE;
+		option cc_enable_arenas = true;
+		message Foo {
+			option deprecated = true;
+			repeated int32 a = 1 [packed = false, deprecated = true];
+		}
+	`)
+
+	wantFile := &descriptorpb.FileOptions{
+		JavaPackage:    proto.String("com.example"),
+		OptimizeFor:    descriptorpb.FileOptions_CODE_SIZE.Enum(),
+		CcEnableArenas: proto.Bool(true),
+	}
+	if diff := cmp.Diff(wantFile, got.GetOptions(), protocmp.Transform()); diff != "" {
+		t.Errorf("Compile: file options mismatch (-want +got):\n%s", diff)
+	}
+	wantMessage := &descriptorpb.MessageOptions{Deprecated: proto.Bool(true)}
+	if diff := cmp.Diff(wantMessage, got.MessageType[0].GetOptions(), protocmp.Transform()); diff != "" {
+		t.Errorf("Compile: message options mismatch (-want +got):\n%s", diff)
+	}
+	wantField := &descriptorpb.FieldOptions{Packed: proto.Bool(false), Deprecated: proto.Bool(true)}
+	if diff := cmp.Diff(wantField, got.MessageType[0].Field[0].GetOptions(), protocmp.Transform()); diff != "" {
+		t.Errorf("Compile: field options mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompile_CustomOptions_AreUnknownFieldsInOrder(t *testing.T) {
+	got := compileFile(t, `
+		syntax = "proto3";
+		package acme;
+		import "google/protobuf/descriptor.proto";
+		message Rule {
+			string name = 1;
+			repeated int32 values = 2;
+		}
+		extend google.protobuf.FileOptions {
+			Rule rule = 50001;
+			sint32 level = 50002;
+			repeated Rule rules = 50003;
+		}
+		option (level) = -2;
+		option (rule).name = "a";
+		option (rules) = { values: [1, 2] };
+		option java_package = "com.example";
+	`)
+
+	var rule []byte
+	rule = protowire.AppendTag(rule, 1, protowire.BytesType)
+	rule = protowire.AppendString(rule, "a")
+	var values []byte
+	values = protowire.AppendTag(values, 2, protowire.BytesType)
+	values = protowire.AppendBytes(values, []byte{1, 2})
+
+	var want []byte
+	want = protowire.AppendTag(want, 50002, protowire.VarintType)
+	want = protowire.AppendVarint(want, protowire.EncodeZigZag(-2))
+	want = protowire.AppendTag(want, 50001, protowire.BytesType)
+	want = protowire.AppendBytes(want, rule)
+	want = protowire.AppendTag(want, 50003, protowire.BytesType)
+	want = protowire.AppendBytes(want, values)
+
+	options := got.GetOptions()
+	if diff := cmp.Diff(want, []byte(options.ProtoReflect().GetUnknown())); diff != "" {
+		t.Errorf("Compile: unknown fields mismatch (-want +got):\n%s", diff)
+	}
+	if options.GetJavaPackage() != "com.example" || len(options.GetUninterpretedOption()) != 0 {
+		t.Errorf("Compile: got options %v, want only java_package to be set", options)
+	}
+}
+
+func TestCompile_OptionErrors(t *testing.T) {
+	const extend = `package p; import "google/protobuf/descriptor.proto"; message M { optional int32 a = 1; } `
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "unknown option",
+			src:  `option foo = 1;`,
+			want: `a.proto:1:8: Option "foo" unknown. Ensure that your proto definition file imports the proto which defines the option.`,
+		},
+		{
+			name: "unknown extension",
+			src:  `option (foo) = 1;`,
+			want: `a.proto:1:8: Option "(foo)" unknown. Ensure that your proto definition file imports the proto which defines the option.`,
+		},
+		{
+			name: "reserved name",
+			src:  `option uninterpreted_option = 1;`,
+			want: `a.proto:1:8: Option must not use reserved name "uninterpreted_option".`,
+		},
+		{
+			name: "already set",
+			src:  `option java_package = "a"; option java_package = "b";`,
+			want: `a.proto:1:35: Option "java_package" was already set.`,
+		},
+		{
+			name: "atomic type",
+			src:  `option java_package.x = "a";`,
+			want: `a.proto:1:8: Option "java_package" is an atomic type, not a message.`,
+		},
+		{
+			name: "string value",
+			src:  `option java_package = 1;`,
+			want: `a.proto:1:23: Value must be quoted string for string option "google.protobuf.FileOptions.java_package".`,
+		},
+		{
+			name: "boolean value",
+			src:  `message Foo { optional int32 a = 1 [deprecated = "yes"]; }`,
+			want: `a.proto:1:50: Value must be "true" or "false" for boolean option "google.protobuf.FieldOptions.deprecated".`,
+		},
+		{
+			name: "enum value",
+			src:  `option optimize_for = FAST;`,
+			want: `a.proto:1:23: Enum type "google.protobuf.FileOptions.OptimizeMode" has no value named "FAST" for option "google.protobuf.FileOptions.optimize_for".`,
+		},
+		{
+			name: "out of range",
+			src:  extend + `extend google.protobuf.FileOptions { optional int32 x = 50000; } option (x) = 3000000000;`,
+			want: `a.proto:1:169: Value out of range for int32 option "p.x".`,
+		},
+		{
+			name: "negative unsigned",
+			src:  extend + `extend google.protobuf.FileOptions { optional uint32 x = 50000; } option (x) = -1;`,
+			want: `a.proto:1:170: Value must be non-negative integer for uint32 option "p.x".`,
+		},
+		{
+			name: "message value",
+			src:  extend + `extend google.protobuf.FileOptions { optional M x = 50000; } option (x) = 1;`,
+			want: `a.proto:1:165: Option "p.x" is a message. To set the entire message, use syntax like "x = { <proto text format> }". To set fields within it, use syntax like "x.foo = value".`,
+		},
+		{
+			name: "repeated message",
+			src:  extend + `extend google.protobuf.FileOptions { repeated M x = 50000; } option (x).a = 1;`,
+			want: `a.proto:1:159: Option field "(x)" is a repeated message. Repeated message options must be initialized using an aggregate value.`,
+		},
+		{
+			name: "wrong options message",
+			src:  extend + `extend google.protobuf.MessageOptions { optional int32 x = 50000; } option (x) = 1;`,
+			want: `a.proto:1:166: Option field "(x)" is not a field or extension of message "FileOptions".`,
+		},
+		{
+			name: "set after its fields",
+			src:  extend + `extend google.protobuf.FileOptions { optional M x = 50000; } option (x).a = 1; option (x) = { a: 2 };`,
+			want: `a.proto:1:177: Option "(x)" was already set.`,
+		},
+		{
+			name: "sibling enum value",
+			src:  extend + `enum E { A = 0; } enum F { B = 0; } extend google.protobuf.FileOptions { optional E x = 50000; } option (x) = B;`,
+			want: `a.proto:1:201: Enum type "p.E" has no value named "B" for option "p.x". This appears to be a value from a sibling type.`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := compileErrors(t, tc.src)
+
+			if diff := cmp.Diff([]string{tc.want}, got); diff != "" {
+				t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
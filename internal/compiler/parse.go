@@ -0,0 +1,1579 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// part identifies the part of an element of a file that a position refers
+// to, such as the name or the type of a field.
+type part int
+
+const (
+	partName part = iota
+	partType
+	partExtendee
+	partNumber
+	partDefault
+	partJSONName
+	partInput
+	partOutput
+	partValue
+)
+
+// location identifies a part of an element of a file.
+type location struct {
+	element proto.Message
+	part    part
+}
+
+// file is a proto file that is being compiled.
+type file struct {
+	// path is the path of the file, relative to the include directories.
+	path string
+
+	// filename is the path of the file on disk, which errors are reported
+	// with.
+	filename string
+
+	// proto is the descriptor of the file. Its type names are resolved, and
+	// its options interpreted, as it is linked.
+	proto *descriptorpb.FileDescriptorProto
+
+	// positions are the locations of the elements of the file, which errors
+	// are reported at.
+	positions map[location]protofile.Position
+
+	// imports are the positions of the import statements, in the order of
+	// the dependencies of the file.
+	imports []protofile.Position
+
+	// builtin reports whether the file is a well-known type that is built
+	// in, whose descriptor is already linked.
+	builtin bool
+
+	// deps are the files of the dependencies of the file, in the same order.
+	deps []*file
+
+	// desc is the descriptor of the file once it has been built.
+	desc protoreflect.FileDescriptor
+}
+
+// position returns the position of the part of the element, or the zero
+// position if it was not recorded.
+func (f *file) position(element proto.Message, p part) protofile.Position {
+	return f.positions[location{element, p}]
+}
+
+// scalarTypes are the types of fields that are named by keywords.
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double":   descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":    descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int64":    descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint64":   descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"int32":    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"fixed64":  descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	"fixed32":  descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	"bool":     descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string":   descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"group":    descriptorpb.FieldDescriptorProto_TYPE_GROUP,
+	"bytes":    descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	"uint32":   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"sfixed32": descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	"sfixed64": descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	"sint32":   descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64":   descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// editions are the editions that files may declare.
+var editions = map[string]descriptorpb.Edition{
+	"2023": descriptorpb.Edition_EDITION_2023,
+	"2024": descriptorpb.Edition_EDITION_2024,
+}
+
+const (
+	// maxFieldNumber is the largest number of a field.
+	maxFieldNumber = 536870911
+
+	// maxRangeSentinel is the end of a range that is declared with "max",
+	// until the number it refers to is known.
+	maxRangeSentinel = -1
+)
+
+// parse parses the contents of the proto file at path, relative to the
+// include directories, into its descriptor, with its type names unresolved
+// and its options uninterpreted, as protoc does. The first syntax error is
+// returned as a *protofile.Error, reported with filename.
+func parse(path, filename string, src []byte) (*file, error) {
+	p := &parser{
+		lexer: protofile.NewLexer(filename, src),
+		file: &file{
+			path:      path,
+			filename:  filename,
+			proto:     &descriptorpb.FileDescriptorProto{Name: proto.String(path)},
+			positions: make(map[location]protofile.Position),
+		},
+		syntax: "proto2",
+	}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	return p.file, nil
+}
+
+// parser parses the tokens of a proto file into its descriptor.
+type parser struct {
+	lexer  *protofile.Lexer
+	file   *file
+	token  protofile.Token
+	peeked *protofile.Token
+
+	// syntax is "proto2", "proto3", or "editions".
+	syntax string
+}
+
+func (p *parser) parseFile() error {
+	fd := p.file.proto
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.token.Is("syntax") || p.token.Is("edition") {
+		if err := p.parseSyntax(); err != nil {
+			return err
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	for p.token.Kind != protofile.TokenEOF {
+		var err error
+		switch {
+		case p.token.Is(";"):
+		case p.token.Is("message"):
+			var message *descriptorpb.DescriptorProto
+			if message, err = p.parseMessage(); err == nil {
+				fd.MessageType = append(fd.MessageType, message)
+			}
+		case p.token.Is("enum"):
+			var enum *descriptorpb.EnumDescriptorProto
+			if enum, err = p.parseEnum(); err == nil {
+				fd.EnumType = append(fd.EnumType, enum)
+			}
+		case p.token.Is("service"):
+			var service *descriptorpb.ServiceDescriptorProto
+			if service, err = p.parseService(); err == nil {
+				fd.Service = append(fd.Service, service)
+			}
+		case p.token.Is("extend"):
+			err = p.parseExtend(&fd.Extension, &fd.MessageType)
+		case p.token.Is("import"):
+			err = p.parseImport()
+		case p.token.Is("package"):
+			err = p.parsePackage()
+		case p.token.Is("option"):
+			if fd.Options == nil {
+				fd.Options = &descriptorpb.FileOptions{}
+			}
+			err = p.parseOptionStatement(&fd.Options.UninterpretedOption)
+		default:
+			err = p.errorf("Expected top-level statement (e.g. \"message\").")
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseSyntax() error {
+	keyword := p.token.Text
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	value := p.token
+	name, err := p.consumeString("Expected syntax identifier.")
+	if err != nil {
+		return err
+	}
+	if err := p.current(";"); err != nil {
+		return err
+	}
+	if keyword == "edition" {
+		edition, ok := editions[name]
+		if !ok {
+			return p.errorAt(value.Position, "Unknown edition \"%s\".", name)
+		}
+		p.syntax = "editions"
+		p.file.proto.Syntax = proto.String("editions")
+		p.file.proto.Edition = edition.Enum()
+		return nil
+	}
+	if name != "proto2" && name != "proto3" {
+		return p.errorAt(value.Position, "Unrecognized syntax identifier \"%s\".  This parser only recognizes \"proto2\" and \"proto3\".", name)
+	}
+	p.syntax = name
+	if name == "proto3" {
+		p.file.proto.Syntax = proto.String(name)
+	}
+	return nil
+}
+
+func (p *parser) parseImport() error {
+	fd := p.file.proto
+	position := p.token.Position
+	if err := p.next(); err != nil {
+		return err
+	}
+	switch {
+	case p.token.Is("public"):
+		fd.PublicDependency = append(fd.PublicDependency, int32(len(fd.Dependency)))
+		if err := p.next(); err != nil {
+			return err
+		}
+	case p.token.Is("weak"):
+		fd.WeakDependency = append(fd.WeakDependency, int32(len(fd.Dependency)))
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	path, err := p.consumeString("Expected a string naming the file to import.")
+	if err != nil {
+		return err
+	}
+	fd.Dependency = append(fd.Dependency, path)
+	p.file.imports = append(p.file.imports, position)
+	return p.current(";")
+}
+
+func (p *parser) parsePackage() error {
+	fd := p.file.proto
+	if fd.Package != nil {
+		return p.errorf("Multiple package definitions.")
+	}
+	p.record(fd, partName)
+	var name strings.Builder
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		identifier, err := p.consumeIdent("Expected identifier.")
+		if err != nil {
+			return err
+		}
+		name.WriteString(identifier)
+		if !p.token.Is(".") {
+			break
+		}
+		name.WriteString(".")
+	}
+	fd.Package = proto.String(name.String())
+	return p.current(";")
+}
+
+func (p *parser) parseMessage() (*descriptorpb.DescriptorProto, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	message := &descriptorpb.DescriptorProto{}
+	p.record(message, partName)
+	name, err := p.consumeIdent("Expected message name.")
+	if err != nil {
+		return nil, err
+	}
+	message.Name = proto.String(name)
+	if err := p.parseMessageBlock(message); err != nil {
+		return nil, err
+	}
+	if p.syntax == "proto3" {
+		generateSyntheticOneofs(message)
+	}
+	return message, nil
+}
+
+// parseMessageBlock parses the body of a message, starting with the token
+// after its name, which must be "{".
+func (p *parser) parseMessageBlock(message *descriptorpb.DescriptorProto) error {
+	if err := p.current("{"); err != nil {
+		return err
+	}
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case p.token.Is("}"):
+			adjustMaxRanges(message)
+			return nil
+		case p.token.Kind == protofile.TokenEOF:
+			return p.errorf("Reached end of input in message definition (missing '}').")
+		case p.token.Is(";"):
+		case p.token.Is("message"):
+			var nested *descriptorpb.DescriptorProto
+			if nested, err = p.parseMessage(); err == nil {
+				message.NestedType = append(message.NestedType, nested)
+			}
+		case p.token.Is("enum"):
+			var enum *descriptorpb.EnumDescriptorProto
+			if enum, err = p.parseEnum(); err == nil {
+				message.EnumType = append(message.EnumType, enum)
+			}
+		case p.token.Is("extensions"):
+			err = p.parseExtensions(message)
+		case p.token.Is("reserved"):
+			err = p.parseMessageReserved(message)
+		case p.token.Is("extend"):
+			err = p.parseExtend(&message.Extension, &message.NestedType)
+		case p.token.Is("option"):
+			if message.Options == nil {
+				message.Options = &descriptorpb.MessageOptions{}
+			}
+			err = p.parseOptionStatement(&message.Options.UninterpretedOption)
+		case p.token.Is("oneof"):
+			err = p.parseOneof(message)
+		default:
+			field := &descriptorpb.FieldDescriptorProto{}
+			message.Field = append(message.Field, field)
+			err = p.parseField(field, &message.NestedType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseField parses a field, starting with its label or type, and adds the
+// message of a group or map entry to messages.
+func (p *parser) parseField(field *descriptorpb.FieldDescriptorProto, messages *[]*descriptorpb.DescriptorProto) error {
+	var label descriptorpb.FieldDescriptorProto_Label
+	switch {
+	case p.token.Is("optional"):
+		label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	case p.token.Is("repeated"):
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	case p.token.Is("required"):
+		label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+	}
+	if label != 0 {
+		if p.syntax == "editions" && label != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+			if label == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+				return p.errorf("Label \"required\" is not supported in editions, use features.field_presence = LEGACY_REQUIRED.")
+			}
+			return p.errorf("Label \"optional\" is not supported in editions. By default, all singular fields in editions have explicit presence. To make a field have implicit presence, set features.field_presence = IMPLICIT.")
+		}
+		field.Label = label.Enum()
+		if label == descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL && p.syntax == "proto3" {
+			field.Proto3Optional = proto.Bool(true)
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return p.parseFieldNoLabel(field, messages)
+}
+
+// mapField is the key and value types of a map field.
+type mapField struct {
+	keyType, valueType         descriptorpb.FieldDescriptorProto_Type
+	keyTypeName, valueTypeName string
+	keyPosition, valuePosition protofile.Position
+}
+
+// parseFieldNoLabel parses a field, starting with its type.
+func (p *parser) parseFieldNoLabel(field *descriptorpb.FieldDescriptorProto, messages *[]*descriptorpb.DescriptorProto) error {
+	var entry *mapField
+	if p.token.Is("map") {
+		position := p.token.Position
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.token.Is("<") {
+			var err error
+			if entry, err = p.parseMapType(field); err != nil {
+				return err
+			}
+		} else {
+			p.unread()
+			p.token = protofile.Token{Kind: protofile.TokenIdent, Text: "map", Value: "map", Position: position}
+		}
+	}
+	if entry == nil {
+		if field.Label == nil {
+			if p.syntax == "proto2" {
+				return p.errorf("Expected \"required\", \"optional\", or \"repeated\".")
+			}
+			field.Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+		}
+		p.record(field, partType)
+		typ, name, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			field.TypeName = proto.String(name)
+		} else {
+			field.Type = typ.Enum()
+		}
+	}
+
+	nameToken := p.token
+	p.record(field, partName)
+	name, err := p.consumeIdent("Expected field name.")
+	if err != nil {
+		return err
+	}
+	field.Name = proto.String(name)
+	if !p.token.Is("=") {
+		return p.errorf("Missing field number.")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	p.record(field, partNumber)
+	number, err := p.consumeInteger(math.MaxInt32, "Expected field number.")
+	if err != nil {
+		return err
+	}
+	field.Number = proto.Int32(int32(number))
+	if p.token.Is("[") {
+		if err := p.parseFieldOptions(field); err != nil {
+			return err
+		}
+	}
+
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+		if p.syntax == "editions" {
+			return p.errorAt(nameToken.Position, "Group syntax is no longer supported in editions. To get group behavior you can specify features.message_encoding = DELIMITED on a message field.")
+		}
+		group := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+		p.file.positions[location{group, partName}] = nameToken.Position
+		*messages = append(*messages, group)
+		field.TypeName = proto.String(name)
+		if name[0] < 'A' || name[0] > 'Z' {
+			return p.errorAt(nameToken.Position, "Group names must start with a capital letter.")
+		}
+		field.Name = proto.String(strings.ToLower(name))
+		if err := p.parseMessageBlock(group); err != nil {
+			return err
+		}
+	} else if err := p.current(";"); err != nil {
+		return err
+	}
+
+	if entry != nil {
+		*messages = append(*messages, p.mapEntry(field, entry))
+	}
+	return nil
+}
+
+// parseMapType parses the "<key, value>" type of a map field.
+func (p *parser) parseMapType(field *descriptorpb.FieldDescriptorProto) (*mapField, error) {
+	switch {
+	case field.OneofIndex != nil:
+		return nil, p.errorf("Map fields are not allowed in oneofs.")
+	case field.Label != nil:
+		return nil, p.errorf("Field labels (required/optional/repeated) are not allowed on map fields.")
+	case field.Extendee != nil:
+		return nil, p.errorf("Map fields are not allowed to be extensions.")
+	}
+	field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	entry := &mapField{}
+	var err error
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	entry.keyPosition = p.token.Position
+	if entry.keyType, entry.keyTypeName, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if err := p.current(","); err != nil {
+		return nil, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	entry.valuePosition = p.token.Position
+	if entry.valueType, entry.valueTypeName, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if err := p.current(">"); err != nil {
+		return nil, err
+	}
+	return entry, p.next()
+}
+
+// mapEntry returns the message of the entries of a map field, and sets the
+// type of the field to it.
+func (p *parser) mapEntry(field *descriptorpb.FieldDescriptorProto, entry *mapField) *descriptorpb.DescriptorProto {
+	name := mapEntryName(field.GetName())
+	field.TypeName = proto.String(name)
+	message := &descriptorpb.DescriptorProto{
+		Name:    proto.String(name),
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	p.file.positions[location{message, partName}] = p.file.position(field, partName)
+	for i, name := range []string{"key", "value"} {
+		typ, typeName, position := entry.keyType, entry.keyTypeName, entry.keyPosition
+		if i == 1 {
+			typ, typeName, position = entry.valueType, entry.valueTypeName, entry.valuePosition
+		}
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(int32(i + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		} else {
+			f.Type = typ.Enum()
+		}
+		p.file.positions[location{f, partName}] = p.file.position(field, partName)
+		p.file.positions[location{f, partType}] = position
+		message.Field = append(message.Field, f)
+	}
+	return message
+}
+
+// parseType parses the type of a field, which is either a scalar type, or
+// the name of a message or enum.
+func (p *parser) parseType() (descriptorpb.FieldDescriptorProto_Type, string, error) {
+	if typ, ok := scalarTypes[p.token.Text]; ok && p.token.Kind == protofile.TokenIdent {
+		return typ, "", p.next()
+	}
+	name, err := p.parseUserType()
+	return 0, name, err
+}
+
+// parseUserType parses the possibly qualified name of a message or enum.
+func (p *parser) parseUserType() (string, error) {
+	var name strings.Builder
+	if p.token.Is(".") {
+		name.WriteString(".")
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+	identifier, err := p.consumeIdent("Expected type name.")
+	if err != nil {
+		return "", err
+	}
+	name.WriteString(identifier)
+	for p.token.Is(".") {
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		identifier, err := p.consumeIdent("Expected identifier.")
+		if err != nil {
+			return "", err
+		}
+		name.WriteString(".")
+		name.WriteString(identifier)
+	}
+	return name.String(), nil
+}
+
+// parseFieldOptions parses the "[...]" options of a field, including the
+// default and json_name pseudo-options.
+func (p *parser) parseFieldOptions(field *descriptorpb.FieldDescriptorProto) error {
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case p.token.Is("default"):
+			err = p.parseDefault(field)
+		case p.token.Is("json_name"):
+			err = p.parseJSONName(field)
+		default:
+			if field.Options == nil {
+				field.Options = &descriptorpb.FieldOptions{}
+			}
+			err = p.parseOption(&field.Options.UninterpretedOption)
+		}
+		if err != nil {
+			return err
+		}
+		if !p.token.Is(",") {
+			break
+		}
+	}
+	if err := p.current("]"); err != nil {
+		return err
+	}
+	return p.next()
+}
+
+func (p *parser) parseDefault(field *descriptorpb.FieldDescriptorProto) error {
+	if field.DefaultValue != nil {
+		return p.errorf("Already set option \"default\".")
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	p.record(field, partDefault)
+
+	var value string
+	if field.Type == nil {
+		// The type is a message or enum that has not been resolved yet, so
+		// the value is taken as the name of an enum value, which is checked
+		// once it is resolved.
+		value = p.token.Text
+		field.DefaultValue = proto.String(value)
+		return p.next()
+	}
+	switch typ := field.GetType(); typ {
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		var max uint64 = math.MaxInt64
+		if typ == descriptorpb.FieldDescriptorProto_TYPE_INT32 || typ == descriptorpb.FieldDescriptorProto_TYPE_SINT32 || typ == descriptorpb.FieldDescriptorProto_TYPE_SFIXED32 {
+			max = math.MaxInt32
+		}
+		if p.token.Is("-") {
+			value = "-"
+			max++
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+		number, err := p.consumeInteger(max, "Expected integer for field default value.")
+		if err != nil {
+			return err
+		}
+		value += strconv.FormatUint(number, 10)
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+		descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		var max uint64 = math.MaxUint64
+		if typ == descriptorpb.FieldDescriptorProto_TYPE_UINT32 || typ == descriptorpb.FieldDescriptorProto_TYPE_FIXED32 {
+			max = math.MaxUint32
+		}
+		if p.token.Is("-") {
+			return p.errorf("Unsigned field can't have negative default value.")
+		}
+		number, err := p.consumeInteger(max, "Expected integer for field default value.")
+		if err != nil {
+			return err
+		}
+		value = strconv.FormatUint(number, 10)
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		if p.token.Is("-") {
+			value = "-"
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+		number, err := p.consumeNumber("Expected number.")
+		if err != nil {
+			return err
+		}
+		value += simpleDtoa(number)
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		if !p.token.Is("true") && !p.token.Is("false") {
+			return p.errorf("Expected \"true\" or \"false\".")
+		}
+		value = p.token.Text
+		if err := p.next(); err != nil {
+			return err
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		s, err := p.consumeString("Expected string for field default value.")
+		if err != nil {
+			return err
+		}
+		value = s
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		s, err := p.consumeString("Expected string.")
+		if err != nil {
+			return err
+		}
+		value = cEscape(s)
+	default:
+		return p.errorf("Messages can't have default values.")
+	}
+	field.DefaultValue = proto.String(value)
+	return nil
+}
+
+func (p *parser) parseJSONName(field *descriptorpb.FieldDescriptorProto) error {
+	if field.JsonName != nil {
+		return p.errorf("Already set option \"json_name\".")
+	}
+	p.record(field, partJSONName)
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	name, err := p.consumeString("Expected string for JSON name.")
+	if err != nil {
+		return err
+	}
+	field.JsonName = proto.String(name)
+	return nil
+}
+
+func (p *parser) parseOneof(message *descriptorpb.DescriptorProto) error {
+	index := int32(len(message.OneofDecl))
+	oneof := &descriptorpb.OneofDescriptorProto{}
+	message.OneofDecl = append(message.OneofDecl, oneof)
+	if err := p.next(); err != nil {
+		return err
+	}
+	p.record(oneof, partName)
+	name, err := p.consumeIdent("Expected oneof name.")
+	if err != nil {
+		return err
+	}
+	oneof.Name = proto.String(name)
+	if err := p.current("{"); err != nil {
+		return err
+	}
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		switch {
+		case p.token.Kind == protofile.TokenEOF:
+			return p.errorf("Reached end of input in oneof definition (missing '}').")
+		case p.token.Is("option"):
+			if oneof.Options == nil {
+				oneof.Options = &descriptorpb.OneofOptions{}
+			}
+			if err := p.parseOptionStatement(&oneof.Options.UninterpretedOption); err != nil {
+				return err
+			}
+		case p.token.Is("required") || p.token.Is("optional") || p.token.Is("repeated"):
+			return p.errorf("Fields in oneofs must not have labels (required / optional / repeated).")
+		default:
+			field := &descriptorpb.FieldDescriptorProto{
+				Label:      descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				OneofIndex: proto.Int32(index),
+			}
+			message.Field = append(message.Field, field)
+			if err := p.parseFieldNoLabel(field, &message.NestedType); err != nil {
+				return err
+			}
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.token.Is("}") {
+			return nil
+		}
+		p.unread()
+	}
+}
+
+func (p *parser) parseExtensions(message *descriptorpb.DescriptorProto) error {
+	first := len(message.ExtensionRange)
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		extensionRange := &descriptorpb.DescriptorProto_ExtensionRange{}
+		p.record(extensionRange, partNumber)
+		start, end, err := p.parseRange(math.MaxInt32, "Expected field number range.")
+		if err != nil {
+			return err
+		}
+		extensionRange.Start = proto.Int32(start)
+		extensionRange.End = proto.Int32(end + 1)
+		message.ExtensionRange = append(message.ExtensionRange, extensionRange)
+		if !p.token.Is(",") {
+			break
+		}
+	}
+	if p.token.Is("[") {
+		options := &descriptorpb.ExtensionRangeOptions{}
+		for {
+			if err := p.next(); err != nil {
+				return err
+			}
+			if err := p.parseOption(&options.UninterpretedOption); err != nil {
+				return err
+			}
+			if !p.token.Is(",") {
+				break
+			}
+		}
+		if err := p.current("]"); err != nil {
+			return err
+		}
+		for _, extensionRange := range message.ExtensionRange[first:] {
+			extensionRange.Options = proto.Clone(options).(*descriptorpb.ExtensionRangeOptions)
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return p.current(";")
+}
+
+// parseRange parses a range of numbers, "start", "start to end", or "start
+// to max", where max is returned as maxRangeSentinel - 1. The end is
+// inclusive.
+func (p *parser) parseRange(max uint64, message string) (int32, int32, error) {
+	start, err := p.consumeInteger(max, message)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !p.token.Is("to") {
+		return int32(start), int32(start), nil
+	}
+	if err := p.next(); err != nil {
+		return 0, 0, err
+	}
+	if p.token.Is("max") {
+		return int32(start), maxRangeSentinel - 1, p.next()
+	}
+	end, err := p.consumeInteger(max, "Expected integer.")
+	if err != nil {
+		return 0, 0, err
+	}
+	return int32(start), int32(end), nil
+}
+
+func (p *parser) parseMessageReserved(message *descriptorpb.DescriptorProto) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.token.Kind == protofile.TokenString || p.token.Kind == protofile.TokenIdent {
+		names, err := p.parseReservedNames("Expected field name.")
+		if err != nil {
+			return err
+		}
+		message.ReservedName = append(message.ReservedName, names...)
+		return nil
+	}
+	first := true
+	for {
+		reserved := &descriptorpb.DescriptorProto_ReservedRange{}
+		p.record(reserved, partNumber)
+		expected := "Expected field number range."
+		if first {
+			expected = "Expected field name or number range."
+		}
+		start, end, err := p.parseRange(math.MaxInt32, expected)
+		if err != nil {
+			return err
+		}
+		reserved.Start = proto.Int32(start)
+		reserved.End = proto.Int32(end + 1)
+		message.ReservedRange = append(message.ReservedRange, reserved)
+		first = false
+		if !p.token.Is(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return p.current(";")
+}
+
+// parseReservedNames parses the names of a reserved statement, which are
+// strings, or identifiers in editions.
+func (p *parser) parseReservedNames(message string) ([]string, error) {
+	var names []string
+	for {
+		switch {
+		case p.token.Kind == protofile.TokenString:
+			name, err := p.consumeString(message)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		case p.token.Kind == protofile.TokenIdent && p.syntax == "editions":
+			names = append(names, p.token.Text)
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case p.token.Kind == protofile.TokenIdent:
+			return nil, p.errorf("Reserved names must be string literals. (Only editions supports identifiers.)")
+		default:
+			return nil, p.errorf(message)
+		}
+		if !p.token.Is(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return names, p.current(";")
+}
+
+func (p *parser) parseEnum() (*descriptorpb.EnumDescriptorProto, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	enum := &descriptorpb.EnumDescriptorProto{}
+	p.record(enum, partName)
+	name, err := p.consumeIdent("Expected enum name.")
+	if err != nil {
+		return nil, err
+	}
+	enum.Name = proto.String(name)
+	if err := p.current("{"); err != nil {
+		return nil, err
+	}
+	for {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		var err error
+		switch {
+		case p.token.Is("}"):
+			return enum, nil
+		case p.token.Kind == protofile.TokenEOF:
+			return nil, p.errorf("Reached end of input in enum definition (missing '}').")
+		case p.token.Is(";"):
+		case p.token.Is("option"):
+			if enum.Options == nil {
+				enum.Options = &descriptorpb.EnumOptions{}
+			}
+			err = p.parseOptionStatement(&enum.Options.UninterpretedOption)
+		case p.token.Is("reserved"):
+			err = p.parseEnumReserved(enum)
+		default:
+			var value *descriptorpb.EnumValueDescriptorProto
+			if value, err = p.parseEnumValue(); err == nil {
+				enum.Value = append(enum.Value, value)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseEnumValue() (*descriptorpb.EnumValueDescriptorProto, error) {
+	value := &descriptorpb.EnumValueDescriptorProto{}
+	p.record(value, partName)
+	name, err := p.consumeIdent("Expected enum constant name.")
+	if err != nil {
+		return nil, err
+	}
+	value.Name = proto.String(name)
+	if !p.token.Is("=") {
+		return nil, p.errorf("Missing numeric value for enum constant.")
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	p.record(value, partNumber)
+	number, err := p.consumeSignedInteger("Expected integer.")
+	if err != nil {
+		return nil, err
+	}
+	value.Number = proto.Int32(number)
+	if p.token.Is("[") {
+		value.Options = &descriptorpb.EnumValueOptions{}
+		for {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if err := p.parseOption(&value.Options.UninterpretedOption); err != nil {
+				return nil, err
+			}
+			if !p.token.Is(",") {
+				break
+			}
+		}
+		if err := p.current("]"); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return value, p.current(";")
+}
+
+func (p *parser) parseEnumReserved(enum *descriptorpb.EnumDescriptorProto) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.token.Kind == protofile.TokenString || p.token.Kind == protofile.TokenIdent {
+		names, err := p.parseReservedNames("Expected enum value.")
+		if err != nil {
+			return err
+		}
+		enum.ReservedName = append(enum.ReservedName, names...)
+		return nil
+	}
+	first := true
+	for {
+		reserved := &descriptorpb.EnumDescriptorProto_EnumReservedRange{}
+		p.record(reserved, partNumber)
+		expected := "Expected enum number range."
+		if first {
+			expected = "Expected enum value or number range."
+		}
+		start, err := p.consumeSignedInteger(expected)
+		if err != nil {
+			return err
+		}
+		end := start
+		if p.token.Is("to") {
+			if err := p.next(); err != nil {
+				return err
+			}
+			if p.token.Is("max") {
+				end = math.MaxInt32
+				if err := p.next(); err != nil {
+					return err
+				}
+			} else if end, err = p.consumeSignedInteger("Expected integer."); err != nil {
+				return err
+			}
+		}
+		reserved.Start = proto.Int32(start)
+		reserved.End = proto.Int32(end)
+		enum.ReservedRange = append(enum.ReservedRange, reserved)
+		first = false
+		if !p.token.Is(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return p.current(";")
+}
+
+func (p *parser) parseService() (*descriptorpb.ServiceDescriptorProto, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	service := &descriptorpb.ServiceDescriptorProto{}
+	p.record(service, partName)
+	name, err := p.consumeIdent("Expected service name.")
+	if err != nil {
+		return nil, err
+	}
+	service.Name = proto.String(name)
+	if err := p.current("{"); err != nil {
+		return nil, err
+	}
+	for {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		var err error
+		switch {
+		case p.token.Is("}"):
+			return service, nil
+		case p.token.Kind == protofile.TokenEOF:
+			return nil, p.errorf("Reached end of input in service definition (missing '}').")
+		case p.token.Is(";"):
+		case p.token.Is("option"):
+			if service.Options == nil {
+				service.Options = &descriptorpb.ServiceOptions{}
+			}
+			err = p.parseOptionStatement(&service.Options.UninterpretedOption)
+		default:
+			var method *descriptorpb.MethodDescriptorProto
+			if method, err = p.parseMethod(); err == nil {
+				service.Method = append(service.Method, method)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseMethod() (*descriptorpb.MethodDescriptorProto, error) {
+	if err := p.current("rpc"); err != nil {
+		return nil, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	method := &descriptorpb.MethodDescriptorProto{}
+	p.record(method, partName)
+	name, err := p.consumeIdent("Expected method name.")
+	if err != nil {
+		return nil, err
+	}
+	method.Name = proto.String(name)
+	for _, part := range []part{partInput, partOutput} {
+		if part == partOutput {
+			if err := p.current("returns"); err != nil {
+				return nil, err
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.current("("); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		streaming := p.token.Is("stream")
+		if streaming {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		p.record(method, part)
+		if _, ok := scalarTypes[p.token.Text]; ok && p.token.Kind == protofile.TokenIdent {
+			return nil, p.errorf("Expected message type.")
+		}
+		typeName, err := p.parseUserType()
+		if err != nil {
+			return nil, err
+		}
+		if part == partInput {
+			method.InputType = proto.String(typeName)
+			if streaming {
+				method.ClientStreaming = proto.Bool(true)
+			}
+		} else {
+			method.OutputType = proto.String(typeName)
+			if streaming {
+				method.ServerStreaming = proto.Bool(true)
+			}
+		}
+		if err := p.current(")"); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.token.Is("{") {
+		return method, p.current(";")
+	}
+	for {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.token.Is("}"):
+			return method, nil
+		case p.token.Kind == protofile.TokenEOF:
+			return nil, p.errorf("Reached end of input in method options (missing '}').")
+		case p.token.Is(";"):
+		default:
+			if method.Options == nil {
+				method.Options = &descriptorpb.MethodOptions{}
+			}
+			if err := p.parseOptionStatement(&method.Options.UninterpretedOption); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// parseExtend parses an extend block, adding its fields to extensions, and
+// the messages of its groups to messages.
+func (p *parser) parseExtend(extensions *[]*descriptorpb.FieldDescriptorProto, messages *[]*descriptorpb.DescriptorProto) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	extendeePosition := p.token.Position
+	extendee, err := p.parseUserType()
+	if err != nil {
+		return err
+	}
+	if err := p.current("{"); err != nil {
+		return err
+	}
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.token.Kind == protofile.TokenEOF {
+			return p.errorf("Reached end of input in extend definition (missing '}').")
+		}
+		field := &descriptorpb.FieldDescriptorProto{Extendee: proto.String(extendee)}
+		p.file.positions[location{field, partExtendee}] = extendeePosition
+		*extensions = append(*extensions, field)
+		if err := p.parseField(field, messages); err != nil {
+			return err
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.token.Is("}") {
+			return nil
+		}
+		p.unread()
+	}
+}
+
+// parseOptionStatement parses an "option name = value;" statement.
+func (p *parser) parseOptionStatement(options *[]*descriptorpb.UninterpretedOption) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	if err := p.parseOption(options); err != nil {
+		return err
+	}
+	return p.current(";")
+}
+
+// parseOption parses a "name = value" option assignment, starting with its
+// name, into an uninterpreted option.
+func (p *parser) parseOption(options *[]*descriptorpb.UninterpretedOption) error {
+	option := &descriptorpb.UninterpretedOption{}
+	p.record(option, partName)
+	for {
+		part := &descriptorpb.UninterpretedOption_NamePart{}
+		if p.token.Is("(") {
+			if err := p.next(); err != nil {
+				return err
+			}
+			var name strings.Builder
+			if p.token.Kind == protofile.TokenIdent {
+				name.WriteString(p.token.Text)
+				if err := p.next(); err != nil {
+					return err
+				}
+			}
+			for p.token.Is(".") {
+				if err := p.next(); err != nil {
+					return err
+				}
+				identifier, err := p.consumeIdent("Expected identifier.")
+				if err != nil {
+					return err
+				}
+				name.WriteString(".")
+				name.WriteString(identifier)
+			}
+			if err := p.current(")"); err != nil {
+				return err
+			}
+			if err := p.next(); err != nil {
+				return err
+			}
+			part.NamePart = proto.String(name.String())
+			part.IsExtension = proto.Bool(true)
+		} else {
+			identifier, err := p.consumeIdent("Expected identifier.")
+			if err != nil {
+				return err
+			}
+			part.NamePart = proto.String(identifier)
+			part.IsExtension = proto.Bool(false)
+		}
+		option.Name = append(option.Name, part)
+		if !p.token.Is(".") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	if err := p.current("="); err != nil {
+		return err
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	p.record(option, partValue)
+	if err := p.parseOptionValue(option); err != nil {
+		return err
+	}
+	*options = append(*options, option)
+	return nil
+}
+
+func (p *parser) parseOptionValue(option *descriptorpb.UninterpretedOption) error {
+	negative := p.token.Is("-")
+	if negative {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	switch p.token.Kind {
+	case protofile.TokenEOF:
+		return p.errorf("Unexpected end of stream while parsing option value.")
+	case protofile.TokenIdent:
+		switch {
+		case !negative:
+			option.IdentifierValue = proto.String(p.token.Text)
+		case p.token.Text == "inf":
+			option.DoubleValue = proto.Float64(math.Inf(-1))
+		case p.token.Text == "nan":
+			option.DoubleValue = proto.Float64(math.NaN())
+		default:
+			return p.errorf("Identifier after '-' symbol must be inf or nan.")
+		}
+		return p.next()
+	case protofile.TokenInt:
+		if negative {
+			value, err := p.consumeInteger(math.MaxInt64+1, "Expected integer.")
+			if err != nil {
+				return err
+			}
+			option.NegativeIntValue = proto.Int64(int64(-value))
+			return nil
+		}
+		value, err := p.consumeInteger(math.MaxUint64, "Expected integer.")
+		if err != nil {
+			return err
+		}
+		option.PositiveIntValue = proto.Uint64(value)
+		return nil
+	case protofile.TokenFloat:
+		value, err := p.consumeNumber("Expected number.")
+		if err != nil {
+			return err
+		}
+		if negative {
+			value = -value
+		}
+		option.DoubleValue = proto.Float64(value)
+		return nil
+	case protofile.TokenString:
+		if negative {
+			return p.errorf("Invalid '-' symbol before string.")
+		}
+		value, err := p.consumeString("Expected string.")
+		if err != nil {
+			return err
+		}
+		option.StringValue = []byte(value)
+		return nil
+	}
+	if !p.token.Is("{") || negative {
+		return p.errorf("Expected option value.")
+	}
+	// Aggregate values are recorded as the text of their tokens, separated
+	// by spaces, and are parsed as text format when they are interpreted.
+	var value strings.Builder
+	depth := 1
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		switch {
+		case p.token.Kind == protofile.TokenEOF:
+			return p.errorf("Unexpected end of stream while parsing aggregate value.")
+		case p.token.Is("{"):
+			depth++
+		case p.token.Is("}"):
+			depth--
+		}
+		if depth == 0 {
+			option.AggregateValue = proto.String(value.String())
+			return p.next()
+		}
+		if value.Len() > 0 {
+			value.WriteByte(' ')
+		}
+		value.WriteString(p.token.Text)
+	}
+}
+
+// consumeIdent returns the current token, which must be an identifier, and
+// reads the next token.
+func (p *parser) consumeIdent(message string) (string, error) {
+	if p.token.Kind != protofile.TokenIdent {
+		return "", p.errorf(message)
+	}
+	text := p.token.Text
+	return text, p.next()
+}
+
+// consumeInteger returns the value of the current token, which must be an
+// integer no greater than max, and reads the next token.
+func (p *parser) consumeInteger(max uint64, message string) (uint64, error) {
+	if p.token.Kind != protofile.TokenInt {
+		return 0, p.errorf(message)
+	}
+	value, err := strconv.ParseUint(p.token.Text, 0, 64)
+	if err != nil || value > max {
+		return 0, p.errorf("Integer out of range.")
+	}
+	return value, p.next()
+}
+
+// consumeSignedInteger returns the value of an optionally negated int32, and
+// reads the next token.
+func (p *parser) consumeSignedInteger(message string) (int32, error) {
+	var max uint64 = math.MaxInt32
+	negative := p.token.Is("-")
+	if negative {
+		max++
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+	}
+	value, err := p.consumeInteger(max, message)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		return int32(-int64(value)), nil
+	}
+	return int32(value), nil
+}
+
+// consumeNumber returns the value of the current token, which must be a
+// number, inf, or nan, and reads the next token.
+func (p *parser) consumeNumber(message string) (float64, error) {
+	var value float64
+	switch {
+	case p.token.Kind == protofile.TokenFloat:
+		text := strings.TrimRight(p.token.Text, "fF")
+		var err error
+		if value, err = strconv.ParseFloat(text, 64); err != nil && !isRangeError(err) {
+			return 0, p.errorf(message)
+		}
+	case p.token.Kind == protofile.TokenInt:
+		number, err := strconv.ParseUint(p.token.Text, 0, 64)
+		if err != nil {
+			return 0, p.errorf("Integer out of range.")
+		}
+		value = float64(number)
+	case p.token.Is("inf"):
+		value = math.Inf(1)
+	case p.token.Is("nan"):
+		value = math.NaN()
+	default:
+		return 0, p.errorf(message)
+	}
+	return value, p.next()
+}
+
+// consumeString returns the value of the current token, which must be a
+// string, concatenated with the values of any strings that directly follow
+// it, and reads the next token.
+func (p *parser) consumeString(message string) (string, error) {
+	if p.token.Kind != protofile.TokenString {
+		return "", p.errorf(message)
+	}
+	var value strings.Builder
+	for p.token.Kind == protofile.TokenString {
+		value.WriteString(p.token.Value)
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+	return value.String(), nil
+}
+
+// record records the position of the current token as the part of the
+// element.
+func (p *parser) record(element proto.Message, part part) {
+	p.file.positions[location{element, part}] = p.token.Position
+}
+
+// expect reads the next token, which must be the symbol.
+func (p *parser) expect(symbol string) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	return p.current(symbol)
+}
+
+// current returns an error unless the current token is the symbol.
+func (p *parser) current(symbol string) error {
+	if !p.token.Is(symbol) {
+		return p.errorf("Expected \"%s\".", symbol)
+	}
+	return nil
+}
+
+func (p *parser) next() error {
+	if p.peeked != nil {
+		p.token, p.peeked = *p.peeked, nil
+		return nil
+	}
+	token, err := p.lexer.Next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+// unread returns the current token to be read again by the next call to
+// next.
+func (p *parser) unread() {
+	token := p.token
+	p.peeked = &token
+}
+
+// errorf returns an error at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	return p.errorAt(p.token.Position, format, args...)
+}
+
+func (p *parser) errorAt(position protofile.Position, format string, args ...any) error {
+	return &protofile.Error{File: p.file.filename, Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+// generateSyntheticOneofs adds a oneof for each proto3 optional field of the
+// message, which is named after the field with a leading underscore. Names
+// that conflict are prefixed with "X" until they are unique.
+func generateSyntheticOneofs(message *descriptorpb.DescriptorProto) {
+	names := make(map[string]bool)
+	for _, field := range message.Field {
+		names[field.GetName()] = true
+	}
+	for _, oneof := range message.OneofDecl {
+		names[oneof.GetName()] = true
+	}
+	for _, field := range message.Field {
+		if !field.GetProto3Optional() {
+			continue
+		}
+		name := field.GetName()
+		if !strings.HasPrefix(name, "_") {
+			name = "_" + name
+		}
+		for names[name] {
+			name = "X" + name
+		}
+		names[name] = true
+		field.OneofIndex = proto.Int32(int32(len(message.OneofDecl)))
+		message.OneofDecl = append(message.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(name)})
+	}
+}
+
+// adjustMaxRanges replaces the ends of the extension and reserved ranges of
+// the message that were declared with "max" with the largest field number,
+// which is larger for messages that use the message set wire format.
+func adjustMaxRanges(message *descriptorpb.DescriptorProto) {
+	end := int32(maxFieldNumber + 1)
+	for _, option := range message.GetOptions().GetUninterpretedOption() {
+		if len(option.Name) == 1 && !option.Name[0].GetIsExtension() && option.Name[0].GetNamePart() == "message_set_wire_format" && option.GetIdentifierValue() == "true" {
+			end = math.MaxInt32
+		}
+	}
+	for _, extensionRange := range message.ExtensionRange {
+		if extensionRange.GetEnd() == maxRangeSentinel {
+			extensionRange.End = proto.Int32(end)
+		}
+	}
+	for _, reserved := range message.ReservedRange {
+		if reserved.GetEnd() == maxRangeSentinel {
+			reserved.End = proto.Int32(end)
+		}
+	}
+}
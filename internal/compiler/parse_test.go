@@ -0,0 +1,181 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCompile_SyntaxErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "unknown syntax",
+			src:  `syntax = "proto4";`,
+			want: `a.proto:1:10: Unrecognized syntax identifier "proto4".  This parser only recognizes "proto2" and "proto3".`,
+		},
+		{
+			name: "unknown edition",
+			src:  `edition = "2099";`,
+			want: `a.proto:1:11: Unknown edition "2099".`,
+		},
+		{
+			name: "missing semicolon",
+			src:  `syntax = "proto3"`,
+			want: `a.proto:1:18: Expected ";".`,
+		},
+		{
+			name: "missing label",
+			src:  `message Foo { int32 a = 1; }`,
+			want: `a.proto:1:15: Expected "required", "optional", or "repeated".`,
+		},
+		{
+			name: "missing field number",
+			src:  `message Foo { optional int32 a; }`,
+			want: `a.proto:1:31: Missing field number.`,
+		},
+		{
+			name: "lowercase group",
+			src:  `message Foo { optional group a = 1 {} }`,
+			want: `a.proto:1:30: Group names must start with a capital letter.`,
+		},
+		{
+			name: "labelled map",
+			src:  `message Foo { repeated map<string, int32> m = 1; }`,
+			want: `a.proto:1:27: Field labels (required/optional/repeated) are not allowed on map fields.`,
+		},
+		{
+			name: "required in proto3",
+			src:  `syntax = "proto3"; message Foo { required int32 a = 1; }`,
+			want: `a.proto:1:43: Required fields are not allowed in proto3.`,
+		},
+		{
+			name: "default in proto3",
+			src:  `syntax = "proto3"; message Foo { optional int32 a = 1 [default = 1]; }`,
+			want: `a.proto:1:66: Explicit default values are not allowed in proto3.`,
+		},
+		{
+			name: "label in editions",
+			src:  `edition = "2023"; message Foo { optional int32 a = 1; }`,
+			want: `a.proto:1:33: Label "optional" is not supported in editions. By default, all singular fields in editions have explicit presence. To make a field have implicit presence, set features.field_presence = IMPLICIT.`,
+		},
+		{
+			name: "label in oneof",
+			src:  `message Foo { oneof x { repeated int32 a = 1; } }`,
+			want: `a.proto:1:25: Fields in oneofs must not have labels (required / optional / repeated).`,
+		},
+		{
+			name: "default out of range",
+			src:  `message Foo { optional int32 a = 1 [default = 3000000000]; }`,
+			want: `a.proto:1:47: Integer out of range.`,
+		},
+		{
+			name: "negative unsigned default",
+			src:  `message Foo { optional uint32 a = 1 [default = -1]; }`,
+			want: `a.proto:1:48: Unsigned field can't have negative default value.`,
+		},
+		{
+			name: "default set twice",
+			src:  `message Foo { optional int32 a = 1 [default = 1, default = 2]; }`,
+			want: `a.proto:1:50: Already set option "default".`,
+		},
+		{
+			name: "multiple packages",
+			src:  `package a.b; package c;`,
+			want: `a.proto:1:14: Multiple package definitions.`,
+		},
+		{
+			name: "mixed reserved",
+			src:  `message Foo { reserved 1, "a"; }`,
+			want: `a.proto:1:27: Expected field number range.`,
+		},
+		{
+			name: "unterminated message",
+			src:  "message Foo {",
+			want: `a.proto:1:14: Reached end of input in message definition (missing '}').`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := compileErrors(t, tc.src)
+
+			if diff := cmp.Diff([]string{tc.want}, got); diff != "" {
+				t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompile_Proto2_MatchesProtoc(t *testing.T) {
+	got := compileFile(t, `
+		syntax = "proto2";
+		message Foo {
+			optional double ratio = 1 [default = 1e3];
+			optional bytes data = 2 [default = "\001\n"];
+			repeated group Item = 3 { required int32 id = 1; }
+			extensions 100 to max;
+			reserved 10 to 20, 30;
+			reserved "old";
+		}
+	`)
+
+	want := &descriptorpb.FileDescriptorProto{
+		Name: proto.String("a.proto"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Foo"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:         proto.String("ratio"),
+					Number:       proto.Int32(1),
+					Label:        descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:         descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum(),
+					DefaultValue: proto.String("1000"),
+					JsonName:     proto.String("ratio"),
+				},
+				{
+					Name:         proto.String("data"),
+					Number:       proto.Int32(2),
+					Label:        descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:         descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+					DefaultValue: proto.String(`\001\n`),
+					JsonName:     proto.String("data"),
+				},
+				{
+					Name:     proto.String("item"),
+					Number:   proto.Int32(3),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(),
+					TypeName: proto.String(".Foo.Item"),
+					JsonName: proto.String("item"),
+				},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{{
+					Name:     proto.String("id"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REQUIRED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+					JsonName: proto.String("id"),
+				}},
+			}},
+			ExtensionRange: []*descriptorpb.DescriptorProto_ExtensionRange{
+				{Start: proto.Int32(100), End: proto.Int32(536870912)},
+			},
+			ReservedRange: []*descriptorpb.DescriptorProto_ReservedRange{
+				{Start: proto.Int32(10), End: proto.Int32(21)},
+				{Start: proto.Int32(30), End: proto.Int32(31)},
+			},
+			ReservedName: []string{"old"},
+		}},
+	}
+	if diff := cmp.Diff(want, got, protocmp.Transform()); diff != "" {
+		t.Errorf("Compile: mismatch (-want +got):\n%s", diff)
+	}
+}
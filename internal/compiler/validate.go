@@ -0,0 +1,504 @@
+package compiler
+
+import (
+	"math"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// optionsMessages are the messages of options, which are the only messages
+// that proto3 files may extend.
+var optionsMessages = map[string]bool{
+	"google.protobuf.FileOptions":           true,
+	"google.protobuf.MessageOptions":        true,
+	"google.protobuf.FieldOptions":          true,
+	"google.protobuf.EnumOptions":           true,
+	"google.protobuf.EnumValueOptions":      true,
+	"google.protobuf.ServiceOptions":        true,
+	"google.protobuf.MethodOptions":         true,
+	"google.protobuf.OneofOptions":          true,
+	"google.protobuf.ExtensionRangeOptions": true,
+}
+
+// checkField checks the number and default value of a field, as they are
+// declared.
+func (l *linker) checkField(name string, field *descriptorpb.FieldDescriptorProto) {
+	extension := field.Extendee != nil
+	switch number := field.GetNumber(); {
+	case number <= 0:
+		l.errorf(field, partNumber, "Field numbers must be positive integers.")
+	case !extension && number > maxFieldNumber:
+		l.errorf(field, partNumber, "Field numbers cannot be greater than %d.", maxFieldNumber)
+	case !extension && number >= 19000 && number <= 19999:
+		l.errorf(field, partNumber, "Field numbers 19000 through 19999 are reserved for the protocol buffer library implementation.")
+	}
+	if field.DefaultValue != nil && field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		l.errorf(field, partDefault, "Repeated fields can't have default values.")
+	}
+	if extension && field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+		l.errorf(field, partType, "The extension %s cannot be required.", name)
+	}
+	if extension && field.JsonName != nil && field.GetJsonName() != jsonName(field.GetName()) {
+		l.errorf(field, partJSONName, "option json_name is not allowed on extension fields.")
+	}
+}
+
+// checkMessage checks the reserved and extension ranges of a message, and
+// that its fields do not conflict with them or with each other.
+func (l *linker) checkMessage(name string, message *descriptorpb.DescriptorProto) {
+	for _, reserved := range message.ReservedRange {
+		if reserved.GetStart() <= 0 {
+			l.errorf(reserved, partNumber, "Reserved numbers must be positive integers.")
+		}
+		if reserved.GetStart() >= reserved.GetEnd() {
+			l.errorf(reserved, partNumber, "Reserved range end number must be greater than start number.")
+		}
+	}
+	for _, extensionRange := range message.ExtensionRange {
+		if extensionRange.GetStart() <= 0 {
+			l.errorf(extensionRange, partNumber, "Extension numbers must be positive integers.")
+		}
+		if extensionRange.GetStart() >= extensionRange.GetEnd() {
+			l.errorf(extensionRange, partNumber, "Extension range end number must be greater than start number.")
+		}
+	}
+
+	for i, lhs := range message.ReservedRange {
+		for _, rhs := range message.ReservedRange[i+1:] {
+			if lhs.GetEnd() > rhs.GetStart() && rhs.GetEnd() > lhs.GetStart() {
+				l.errorf(lhs, partNumber, "Reserved range %d to %d overlaps with already-defined range %d to %d.", rhs.GetStart(), rhs.GetEnd()-1, lhs.GetStart(), lhs.GetEnd()-1)
+			}
+		}
+	}
+	reservedNames := make(map[string]bool)
+	for _, reserved := range message.ReservedName {
+		if reservedNames[reserved] {
+			l.errorf(message, partName, "Field name \"%s\" is reserved multiple times.", reserved)
+		}
+		reservedNames[reserved] = true
+	}
+	for _, field := range message.Field {
+		number := field.GetNumber()
+		for _, extensionRange := range message.ExtensionRange {
+			if extensionRange.GetStart() <= number && number < extensionRange.GetEnd() {
+				l.errorf(extensionRange, partNumber, "Extension range %d to %d includes field \"%s\" (%d).", extensionRange.GetStart(), extensionRange.GetEnd()-1, field.GetName(), number)
+			}
+		}
+		for _, reserved := range message.ReservedRange {
+			if reserved.GetStart() <= number && number < reserved.GetEnd() {
+				l.errorf(reserved, partNumber, "Field \"%s\" uses reserved number %d.", field.GetName(), number)
+			}
+		}
+		if reservedNames[field.GetName()] {
+			l.errorf(field, partName, "Field name \"%s\" is reserved.", field.GetName())
+		}
+	}
+	for i, lhs := range message.ExtensionRange {
+		for _, reserved := range message.ReservedRange {
+			if lhs.GetEnd() > reserved.GetStart() && reserved.GetEnd() > lhs.GetStart() {
+				l.errorf(lhs, partNumber, "Extension range %d to %d overlaps with reserved range %d to %d.", lhs.GetStart(), lhs.GetEnd()-1, reserved.GetStart(), reserved.GetEnd()-1)
+			}
+		}
+		for _, rhs := range message.ExtensionRange[i+1:] {
+			if lhs.GetEnd() > rhs.GetStart() && rhs.GetEnd() > lhs.GetStart() {
+				l.errorf(lhs, partNumber, "Extension range %d to %d overlaps with already-defined range %d to %d.", rhs.GetStart(), rhs.GetEnd()-1, lhs.GetStart(), lhs.GetEnd()-1)
+			}
+		}
+	}
+
+	l.checkJSONNames(message, false)
+	l.checkJSONNames(message, true)
+}
+
+// checkJSONNames checks that the JSON names of the fields of a message are
+// unique. Without custom, every field is checked by its default JSON name;
+// with it, fields that set json_name are checked by that name instead. In
+// proto2 files, conflicts that involve a default JSON name are allowed.
+func (l *linker) checkJSONNames(message *descriptorpb.DescriptorProto, custom bool) {
+	type details struct {
+		field  *descriptorpb.FieldDescriptorProto
+		name   string
+		custom bool
+	}
+	names := make(map[string]details)
+	for _, field := range message.Field {
+		d := details{field: field, name: jsonName(field.GetName())}
+		if custom && field.JsonName != nil && field.GetJsonName() != d.name {
+			d.name, d.custom = field.GetJsonName(), true
+		}
+		if d.custom && strings.HasPrefix(d.name, "[") && strings.HasSuffix(d.name, "]") {
+			l.errorf(field, partName, "The custom JSON name of field \"%s\" (\"%s\") is invalid: JSON names may not start with '[' and end with ']'.", field.GetName(), d.name)
+			continue
+		}
+		match, ok := names[d.name]
+		if !ok {
+			names[d.name] = d
+			continue
+		}
+		if custom && !d.custom && !match.custom {
+			continue
+		}
+		if l.syntax() == "proto2" && (!d.custom || !match.custom) {
+			continue
+		}
+		kind, matchKind := "default", "default"
+		if d.custom {
+			kind = "custom"
+		}
+		if match.custom {
+			matchKind = "custom"
+		}
+		l.errorf(field, partName, "The %s JSON name of field \"%s\" (\"%s\") conflicts with the %s JSON name of field \"%s\".", kind, field.GetName(), d.name, matchKind, match.field.GetName())
+	}
+}
+
+// checkEnum checks the values and reserved ranges of an enum.
+func (l *linker) checkEnum(enum *descriptorpb.EnumDescriptorProto) {
+	if len(enum.Value) == 0 {
+		l.errorf(enum, partName, "Enums must contain at least one value.")
+	}
+	for _, reserved := range enum.ReservedRange {
+		if reserved.GetStart() > reserved.GetEnd() {
+			l.errorf(reserved, partNumber, "Reserved range end number must be greater than start number.")
+		}
+	}
+	for i, lhs := range enum.ReservedRange {
+		for _, rhs := range enum.ReservedRange[i+1:] {
+			if lhs.GetEnd() >= rhs.GetStart() && rhs.GetEnd() >= lhs.GetStart() {
+				l.errorf(lhs, partNumber, "Reserved range %d to %d overlaps with already-defined range %d to %d.", rhs.GetStart(), rhs.GetEnd(), lhs.GetStart(), lhs.GetEnd())
+			}
+		}
+	}
+	reservedNames := make(map[string]bool)
+	for _, reserved := range enum.ReservedName {
+		if reservedNames[reserved] {
+			l.errorf(enum, partName, "Enum value \"%s\" is reserved multiple times.", reserved)
+		}
+		reservedNames[reserved] = true
+	}
+	for _, value := range enum.Value {
+		for _, reserved := range enum.ReservedRange {
+			if reserved.GetStart() <= value.GetNumber() && value.GetNumber() <= reserved.GetEnd() {
+				l.errorf(reserved, partNumber, "Enum value \"%s\" uses reserved number %d.", value.GetName(), value.GetNumber())
+			}
+		}
+		if reservedNames[value.GetName()] {
+			l.errorf(value, partName, "Enum value \"%s\" is reserved.", value.GetName())
+		}
+	}
+}
+
+// validate checks the rules that depend on the options of the file, once
+// they have been interpreted, and the rules of proto3 files.
+func (l *linker) validate() {
+	fd := l.file.proto
+	scope := fd.GetPackage()
+	for _, message := range fd.MessageType {
+		l.validateMessage(scope, message, nil)
+	}
+	for _, enum := range fd.EnumType {
+		l.validateEnum(scope, enum, nil)
+	}
+	for _, field := range fd.Extension {
+		l.validateField(scope, field, nil)
+	}
+}
+
+func (l *linker) validateMessage(scope string, message *descriptorpb.DescriptorProto, parents []*descriptorpb.DescriptorProto) {
+	name := join(scope, message.GetName())
+	parents = append(parents, message)
+	for _, field := range message.Field {
+		l.validateField(name, field, message)
+	}
+	for _, nested := range message.NestedType {
+		l.validateMessage(name, nested, parents)
+	}
+	for _, enum := range message.EnumType {
+		l.validateEnum(name, enum, parents)
+	}
+	for _, field := range message.Extension {
+		l.validateField(name, field, nil)
+	}
+
+	messageSet := message.GetOptions().GetMessageSetWireFormat()
+	max := int32(maxFieldNumber)
+	if messageSet {
+		max = math.MaxInt32
+	}
+	for _, extensionRange := range message.ExtensionRange {
+		if int64(extensionRange.GetEnd()) > int64(max)+1 {
+			l.errorf(extensionRange, partNumber, "Extension numbers cannot be greater than %d.", max)
+		}
+	}
+	if l.syntax() == "proto3" {
+		if len(message.ExtensionRange) > 0 {
+			l.errorf(message.ExtensionRange[0], partNumber, "Extension ranges are not allowed in proto3.")
+		}
+		if messageSet {
+			l.errorf(message, partName, "MessageSet is not supported in proto3.")
+		}
+	}
+}
+
+// validateField validates a field of the message, or an extension if the
+// message is nil.
+func (l *linker) validateField(scope string, field *descriptorpb.FieldDescriptorProto, message *descriptorpb.DescriptorProto) {
+	options := field.GetOptions()
+	if (options.GetLazy() || options.GetUnverifiedLazy()) && field.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		l.errorf(field, partType, "[lazy = true] can only be specified for submessage fields.")
+	}
+	if options.GetPacked() && !isPackable(field) {
+		l.errorf(field, partType, "[packed = true] can only be specified for repeated primitive fields.")
+	}
+
+	containing := message
+	if field.Extendee != nil {
+		containing, _ = l.element(field.GetExtendee()).(*descriptorpb.DescriptorProto)
+	}
+	if containing.GetOptions().GetMessageSetWireFormat() {
+		switch {
+		case message != nil:
+			l.errorf(field, partName, "MessageSets cannot have fields, only extensions.")
+		case field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL || field.GetType() != descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+			l.errorf(field, partType, "Extensions of MessageSets must be optional messages.")
+		}
+	}
+
+	if entry, ok := l.element(field.GetTypeName()).(*descriptorpb.DescriptorProto); ok && entry.GetOptions().GetMapEntry() {
+		if !isMapEntry(scope, field, entry) {
+			l.errorAt(protofile.Position{}, "map_entry should not be set explicitly. Use map<KeyType, ValueType> instead.")
+		} else {
+			l.validateMapEntry(field, entry)
+		}
+	}
+
+	if l.syntax() != "proto3" {
+		return
+	}
+	if field.Extendee != nil && !optionsMessages[strings.TrimPrefix(field.GetExtendee(), ".")] {
+		l.errorf(field, partExtendee, "Extensions in proto3 are only allowed for defining options.")
+	}
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+		l.errorf(field, partType, "Required fields are not allowed in proto3.")
+	}
+	if field.DefaultValue != nil {
+		l.errorf(field, partDefault, "Explicit default values are not allowed in proto3.")
+	}
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM && l.closedEnum(field.GetTypeName()) {
+		containingName := scope
+		if message == nil {
+			containingName = strings.TrimPrefix(field.GetExtendee(), ".")
+		}
+		l.errorf(field, partType, "Enum type \"%s\" is not an open enum, but is used in \"%s\" which is a proto3 message type.", strings.TrimPrefix(field.GetTypeName(), "."), containingName)
+	}
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+		l.errorf(field, partType, "Groups are not supported in proto3 syntax.")
+	}
+}
+
+// isMapEntry reports whether the entry is a valid map entry message of the
+// field, as the parser generates it for a map field.
+func isMapEntry(scope string, field *descriptorpb.FieldDescriptorProto, entry *descriptorpb.DescriptorProto) bool {
+	if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED || field.Extendee != nil ||
+		len(entry.Extension) > 0 || len(entry.ExtensionRange) > 0 || len(entry.NestedType) > 0 || len(entry.EnumType) > 0 ||
+		len(entry.Field) != 2 || entry.GetName() != mapEntryName(field.GetName()) ||
+		field.GetTypeName() != "."+join(scope, entry.GetName()) {
+		return false
+	}
+	for i, name := range []string{"key", "value"} {
+		f := entry.Field[i]
+		if f.GetName() != name || f.GetNumber() != int32(i+1) || f.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *linker) validateMapEntry(field *descriptorpb.FieldDescriptorProto, entry *descriptorpb.DescriptorProto) {
+	switch entry.Field[0].GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		l.errorf(field, partType, "Key in map fields cannot be enum types.")
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+		descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP,
+		descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		l.errorf(field, partType, "Key in map fields cannot be float/double, bytes or message types.")
+	}
+	value := entry.Field[1]
+	if value.GetType() != descriptorpb.FieldDescriptorProto_TYPE_ENUM {
+		return
+	}
+	if enum, ok := l.element(value.GetTypeName()).(*descriptorpb.EnumDescriptorProto); ok && len(enum.Value) > 0 && enum.Value[0].GetNumber() != 0 {
+		l.errorf(field, partType, "Enum value in map must define 0 as the first value.")
+	}
+}
+
+// validateEnum validates an enum of the messages of parents, which are
+// ordered from outermost to innermost.
+func (l *linker) validateEnum(scope string, enum *descriptorpb.EnumDescriptorProto, parents []*descriptorpb.DescriptorProto) {
+	open := l.openEnum(enum, parents)
+	if open && len(enum.Value) > 0 && enum.Value[0].GetNumber() != 0 {
+		l.errorf(enum.Value[0], partNumber, "The first enum value must be zero for open enums.")
+	}
+
+	aliased := false
+	values := make(map[int32]string)
+	for _, value := range enum.Value {
+		name := join(scope, value.GetName())
+		existing, ok := values[value.GetNumber()]
+		if !ok {
+			values[value.GetNumber()] = name
+			continue
+		}
+		aliased = true
+		if !enum.GetOptions().GetAllowAlias() {
+			l.errorf(value, partNumber, "\"%s\" uses the same enum value as \"%s\". If this is intended, set 'option allow_alias = true;' to the enum definition.", name, existing)
+		}
+	}
+	if enum.GetOptions().GetAllowAlias() && !aliased {
+		l.errorf(enum, partName, "\"%s\" declares support for enum aliases but no enum values share field numbers. Please remove the unnecessary 'option allow_alias = true;' declaration.", join(scope, enum.GetName()))
+	}
+
+	if !open {
+		return
+	}
+	// Values must also be unique once the name of the enum is removed from
+	// them, and they are converted to upper camel case, since that is how
+	// some languages name them.
+	stripped := make(map[string]*descriptorpb.EnumValueDescriptorProto)
+	for _, value := range enum.Value {
+		name := enumValuePascalCase(removeEnumPrefix(value.GetName(), enum.GetName()))
+		existing, ok := stripped[name]
+		if !ok {
+			stripped[name] = value
+			continue
+		}
+		if existing.GetName() != value.GetName() && existing.GetNumber() != value.GetNumber() {
+			l.errorf(value, partName, "Enum name %s has the same name as %s if you ignore case and strip out the enum name prefix (if any). (If you are using allow_alias, please assign the same numeric value to both enums.)", value.GetName(), existing.GetName())
+		}
+	}
+}
+
+// openEnum reports whether an enum of the file is open, which depends on the
+// syntax of the file, or, in editions, its features.
+func (l *linker) openEnum(enum *descriptorpb.EnumDescriptorProto, parents []*descriptorpb.DescriptorProto) bool {
+	switch l.syntax() {
+	case "proto2":
+		return false
+	case "proto3":
+		return true
+	}
+	features := []*descriptorpb.FeatureSet{enum.GetOptions().GetFeatures()}
+	for i := len(parents) - 1; i >= 0; i-- {
+		features = append(features, parents[i].GetOptions().GetFeatures())
+	}
+	features = append(features, l.file.proto.GetOptions().GetFeatures())
+	for _, feature := range features {
+		if feature.GetEnumType() != descriptorpb.FeatureSet_ENUM_TYPE_UNKNOWN {
+			return feature.GetEnumType() == descriptorpb.FeatureSet_OPEN
+		}
+	}
+	return true
+}
+
+// closedEnum reports whether the enum with the fully qualified type name is
+// closed. Enums of the file being linked are never closed, since the check
+// only applies to proto3 files.
+func (l *linker) closedEnum(typeName string) bool {
+	descriptor, err := l.registry.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(typeName, ".")))
+	if err != nil {
+		return false
+	}
+	enum, ok := descriptor.(protoreflect.EnumDescriptor)
+	return ok && enum.IsClosed()
+}
+
+// element returns the descriptor that the fully qualified type name refers
+// to, or nil if it is not defined.
+func (l *linker) element(typeName string) any {
+	if symbol := l.symbols[strings.TrimPrefix(typeName, ".")]; symbol != nil && typeName != "" {
+		return symbol.element
+	}
+	return nil
+}
+
+// syntax returns the syntax of the file being linked, which is "proto2",
+// "proto3", or "editions".
+func (l *linker) syntax() string {
+	if syntax := l.file.proto.GetSyntax(); syntax != "" {
+		return syntax
+	}
+	return "proto2"
+}
+
+// isPackable reports whether a field is a repeated field of a scalar type
+// whose values can be packed.
+func isPackable(field *descriptorpb.FieldDescriptorProto) bool {
+	if field.GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return false
+	}
+	switch field.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+		descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	}
+	return true
+}
+
+// removeEnumPrefix removes the name of an enum from the start of the name of
+// one of its values, ignoring case and underscores, unless it would leave the
+// value with an empty name.
+func removeEnumPrefix(value, enum string) string {
+	prefix := strings.ToLower(strings.ReplaceAll(enum, "_", ""))
+	i, j := 0, 0
+	for ; i < len(value) && j < len(prefix); i++ {
+		if value[i] == '_' {
+			continue
+		}
+		if lower(value[i]) != prefix[j] {
+			return value
+		}
+		j++
+	}
+	if j < len(prefix) {
+		return value
+	}
+	for i < len(value) && value[i] == '_' {
+		i++
+	}
+	if i == len(value) {
+		return value
+	}
+	return value[i:]
+}
+
+// enumValuePascalCase converts the name of an enum value, which is usually
+// in upper snake case, to upper camel case.
+func enumValuePascalCase(name string) string {
+	var sb strings.Builder
+	upper := true
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_':
+			upper = true
+			continue
+		case upper && c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case !upper:
+			c = lower(c)
+		}
+		sb.WriteByte(c)
+		upper = false
+	}
+	return sb.String()
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
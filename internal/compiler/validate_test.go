@@ -0,0 +1,112 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompile_ValidationErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "zero field number",
+			src:  `message Foo { optional int32 a = 0; }`,
+			want: []string{`a.proto:1:34: Field numbers must be positive integers.`},
+		},
+		{
+			name: "implementation reserved field number",
+			src:  `message Foo { optional int32 a = 19000; }`,
+			want: []string{`a.proto:1:34: Field numbers 19000 through 19999 are reserved for the protocol buffer library implementation.`},
+		},
+		{
+			name: "field number too large",
+			src:  `message Foo { optional int32 a = 536870912; }`,
+			want: []string{`a.proto:1:34: Field numbers cannot be greater than 536870911.`},
+		},
+		{
+			name: "reserved number",
+			src:  `message Foo { reserved 1 to 5; optional int32 a = 3; }`,
+			want: []string{`a.proto:1:24: Field "a" uses reserved number 3.`},
+		},
+		{
+			name: "reserved name",
+			src:  `message Foo { reserved "a"; optional int32 a = 3; }`,
+			want: []string{`a.proto:1:44: Field name "a" is reserved.`},
+		},
+		{
+			name: "overlapping extension ranges",
+			src:  `message Foo { extensions 5 to 10; extensions 8 to 12; }`,
+			want: []string{`a.proto:1:26: Extension range 8 to 12 overlaps with already-defined range 5 to 10.`},
+		},
+		{
+			name: "reserved enum number",
+			src:  `enum E { A = 0; reserved 0; }`,
+			want: []string{`a.proto:1:26: Enum value "A" uses reserved number 0.`},
+		},
+		{
+			name: "open enum first value",
+			src:  `syntax = "proto3"; enum E { A = 1; }`,
+			want: []string{`a.proto:1:33: The first enum value must be zero for open enums.`},
+		},
+		{
+			name: "enum alias",
+			src:  `enum E { A = 0; B = 0; }`,
+			want: []string{`a.proto:1:21: "B" uses the same enum value as "A". If this is intended, set 'option allow_alias = true;' to the enum definition.`},
+		},
+		{
+			name: "unnecessary allow_alias",
+			src:  `enum E { option allow_alias = true; A = 0; B = 1; }`,
+			want: []string{`a.proto:1:6: "E" declares support for enum aliases but no enum values share field numbers. Please remove the unnecessary 'option allow_alias = true;' declaration.`},
+		},
+		{
+			name: "enum value prefix conflict",
+			src:  `syntax = "proto3"; enum E { E_A = 0; A = 1; }`,
+			want: []string{`a.proto:1:38: Enum name A has the same name as E_A if you ignore case and strip out the enum name prefix (if any). (If you are using allow_alias, please assign the same numeric value to both enums.)`},
+		},
+		{
+			name: "JSON name conflict",
+			src:  `syntax = "proto3"; message Foo { int32 foo_bar = 1; int32 fooBar = 2; }`,
+			want: []string{`a.proto:1:59: The default JSON name of field "fooBar" ("fooBar") conflicts with the default JSON name of field "foo_bar".`},
+		},
+		{
+			name: "custom JSON name conflict",
+			src:  `message Foo { optional int32 a = 1 [json_name = "b"]; optional int32 c = 3 [json_name = "b"]; }`,
+			want: []string{`a.proto:1:70: The custom JSON name of field "c" ("b") conflicts with the custom JSON name of field "a".`},
+		},
+		{
+			name: "extension range in proto3",
+			src:  `syntax = "proto3"; message Foo { extensions 1 to 10; }`,
+			want: []string{`a.proto:1:45: Extension ranges are not allowed in proto3.`},
+		},
+		{
+			name: "packed string",
+			src:  `message Foo { repeated string a = 1 [packed = true]; }`,
+			want: []string{`a.proto:1:24: [packed = true] can only be specified for repeated primitive fields.`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := compileErrors(t, tc.src)
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCompile_Proto3ClosedEnum_ReportsError(t *testing.T) {
+	_, got := compile(t, map[string]string{
+		"a.proto": `syntax = "proto3"; import "b.proto"; message Foo { E e = 1; }`,
+		"b.proto": `enum E { A = 0; }`,
+	}, "a.proto")
+
+	want := []string{`a.proto:1:52: Enum type "E" is not an open enum, but is used in "Foo" which is a proto3 message type.`}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Compile: errors mismatch (-want +got):\n%s", diff)
+	}
+}
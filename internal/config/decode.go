@@ -0,0 +1,207 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decoder decodes node trees into Go values, using the `json` struct tags to
+// map object members to struct fields.
+type decoder struct {
+	errs Errors
+}
+
+// decode decodes the node into the value pointed to by out. All errors are
+// collected rather than stopping at the first, so that a single load can
+// report every problem with a file.
+func decode(n *node, out any) error {
+	d := &decoder{}
+	d.decode(n, reflect.ValueOf(out).Elem(), "")
+	return d.errs.Err()
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func (d *decoder) errorf(n *node, field, format string, args ...any) {
+	d.errs = append(d.errs, &Error{
+		Position: n.pos,
+		Field:    field,
+		Err:      fmt.Errorf(format, args...),
+	})
+}
+
+func (d *decoder) mismatch(n *node, field string, want string) {
+	d.errorf(n, field, "expected %s, but got %s", want, n.kind)
+}
+
+func (d *decoder) decode(n *node, v reflect.Value, field string) {
+	if n.kind == kindNull {
+		v.SetZero()
+		return
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.decode(n, v.Elem(), field)
+		return
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		d.decodeText(n, v, field)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if n.kind != kindString {
+			d.mismatch(n, field, "string")
+			return
+		}
+		v.SetString(n.scalar)
+	case reflect.Bool:
+		if n.kind != kindBool {
+			d.mismatch(n, field, "boolean")
+			return
+		}
+		v.SetBool(n.scalar == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.kind != kindNumber {
+			d.mismatch(n, field, "integer")
+			return
+		}
+		i, err := strconv.ParseInt(n.scalar, 10, v.Type().Bits())
+		if err != nil {
+			d.errorf(n, field, "invalid integer %s", n.scalar)
+			return
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.kind != kindNumber {
+			d.mismatch(n, field, "integer")
+			return
+		}
+		u, err := strconv.ParseUint(n.scalar, 10, v.Type().Bits())
+		if err != nil {
+			d.errorf(n, field, "invalid non-negative integer %s", n.scalar)
+			return
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if n.kind != kindNumber {
+			d.mismatch(n, field, "number")
+			return
+		}
+		f, err := strconv.ParseFloat(n.scalar, v.Type().Bits())
+		if err != nil {
+			d.errorf(n, field, "invalid number %s", n.scalar)
+			return
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		d.decodeSlice(n, v, field)
+	case reflect.Map:
+		d.decodeMap(n, v, field)
+	case reflect.Struct:
+		d.decodeStruct(n, v, field)
+	case reflect.Interface:
+		// Interfaces are only used as a generic escape hatch; retain the
+		// textual form of scalars.
+		if n.kind != kindObject && n.kind != kindArray {
+			v.Set(reflect.ValueOf(n.scalar))
+		}
+	default:
+		d.errorf(n, field, "unsupported field type %v", v.Type())
+	}
+}
+
+func (d *decoder) decodeText(n *node, v reflect.Value, field string) {
+	if n.kind != kindString {
+		d.mismatch(n, field, "string")
+		return
+	}
+	unmarshaler := v.Addr().Interface().(encoding.TextUnmarshaler)
+	if err := unmarshaler.UnmarshalText([]byte(n.scalar)); err != nil {
+		d.errorf(n, field, "%w", err)
+	}
+}
+
+func (d *decoder) decodeSlice(n *node, v reflect.Value, field string) {
+	if n.kind != kindArray {
+		d.mismatch(n, field, "array")
+		return
+	}
+	slice := reflect.MakeSlice(v.Type(), len(n.items), len(n.items))
+	for i, item := range n.items {
+		d.decode(item, slice.Index(i), fmt.Sprintf("%s[%d]", field, i))
+	}
+	v.Set(slice)
+}
+
+func (d *decoder) decodeMap(n *node, v reflect.Value, field string) {
+	if n.kind != kindObject {
+		d.mismatch(n, field, "object")
+		return
+	}
+	if v.Type().Key().Kind() != reflect.String {
+		d.errorf(n, field, "unsupported map key type %v", v.Type().Key())
+		return
+	}
+	m := reflect.MakeMapWithSize(v.Type(), len(n.members))
+	for _, member := range n.members {
+		value := reflect.New(v.Type().Elem()).Elem()
+		d.decode(member.value, value, joinField(field, member.key))
+		m.SetMapIndex(reflect.ValueOf(member.key).Convert(v.Type().Key()), value)
+	}
+	v.Set(m)
+}
+
+func (d *decoder) decodeStruct(n *node, v reflect.Value, field string) {
+	if n.kind != kindObject {
+		d.mismatch(n, field, "object")
+		return
+	}
+	fields := structFields(v.Type())
+	for _, member := range n.members {
+		path := joinField(field, member.key)
+		index, ok := fields[member.key]
+		if !ok {
+			d.errs = append(d.errs, &Error{
+				Position: member.pos,
+				Field:    path,
+				Err:      fmt.Errorf("unknown field %q", member.key),
+			})
+			continue
+		}
+		d.decode(member.value, v.FieldByIndex(index), path)
+	}
+}
+
+// structFields returns the index of every decodable field in the struct type,
+// keyed by the name of the field in the document.
+func structFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int, t.NumField())
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Index
+	}
+	return fields
+}
+
+func joinField(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/semver"
+)
+
+// Dependency is a dependency of a target or project on another target or
+// registry project.
+type Dependency struct {
+	// Name is the name of the target or project that is depended on.
+	Name string
+
+	// Constraint is the range of versions of the project that are accepted.
+	// Targets are not versioned, so dependencies on targets accept any
+	// version.
+	Constraint semver.Constraint
+}
+
+// ParseDependency parses a dependency of the form "name" or
+// "name <constraint>", such as "googleapis/googleapis >= 1.2, < 2".
+func ParseDependency(s string) (Dependency, error) {
+	name, constraint := s, ""
+	if i := strings.IndexAny(s, " \t<>=!^~"); i >= 0 {
+		name, constraint = s[:i], s[i:]
+	}
+	if name == "" {
+		return Dependency{}, fmt.Errorf("dependency %q has no name", s)
+	}
+	parsed, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return Dependency{}, fmt.Errorf("dependency %q: %w", s, err)
+	}
+	return Dependency{Name: name, Constraint: parsed}, nil
+}
+
+// DependencyName returns the name of the target or project that a
+// dependency, which may include a version constraint, depends on.
+func DependencyName(s string) string {
+	if i := strings.IndexAny(s, " \t<>=!^~"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+)
+
+func TestParseDependency(t *testing.T) {
+	testCases := []struct {
+		input          string
+		wantName       string
+		wantConstraint string
+		wantErr        bool
+	}{
+		{input: "common", wantName: "common", wantConstraint: "*"},
+		{input: "google/fhir", wantName: "google/fhir", wantConstraint: "*"},
+		{input: "googleapis/googleapis >= 1.2, < 2", wantName: "googleapis/googleapis", wantConstraint: ">= 1.2, < 2"},
+		{input: "public/google/fhir^1.2", wantName: "public/google/fhir", wantConstraint: "^1.2"},
+		{input: ">= 1.2", wantErr: true},
+		{input: "google/fhir >= main", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := config.ParseDependency(tc.input)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseDependency(%q): got nil error, want error", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDependency(%q): unexpected error: %v", tc.input, err)
+			}
+			if got.Name != tc.wantName || got.Constraint.String() != tc.wantConstraint {
+				t.Errorf("ParseDependency(%q): got %q %q, want %q %q",
+					tc.input, got.Name, got.Constraint, tc.wantName, tc.wantConstraint,
+				)
+			}
+			if name := config.DependencyName(tc.input); name != tc.wantName {
+				t.Errorf("DependencyName(%q): got %q, want %q", tc.input, name, tc.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+/*
+Package config provides the typed models and loaders for the protobuild
+configuration files.
+
+Configuration files are first parsed into a position-annotated document tree,
+which is then decoded into the typed models. This allows every error -- whether
+syntactic or semantic -- to be reported against the file, line, column, and
+field that caused it.
+*/
+package config
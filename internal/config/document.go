@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// document is a configuration file that has been parsed into a node tree.
+type document struct {
+	path string
+	root *node
+}
+
+// readDocument reads and parses the configuration file at path, in the format
+// indicated by its extension, and validates it against the embedded JSON
+// Schema with the given name.
+func readDocument(path, schema string) (*document, error) {
+	doc := &document{path: path}
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, doc.annotate(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := format.parse(data)
+	if err != nil {
+		return nil, doc.annotate(err)
+	}
+	doc.root = root
+	if err := doc.validate(schema); err != nil {
+		return nil, doc.annotate(err)
+	}
+	return doc, nil
+}
+
+// decode decodes the document into the value pointed to by out.
+func (d *document) decode(out any) error {
+	return d.annotate(decode(d.root, out))
+}
+
+// annotate attributes any errors to this document, filling in the file and
+// resolving the position of any errors that only name a field.
+func (d *document) annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if errors.As(err, &errs) {
+		for i, err := range errs {
+			errs[i] = d.annotate(err)
+		}
+		return errs
+	}
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		return &Error{File: d.path, Err: err}
+	}
+	if cerr.File == "" {
+		cerr.File = d.path
+	}
+	if !cerr.Position.IsValid() && cerr.Field != "" {
+		cerr.Position = d.locate(cerr.Field)
+	}
+	return err
+}
+
+// locate finds the position of the field with the given path, such as
+// "registries[0].name". If the field does not exist in the document, the
+// position of its closest existing ancestor is returned instead.
+func (d *document) locate(field string) Position {
+	if d.root == nil {
+		return Position{}
+	}
+	return d.find(splitField(field)).pos
+}
+
+// find finds the node at the path of object keys and array indices. If the
+// node does not exist, its closest existing ancestor is returned instead.
+func (d *document) find(path []string) *node {
+	current := d.root
+	for _, part := range path {
+		var next *node
+		if index, err := strconv.Atoi(part); err == nil && current.kind == kindArray {
+			if index >= 0 && index < len(current.items) {
+				next = current.items[index]
+			}
+		} else if current.kind == kindObject {
+			if m := current.lookup(part); m != nil {
+				next = m.value
+			}
+		}
+		if next == nil {
+			break
+		}
+		current = next
+	}
+	return current
+}
+
+// splitField splits a field path into its object keys and array indices.
+func splitField(field string) []string {
+	field = strings.ReplaceAll(field, "[", ".")
+	field = strings.ReplaceAll(field, "]", "")
+	return strings.Split(field, ".")
+}
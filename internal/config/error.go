@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSyntax is returned when a configuration file is not well-formed.
+var ErrSyntax = errors.New("syntax error")
+
+// ErrNotFound is returned when a configuration file could not be found.
+var ErrNotFound = errors.New("not found")
+
+// Position is a location within a configuration file.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid returns whether the position refers to a real location in a file.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String converts this position into a "line:column" string.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Error is an error that occurred while loading a configuration file, which
+// may be attributed to a specific position and field within that file.
+type Error struct {
+	// File is the path to the configuration file.
+	File string
+
+	// Position is the location in the file where the error occurred. This
+	// may be the zero value if the error is not attributable to a location.
+	Position Position
+
+	// Field is the path to the field that caused the error, such as
+	// "registries[0].name". This may be empty if the error is not attributable
+	// to a field.
+	Field string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.File)
+	if e.Position.IsValid() {
+		sb.WriteByte(':')
+		sb.WriteString(e.Position.String())
+	}
+	sb.WriteString(": ")
+	if e.Field != "" {
+		sb.WriteString(e.Field)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var _ error = (*Error)(nil)
+
+// Errors is a collection of errors that occurred while loading or validating
+// configuration files.
+type Errors []error
+
+// Error implements the error interface.
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the underlying errors.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Err returns this collection as an error, or nil if it is empty.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+var _ error = (*Errors)(nil)
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a file format that configuration files may be written in.
+type Format string
+
+const (
+	// FormatJSON is the JSON format, used by files with a ".json" extension.
+	FormatJSON Format = "json"
+
+	// FormatYAML is the YAML format, used by files with a ".yaml" or ".yml"
+	// extension.
+	FormatYAML Format = "yaml"
+
+	// FormatTOML is the TOML format, used by files with a ".toml" extension.
+	FormatTOML Format = "toml"
+)
+
+// ErrUnsupportedFormat is returned when a configuration file is not in a
+// supported format.
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// extensions maps each supported file extension to its format, in the order
+// that files are searched for.
+var extensions = []struct {
+	ext    string
+	format Format
+}{
+	{".json", FormatJSON},
+	{".yaml", FormatYAML},
+	{".yml", FormatYAML},
+	{".toml", FormatTOML},
+}
+
+// FormatOf returns the format of the configuration file at path, based on its
+// extension.
+func FormatOf(path string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, entry := range extensions {
+		if entry.ext == ext {
+			return entry.format, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q; must be one of %s", ErrUnsupportedFormat, ext, strings.Join(Extensions(), ", "))
+}
+
+// Extensions returns every file extension that configuration files may use.
+func Extensions() []string {
+	result := make([]string, 0, len(extensions))
+	for _, entry := range extensions {
+		result = append(result, entry.ext)
+	}
+	return result
+}
+
+// FileNames returns every file name that a configuration file with the given
+// base name may have, such as "protobuild.json" and "protobuild.yaml".
+func FileNames(base string) []string {
+	result := make([]string, 0, len(extensions))
+	for _, ext := range Extensions() {
+		result = append(result, base+ext)
+	}
+	return result
+}
+
+// findFile finds the configuration file with the given base name in dir. It is
+// an error for the directory to contain more than one such file, since it
+// would be ambiguous which should be used.
+func findFile(dir, base string) (string, error) {
+	var found []string
+	for _, name := range FileNames(base) {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("%w: no %s file in %s", ErrNotFound, base, dir)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("ambiguous %s file in %s; found %s", base, dir, strings.Join(found, ", "))
+}
+
+// parse parses data in this format into a node tree.
+func (f Format) parse(data []byte) (*node, error) {
+	switch f {
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatYAML:
+		return parseYAML(data)
+	case FormatTOML:
+		return parseTOML(data)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, string(f))
+}
+
+// encode encodes the value in the format. Values are encoded through their
+// JSON form, which keeps the field names of every format consistent with the
+// schema.
+func (f Format) encode(value any) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if f == FormatJSON {
+		return append(data, '\n'), nil
+	}
+	if f == FormatYAML {
+		// JSON is YAML, so decoding it into a node keeps the fields in the
+		// order of the schema, rather than sorting them.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		blockStyle(&doc)
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), encoder.Close()
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return toml.Marshal(generic)
+}
+
+// blockStyle clears the flow and quoting styles of the JSON that the node was
+// decoded from, so that it is encoded in the usual style of YAML.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, child := range n.Content {
+		blockStyle(child)
+	}
+}
+
+// save writes the value to the file at path, in the format indicated by its
+// extension. The file is replaced atomically, so that it is never left
+// partially written.
+func save(path string, value any) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	data, err := format.encode(value)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
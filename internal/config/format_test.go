@@ -0,0 +1,206 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFormatOf(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		want    config.Format
+		wantErr error
+	}{
+		{name: "json", path: "protobuild.json", want: config.FormatJSON},
+		{name: "yaml", path: "protobuild.yaml", want: config.FormatYAML},
+		{name: "yml", path: "protobuild.yml", want: config.FormatYAML},
+		{name: "toml", path: "protobuild.toml", want: config.FormatTOML},
+		{name: "uppercase", path: "PROTOBUILD.JSON", want: config.FormatJSON},
+		{name: "unsupported", path: "protobuild.ini", wantErr: config.ErrUnsupportedFormat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := config.FormatOf(tc.path)
+
+			if !cmp.Equal(err, tc.wantErr, cmpopts.EquateErrors()) {
+				t.Fatalf("FormatOf: got err %v, want %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("FormatOf: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadWorkspace_AnyFormat_ReturnsSameWorkspace(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "json",
+			file: "protobuild.json",
+			content: `{
+				"targets": ["**/protobuild-target.*"],
+				"registries": [{"name": "public", "url": "https://example.com/registry.git"}],
+				"plugin-options": {"go": ["paths=source_relative"]},
+				"output": "gen"
+			}`,
+		}, {
+			name: "yaml",
+			file: "protobuild.yaml",
+			content: `
+targets:
+  - "**/protobuild-target.*"
+registries:
+  - name: public
+    url: https://example.com/registry.git
+plugin-options:
+  go: [paths=source_relative]
+output: gen
+`,
+		}, {
+			name: "toml",
+			file: "protobuild.toml",
+			content: `
+targets = ["**/protobuild-target.*"]
+output = "gen"
+
+[plugin-options]
+go = ["paths=source_relative"]
+
+[[registries]]
+name = "public"
+url = "https://example.com/registry.git"
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, filepath.Join(t.TempDir(), tc.file), tc.content)
+			want := &config.Workspace{
+				Targets: glob.NewPatterns("**/protobuild-target.*"),
+				Registries: []config.RegistryRef{
+					{Name: "public", URL: "https://example.com/registry.git"},
+				},
+				PluginOptions: map[string][]string{
+					"go": {"paths=source_relative"},
+				},
+				Output: "gen",
+				Path:   path,
+			}
+
+			got, err := config.LoadWorkspace(path)
+			if err != nil {
+				t.Fatalf("LoadWorkspace: unexpected error: %v", err)
+			}
+
+			opts := cmpopts.IgnoreUnexported(config.Workspace{})
+			if !cmp.Equal(got, want, opts) {
+				t.Errorf("LoadWorkspace: (-got +want):\n%s", cmp.Diff(got, want, opts))
+			}
+		})
+	}
+}
+
+func TestLoadWorkspace_AnyFormat_ReportsOriginalPosition(t *testing.T) {
+	testCases := []struct {
+		name      string
+		file      string
+		content   string
+		want      config.Position
+		wantField string
+	}{
+		{
+			name: "yaml type mismatch",
+			file: "protobuild.yaml",
+			content: `targets:
+  - "*.json"
+registries:
+  - name: public
+    url: 42
+`,
+			want:      config.Position{Line: 5, Column: 10},
+			wantField: "registries[0].url",
+		}, {
+			name: "yaml unknown field",
+			file: "protobuild.yml",
+			content: `targets: ["*.json"]
+outptu: gen
+`,
+			want:      config.Position{Line: 2, Column: 1},
+			wantField: "outptu",
+		}, {
+			name: "yaml syntax error",
+			file: "protobuild.yaml",
+			content: `targets: ["*.json"]
+output: gen: dir
+`,
+			want: config.Position{Line: 2, Column: 1},
+		}, {
+			name: "toml type mismatch",
+			file: "protobuild.toml",
+			content: `targets = ["*.json"]
+
+[[registries]]
+name = "public"
+url = 42
+`,
+			want:      config.Position{Line: 5, Column: 7},
+			wantField: "registries[0].url",
+		}, {
+			name: "toml unknown field",
+			file: "protobuild.toml",
+			content: `targets = ["*.json"]
+outptu = "gen"
+`,
+			want:      config.Position{Line: 2, Column: 1},
+			wantField: "outptu",
+		}, {
+			name: "toml syntax error",
+			file: "protobuild.toml",
+			content: `targets = ["*.json"]
+output = = "gen"
+`,
+			want: config.Position{Line: 2, Column: 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, filepath.Join(t.TempDir(), tc.file), tc.content)
+
+			_, err := config.LoadWorkspace(path)
+
+			var got *config.Error
+			if !errors.As(err, &got) {
+				t.Fatalf("LoadWorkspace: got err %v, want config.Error", err)
+			}
+			if got.Position != tc.want || got.Field != tc.wantField {
+				t.Errorf("LoadWorkspace: got %v %q, want %v %q", got.Position, got.Field, tc.want, tc.wantField)
+			}
+		})
+	}
+}
+
+func TestFindWorkspace_MultipleFormats_ReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "protobuild.json"), `{}`)
+	writeFile(t, filepath.Join(dir, "protobuild.yaml"), ``)
+
+	_, err := config.FindWorkspace(dir)
+
+	if err == nil {
+		t.Errorf("FindWorkspace: got nil error, want ambiguity error")
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// lines maps byte offsets in a document to line and column positions.
+type lines []int
+
+func newLines(data []byte) lines {
+	starts := lines{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// position converts the byte offset into a 1-based line and column.
+func (l lines) position(offset int) Position {
+	line := sort.Search(len(l), func(i int) bool { return l[i] > offset }) - 1
+	return Position{
+		Line:   line + 1,
+		Column: offset - l[line] + 1,
+	}
+}
+
+// jsonParser parses a JSON document into a node tree.
+type jsonParser struct {
+	data  []byte
+	lines lines
+	dec   *json.Decoder
+}
+
+// parseJSON parses the JSON document in data into a node tree.
+func parseJSON(data []byte) (*node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	p := &jsonParser{
+		data:  data,
+		lines: newLines(data),
+		dec:   dec,
+	}
+	n, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, p.errorf(int(dec.InputOffset()), "unexpected content after top-level value")
+	}
+	return n, nil
+}
+
+// start computes the offset of the start of the next token, skipping any
+// whitespace and delimiters that the decoder does not report.
+func (p *jsonParser) start() int {
+	offset := int(p.dec.InputOffset())
+	for offset < len(p.data) {
+		switch p.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+			continue
+		}
+		break
+	}
+	return offset
+}
+
+func (p *jsonParser) token() (json.Token, Position, error) {
+	offset := p.start()
+	tok, err := p.dec.Token()
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// The offset of a syntax error is just past the offending byte.
+			return nil, Position{}, p.errorf(int(syntaxErr.Offset)-1, "%s", syntaxErr.Error())
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, Position{}, p.errorf(len(p.data), "unexpected end of document")
+		}
+		return nil, Position{}, p.errorf(offset, "%s", err.Error())
+	}
+	return tok, p.lines.position(offset), nil
+}
+
+func (p *jsonParser) parse() (*node, error) {
+	tok, pos, err := p.token()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseValue(tok, pos)
+}
+
+func (p *jsonParser) parseValue(tok json.Token, pos Position) (*node, error) {
+	switch v := tok.(type) {
+	case nil:
+		return &node{kind: kindNull, pos: pos}, nil
+	case bool:
+		return &node{kind: kindBool, pos: pos, scalar: fmt.Sprint(v)}, nil
+	case json.Number:
+		return &node{kind: kindNumber, pos: pos, scalar: v.String()}, nil
+	case string:
+		return &node{kind: kindString, pos: pos, scalar: v}, nil
+	case json.Delim:
+		if v == '[' {
+			return p.parseArray(pos)
+		}
+		return p.parseObject(pos)
+	}
+	return nil, &Error{
+		Position: pos,
+		Err:      fmt.Errorf("%w: unexpected token %v", ErrSyntax, tok),
+	}
+}
+
+func (p *jsonParser) parseArray(pos Position) (*node, error) {
+	n := &node{kind: kindArray, pos: pos}
+	for {
+		tok, pos, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == json.Delim(']') {
+			return n, nil
+		}
+		item, err := p.parseValue(tok, pos)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+	}
+}
+
+func (p *jsonParser) parseObject(pos Position) (*node, error) {
+	n := &node{kind: kindObject, pos: pos}
+	for {
+		tok, keyPos, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == json.Delim('}') {
+			return n, nil
+		}
+		key := tok.(string)
+		if n.lookup(key) != nil {
+			return nil, &Error{
+				Position: keyPos,
+				Err:      fmt.Errorf("%w: duplicate key %q", ErrSyntax, key),
+			}
+		}
+		value, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		n.members = append(n.members, &member{key: key, pos: keyPos, value: value})
+	}
+}
+
+func (p *jsonParser) errorf(offset int, format string, args ...any) error {
+	return &Error{
+		Position: p.lines.position(min(offset, max(len(p.data)-1, 0))),
+		Err:      fmt.Errorf("%w: %s", ErrSyntax, fmt.Sprintf(format, args...)),
+	}
+}
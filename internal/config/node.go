@@ -0,0 +1,96 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// kind is the kind of value held by a node.
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindNumber
+	kindString
+	kindArray
+	kindObject
+)
+
+// String converts this kind into the name used in error messages.
+func (k kind) String() string {
+	switch k {
+	case kindNull:
+		return "null"
+	case kindBool:
+		return "boolean"
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	case kindArray:
+		return "array"
+	case kindObject:
+		return "object"
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+// node is a format-agnostic, position-annotated value from a configuration
+// file.
+type node struct {
+	kind kind
+	pos  Position
+
+	// scalar is the textual representation of a bool, number, or string.
+	scalar string
+
+	// items contains the elements of an array.
+	items []*node
+
+	// members contains the members of an object, in document order.
+	members []*member
+}
+
+// member is a single key-value pair in an object node.
+type member struct {
+	key   string
+	pos   Position
+	value *node
+}
+
+// lookup finds the member of an object node with the given key.
+func (n *node) lookup(key string) *member {
+	for _, m := range n.members {
+		if m.key == key {
+			return m
+		}
+	}
+	return nil
+}
+
+// interface_ converts this node into the generic Go representation that
+// encoding/json would produce, with numbers decoded as json.Number.
+func (n *node) interface_() any {
+	switch n.kind {
+	case kindBool:
+		return n.scalar == "true"
+	case kindNumber:
+		return json.Number(n.scalar)
+	case kindString:
+		return n.scalar
+	case kindArray:
+		items := make([]any, 0, len(n.items))
+		for _, item := range n.items {
+			items = append(items, item.interface_())
+		}
+		return items
+	case kindObject:
+		members := make(map[string]any, len(n.members))
+		for _, m := range n.members {
+			members[m.key] = m.value.interface_()
+		}
+		return members
+	}
+	return nil
+}
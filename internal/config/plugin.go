@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Plugin is a recipe for installing a protoc plugin, which is defined in a
+// registry.
+type Plugin struct {
+	// Name is the name of the plugin as it is used by outputs, such as "go"
+	// for protoc-gen-go.
+	Name string `json:"name" jsonschema:"pattern=^[A-Za-z0-9][A-Za-z0-9_-]*$" description:"The name of the plugin as it is used by outputs, such as 'go' for protoc-gen-go."`
+
+	// Description is a human-readable description of the plugin.
+	Description string `json:"description,omitempty" description:"A human-readable description of the plugin."`
+
+	// Versions are the installable versions of the plugin, ordered from
+	// newest to oldest.
+	Versions []PluginVersion `json:"versions" jsonschema:"minItems=1" description:"The installable versions of the plugin, ordered from newest to oldest."`
+}
+
+// PluginVersion is a single installable version of a plugin. Exactly one of
+// Go, Archives, and Build must be set.
+type PluginVersion struct {
+	// Version is the name of the version, such as "v1.34.2".
+	Version string `json:"version" description:"The name of the version, such as 'v1.34.2'."`
+
+	// Go is the path of the Go package of the plugin, which is installed with
+	// "go install <go>@<version>".
+	Go string `json:"go,omitempty" description:"The path of the Go package of the plugin, which is installed with 'go install <go>@<version>'."`
+
+	// Archives are the prebuilt executables of the plugin, keyed by the
+	// "<os>/<arch>" platform that they run on, such as "linux/amd64".
+	Archives map[string]PluginArchive `json:"archives,omitempty" description:"The prebuilt executables of the plugin, keyed by the '<os>/<arch>' platform that they run on, such as 'linux/amd64'."`
+
+	// Build is the command that builds the plugin from sources in the
+	// registry.
+	Build *PluginBuild `json:"build,omitempty" description:"The command that builds the plugin from sources in the registry."`
+}
+
+// PluginArchive is a prebuilt executable of a plugin for a single platform.
+type PluginArchive struct {
+	// URL is the location of the executable, or of an archive containing it.
+	// The text "{version}" is substituted with the version being installed.
+	URL string `json:"url" description:"The location of the executable, or of an archive containing it. '{version}' is substituted with the version being installed."`
+
+	// SHA256 is the hex SHA-256 digest of the file at the URL.
+	SHA256 string `json:"sha256" jsonschema:"pattern=^[0-9a-f]{64}$" description:"The hex SHA-256 digest of the file at the URL."`
+
+	// Path is the slash-separated path of the executable within the zip,
+	// tar, or gzipped tar archive at the URL. As with project archives, a
+	// single top-level directory that contains every file is not part of the
+	// path. If empty, the file at the URL is the executable itself.
+	Path string `json:"path,omitempty" description:"The path of the executable within the archive, excluding a single top-level directory that contains every file. If omitted, the file at the URL is the executable itself."`
+}
+
+// PluginBuild is a command that builds a plugin.
+type PluginBuild struct {
+	// Command is the program and arguments that build the plugin. The
+	// environment variable PROTOBUILD_PLUGIN_VERSION is set to the version
+	// being built.
+	Command []string `json:"command" jsonschema:"minItems=1" description:"The program and arguments that build the plugin. PROTOBUILD_PLUGIN_VERSION is set to the version being built."`
+
+	// Dir is the directory, relative to the registry, that the command is run
+	// in. Defaults to the registry directory.
+	Dir string `json:"dir,omitempty" jsonschema:"default=." description:"The directory, relative to the registry, that the command is run in."`
+
+	// Output is the path of the executable that the command builds, relative
+	// to Dir.
+	Output string `json:"output" description:"The path of the executable that the command builds, relative to the directory it is run in."`
+}
+
+// Executable returns the name of the executable of the plugin, such as
+// "protoc-gen-go".
+func (p *Plugin) Executable() string {
+	return "protoc-gen-" + p.Name
+}
+
+// Version returns the version with the given name, or nil if the plugin has
+// no such version.
+func (p *Plugin) Version(name string) *PluginVersion {
+	for i := range p.Versions {
+		if p.Versions[i].Version == name {
+			return &p.Versions[i]
+		}
+	}
+	return nil
+}
+
+// Plugin returns the plugin with the given name, or nil if no such plugin is
+// defined in the registry.
+func (r *Registry) Plugin(name string) *Plugin {
+	for i := range r.Plugins {
+		if r.Plugins[i].Name == name {
+			return &r.Plugins[i]
+		}
+	}
+	return nil
+}
+
+// Plugin finds the plugin with the given name in the registry with the
+// highest priority that defines it, and returns it with that registry. If the
+// plugin is not found, the returned plugin is nil.
+func (i *Index) Plugin(name string) (*Plugin, *Registry) {
+	for _, registry := range i.Registries() {
+		if plugin := registry.Plugin(name); plugin != nil {
+			return plugin, registry
+		}
+	}
+	return nil, nil
+}
+
+var (
+	pluginName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+	platform   = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+$`)
+	sha256Hex  = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// validatePlugins checks the plugins of a registry for semantic errors.
+func validatePlugins(plugins []Plugin) Errors {
+	var errs Errors
+	errorf := func(field, format string, args ...any) {
+		errs = append(errs, &Error{Field: field, Err: fmt.Errorf(format, args...)})
+	}
+
+	names := make(map[string]int, len(plugins))
+	for i, plugin := range plugins {
+		field := fmt.Sprintf("plugins[%d]", i)
+		switch j, ok := names[plugin.Name]; {
+		case !pluginName.MatchString(plugin.Name):
+			errorf(field+".name", "plugin name %q must contain only letters, digits, '_', and '-'", plugin.Name)
+		case ok:
+			errorf(field+".name", "duplicate plugin %q; first defined in plugins[%d]", plugin.Name, j)
+		default:
+			names[plugin.Name] = i
+		}
+
+		if len(plugin.Versions) == 0 {
+			errorf(field+".versions", "at least one version is required")
+		}
+		versions := make(map[string]struct{}, len(plugin.Versions))
+		for j, version := range plugin.Versions {
+			vfield := fmt.Sprintf("%s.versions[%d]", field, j)
+			if version.Version == "" {
+				errorf(vfield+".version", "version is required")
+			} else if _, ok := versions[version.Version]; ok {
+				errorf(vfield+".version", "duplicate version %q", version.Version)
+			}
+			versions[version.Version] = struct{}{}
+
+			recipes := 0
+			for _, set := range []bool{version.Go != "", len(version.Archives) > 0, version.Build != nil} {
+				if set {
+					recipes++
+				}
+			}
+			if recipes != 1 {
+				errorf(vfield, "exactly one of go, archives, or build must be set")
+			}
+			keys := make([]string, 0, len(version.Archives))
+			for key := range version.Archives {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				archive := version.Archives[key]
+				afield := fmt.Sprintf("%s.archives.%s", vfield, key)
+				if !platform.MatchString(key) {
+					errorf(afield, "platform %q must be of the form \"<os>/<arch>\"", key)
+				}
+				if archive.URL == "" {
+					errorf(afield+".url", "url is required")
+				}
+				if !sha256Hex.MatchString(archive.SHA256) {
+					errorf(afield+".sha256", "sha256 %q must be a hex SHA-256 digest", archive.SHA256)
+				}
+			}
+			if build := version.Build; build != nil {
+				if len(build.Command) == 0 || strings.TrimSpace(build.Command[0]) == "" {
+					errorf(vfield+".build.command", "command is required")
+				}
+				if build.Output == "" {
+					errorf(vfield+".build.output", "output is required")
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,386 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/jsonschema"
+)
+
+// RegistryBaseName is the name, without extension, of the manifest file at the
+// root of a registry, such as "protobuild-registry.yaml".
+const RegistryBaseName = "protobuild-registry"
+
+// Registry is a manifest of named protobuf projects that targets may depend
+// on.
+type Registry struct {
+	// Schema is the optional URL of the JSON Schema for the file.
+	Schema string `json:"$schema,omitempty" description:"The URL of the JSON Schema for this file."`
+
+	// Description is a human-readable description of the registry.
+	Description string `json:"description,omitempty" description:"A human-readable description of the registry."`
+
+	// Projects are the projects defined by this registry.
+	Projects []Project `json:"projects" description:"The projects defined by this registry."`
+
+	// Plugins are the recipes for installing protoc plugins that are defined
+	// by this registry.
+	Plugins []Plugin `json:"plugins,omitempty" description:"The recipes for installing protoc plugins that are defined by this registry."`
+
+	// Name is the name of the registry, which is the name of the directory
+	// that it is stored in.
+	Name string `json:"-"`
+
+	// Path is the path of the file this registry was loaded from.
+	Path string `json:"-"`
+
+	doc *document
+}
+
+// Project is an external protobuf project that is defined in a registry.
+type Project struct {
+	// Name is the name of the project in "owner/project" form, such as
+	// "protocolbuffers/protobuf".
+	Name string `json:"name" jsonschema:"pattern=^[^/]+/[^/]+$" description:"The name of the project in 'owner/project' form, such as 'protocolbuffers/protobuf'."`
+
+	// Description is a human-readable description of the project.
+	Description string `json:"description,omitempty" description:"A human-readable description of the project."`
+
+	// Source is the location that the project sources are retrieved from.
+	Source Source `json:"source" jsonschema:"minProperties=1,maxProperties=1" description:"The location of the sources of the project. Exactly one of the fields must be set."`
+
+	// Versions are the known versions of the project, ordered from newest to
+	// oldest.
+	Versions []Version `json:"versions" jsonschema:"minItems=1" description:"The known versions of the project, ordered from newest to oldest."`
+
+	// ProtoRoots are the directories, relative to the root of the project
+	// sources, that contain the proto files of the project. Defaults to the
+	// root of the project sources.
+	ProtoRoots []string `json:"proto-roots,omitempty" jsonschema:"default=[\".\"]" description:"The directories, relative to the root of the project sources, that contain the proto files of the project."`
+
+	// Dependencies are the names of the other projects that this project
+	// imports from, each optionally followed by a constraint on its version.
+	Dependencies []string `json:"dependencies,omitempty" description:"The names of the other projects that this project imports from, each optionally followed by a constraint on its version, such as 'protocolbuffers/protobuf >= 27'."`
+}
+
+// Source is the location of the sources of a project. Exactly one of the
+// fields must be set.
+type Source struct {
+	// Git is the URL of the git repository containing the project.
+	Git string `json:"git,omitempty" description:"The URL of the git repository containing the project."`
+
+	// Archive is the URL template of an archive containing the project. The
+	// text "{version}" and "{ref}" are substituted with the version being
+	// retrieved.
+	Archive string `json:"archive,omitempty" description:"The URL template of an archive containing the project. '{version}' and '{ref}' are substituted with the version being retrieved."`
+}
+
+// Version is a single released version of a project.
+type Version struct {
+	// Version is the name of the version, such as "v1.2.0". Versions that are
+	// named by semantic versions may be selected by version constraints.
+	Version string `json:"version" description:"The name of the version, such as 'v1.2.0'. Versions that are named by semantic versions may be selected by version constraints."`
+
+	// Ref is the git ref or archive substitution for the version. Defaults to
+	// the version name.
+	Ref string `json:"ref,omitempty" description:"The git ref or archive substitution for the version. Defaults to the version name."`
+
+	// Integrity is the optional digest of the project sources, in
+	// "sha256-<hex>" form.
+	Integrity string `json:"integrity,omitempty" jsonschema:"pattern=^sha256-[0-9a-f]{64}$" description:"The digest of the project sources, in 'sha256-<hex>' form."`
+}
+
+// GetRef returns the git ref or archive substitution for the version.
+func (v *Version) GetRef() string {
+	if v.Ref != "" {
+		return v.Ref
+	}
+	return v.Version
+}
+
+// Dir returns the registry directory.
+func (r *Registry) Dir() string {
+	return filepath.Dir(r.Path)
+}
+
+// Project returns the project with the given name, or nil if no such project
+// is defined in the registry.
+func (r *Registry) Project(name string) *Project {
+	for i := range r.Projects {
+		if r.Projects[i].Name == name {
+			return &r.Projects[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks the registry for semantic errors that cannot be detected
+// while decoding.
+func (r *Registry) Validate() error {
+	var errs Errors
+	errorf := func(field, format string, args ...any) {
+		errs = append(errs, &Error{Field: field, Err: fmt.Errorf(format, args...)})
+	}
+
+	names := make(map[string]int, len(r.Projects))
+	for i, project := range r.Projects {
+		field := fmt.Sprintf("projects[%d]", i)
+		switch j, ok := names[project.Name]; {
+		case !isProjectName(project.Name):
+			errorf(field+".name", "project name %q must be of the form \"owner/project\"", project.Name)
+		case ok:
+			errorf(field+".name", "duplicate project %q; first defined in projects[%d]", project.Name, j)
+		default:
+			names[project.Name] = i
+		}
+
+		if (project.Source.Git == "") == (project.Source.Archive == "") {
+			errorf(field+".source", "exactly one of git or archive must be set")
+		}
+
+		versions := make(map[string]struct{}, len(project.Versions))
+		if len(project.Versions) == 0 {
+			errorf(field+".versions", "at least one version is required")
+		}
+		for j, version := range project.Versions {
+			vfield := fmt.Sprintf("%s.versions[%d]", field, j)
+			if version.Version == "" {
+				errorf(vfield+".version", "version is required")
+			} else if _, ok := versions[version.Version]; ok {
+				errorf(vfield+".version", "duplicate version %q", version.Version)
+			}
+			versions[version.Version] = struct{}{}
+			if version.Integrity != "" && !strings.HasPrefix(version.Integrity, "sha256-") {
+				errorf(vfield+".integrity", "integrity %q must be of the form \"sha256-<hex>\"", version.Integrity)
+			}
+		}
+
+		for j, text := range project.Dependencies {
+			dfield := fmt.Sprintf("%s.dependencies[%d]", field, j)
+			if dependency, err := ParseDependency(text); err != nil {
+				errorf(dfield, "%v", err)
+			} else if dependency.Name == project.Name {
+				errorf(dfield, "project %q must not depend on itself", project.Name)
+			}
+		}
+	}
+	errs = append(errs, validatePlugins(r.Plugins)...)
+	return errs.Err()
+}
+
+// errorf creates an error attributed to the specified field of the registry.
+func (r *Registry) errorf(field, format string, args ...any) error {
+	err := &Error{File: r.Path, Field: field, Err: fmt.Errorf(format, args...)}
+	if r.doc == nil {
+		return err
+	}
+	return r.doc.annotate(err)
+}
+
+// FindRegistry returns the path of the registry manifest in dir, which may be
+// in any supported format.
+func FindRegistry(dir string) (string, error) {
+	return findFile(dir, RegistryBaseName)
+}
+
+// LoadRegistry reads, decodes, and validates the registry manifest at path.
+// The name of the registry is the name of the directory containing it.
+func LoadRegistry(path string) (*Registry, error) {
+	doc, err := readDocument(path, jsonschema.Registry)
+	if err != nil {
+		return nil, err
+	}
+	registry := &Registry{}
+	if err := doc.decode(registry); err != nil {
+		return nil, err
+	}
+	registry.Name = filepath.Base(filepath.Dir(path))
+	registry.Path = path
+	registry.doc = doc
+	if err := doc.annotate(registry.Validate()); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// LoadRegistries loads every registry that is stored in a sub-directory of
+// dir, sorted by name. A missing directory contains no registries.
+//
+// A registry that cannot be loaded is skipped, so that it does not prevent
+// the others from being used; the errors of every such registry are returned
+// as Errors, along with the registries that were loaded.
+func LoadRegistries(dir string) ([]*Registry, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var errs Errors
+	var registries []*Registry
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path, err := FindRegistry(filepath.Join(dir, entry.Name()))
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		registry, err := LoadRegistry(path)
+		if err != nil {
+			errs = append(errs, flatten(err)...)
+			continue
+		}
+		registries = append(registries, registry)
+	}
+	slices.SortFunc(registries, func(lhs, rhs *Registry) int {
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	return registries, errs.Err()
+}
+
+// PrioritizeRegistries orders the registries by priority. The registries
+// with the given names come first, in the order they are named, followed by
+// every other registry in its original order. Names that do not match any
+// registry are ignored, as are later repetitions of a name.
+func PrioritizeRegistries(registries []*Registry, names ...string) []*Registry {
+	result := make([]*Registry, 0, len(registries))
+	for _, name := range names {
+		i := slices.IndexFunc(registries, func(registry *Registry) bool {
+			return registry.Name == name
+		})
+		if i >= 0 && !slices.Contains(result, registries[i]) {
+			result = append(result, registries[i])
+		}
+	}
+	for _, registry := range registries {
+		if !slices.Contains(result, registry) {
+			result = append(result, registry)
+		}
+	}
+	return result
+}
+
+// Index is a collection of registries that projects may be looked up from,
+// ordered from highest to lowest priority.
+type Index struct {
+	registries []*Registry
+}
+
+// NewIndex creates an index of the projects in the specified registries, which
+// are ordered from highest to lowest priority.
+func NewIndex(registries ...*Registry) *Index {
+	return &Index{registries: registries}
+}
+
+// Registries returns the registries in the index, ordered from highest to
+// lowest priority.
+func (i *Index) Registries() []*Registry {
+	if i == nil {
+		return nil
+	}
+	return i.registries
+}
+
+// Registry returns the registry with the given name, or nil if it is not in
+// the index.
+func (i *Index) Registry(name string) *Registry {
+	for _, registry := range i.Registries() {
+		if registry.Name == name {
+			return registry
+		}
+	}
+	return nil
+}
+
+// Lookup finds the project with the given name, and the registry that defines
+// it. The name may be qualified with the name of a registry, such as
+// "public/google/fhir", to find the project in only that registry; otherwise,
+// the project is found in the registry with the highest priority that defines
+// it. If the project is not found, the returned project is nil.
+func (i *Index) Lookup(name string) (*Project, *Registry) {
+	if registryName, projectName, ok := splitQualifiedName(name); ok {
+		registry := i.Registry(registryName)
+		if registry == nil {
+			return nil, nil
+		}
+		if project := registry.Project(projectName); project != nil {
+			return project, registry
+		}
+		return nil, nil
+	}
+	for _, registry := range i.Registries() {
+		if project := registry.Project(name); project != nil {
+			return project, registry
+		}
+	}
+	return nil, nil
+}
+
+// Name returns the name that a project of a registry in the index is referred
+// to by. This is the name of the project, unless the project is shadowed by a
+// registry of higher priority, in which case it is qualified with the name of
+// its registry.
+func (i *Index) Name(project *Project, registry *Registry) string {
+	if _, found := i.Lookup(project.Name); found == registry {
+		return project.Name
+	}
+	return registry.Name + "/" + project.Name
+}
+
+// Shadowed returns the registries that also define the project with the
+// given unqualified name, but are shadowed by the registry of higher priority
+// that it is found in.
+func (i *Index) Shadowed(name string) []*Registry {
+	var result []*Registry
+	found := false
+	for _, registry := range i.Registries() {
+		if registry.Project(name) == nil {
+			continue
+		}
+		if found {
+			result = append(result, registry)
+		}
+		found = true
+	}
+	return result
+}
+
+// splitQualifiedName splits a project name that is qualified with the name of
+// a registry, such as "public/google/fhir", into its registry and project.
+func splitQualifiedName(name string) (registry, project string, ok bool) {
+	registry, project, ok = strings.Cut(name, "/")
+	if !ok || registry == "" || !isProjectName(project) {
+		return "", "", false
+	}
+	return registry, project, true
+}
+
+// Validate checks that the dependencies of every project in the index refer
+// to projects that are also in the index.
+func (i *Index) Validate() error {
+	var errs Errors
+	for _, registry := range i.Registries() {
+		for j, project := range registry.Projects {
+			for k, dependency := range project.Dependencies {
+				name := DependencyName(dependency)
+				if found, _ := i.Lookup(name); found != nil {
+					continue
+				}
+				field := fmt.Sprintf("projects[%d].dependencies[%d]", j, k)
+				errs = append(errs, registry.errorf(field, "unknown dependency %q", name))
+			}
+		}
+	}
+	return errs.Err()
+}
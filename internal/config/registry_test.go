@@ -0,0 +1,358 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const fhirRegistry = `{
+	"description": "Public protobuf projects",
+	"projects": [
+		{
+			"name": "protocolbuffers/protobuf",
+			"source": {"git": "https://github.com/protocolbuffers/protobuf.git"},
+			"versions": [{"version": "v27.0"}],
+			"proto-roots": ["src"]
+		},
+		{
+			"name": "google/fhir",
+			"source": {"git": "https://github.com/google/fhir.git"},
+			"versions": [{"version": "v0.7.4", "ref": "a1b2c3"}],
+			"proto-roots": ["proto"],
+			"dependencies": ["protocolbuffers/protobuf"]
+		}
+	]
+}`
+
+func TestLoadRegistries_RegistriesInDir_ReturnsSortedRegistries(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "public", config.RegistryBaseName+".json"), fhirRegistry)
+	writeFile(t, filepath.Join(dir, "internal", config.RegistryBaseName+".json"), `{"projects": []}`)
+	writeFile(t, filepath.Join(dir, "not-a-registry", "README.md"), ``)
+	want := []string{"internal", "public"}
+
+	registries, err := config.LoadRegistries(dir)
+	if err != nil {
+		t.Fatalf("LoadRegistries: unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, registry := range registries {
+		got = append(got, registry.Name)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("LoadRegistries: got %v, want %v", got, want)
+	}
+}
+
+func TestLoadRegistries_InvalidRegistry_ReturnsOtherRegistries(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "public", config.RegistryBaseName+".json"), fhirRegistry)
+	writeFile(t, filepath.Join(dir, "broken", config.RegistryBaseName+".json"), `{`)
+
+	registries, err := config.LoadRegistries(dir)
+
+	var errs config.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Errorf("LoadRegistries: got err %v, want one config.Error", err)
+	}
+	var got []string
+	for _, registry := range registries {
+		got = append(got, registry.Name)
+	}
+	if want := []string{"public"}; !cmp.Equal(got, want) {
+		t.Errorf("LoadRegistries: got %v, want %v", got, want)
+	}
+}
+
+func TestLoadRegistries_MissingDir_ReturnsNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	got, err := config.LoadRegistries(dir)
+
+	if err != nil {
+		t.Fatalf("LoadRegistries: unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("LoadRegistries: got %v, want nothing", got)
+	}
+}
+
+func TestIndexLookup_ProjectExists_ReturnsProject(t *testing.T) {
+	path := writeFile(t, filepath.Join(t.TempDir(), "public", config.RegistryBaseName+".json"), fhirRegistry)
+	registry, err := config.LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: unexpected error: %v", err)
+	}
+	index := config.NewIndex(registry)
+	want := &config.Project{
+		Name:         "google/fhir",
+		Source:       config.Source{Git: "https://github.com/google/fhir.git"},
+		Versions:     []config.Version{{Version: "v0.7.4", Ref: "a1b2c3"}},
+		ProtoRoots:   []string{"proto"},
+		Dependencies: []string{"protocolbuffers/protobuf"},
+	}
+
+	got, gotRegistry := index.Lookup("google/fhir")
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Index.Lookup: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+	if gotRegistry != registry {
+		t.Errorf("Index.Lookup: got registry %v, want %v", gotRegistry.Name, registry.Name)
+	}
+	if err := index.Validate(); err != nil {
+		t.Errorf("Index.Validate: unexpected error: %v", err)
+	}
+}
+
+func TestLoadRegistry_InvalidRegistry_ReturnsFieldError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantField string
+	}{
+		{
+			name:      "unqualified project name",
+			content:   `{"projects": [{"name": "fhir", "source": {"git": "x"}, "versions": [{"version": "v1"}]}]}`,
+			wantField: "projects[0].name",
+		}, {
+			name:      "no source",
+			content:   `{"projects": [{"name": "google/fhir", "source": {}, "versions": [{"version": "v1"}]}]}`,
+			wantField: "projects[0].source",
+		}, {
+			name:      "no versions",
+			content:   `{"projects": [{"name": "google/fhir", "source": {"git": "x"}, "versions": []}]}`,
+			wantField: "projects[0].versions",
+		}, {
+			name:      "bad integrity",
+			content:   `{"projects": [{"name": "google/fhir", "source": {"git": "x"}, "versions": [{"version": "v1", "integrity": "md5-abc"}]}]}`,
+			wantField: "projects[0].versions[0].integrity",
+		}, {
+			name:      "plugin without recipe",
+			content:   `{"projects": [], "plugins": [{"name": "go", "versions": [{"version": "v1"}]}]}`,
+			wantField: "plugins[0].versions[0]",
+		}, {
+			name:      "plugin with two recipes",
+			content:   `{"projects": [], "plugins": [{"name": "go", "versions": [{"version": "v1", "go": "x", "build": {"command": ["make"], "output": "x"}}]}]}`,
+			wantField: "plugins[0].versions[0]",
+		}, {
+			name:      "duplicate plugin",
+			content:   `{"projects": [], "plugins": [{"name": "go", "versions": [{"version": "v1", "go": "x"}]}, {"name": "go", "versions": [{"version": "v1", "go": "x"}]}]}`,
+			wantField: "plugins[1].name",
+		}, {
+			name:      "plugin archive platform",
+			content:   `{"projects": [], "plugins": [{"name": "go", "versions": [{"version": "v1", "archives": {"linux": {"url": "x", "sha256": "` + strings.Repeat("0", 64) + `"}}}]}]}`,
+			wantField: "plugins[0].versions[0].archives.linux",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, filepath.Join(t.TempDir(), "public", config.RegistryBaseName+".json"), tc.content)
+
+			_, err := config.LoadRegistry(path)
+
+			var got *config.Error
+			if !errors.As(err, &got) {
+				t.Fatalf("LoadRegistry: got err %v, want config.Error", err)
+			}
+			if got.Field != tc.wantField {
+				t.Errorf("LoadRegistry: got field %q, want %q", got.Field, tc.wantField)
+			}
+		})
+	}
+}
+
+func TestLoadRegistry_InvalidPluginArchives_ReturnsErrorsByPlatform(t *testing.T) {
+	archive := `{"url": "x", "sha256": "` + strings.Repeat("0", 64) + `"}`
+	path := writeFile(t, filepath.Join(t.TempDir(), "public", config.RegistryBaseName+".json"), `{
+		"projects": [],
+		"plugins": [{"name": "go", "versions": [{"version": "v1", "archives": {
+			"zos": `+archive+`, "aix": `+archive+`, "mac": `+archive+`, "linux": `+archive+`
+		}}]}]
+	}`)
+
+	_, err := config.LoadRegistry(path)
+
+	var errs config.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("LoadRegistry: got err %v, want config.Errors", err)
+	}
+	var got []string
+	for _, err := range errs {
+		var cerr *config.Error
+		if errors.As(err, &cerr) {
+			got = append(got, cerr.Field)
+		}
+	}
+	want := []string{
+		"plugins[0].versions[0].archives.aix",
+		"plugins[0].versions[0].archives.linux",
+		"plugins[0].versions[0].archives.mac",
+		"plugins[0].versions[0].archives.zos",
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("LoadRegistry: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestIndexValidate_UnknownDependency_ReturnsError(t *testing.T) {
+	path := writeFile(t, filepath.Join(t.TempDir(), "public", config.RegistryBaseName+".json"), `{
+		"projects": [{
+			"name": "google/fhir",
+			"source": {"git": "x"},
+			"versions": [{"version": "v1"}],
+			"dependencies": ["google/missing"]
+		}]
+	}`)
+	registry, err := config.LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: unexpected error: %v", err)
+	}
+
+	err = config.NewIndex(registry).Validate()
+
+	var got *config.Error
+	if !errors.As(err, &got) {
+		t.Fatalf("Index.Validate: got err %v, want config.Error", err)
+	}
+	want := &config.Error{
+		File:     path,
+		Position: config.Position{Line: 6, Column: 21},
+		Field:    "projects[0].dependencies[0]",
+	}
+	if !cmp.Equal(got, want, cmpopts.IgnoreFields(config.Error{}, "Err")) {
+		t.Errorf("Index.Validate: (-got +want):\n%s", cmp.Diff(got, want, cmpopts.IgnoreFields(config.Error{}, "Err")))
+	}
+}
+
+// layeredIndex creates an index of an internal registry that is layered over
+// a public one, both of which define "google/fhir".
+func layeredIndex() *config.Index {
+	public := &config.Registry{
+		Name: "public",
+		Projects: []config.Project{
+			{Name: "google/fhir", Description: "public"},
+			{Name: "protocolbuffers/protobuf"},
+		},
+	}
+	internal := &config.Registry{
+		Name:     "internal",
+		Projects: []config.Project{{Name: "google/fhir", Description: "internal"}},
+	}
+	return config.NewIndex(config.PrioritizeRegistries([]*config.Registry{public, internal}, "internal")...)
+}
+
+func TestIndexLookup_LayeredRegistries_ResolvesByPriority(t *testing.T) {
+	testCases := []struct {
+		name         string
+		lookup       string
+		wantProject  string
+		wantRegistry string
+	}{
+		{
+			name:         "shadowed project",
+			lookup:       "google/fhir",
+			wantProject:  "internal",
+			wantRegistry: "internal",
+		}, {
+			name:         "qualified shadowed project",
+			lookup:       "public/google/fhir",
+			wantProject:  "public",
+			wantRegistry: "public",
+		}, {
+			name:         "qualified project",
+			lookup:       "internal/google/fhir",
+			wantProject:  "internal",
+			wantRegistry: "internal",
+		}, {
+			name:         "lower priority project",
+			lookup:       "protocolbuffers/protobuf",
+			wantRegistry: "public",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			index := layeredIndex()
+
+			project, registry := index.Lookup(tc.lookup)
+
+			if project == nil {
+				t.Fatalf("Index.Lookup(%q): got nil project", tc.lookup)
+			}
+			if project.Description != tc.wantProject {
+				t.Errorf("Index.Lookup(%q): got project from %q, want %q", tc.lookup, project.Description, tc.wantProject)
+			}
+			if registry.Name != tc.wantRegistry {
+				t.Errorf("Index.Lookup(%q): got registry %q, want %q", tc.lookup, registry.Name, tc.wantRegistry)
+			}
+		})
+	}
+}
+
+func TestIndexLookup_UnknownQualification_ReturnsNil(t *testing.T) {
+	index := layeredIndex()
+
+	for _, name := range []string{"other/google/fhir", "internal/protocolbuffers/protobuf"} {
+		if project, _ := index.Lookup(name); project != nil {
+			t.Errorf("Index.Lookup(%q): got %v, want nil", name, project)
+		}
+	}
+}
+
+func TestIndexName_ShadowedProject_ReturnsQualifiedName(t *testing.T) {
+	index := layeredIndex()
+	var got []string
+	want := []string{"google/fhir", "public/google/fhir", "protocolbuffers/protobuf"}
+
+	for _, registry := range index.Registries() {
+		for i := range registry.Projects {
+			got = append(got, index.Name(&registry.Projects[i], registry))
+		}
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Index.Name: got %v, want %v", got, want)
+	}
+}
+
+func TestIndexShadowed_ShadowedProject_ReturnsLowerPriorityRegistries(t *testing.T) {
+	index := layeredIndex()
+
+	var got []string
+	for _, registry := range index.Shadowed("google/fhir") {
+		got = append(got, registry.Name)
+	}
+
+	if want := []string{"public"}; !cmp.Equal(got, want) {
+		t.Errorf("Index.Shadowed: got %v, want %v", got, want)
+	}
+	if got := index.Shadowed("protocolbuffers/protobuf"); len(got) != 0 {
+		t.Errorf("Index.Shadowed: got %v for an unshadowed project, want none", got)
+	}
+}
+
+func TestPrioritizeRegistries_Names_OrdersNamedFirst(t *testing.T) {
+	var registries []*config.Registry
+	for _, name := range []string{"a", "b", "c", "d"} {
+		registries = append(registries, &config.Registry{Name: name})
+	}
+	want := []string{"c", "a", "b", "d"}
+
+	var got []string
+	for _, registry := range config.PrioritizeRegistries(registries, "c", "missing", "a", "c") {
+		got = append(got, registry.Name)
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("PrioritizeRegistries: got %v, want %v", got, want)
+	}
+}
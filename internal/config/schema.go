@@ -0,0 +1,335 @@
+package config
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bitwizeshift/protobuild/internal/schemagen"
+	"github.com/bitwizeshift/protobuild/jsonschema"
+	validator "github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaError is an error for a value that does not conform to the JSON Schema
+// of the file that it is defined in.
+type SchemaError struct {
+	// Pointer is the JSON pointer to the offending value, such as
+	// "/registries/0/url".
+	Pointer string
+
+	// Value is a short rendering of the offending value, or empty if the
+	// value is missing.
+	Value string
+
+	// Message describes what the schema expected of the value.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *SchemaError) Error() string {
+	if e.Value == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (value: %s)", e.Message, e.Value)
+}
+
+var _ error = (*SchemaError)(nil)
+
+// schemaDefinition describes the schema of a kind of configuration file.
+type schemaDefinition struct {
+	kind        string
+	name        string
+	title       string
+	description string
+	typ         reflect.Type
+}
+
+// schemaDefinitions describes the schema of every kind of configuration file.
+var schemaDefinitions = []schemaDefinition{
+	{
+		kind:        "workspace",
+		name:        jsonschema.Workspace,
+		title:       "Protobuild Workspace",
+		description: "The top-level configuration of a protobuild project.",
+		typ:         reflect.TypeFor[Workspace](),
+	}, {
+		kind:        "target",
+		name:        jsonschema.Target,
+		title:       "Protobuild Target",
+		description: "A named collection of proto sources, and the outputs that are generated from them.",
+		typ:         reflect.TypeFor[Target](),
+	}, {
+		kind:        "registry",
+		name:        jsonschema.Registry,
+		title:       "Protobuild Registry",
+		description: "A manifest of named protobuf projects that targets may depend on.",
+		typ:         reflect.TypeFor[Registry](),
+	}, {
+		kind:        "user",
+		name:        jsonschema.User,
+		title:       "Protobuild User Configuration",
+		description: "The configuration of the current user, which applies to every workspace.",
+		typ:         reflect.TypeFor[User](),
+	},
+}
+
+// SchemaKinds returns the kinds of configuration files that have a schema.
+func SchemaKinds() []string {
+	kinds := make([]string, 0, len(schemaDefinitions))
+	for _, def := range schemaDefinitions {
+		kinds = append(kinds, def.kind)
+	}
+	return kinds
+}
+
+// SchemaFileName returns the name of the schema file for the kind of
+// configuration file.
+func SchemaFileName(kind string) (string, error) {
+	def, err := lookupSchema(kind)
+	if err != nil {
+		return "", err
+	}
+	return def.name, nil
+}
+
+// GenerateSchema generates the JSON Schema of the kind of configuration file
+// from its Go type.
+func GenerateSchema(kind string) ([]byte, error) {
+	def, err := lookupSchema(kind)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := schemagen.Generate(def.typ, schemagen.Options{
+		ID:          jsonschema.URL(def.name),
+		Title:       def.title,
+		Description: def.description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schema.Marshal()
+}
+
+func lookupSchema(kind string) (*schemaDefinition, error) {
+	for i := range schemaDefinitions {
+		if schemaDefinitions[i].kind == kind {
+			return &schemaDefinitions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown schema %q; must be one of %s", kind, strings.Join(SchemaKinds(), ", "))
+}
+
+// schemas compiles every embedded schema, keyed by name.
+var schemas = sync.OnceValues(func() (map[string]*validator.Schema, error) {
+	compiler := validator.NewCompiler()
+	var names []string
+	for _, def := range schemaDefinitions {
+		names = append(names, def.name)
+	}
+	for _, name := range names {
+		file, err := jsonschema.FS.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		err = compiler.AddResource(jsonschema.URL(name), file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := make(map[string]*validator.Schema, len(names))
+	for _, name := range names {
+		schema, err := compiler.Compile(jsonschema.URL(name))
+		if err != nil {
+			return nil, err
+		}
+		result[name] = schema
+	}
+	return result, nil
+})
+
+// validate checks the document against the embedded schema with the given
+// name, returning a SchemaError for every violation.
+func (d *document) validate(name string) error {
+	compiled, err := schemas()
+	if err != nil {
+		return fmt.Errorf("compiling embedded schemas: %w", err)
+	}
+	schema, ok := compiled[name]
+	if !ok {
+		return fmt.Errorf("no embedded schema %q", name)
+	}
+	err = schema.Validate(d.root.interface_())
+	var verr *validator.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+
+	var errs Errors
+	for _, leaf := range leaves(verr) {
+		errs = append(errs, d.schemaErrors(leaf)...)
+	}
+	slices.SortStableFunc(errs, func(lhs, rhs error) int {
+		lpos, rpos := lhs.(*Error).Position, rhs.(*Error).Position
+		return cmp.Or(cmp.Compare(lpos.Line, rpos.Line), cmp.Compare(lpos.Column, rpos.Column))
+	})
+	return errs.Err()
+}
+
+// quotedNames matches the property names quoted in validation messages.
+var quotedNames = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+
+// schemaErrors converts a single validation error into errors attributed to
+// the offending values of the document.
+//
+// Errors about unknown or missing properties are reported by the validator
+// against the enclosing object; these are split so that each property is
+// reported individually, and unknown properties are attributed to their key.
+func (d *document) schemaErrors(leaf *validator.ValidationError) Errors {
+	path := splitPointer(leaf.InstanceLocation)
+	n := d.find(path)
+	keyword := leaf.KeywordLocation[strings.LastIndex(leaf.KeywordLocation, "/")+1:]
+	names := quotedNames.FindAllStringSubmatch(leaf.Message, -1)
+
+	var errs Errors
+	switch {
+	case keyword == "additionalProperties" && len(names) > 0 && n.kind == kindObject:
+		for _, name := range names {
+			key := name[1]
+			m := n.lookup(key)
+			if m == nil {
+				continue
+			}
+			errs = append(errs, &Error{
+				File:     d.path,
+				Position: m.pos,
+				Field:    d.field(append(path, key)),
+				Err: &SchemaError{
+					Pointer: joinPointer(leaf.InstanceLocation, key),
+					Value:   m.value.summary(),
+					Message: fmt.Sprintf("unknown property %q is not allowed", key),
+				},
+			})
+		}
+	case keyword == "required" && len(names) > 0:
+		for _, name := range names {
+			key := name[1]
+			errs = append(errs, &Error{
+				File:     d.path,
+				Position: n.pos,
+				Field:    d.field(append(path, key)),
+				Err: &SchemaError{
+					Pointer: joinPointer(leaf.InstanceLocation, key),
+					Message: fmt.Sprintf("missing required property %q", key),
+				},
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return Errors{&Error{
+		File:     d.path,
+		Position: n.pos,
+		Field:    d.field(path),
+		Err: &SchemaError{
+			Pointer: leaf.InstanceLocation,
+			Value:   n.summary(),
+			Message: leaf.Message,
+		},
+	}}
+}
+
+// field converts a path of object keys and array indices into the field
+// notation used by errors, such as "registries[0].url".
+func (d *document) field(path []string) string {
+	var sb strings.Builder
+	current := d.root
+	for _, part := range path {
+		if current != nil && current.kind == kindArray {
+			sb.WriteString("[" + part + "]")
+			items := current.items
+			current = nil
+			if index, err := strconv.Atoi(part); err == nil && index >= 0 && index < len(items) {
+				current = items[index]
+			}
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(part)
+		if current != nil && current.kind == kindObject {
+			if m := current.lookup(part); m != nil {
+				current = m.value
+				continue
+			}
+		}
+		current = nil
+	}
+	return sb.String()
+}
+
+// joinPointer appends an unescaped reference token to a JSON pointer.
+func joinPointer(pointer, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return pointer + "/" + strings.ReplaceAll(token, "/", "~1")
+}
+
+// leaves returns the most specific causes of a validation error, which are
+// the ones that describe what is actually wrong with the value.
+func leaves(err *validator.ValidationError) []*validator.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*validator.ValidationError{err}
+	}
+	var result []*validator.ValidationError
+	for _, cause := range err.Causes {
+		result = append(result, leaves(cause)...)
+	}
+	return result
+}
+
+// splitPointer splits a JSON pointer into its unescaped reference tokens.
+func splitPointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, part := range parts {
+		part = strings.ReplaceAll(part, "~1", "/")
+		parts[i] = strings.ReplaceAll(part, "~0", "~")
+	}
+	return parts
+}
+
+// maxSummary is the longest that a value summary may be before it is
+// truncated.
+const maxSummary = 40
+
+// summary renders a short, JSON-like representation of the node for use in
+// error messages.
+func (n *node) summary() string {
+	var s string
+	switch n.kind {
+	case kindNull:
+		s = "null"
+	case kindString:
+		s = strconv.Quote(n.scalar)
+	case kindArray:
+		s = fmt.Sprintf("array of %d item(s)", len(n.items))
+	case kindObject:
+		s = fmt.Sprintf("object with %d member(s)", len(n.members))
+	default:
+		s = n.scalar
+	}
+	if len(s) > maxSummary {
+		s = s[:maxSummary-3] + "..."
+	}
+	return s
+}
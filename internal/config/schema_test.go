@@ -0,0 +1,75 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/jsonschema"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadWorkspace_SchemaViolation_ReturnsSchemaErrors(t *testing.T) {
+	path := writeFile(t, filepath.Join(t.TempDir(), "protobuild.yaml"), `targets: ["*.json"]
+registries:
+  - name: public
+    url: 42
+    branch: main
+`)
+	want := []config.SchemaError{
+		{
+			Pointer: "/registries/0/url",
+			Value:   "42",
+			Message: "expected string, but got number",
+		}, {
+			Pointer: "/registries/0/branch",
+			Value:   `"main"`,
+			Message: `unknown property "branch" is not allowed`,
+		},
+	}
+
+	_, err := config.LoadWorkspace(path)
+
+	var errs config.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("LoadWorkspace: got err %v, want config.Errors", err)
+	}
+	var got []config.SchemaError
+	for _, err := range errs {
+		var serr *config.SchemaError
+		if !errors.As(err, &serr) {
+			t.Fatalf("LoadWorkspace: got err %v, want config.SchemaError", err)
+		}
+		got = append(got, *serr)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("LoadWorkspace: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestGenerateSchema_MatchesEmbeddedSchema(t *testing.T) {
+	for _, kind := range config.SchemaKinds() {
+		t.Run(kind, func(t *testing.T) {
+			name, err := config.SchemaFileName(kind)
+			if err != nil {
+				t.Fatalf("SchemaFileName: unexpected error: %v", err)
+			}
+			want, err := jsonschema.FS.ReadFile(name)
+			if err != nil {
+				t.Fatalf("ReadFile: unexpected error: %v", err)
+			}
+
+			got, err := config.GenerateSchema(kind)
+			if err != nil {
+				t.Fatalf("GenerateSchema: unexpected error: %v", err)
+			}
+
+			if !cmp.Equal(string(got), string(want)) {
+				t.Errorf("GenerateSchema: embedded %s is stale; run tools/generate-schemas.sh (-got +want):\n%s",
+					name, cmp.Diff(string(got), string(want)),
+				)
+			}
+		})
+	}
+}
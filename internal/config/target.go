@@ -0,0 +1,321 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/jsonschema"
+)
+
+// TargetBaseName is the conventional name, without extension, of a target
+// definition file, such as "protobuild-target.yaml".
+const TargetBaseName = "protobuild-target"
+
+// Target is a named collection of proto sources, and the outputs that are
+// generated from them.
+type Target struct {
+	// Schema is the optional URL of the JSON Schema for the file.
+	Schema string `json:"$schema,omitempty" description:"The URL of the JSON Schema for this file."`
+
+	// Name is the unique name of the target within the workspace.
+	Name string `json:"name" jsonschema:"pattern=^[^/]+$" description:"The unique name of the target within the workspace."`
+
+	// Sources are the glob patterns, relative to the target directory, that
+	// select the proto sources of the target. Patterns prefixed with `!`
+	// exclude matches.
+	Sources glob.Patterns `json:"sources" jsonschema:"minItems=1,items.minLength=1" description:"Glob patterns, relative to the target directory, that select the proto sources of the target. Patterns prefixed with '!' exclude matches."`
+
+	// ImportRoots are the directories, relative to the workspace root, that
+	// imports are resolved against. Defaults to the workspace root.
+	ImportRoots []string `json:"import-roots,omitempty" jsonschema:"default=[\".\"]" description:"The directories, relative to the workspace root, that imports are resolved against."`
+
+	// Dependencies are the names of the other targets or registry projects
+	// that this target imports from. Registry projects may be followed by a
+	// constraint on their version, such as "google/fhir >= 1.2, < 2".
+	Dependencies []string `json:"dependencies,omitempty" jsonschema:"uniqueItems" description:"The names of the other targets or registry projects that this target imports from. Registry projects may be followed by a constraint on their version, such as 'google/fhir >= 1.2, < 2'."`
+
+	// Outputs are the generation outputs of this target.
+	Outputs []Output `json:"outputs" jsonschema:"minItems=1" description:"The generation outputs of this target."`
+
+	// Path is the path of the file this target was loaded from.
+	Path string `json:"-"`
+
+	doc *document
+}
+
+// Output is a single generation output of a target, which corresponds to a
+// single protoc plugin invocation.
+type Output struct {
+	// Name is the display name of the output. Defaults to the plugin name.
+	Name string `json:"name,omitempty" description:"The display name of the output. Defaults to the plugin name."`
+
+	// Plugin is the name of the protoc plugin, such as "go" for
+	// protoc-gen-go, or a builtin generator such as "cpp" or "python".
+	Plugin string `json:"plugin" description:"The name of the protoc plugin, such as 'go' for protoc-gen-go, or a builtin generator such as 'cpp' or 'python'."`
+
+	// Out is the output directory, relative to the workspace output
+	// directory.
+	Out string `json:"out" description:"The output directory, relative to the workspace output directory."`
+
+	// Options are the options passed to the plugin. These are appended to
+	// any default plugin options from the workspace.
+	Options []string `json:"options,omitempty" description:"The options passed to the plugin, appended to any default plugin options from the workspace."`
+}
+
+// Label returns the display name of the output.
+func (o *Output) Label() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.Plugin
+}
+
+// Dir returns the target directory.
+func (t *Target) Dir() string {
+	return filepath.Dir(t.Path)
+}
+
+// Save writes the target to its path, in the format indicated by its
+// extension. Comments and formatting of the previous file are not preserved.
+func (t *Target) Save() error {
+	return save(t.Path, t)
+}
+
+// SourceFiles returns the sorted list of files selected by the sources of the
+// target.
+func (t *Target) SourceFiles() []string {
+	var files []string
+	for _, path := range t.Sources.Glob(t.Dir()) {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+// Validate checks the target for semantic errors that can be detected without
+// knowledge of the rest of the workspace.
+func (t *Target) Validate() error {
+	var errs Errors
+	errorf := func(field, format string, args ...any) {
+		errs = append(errs, &Error{Field: field, Err: fmt.Errorf(format, args...)})
+	}
+
+	switch {
+	case t.Name == "":
+		errorf("name", "name is required")
+	case strings.Contains(t.Name, "/"):
+		errorf("name", "target name %q must not contain '/', which is reserved for registry projects", t.Name)
+	}
+
+	if len(t.Sources) == 0 {
+		errorf("sources", "at least one source pattern is required")
+	}
+	positive := false
+	for i, pattern := range t.Sources {
+		trimmed := strings.TrimLeft(string(pattern), "!")
+		if trimmed == "" {
+			errorf(fmt.Sprintf("sources[%d]", i), "source pattern must not be empty")
+		}
+		positive = positive || len(trimmed) == len(pattern)
+	}
+	if len(t.Sources) > 0 && !positive {
+		errorf("sources", "at least one source pattern must not be negated")
+	}
+
+	dependencies := make(map[string]struct{}, len(t.Dependencies))
+	for i, text := range t.Dependencies {
+		field := fmt.Sprintf("dependencies[%d]", i)
+		dependency, err := ParseDependency(text)
+		if err != nil {
+			errorf(field, "%v", err)
+			continue
+		}
+		if _, ok := dependencies[dependency.Name]; ok {
+			errorf(field, "duplicate dependency %q", dependency.Name)
+		} else if dependency.Name == t.Name {
+			errorf(field, "target %q must not depend on itself", t.Name)
+		}
+		dependencies[dependency.Name] = struct{}{}
+	}
+
+	if len(t.Outputs) == 0 {
+		errorf("outputs", "at least one output is required")
+	}
+	for i, output := range t.Outputs {
+		field := fmt.Sprintf("outputs[%d]", i)
+		if output.Plugin == "" {
+			errorf(field+".plugin", "plugin is required")
+		}
+		if output.Out == "" {
+			errorf(field+".out", "out is required")
+		}
+	}
+	return errs.Err()
+}
+
+// LoadTarget reads, decodes, and validates the target file at path.
+func LoadTarget(path string) (*Target, error) {
+	doc, err := readDocument(path, jsonschema.Target)
+	if err != nil {
+		return nil, err
+	}
+	target := &Target{}
+	if err := doc.decode(target); err != nil {
+		return nil, err
+	}
+	target.Path = path
+	target.doc = doc
+	if err := doc.annotate(target.Validate()); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// TargetFiles returns the sorted list of target files selected by the
+// workspace.
+func (w *Workspace) TargetFiles() []string {
+	var files []string
+	for _, path := range w.Targets.Glob(w.Dir()) {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	slices.Sort(files)
+	return files
+}
+
+// LoadTargets loads every target selected by the workspace, and validates
+// them against each other and the projects in the index.
+//
+// Errors from every target are collected, so that all problems in the
+// workspace may be reported at once.
+func (w *Workspace) LoadTargets(index *Index) ([]*Target, error) {
+	var errs Errors
+	var targets []*Target
+	for _, path := range w.TargetFiles() {
+		target, err := LoadTarget(path)
+		if err != nil {
+			errs = append(errs, flatten(err)...)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 && len(errs) == 0 {
+		errs = append(errs, w.errorf("targets", "target patterns do not match any files"))
+	}
+	errs = append(errs, flatten(w.ValidateTargets(targets, index))...)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// ValidateTargets checks the targets for errors that can only be detected
+// with knowledge of every target in the workspace, such as duplicate names,
+// unknown dependencies, and overlapping outputs. Dependencies that are not
+// targets are looked up as projects in the index.
+func (w *Workspace) ValidateTargets(targets []*Target, index *Index) error {
+	var errs Errors
+	errorf := func(target *Target, field, format string, args ...any) {
+		errs = append(errs, target.Errorf(field, format, args...))
+	}
+
+	names := make(map[string]*Target, len(targets))
+	for _, target := range targets {
+		if other, ok := names[target.Name]; ok {
+			errorf(target, "name", "duplicate target %q; first defined in %s", target.Name, other.Path)
+			continue
+		}
+		names[target.Name] = target
+	}
+
+	for _, target := range targets {
+		for i, text := range target.Dependencies {
+			field := fmt.Sprintf("dependencies[%d]", i)
+			dependency, err := ParseDependency(text)
+			if err != nil {
+				continue
+			}
+			if _, ok := names[dependency.Name]; ok {
+				if !dependency.Constraint.IsAny() {
+					errorf(target, field, "dependency on target %q must not have a version constraint", dependency.Name)
+				}
+				continue
+			}
+			if project, _ := index.Lookup(dependency.Name); project != nil {
+				continue
+			}
+			errorf(target, field, "unknown dependency %q", dependency.Name)
+		}
+	}
+
+	type outputRef struct {
+		target *Target
+		index  int
+		dir    string
+	}
+	var outputs []outputRef
+	for _, target := range targets {
+		for i, output := range target.Outputs {
+			dir := w.resolve(filepath.Join(w.Output, output.Out))
+			for _, other := range outputs {
+				if !overlaps(dir, other.dir) {
+					continue
+				}
+				errorf(target, fmt.Sprintf("outputs[%d].out", i),
+					"output %q of plugin %q overlaps with outputs[%d] of target %q",
+					output.Out, output.Plugin, other.index, other.target.Name,
+				)
+				break
+			}
+			outputs = append(outputs, outputRef{target: target, index: i, dir: dir})
+		}
+	}
+	return errs.Err()
+}
+
+// Errorf creates an error attributed to the specified field of the target.
+func (t *Target) Errorf(field, format string, args ...any) error {
+	err := &Error{File: t.Path, Field: field, Err: fmt.Errorf(format, args...)}
+	if t.doc == nil {
+		return err
+	}
+	return t.doc.annotate(err)
+}
+
+// overlaps returns whether the cleaned directories are the same, or one is
+// nested within the other.
+func overlaps(a, b string) bool {
+	return a == b || isWithin(a, b) || isWithin(b, a)
+}
+
+// isWithin returns whether the cleaned path is nested within dir.
+func isWithin(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// isProjectName returns whether the name is of the "owner/project" form used
+// for projects defined in a registry.
+func isProjectName(name string) bool {
+	owner, project, ok := strings.Cut(name, "/")
+	return ok && owner != "" && project != "" && !strings.Contains(project, "/")
+}
+
+// flatten converts the error into a flat list of errors.
+func flatten(err error) Errors {
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return Errors{err}
+}
@@ -0,0 +1,208 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLoadTarget_ValidFile_ReturnsTarget(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "foo.proto"), `syntax = "proto3";`)
+	path := writeFile(t, filepath.Join(dir, config.TargetBaseName+".json"), `{
+		"name": "my-project",
+		"sources": ["*.proto"],
+		"dependencies": ["google/fhir"],
+		"outputs": [{"name": "c++", "plugin": "cpp", "out": "cpp"}]
+	}`)
+	want := &config.Target{
+		Name:         "my-project",
+		Sources:      glob.NewPatterns("*.proto"),
+		Dependencies: []string{"google/fhir"},
+		Outputs: []config.Output{
+			{Name: "c++", Plugin: "cpp", Out: "cpp"},
+		},
+		Path: path,
+	}
+
+	got, err := config.LoadTarget(path)
+	if err != nil {
+		t.Fatalf("LoadTarget: unexpected error: %v", err)
+	}
+
+	opts := cmpopts.IgnoreUnexported(config.Target{})
+	if !cmp.Equal(got, want, opts) {
+		t.Errorf("LoadTarget: (-got +want):\n%s", cmp.Diff(got, want, opts))
+	}
+	if got, want := got.SourceFiles(), []string{filepath.Join(dir, "foo.proto")}; !cmp.Equal(got, want) {
+		t.Errorf("Target.SourceFiles: got %v, want %v", got, want)
+	}
+}
+
+func TestLoadTarget_InvalidTarget_ReturnsFieldError(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantField string
+	}{
+		{
+			name:      "empty sources",
+			content:   `{"name": "a", "sources": [], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "sources",
+		}, {
+			name:      "empty pattern",
+			content:   `{"name": "a", "sources": ["*.proto", ""], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "sources[1]",
+		}, {
+			name:      "only negated patterns",
+			content:   `{"name": "a", "sources": ["!*.proto"], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "sources",
+		}, {
+			name:      "self dependency",
+			content:   `{"name": "a", "sources": ["*.proto"], "dependencies": ["a"], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "dependencies[0]",
+		}, {
+			name:      "invalid constraint",
+			content:   `{"name": "a", "sources": ["*.proto"], "dependencies": ["google/fhir >= main"], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "dependencies[0]",
+		}, {
+			name:      "duplicate constrained dependency",
+			content:   `{"name": "a", "sources": ["*.proto"], "dependencies": ["google/fhir", "google/fhir ^1"], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "dependencies[1]",
+		}, {
+			name:      "missing plugin",
+			content:   `{"name": "a", "sources": ["*.proto"], "outputs": [{"out": "go"}]}`,
+			wantField: "outputs[0].plugin",
+		}, {
+			name:      "slash in name",
+			content:   `{"name": "a/b", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "go"}]}`,
+			wantField: "name",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "foo.proto"), `syntax = "proto3";`)
+			path := writeFile(t, filepath.Join(dir, config.TargetBaseName+".json"), tc.content)
+
+			_, err := config.LoadTarget(path)
+
+			var got *config.Error
+			if !errors.As(err, &got) {
+				t.Fatalf("LoadTarget: got err %v, want config.Error", err)
+			}
+			if got.Field != tc.wantField {
+				t.Errorf("LoadTarget: got field %q, want %q", got.Field, tc.wantField)
+			}
+		})
+	}
+}
+
+func writeWorkspace(t *testing.T, targets map[string]string) *config.Workspace {
+	t.Helper()
+	dir := t.TempDir()
+	path := writeFile(t, filepath.Join(dir, config.WorkspaceBaseName+".json"), `{"targets": ["**/protobuild-target.json"]}`)
+	for name, content := range targets {
+		writeFile(t, filepath.Join(dir, name, "foo.proto"), `syntax = "proto3";`)
+		writeFile(t, filepath.Join(dir, name, config.TargetBaseName+".json"), content)
+	}
+	workspace, err := config.LoadWorkspace(path)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: unexpected error: %v", err)
+	}
+	return workspace
+}
+
+func TestWorkspaceLoadTargets_ValidTargets_ReturnsSortedTargets(t *testing.T) {
+	workspace := writeWorkspace(t, map[string]string{
+		"a": `{"name": "a", "sources": ["*.proto"], "dependencies": ["b", "google/fhir"], "outputs": [{"plugin": "go", "out": "a"}]}`,
+		"b": `{"name": "b", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "ab"}]}`,
+	})
+	index := config.NewIndex(&config.Registry{
+		Name:     "public",
+		Projects: []config.Project{{Name: "google/fhir"}},
+	})
+	want := []string{"a", "b"}
+
+	targets, err := workspace.LoadTargets(index)
+	if err != nil {
+		t.Fatalf("Workspace.LoadTargets: unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, target := range targets {
+		got = append(got, target.Name)
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Workspace.LoadTargets: got %v, want %v", got, want)
+	}
+}
+
+func TestWorkspaceLoadTargets_InvalidTargets_ReturnsErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		targets map[string]string
+		want    string
+	}{
+		{
+			name: "duplicate names",
+			targets: map[string]string{
+				"a": `{"name": "a", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "a"}]}`,
+				"b": `{"name": "a", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "b"}]}`,
+			},
+			want: `duplicate target "a"`,
+		}, {
+			name: "unknown dependency",
+			targets: map[string]string{
+				"a": `{"name": "a", "sources": ["*.proto"], "dependencies": ["c"], "outputs": [{"plugin": "go", "out": "a"}]}`,
+			},
+			want: `unknown dependency "c"`,
+		}, {
+			name: "constrained target dependency",
+			targets: map[string]string{
+				"a": `{"name": "a", "sources": ["*.proto"], "dependencies": ["b >= 1"], "outputs": [{"plugin": "go", "out": "a"}]}`,
+				"b": `{"name": "b", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "b"}]}`,
+			},
+			want: `dependency on target "b" must not have a version constraint`,
+		}, {
+			name: "overlapping outputs",
+			targets: map[string]string{
+				"a": `{"name": "a", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "gen"}]}`,
+				"b": `{"name": "b", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "gen"}]}`,
+			},
+			want: `overlaps with outputs[0] of target "a"`,
+		}, {
+			name: "overlapping outputs of different plugins",
+			targets: map[string]string{
+				"a": `{"name": "a", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "gen"}]}`,
+				"b": `{"name": "b", "sources": ["*.proto"], "outputs": [{"plugin": "java", "out": "gen/"}]}`,
+			},
+			want: `output "gen/" of plugin "java" overlaps with outputs[0] of target "a"`,
+		}, {
+			name: "nested outputs",
+			targets: map[string]string{
+				"a": `{"name": "a", "sources": ["*.proto"], "outputs": [{"plugin": "go", "out": "gen"}, {"plugin": "java", "out": "gen/go"}]}`,
+			},
+			want: `output "gen/go" of plugin "java" overlaps with outputs[0] of target "a"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			workspace := writeWorkspace(t, tc.targets)
+
+			_, err := workspace.LoadTargets(config.NewIndex())
+
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Workspace.LoadTargets: got err %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
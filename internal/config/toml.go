@@ -0,0 +1,201 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// tomlParser parses a TOML document into a node tree.
+type tomlParser struct {
+	parser unstable.Parser
+	root   *node
+}
+
+// parseTOML parses the TOML document in data into a node tree.
+func parseTOML(data []byte) (*node, error) {
+	// The unstable parser does not report the position of errors, so the
+	// document is first checked with the stable decoder, which does.
+	var discard any
+	if err := toml.Unmarshal(data, &discard); err != nil {
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, column := decodeErr.Position()
+			return nil, &Error{
+				Position: Position{Line: line, Column: column},
+				Err:      fmt.Errorf("%w: %s", ErrSyntax, decodeErr.Error()),
+			}
+		}
+		return nil, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	p := &tomlParser{
+		root: &node{kind: kindObject, pos: Position{Line: 1, Column: 1}},
+	}
+	p.parser.Reset(data)
+	current := p.root
+	for p.parser.NextExpression() {
+		expr := p.parser.Expression()
+		var err error
+		switch expr.Kind {
+		case unstable.KeyValue:
+			err = p.keyValue(current, expr)
+		case unstable.Table:
+			current, err = p.table(expr)
+		case unstable.ArrayTable:
+			current, err = p.arrayTable(expr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.parser.Error(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+	return p.root, nil
+}
+
+// position returns the position of the TOML node, or fallback if the node
+// does not record its location.
+func (p *tomlParser) position(n *unstable.Node, fallback Position) Position {
+	if n.Raw.Length == 0 {
+		return fallback
+	}
+	start := p.parser.Shape(n.Raw).Start
+	return Position{Line: start.Line, Column: start.Column}
+}
+
+// keys returns the parts of a dotted key, along with the position of each.
+func (p *tomlParser) keys(it unstable.Iterator) ([]string, []Position) {
+	var keys []string
+	var positions []Position
+	for it.Next() {
+		key := it.Node()
+		keys = append(keys, string(key.Data))
+		positions = append(positions, p.position(key, Position{}))
+	}
+	return keys, positions
+}
+
+// descend navigates from the object through each of the keys, creating any
+// objects that do not yet exist. If a key refers to an array of tables, the
+// most recently defined table of that array is used.
+func (p *tomlParser) descend(current *node, keys []string, positions []Position) (*node, error) {
+	for i, key := range keys {
+		m := current.lookup(key)
+		if m == nil {
+			m = &member{key: key, pos: positions[i], value: &node{kind: kindObject, pos: positions[i]}}
+			current.members = append(current.members, m)
+		}
+		next := m.value
+		if next.kind == kindArray && len(next.items) > 0 {
+			next = next.items[len(next.items)-1]
+		}
+		if next.kind != kindObject {
+			return nil, &Error{
+				Position: positions[i],
+				Err:      fmt.Errorf("%w: key %q is already defined as a %s", ErrSyntax, key, next.kind),
+			}
+		}
+		current = next
+	}
+	return current, nil
+}
+
+func (p *tomlParser) keyValue(current *node, expr *unstable.Node) error {
+	keys, positions := p.keys(expr.Key())
+	parent, err := p.descend(current, keys[:len(keys)-1], positions)
+	if err != nil {
+		return err
+	}
+	key, pos := keys[len(keys)-1], positions[len(keys)-1]
+	if parent.lookup(key) != nil {
+		return &Error{Position: pos, Err: fmt.Errorf("%w: duplicate key %q", ErrSyntax, key)}
+	}
+	value, err := p.value(expr.Value(), pos)
+	if err != nil {
+		return err
+	}
+	parent.members = append(parent.members, &member{key: key, pos: pos, value: value})
+	return nil
+}
+
+func (p *tomlParser) table(expr *unstable.Node) (*node, error) {
+	keys, positions := p.keys(expr.Key())
+	return p.descend(p.root, keys, positions)
+}
+
+func (p *tomlParser) arrayTable(expr *unstable.Node) (*node, error) {
+	keys, positions := p.keys(expr.Key())
+	parent, err := p.descend(p.root, keys[:len(keys)-1], positions)
+	if err != nil {
+		return nil, err
+	}
+	key, pos := keys[len(keys)-1], positions[len(keys)-1]
+	m := parent.lookup(key)
+	if m == nil {
+		m = &member{key: key, pos: pos, value: &node{kind: kindArray, pos: pos}}
+		parent.members = append(parent.members, m)
+	}
+	if m.value.kind != kindArray {
+		return nil, &Error{
+			Position: pos,
+			Err:      fmt.Errorf("%w: key %q is already defined as a %s", ErrSyntax, key, m.value.kind),
+		}
+	}
+	table := &node{kind: kindObject, pos: pos}
+	m.value.items = append(m.value.items, table)
+	return table, nil
+}
+
+// value converts a TOML value into a node. Values that do not record their
+// own position are attributed to the fallback position, which is the position
+// of their key or enclosing value.
+func (p *tomlParser) value(v *unstable.Node, fallback Position) (*node, error) {
+	pos := p.position(v, fallback)
+	n := &node{pos: pos}
+	switch v.Kind {
+	case unstable.String:
+		n.kind, n.scalar = kindString, string(v.Data)
+	case unstable.Bool:
+		n.kind, n.scalar = kindBool, string(v.Data)
+	case unstable.Integer:
+		i, err := strconv.ParseInt(strings.ReplaceAll(string(v.Data), "_", ""), 0, 64)
+		if err != nil {
+			return nil, &Error{Position: pos, Err: fmt.Errorf("%w: invalid integer %s", ErrSyntax, v.Data)}
+		}
+		n.kind, n.scalar = kindNumber, strconv.FormatInt(i, 10)
+	case unstable.Float:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(string(v.Data), "_", ""), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, &Error{Position: pos, Err: fmt.Errorf("%w: %s is not a finite number", ErrSyntax, v.Data)}
+		}
+		n.kind, n.scalar = kindNumber, strconv.FormatFloat(f, 'g', -1, 64)
+	case unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		n.kind, n.scalar = kindString, string(v.Data)
+	case unstable.Array:
+		n.kind = kindArray
+		for it := v.Children(); it.Next(); {
+			item, err := p.value(it.Node(), pos)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+	case unstable.InlineTable:
+		n.kind = kindObject
+		for it := v.Children(); it.Next(); {
+			if err := p.keyValue(n, it.Node()); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, &Error{Position: pos, Err: fmt.Errorf("%w: unexpected %s", ErrSyntax, v.Kind)}
+	}
+	return n, nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/bitwizeshift/protobuild/jsonschema"
+)
+
+// UserBaseName is the name, without extension, of the file in the protobuild
+// path that holds the configuration of the current user, such as
+// "config.json".
+const UserBaseName = "config"
+
+// User is the configuration of the current user, which applies to every
+// workspace.
+type User struct {
+	// Schema is the optional URL of the JSON Schema for the file.
+	Schema string `json:"$schema,omitempty" description:"The URL of the JSON Schema for this file."`
+
+	// Registries are the registries that have been added to the registry
+	// path, and the locations that they are updated from.
+	Registries []RegistryRef `json:"registries,omitempty" description:"The registries that have been added to the registry path, and the locations that they are updated from."`
+
+	// Path is the path of the file this configuration was loaded from, and
+	// that it is saved to.
+	Path string `json:"-"`
+
+	doc *document
+}
+
+// registryName matches the names that registries may be added with, which
+// must be usable as the name of a directory on every platform.
+var registryName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// IsRegistryName returns whether name may be used as the name of a registry.
+func IsRegistryName(name string) bool {
+	return registryName.MatchString(name)
+}
+
+// Registry returns the registry with the given name, or nil if no such
+// registry has been added.
+func (u *User) Registry(name string) *RegistryRef {
+	for i := range u.Registries {
+		if u.Registries[i].Name == name {
+			return &u.Registries[i]
+		}
+	}
+	return nil
+}
+
+// AddRegistry records a registry in the configuration. It is an error to add
+// a registry with the same name as one that has already been added.
+func (u *User) AddRegistry(ref RegistryRef) error {
+	if !IsRegistryName(ref.Name) {
+		return fmt.Errorf("invalid registry name %q; must contain only letters, digits, '.', '_', and '-'", ref.Name)
+	}
+	if u.Registry(ref.Name) != nil {
+		return fmt.Errorf("registry %q has already been added", ref.Name)
+	}
+	u.Registries = append(u.Registries, ref)
+	return nil
+}
+
+// RemoveRegistry removes the registry with the given name from the
+// configuration, and returns whether it had been added.
+func (u *User) RemoveRegistry(name string) bool {
+	n := len(u.Registries)
+	u.Registries = slices.DeleteFunc(u.Registries, func(ref RegistryRef) bool {
+		return ref.Name == name
+	})
+	return len(u.Registries) != n
+}
+
+// Validate checks the configuration for semantic errors that cannot be
+// detected while decoding.
+func (u *User) Validate() error {
+	errs := validateRegistryRefs(u.Registries)
+	for i, registry := range u.Registries {
+		if registry.Name != "" && !IsRegistryName(registry.Name) {
+			errs = append(errs, &Error{
+				Field: fmt.Sprintf("registries[%d].name", i),
+				Err:   fmt.Errorf("registry name %q must contain only letters, digits, '.', '_', and '-'", registry.Name),
+			})
+		}
+	}
+	return errs.Err()
+}
+
+// Save writes the configuration to its path, in the format indicated by its
+// extension. Comments and formatting of the previous file are not preserved.
+func (u *User) Save() error {
+	return save(u.Path, u)
+}
+
+// LoadUser reads, decodes, and validates the user configuration in dir, which
+// is normally the protobuild path. If dir contains no configuration, an empty
+// configuration that saves to a JSON file in dir is returned.
+func LoadUser(dir string) (*User, error) {
+	path, err := findFile(dir, UserBaseName)
+	if errors.Is(err, ErrNotFound) {
+		return &User{Path: filepath.Join(dir, UserBaseName+".json")}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readDocument(path, jsonschema.User)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{}
+	if err := doc.decode(user); err != nil {
+		return nil, err
+	}
+	user.Path = path
+	user.doc = doc
+	if err := doc.annotate(user.Validate()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,82 @@
+package config_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadUser_NoFile_ReturnsEmptyUser(t *testing.T) {
+	dir := t.TempDir()
+	want := filepath.Join(dir, config.UserBaseName+".json")
+
+	got, err := config.LoadUser(dir)
+	if err != nil {
+		t.Fatalf("LoadUser: unexpected error: %v", err)
+	}
+
+	if got.Path != want {
+		t.Errorf("LoadUser: got path %q, want %q", got.Path, want)
+	}
+	if len(got.Registries) != 0 {
+		t.Errorf("LoadUser: got registries %v, want none", got.Registries)
+	}
+}
+
+func TestUserSave_EachFormat_RoundTrips(t *testing.T) {
+	want := []config.RegistryRef{
+		{Name: "public", URL: "https://example.com/registry.git"},
+		{Name: "local", URL: "file:///srv/registry.git"},
+	}
+	for _, ext := range config.Extensions() {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			content := ""
+			if ext == ".json" {
+				content = "{}"
+			}
+			writeFile(t, filepath.Join(dir, config.UserBaseName+ext), content)
+			user, err := config.LoadUser(dir)
+			if err != nil {
+				t.Fatalf("LoadUser: unexpected error: %v", err)
+			}
+			for _, ref := range want {
+				if err := user.AddRegistry(ref); err != nil {
+					t.Fatalf("User.AddRegistry: unexpected error: %v", err)
+				}
+			}
+
+			if err := user.Save(); err != nil {
+				t.Fatalf("User.Save: unexpected error: %v", err)
+			}
+
+			got, err := config.LoadUser(dir)
+			if err != nil {
+				t.Fatalf("LoadUser: unexpected error: %v", err)
+			}
+			if !cmp.Equal(got.Registries, want) {
+				t.Errorf("LoadUser: (-got +want):\n%s", cmp.Diff(got.Registries, want))
+			}
+		})
+	}
+}
+
+func TestLoadUser_InvalidRegistryName_ReturnsFieldError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, config.UserBaseName+".json"), `{
+  "registries": [{"name": "../escape", "url": "https://example.com/registry.git"}]
+}`)
+
+	_, err := config.LoadUser(dir)
+
+	var got *config.Error
+	if !errors.As(err, &got) {
+		t.Fatalf("LoadUser: got err %v, want config.Error", err)
+	}
+	if want := "registries[0].name"; got.Field != want {
+		t.Errorf("LoadUser: got field %q, want %q", got.Field, want)
+	}
+}
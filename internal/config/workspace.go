@@ -0,0 +1,302 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/jsonschema"
+)
+
+// WorkspaceBaseName is the name, without extension, of the file that defines a
+// protobuild workspace, such as "protobuild.yaml". The directory containing
+// this file is the workspace directory.
+const WorkspaceBaseName = "protobuild"
+
+// DefaultVendor is the vendor directory of workspaces that do not specify
+// one.
+const DefaultVendor = "third_party/protobuild"
+
+// The strategies that the versions of registry projects are selected with.
+const (
+	// SelectHighest selects the highest version of each project that
+	// satisfies every version constraint on it.
+	SelectHighest = "highest"
+
+	// SelectMinimal selects the lowest version of each project that satisfies
+	// every version constraint on it.
+	SelectMinimal = "minimal"
+)
+
+// Workspace is the top-level configuration of a protobuild project.
+type Workspace struct {
+	// Schema is the optional URL of the JSON Schema for the file.
+	Schema string `json:"$schema,omitempty" description:"The URL of the JSON Schema for this file."`
+
+	// Root is the directory that proto sources are resolved against, relative
+	// to the workspace directory. Defaults to the workspace directory.
+	Root string `json:"root,omitempty" jsonschema:"default=." description:"The directory that proto sources are resolved against, relative to the workspace directory."`
+
+	// Targets are the glob patterns, relative to the workspace directory,
+	// that select the target definition files of this workspace.
+	Targets glob.Patterns `json:"targets" jsonschema:"minItems=1" description:"Glob patterns, relative to the workspace directory, that select the target definition files of this workspace. Patterns prefixed with '!' exclude matches."`
+
+	// Registries are the registries that external projects are resolved
+	// from.
+	Registries []RegistryRef `json:"registries,omitempty" description:"The registries that external projects are resolved from."`
+
+	// VersionSelection is the strategy that the versions of registry projects
+	// with version constraints are selected with: "highest" or "minimal".
+	// Defaults to "highest".
+	VersionSelection string `json:"version-selection,omitempty" jsonschema:"enum=highest|minimal,default=highest" description:"How versions of registry projects with version constraints are selected: the highest or the minimal version that satisfies every constraint."`
+
+	// PluginOptions are the default options passed to each protoc plugin,
+	// keyed by the name of the plugin.
+	PluginOptions map[string][]string `json:"plugin-options,omitempty" description:"The default options passed to each protoc plugin, keyed by the name of the plugin."`
+
+	// Output is the directory that generated outputs are written under,
+	// relative to the workspace directory. Defaults to the workspace
+	// directory.
+	Output string `json:"output,omitempty" jsonschema:"default=." description:"The directory that generated outputs are written under, relative to the workspace directory."`
+
+	// Vendor is the directory that registry projects are vendored into,
+	// relative to the workspace directory. Defaults to
+	// "third_party/protobuild".
+	Vendor string `json:"vendor,omitempty" jsonschema:"default=third_party/protobuild" description:"The directory that registry projects are vendored into, relative to the workspace directory."`
+
+	// Cache is the remote cache that generated outputs are shared through,
+	// such as between continuous integration and developers.
+	Cache *RemoteCache `json:"cache,omitempty" description:"The remote cache that generated outputs are shared through."`
+
+	// Protoc pins the version of protoc that outputs are generated with,
+	// which is downloaded into the bin path. If nil, protoc is searched for
+	// in the PATH.
+	Protoc *Protoc `json:"protoc,omitempty" description:"The version of protoc that outputs are generated with, which is downloaded into the bin path."`
+
+	// Path is the path of the file this workspace was loaded from.
+	Path string `json:"-"`
+
+	doc *document
+}
+
+// DefaultProtocURL is the URL template of the protoc release archives that
+// are downloaded when a workspace does not specify one.
+const DefaultProtocURL = "https://github.com/protocolbuffers/protobuf/releases/download/v{version}/protoc-{version}-{platform}.zip"
+
+// Protoc is the pinned version of protoc, and where it is downloaded from.
+type Protoc struct {
+	// Version is the version of protoc, such as "27.1".
+	Version string `json:"version" description:"The version of protoc, such as '27.1'."`
+
+	// URL is the template of the URL of the release archive. The texts
+	// "{version}" and "{platform}" are substituted with the version and the
+	// name that protoc releases give the current platform, such as
+	// "linux-x86_64". Defaults to DefaultProtocURL.
+	URL string `json:"url,omitempty" jsonschema:"pattern=^(https?|file)://,default=https://github.com/protocolbuffers/protobuf/releases/download/v{version}/protoc-{version}-{platform}.zip" description:"The URL template of the release archive, as an http, https, or file URL. '{version}' and '{platform}' are substituted with the version and the release name of the current platform, such as 'linux-x86_64'."`
+
+	// Checksums are the hex SHA-256 digests of the release archive of each
+	// platform, keyed by the "<os>/<arch>" platform, such as "linux/amd64".
+	Checksums map[string]string `json:"checksums" jsonschema:"minProperties=1" description:"The hex SHA-256 digests of the release archive of each platform, keyed by the '<os>/<arch>' platform, such as 'linux/amd64'."`
+}
+
+// GetURL returns the URL template of the release archive.
+func (p *Protoc) GetURL() string {
+	if p.URL == "" {
+		return DefaultProtocURL
+	}
+	return p.URL
+}
+
+// RemoteCache is the configuration of a remote cache.
+type RemoteCache struct {
+	// URL is the location of the cache. HTTP caches are read with GET and
+	// written with PUT; file URLs refer to a shared directory.
+	URL string `json:"url" jsonschema:"pattern=^(https?|file)://" description:"The location of the cache, as an http, https, or file URL."`
+
+	// ReadOnly only reads outputs from the cache, without storing newly
+	// generated outputs in it.
+	ReadOnly bool `json:"read-only,omitempty" jsonschema:"default=false" description:"Only read outputs from the cache, without storing newly generated outputs in it."`
+}
+
+// RegistryRef is a reference to a registry from a workspace.
+type RegistryRef struct {
+	// Name is the name that the registry is referred to by.
+	Name string `json:"name" description:"The name that the registry is referred to by."`
+
+	// URL is the location the registry is retrieved from.
+	URL string `json:"url" description:"The location the registry is retrieved from."`
+}
+
+// Dir returns the workspace directory.
+func (w *Workspace) Dir() string {
+	return filepath.Dir(w.Path)
+}
+
+// RootDir returns the directory that proto sources are resolved against.
+func (w *Workspace) RootDir() string {
+	return w.resolve(w.Root)
+}
+
+// OutputDir returns the directory that generated outputs are written under.
+func (w *Workspace) OutputDir() string {
+	return w.resolve(w.Output)
+}
+
+// VendorDir returns the directory that registry projects are vendored into.
+func (w *Workspace) VendorDir() string {
+	if w.Vendor == "" {
+		return w.resolve(filepath.FromSlash(DefaultVendor))
+	}
+	return w.resolve(w.Vendor)
+}
+
+func (w *Workspace) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(w.Dir(), path)
+}
+
+// Save writes the workspace to its path, in the format indicated by its
+// extension. Comments and formatting of the previous file are not preserved.
+func (w *Workspace) Save() error {
+	return save(w.Path, w)
+}
+
+// Validate checks the workspace for semantic errors that cannot be detected
+// while decoding.
+func (w *Workspace) Validate() error {
+	var errs Errors
+	if len(w.Targets) == 0 {
+		errs = append(errs, &Error{
+			Field: "targets",
+			Err:   errors.New("at least one target pattern is required"),
+		})
+	}
+	errs = append(errs, validateRegistryRefs(w.Registries)...)
+	if w.Cache != nil {
+		u, err := url.Parse(w.Cache.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+			errs = append(errs, &Error{
+				Field: "cache.url",
+				Err:   fmt.Errorf("cache url %q must be an http, https, or file URL", w.Cache.URL),
+			})
+		}
+	}
+	if w.Protoc != nil {
+		errs = append(errs, validateProtoc(w.Protoc)...)
+	}
+	return errs.Err()
+}
+
+// validateProtoc checks that the pinned version of protoc has a version and
+// a well-formed checksum for each platform.
+func validateProtoc(protoc *Protoc) Errors {
+	var errs Errors
+	if protoc.Version == "" {
+		errs = append(errs, &Error{Field: "protoc.version", Err: errors.New("version is required")})
+	}
+	if protoc.URL != "" {
+		u, err := url.Parse(protoc.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+			errs = append(errs, &Error{
+				Field: "protoc.url",
+				Err:   fmt.Errorf("protoc url %q must be an http, https, or file URL", protoc.URL),
+			})
+		}
+	}
+	keys := make([]string, 0, len(protoc.Checksums))
+	for key := range protoc.Checksums {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		field := "protoc.checksums." + key
+		if !platform.MatchString(key) {
+			errs = append(errs, &Error{Field: field, Err: fmt.Errorf("platform %q must be of the form \"<os>/<arch>\"", key)})
+		} else if !sha256Hex.MatchString(protoc.Checksums[key]) {
+			errs = append(errs, &Error{Field: field, Err: fmt.Errorf("checksum %q must be a hex SHA-256 digest", protoc.Checksums[key])})
+		}
+	}
+	return errs
+}
+
+// validateRegistryRefs checks that every registry reference has a name and a
+// URL, and that no two references share a name.
+func validateRegistryRefs(refs []RegistryRef) Errors {
+	var errs Errors
+	names := make(map[string]int, len(refs))
+	for i, registry := range refs {
+		field := fmt.Sprintf("registries[%d]", i)
+		if registry.Name == "" {
+			errs = append(errs, &Error{Field: field + ".name", Err: errors.New("name is required")})
+		} else if j, ok := names[registry.Name]; ok {
+			errs = append(errs, &Error{
+				Field: field + ".name",
+				Err:   fmt.Errorf("duplicate registry %q; first defined in registries[%d]", registry.Name, j),
+			})
+		} else {
+			names[registry.Name] = i
+		}
+		if registry.URL == "" {
+			errs = append(errs, &Error{Field: field + ".url", Err: errors.New("url is required")})
+		}
+	}
+	return errs
+}
+
+// errorf creates an error attributed to the specified field of the workspace.
+func (w *Workspace) errorf(field, format string, args ...any) error {
+	err := &Error{File: w.Path, Field: field, Err: fmt.Errorf(format, args...)}
+	if w.doc == nil {
+		return err
+	}
+	return w.doc.annotate(err)
+}
+
+// FindWorkspace searches for the workspace file in dir and each of its parent
+// directories, returning the path of the first one found. The workspace file
+// may be in any supported format.
+func FindWorkspace(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path, err := findFile(dir, WorkspaceBaseName)
+		if err == nil {
+			return path, nil
+		} else if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("workspace %w: no %s file in the current directory or any parent",
+				ErrNotFound, strings.Join(FileNames(WorkspaceBaseName), ", "),
+			)
+		}
+		dir = parent
+	}
+}
+
+// LoadWorkspace reads, decodes, and validates the workspace file at path.
+func LoadWorkspace(path string) (*Workspace, error) {
+	doc, err := readDocument(path, jsonschema.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	workspace := &Workspace{}
+	if err := doc.decode(workspace); err != nil {
+		return nil, err
+	}
+	workspace.Path = path
+	workspace.doc = doc
+	if err := doc.annotate(workspace.Validate()); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
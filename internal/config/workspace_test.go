@@ -0,0 +1,188 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll: unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestFindWorkspace_FileInParent_ReturnsPath(t *testing.T) {
+	root := t.TempDir()
+	want := writeFile(t, filepath.Join(root, config.WorkspaceBaseName+".json"), `{}`)
+	dir := filepath.Join(root, "foo", "bar")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: unexpected error: %v", err)
+	}
+
+	got, err := config.FindWorkspace(dir)
+	if err != nil {
+		t.Fatalf("FindWorkspace: unexpected error: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("FindWorkspace: got %q, want %q", got, want)
+	}
+}
+
+func TestFindWorkspace_NoFile_ReturnsNotFound(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := config.FindWorkspace(dir)
+
+	if got, want := err, config.ErrNotFound; !cmp.Equal(got, want, cmpopts.EquateErrors()) {
+		t.Errorf("FindWorkspace: got err %v, want %v", got, want)
+	}
+}
+
+func TestLoadWorkspace_ValidFile_ReturnsWorkspace(t *testing.T) {
+	path := writeFile(t, filepath.Join(t.TempDir(), config.WorkspaceBaseName+".json"), `{
+		"root": "proto",
+		"targets": ["**/protobuild-target.json", "!third_party/**"],
+		"registries": [{"name": "public", "url": "https://example.com/registry.git"}],
+		"plugin-options": {"go": ["paths=source_relative"]},
+		"output": "gen"
+	}`)
+	want := &config.Workspace{
+		Root:    "proto",
+		Targets: glob.NewPatterns("**/protobuild-target.json", "!third_party/**"),
+		Registries: []config.RegistryRef{
+			{Name: "public", URL: "https://example.com/registry.git"},
+		},
+		PluginOptions: map[string][]string{
+			"go": {"paths=source_relative"},
+		},
+		Output: "gen",
+		Path:   path,
+	}
+
+	got, err := config.LoadWorkspace(path)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: unexpected error: %v", err)
+	}
+
+	opts := cmpopts.IgnoreUnexported(config.Workspace{})
+	if !cmp.Equal(got, want, opts) {
+		t.Errorf("LoadWorkspace: (-got +want):\n%s", cmp.Diff(got, want, opts))
+	}
+}
+
+func TestLoadWorkspace_InvalidFile_ReturnsPositionedError(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    config.Error
+		wantErr error
+	}{
+		{
+			name: "unknown field",
+			content: `{
+  "targets": ["*.json"],
+  "outptu": "gen"
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 3, Column: 3},
+				Field:    "outptu",
+			},
+		}, {
+			name: "type mismatch",
+			content: `{
+  "targets": ["*.json"],
+  "registries": [
+    {"name": "public", "url": 42}
+  ]
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 4, Column: 31},
+				Field:    "registries[0].url",
+			},
+		}, {
+			name: "syntax error",
+			content: `{
+  "targets": ["*.json"],,
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 2, Column: 25},
+			},
+			wantErr: config.ErrSyntax,
+		}, {
+			name: "missing targets",
+			content: `{
+  "output": "gen"
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 1, Column: 1},
+				Field:    "targets",
+			},
+		}, {
+			name: "duplicate registry",
+			content: `{
+  "targets": ["*.json"],
+  "registries": [
+    {"name": "public", "url": "a"},
+    {"name": "public", "url": "b"}
+  ]
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 5, Column: 14},
+				Field:    "registries[1].name",
+			},
+		}, {
+			name: "unsupported cache url",
+			content: `{
+  "targets": ["*.json"],
+  "cache": {"url": "ftp://cache.example.com"}
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 3, Column: 20},
+				Field:    "cache.url",
+			},
+		}, {
+			name: "bad protoc checksum",
+			content: `{
+  "targets": ["*.json"],
+  "protoc": {"version": "27.1", "checksums": {"linux/amd64": "abc"}}
+}`,
+			want: config.Error{
+				Position: config.Position{Line: 3, Column: 62},
+				Field:    "protoc.checksums.linux/amd64",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, filepath.Join(t.TempDir(), config.WorkspaceBaseName+".json"), tc.content)
+			tc.want.File = path
+
+			_, err := config.LoadWorkspace(path)
+
+			var got *config.Error
+			if !errors.As(err, &got) {
+				t.Fatalf("LoadWorkspace: got err %v, want config.Error", err)
+			}
+			if !cmp.Equal(*got, tc.want, cmpopts.IgnoreFields(config.Error{}, "Err")) {
+				t.Errorf("LoadWorkspace: (-got +want):\n%s", cmp.Diff(*got, tc.want, cmpopts.IgnoreFields(config.Error{}, "Err")))
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("LoadWorkspace: got err %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlErrorLine extracts the line number from the errors reported by the
+// YAML parser, which are of the form "yaml: line N: message".
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parseYAML parses the YAML document in data into a node tree. Only the first
+// document of a multi-document stream is used.
+func parseYAML(data []byte) (*node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return nil, &Error{
+				Position: Position{Line: line, Column: 1},
+				Err:      fmt.Errorf("%w: %s", ErrSyntax, match[2]),
+			}
+		}
+		return nil, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+	if doc.Kind == 0 {
+		// An empty document is equivalent to an empty object.
+		return &node{kind: kindObject, pos: Position{Line: 1, Column: 1}}, nil
+	}
+	return convertYAML(&doc)
+}
+
+func convertYAML(y *yaml.Node) (*node, error) {
+	pos := Position{Line: y.Line, Column: y.Column}
+	switch y.Kind {
+	case yaml.DocumentNode:
+		return convertYAML(y.Content[0])
+	case yaml.AliasNode:
+		return convertYAML(y.Alias)
+	case yaml.SequenceNode:
+		n := &node{kind: kindArray, pos: pos}
+		for _, item := range y.Content {
+			child, err := convertYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, child)
+		}
+		return n, nil
+	case yaml.MappingNode:
+		n := &node{kind: kindObject, pos: pos}
+		if err := mergeYAML(n, y); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return convertYAMLScalar(y)
+}
+
+// mergeYAML adds the members of the mapping to the object node, expanding any
+// "<<" merge keys.
+func mergeYAML(n *node, y *yaml.Node) error {
+	for i := 0; i+1 < len(y.Content); i += 2 {
+		key, value := y.Content[i], y.Content[i+1]
+		pos := Position{Line: key.Line, Column: key.Column}
+		if key.Kind != yaml.ScalarNode {
+			return &Error{Position: pos, Err: fmt.Errorf("%w: mapping keys must be scalars", ErrSyntax)}
+		}
+		if key.Tag == "!!merge" {
+			if err := mergeYAMLValue(n, value); err != nil {
+				return err
+			}
+			continue
+		}
+		child, err := convertYAML(value)
+		if err != nil {
+			return err
+		}
+		if existing := n.lookup(key.Value); existing != nil {
+			return &Error{Position: pos, Err: fmt.Errorf("%w: duplicate key %q", ErrSyntax, key.Value)}
+		}
+		n.members = append(n.members, &member{key: key.Value, pos: pos, value: child})
+	}
+	return nil
+}
+
+func mergeYAMLValue(n *node, value *yaml.Node) error {
+	for value.Kind == yaml.AliasNode {
+		value = value.Alias
+	}
+	switch value.Kind {
+	case yaml.MappingNode:
+		return mergeYAML(n, value)
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			if err := mergeYAMLValue(n, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return &Error{
+		Position: Position{Line: value.Line, Column: value.Column},
+		Err:      fmt.Errorf("%w: merge value must be a mapping", ErrSyntax),
+	}
+}
+
+func convertYAMLScalar(y *yaml.Node) (*node, error) {
+	n := &node{pos: Position{Line: y.Line, Column: y.Column}}
+	switch y.ShortTag() {
+	case "!!null":
+		n.kind = kindNull
+		return n, nil
+	case "!!bool":
+		var b bool
+		if err := y.Decode(&b); err != nil {
+			return nil, &Error{Position: n.pos, Err: fmt.Errorf("%w: %w", ErrSyntax, err)}
+		}
+		n.kind, n.scalar = kindBool, strconv.FormatBool(b)
+		return n, nil
+	case "!!int":
+		var i int64
+		if err := y.Decode(&i); err != nil {
+			return nil, &Error{Position: n.pos, Err: fmt.Errorf("%w: %w", ErrSyntax, err)}
+		}
+		n.kind, n.scalar = kindNumber, strconv.FormatInt(i, 10)
+		return n, nil
+	case "!!float":
+		var f float64
+		if err := y.Decode(&f); err != nil {
+			return nil, &Error{Position: n.pos, Err: fmt.Errorf("%w: %w", ErrSyntax, err)}
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, &Error{Position: n.pos, Err: fmt.Errorf("%w: %s is not a finite number", ErrSyntax, y.Value)}
+		}
+		n.kind, n.scalar = kindNumber, strconv.FormatFloat(f, 'g', -1, 64)
+		return n, nil
+	}
+	// Strings, timestamps, and binary values are all retained as text.
+	n.kind, n.scalar = kindString, y.Value
+	return n, nil
+}
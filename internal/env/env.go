@@ -19,22 +19,8 @@ var ErrNoProtobuildPath = fmt.Errorf("protobuild path")
 
 // ConfigPath returns the path to the protobuild directory.
 func ConfigPath() (string, error) {
-	if path := os.Getenv("PROTOBUILD_PATH"); path != "" {
-		return path, nil
-	}
-	if home := os.Getenv("HOME"); home != "" {
-		return filepath.Join(home, ".protobuild"), nil
-	}
-	if build.Default.GOOS == "windows" {
-		if userprofile := os.Getenv("USERPROFILE"); userprofile != "" {
-			return filepath.Join(userprofile, ".protobuild"), nil
-		}
-	}
-	dirname, err := sys.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("%w: %w", ErrNoProtobuildPath, err)
-	}
-	return filepath.Join(dirname, ".protobuild"), nil
+	path, _, err := resolveConfigPath()
+	return path, err
 }
 
 // BinPath the path to where protobuf binaries are stored.
@@ -52,6 +38,51 @@ func CachePath() (string, error) {
 	return configPath("PROTOBUILD_CACHE", "cache")
 }
 
+// subpaths are the directories of the protobuild path that each path
+// defaults to, keyed by the environment variable that overrides it.
+var subpaths = map[string]string{
+	"PROTOBUILD_BIN":      "bin",
+	"PROTOBUILD_REGISTRY": "registry",
+	"PROTOBUILD_CACHE":    "cache",
+}
+
+// Origin describes what determined the path that is set by the environment
+// variable, which is one of PROTOBUILD_PATH, PROTOBUILD_BIN,
+// PROTOBUILD_REGISTRY, and PROTOBUILD_CACHE. The origin is the variable if
+// it is set, such as "$PROTOBUILD_BIN", and otherwise the default that the
+// path falls back to, such as "$HOME/.protobuild/bin".
+func Origin(variable string) string {
+	if os.Getenv(variable) != "" {
+		return "$" + variable
+	}
+	_, origin, _ := resolveConfigPath()
+	if subpath, ok := subpaths[variable]; ok {
+		return origin + "/" + subpath
+	}
+	return origin
+}
+
+// resolveConfigPath returns the path to the protobuild directory, along with
+// a description of what determined it.
+func resolveConfigPath() (string, string, error) {
+	if path := os.Getenv("PROTOBUILD_PATH"); path != "" {
+		return path, "$PROTOBUILD_PATH", nil
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".protobuild"), "$HOME/.protobuild", nil
+	}
+	if build.Default.GOOS == "windows" {
+		if userprofile := os.Getenv("USERPROFILE"); userprofile != "" {
+			return filepath.Join(userprofile, ".protobuild"), "%USERPROFILE%/.protobuild", nil
+		}
+	}
+	dirname, err := sys.UserHomeDir()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrNoProtobuildPath, err)
+	}
+	return filepath.Join(dirname, ".protobuild"), "~/.protobuild", nil
+}
+
 func configPath(env, subpath string) (string, error) {
 	if path := os.Getenv(env); path != "" {
 		return path, nil
@@ -70,3 +101,9 @@ func Must[T any](value T, err error) T {
 	}
 	return value
 }
+
+// ProtocMirror returns the directory that protoc release archives are taken
+// from, instead of downloading them, or "" if no mirror has been set.
+func ProtocMirror() string {
+	return os.Getenv("PROTOBUILD_PROTOC_MIRROR")
+}
@@ -239,3 +239,48 @@ func TestPath_ConfigPathErrors_ReturnsError(t *testing.T) {
 		})
 	}
 }
+
+func TestOrigin(t *testing.T) {
+	testCases := []struct {
+		name     string
+		variable string
+		env      map[string]string
+		want     string
+	}{
+		{
+			name:     "variable set",
+			variable: "PROTOBUILD_BIN",
+			env:      map[string]string{"PROTOBUILD_BIN": "/opt/bin", "PROTOBUILD_PATH": "/opt"},
+			want:     "$PROTOBUILD_BIN",
+		}, {
+			name:     "protobuild path set",
+			variable: "PROTOBUILD_CACHE",
+			env:      map[string]string{"PROTOBUILD_CACHE": "", "PROTOBUILD_PATH": "/opt"},
+			want:     "$PROTOBUILD_PATH/cache",
+		}, {
+			name:     "home set",
+			variable: "PROTOBUILD_REGISTRY",
+			env:      map[string]string{"PROTOBUILD_REGISTRY": "", "PROTOBUILD_PATH": "", "HOME": defaultHome()},
+			want:     "$HOME/.protobuild/registry",
+		}, {
+			name:     "protobuild path",
+			variable: "PROTOBUILD_PATH",
+			env:      map[string]string{"PROTOBUILD_PATH": "", "HOME": defaultHome()},
+			want:     "$HOME/.protobuild",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			got := env.Origin(tc.variable)
+
+			if got != tc.want {
+				t.Errorf("Origin: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
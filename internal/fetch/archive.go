@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extract extracts the zip, tar, or gzipped tar archive into root. The format
+// is detected from the contents of the archive, rather than its name. If
+// every file of the archive is within a single top-level directory, as with
+// the archives that git hosts create, that directory becomes the root.
+func Extract(archive *os.File, name string, root string) error {
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tmp := root + ".extract"
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	r := bufio.NewReader(archive)
+	magic, _ := r.Peek(4)
+	var err error
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		err = extractZip(archive, tmp)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(r); err == nil {
+			err = extractTar(gz, tmp)
+		}
+	default:
+		err = extractTar(r, tmp)
+	}
+	if err != nil {
+		return fmt.Errorf("extracting %s: %w", name, err)
+	}
+
+	top := tmp
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		top = filepath.Join(tmp, entries[0].Name())
+	}
+	return os.Rename(top, root)
+}
+
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if err := writeEntry(dir, header.Name, fs.FileMode(header.Mode), tr); err != nil {
+				return err
+			}
+		case tar.TypeDir:
+			if err := makeDir(dir, header.Name); err != nil {
+				return err
+			}
+		}
+		// Links and other special files are never needed to generate, and
+		// are skipped so that they cannot point outside of the sources.
+	}
+}
+
+func extractZip(archive *os.File, dir string) error {
+	info, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(archive, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		switch mode := file.Mode(); {
+		case mode.IsDir():
+			if err := makeDir(dir, file.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := file.Open()
+			if err != nil {
+				return err
+			}
+			err = writeEntry(dir, file.Name, mode, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryPath returns the path of an archive entry within dir. Entries that
+// would be written outside of dir are rejected.
+func entryPath(dir, name string) (string, error) {
+	name = filepath.FromSlash(strings.TrimPrefix(name, "./"))
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("archive entry %q is outside of the archive", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func makeDir(dir, name string) error {
+	if strings.Trim(name, "./") == "" {
+		return nil
+	}
+	path, err := entryPath(dir, name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0o755)
+}
+
+func writeEntry(dir, name string, mode fs.FileMode, r io.Reader) error {
+	path, err := entryPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
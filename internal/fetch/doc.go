@@ -0,0 +1,10 @@
+/*
+Package fetch materializes the sources of registry projects on the local
+filesystem, so that their proto files can be used as include paths.
+
+Each version of a project is fetched once, from its git repository or archive,
+into its own directory under the cache, and is verified against the integrity
+digest recorded in its registry. Projects may also be vendored into a
+workspace, so that generation does not depend on the network.
+*/
+package fetch
@@ -0,0 +1,322 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/git"
+)
+
+// IntegrityPrefix is the prefix of every integrity digest.
+const IntegrityPrefix = "sha256-"
+
+// metadataExt is the extension of the file beside each fetched source
+// directory that records where it was fetched from, and its integrity digest.
+const metadataExt = ".json"
+
+// IntegrityError is the error returned when fetched sources do not match the
+// integrity digest of their version.
+type IntegrityError struct {
+	// Project is the name of the project that was fetched.
+	Project string
+
+	// Version is the version that was fetched.
+	Version string
+
+	// Want is the integrity digest recorded in the registry.
+	Want string
+
+	// Got is the integrity digest of the fetched sources.
+	Got string
+}
+
+// Error implements the error interface.
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("project %s %s: integrity mismatch: registry has %s, but the sources have %s",
+		e.Project, e.Version, e.Want, e.Got,
+	)
+}
+
+var _ error = (*IntegrityError)(nil)
+
+// Fetcher fetches the sources of registry projects into versioned directories.
+type Fetcher struct {
+	// Dir is the directory that sources are fetched into. Each version of a
+	// project is stored in "<registry>/<owner>/<project>/<version>".
+	Dir string
+
+	// Client is the client that archives are downloaded with. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Source is the fetched sources of a single version of a project.
+type Source struct {
+	// Project is the project that was fetched.
+	Project *config.Project
+
+	// Registry is the registry that defines the project.
+	Registry *config.Registry
+
+	// Version is the version of the project that was fetched.
+	Version *config.Version
+
+	// Dir is the directory containing the sources.
+	Dir string
+
+	// URL is the location that the sources were fetched from, which is the
+	// git repository or the expanded archive URL.
+	URL string `json:"url"`
+
+	// Commit is the hash of the commit that the sources were fetched at, if
+	// they were fetched from a git repository.
+	Commit string `json:"commit,omitempty"`
+
+	// Integrity is the integrity digest of the sources.
+	Integrity string `json:"integrity"`
+
+	// Fetched is whether the sources were fetched, rather than already
+	// present.
+	Fetched bool `json:"-"`
+}
+
+// Path returns the directory that the version of the project of the registry
+// is fetched into.
+func (f *Fetcher) Path(project *config.Project, registry *config.Registry, version *config.Version) string {
+	owner, name, _ := strings.Cut(project.Name, "/")
+	return filepath.Join(f.Dir, registry.Name, owner, name, url.PathEscape(version.Version))
+}
+
+// Fetch returns the sources of the version of the project, fetching them if
+// they have not been fetched before. If the version has an integrity digest,
+// the sources must match it.
+//
+// Sources that were fetched before are reused as long as they match the
+// integrity digest of the version. A version without a digest may refer to a
+// ref that moves, such as a branch; such versions are only fetched again if
+// refetch is set.
+func (f *Fetcher) Fetch(ctx context.Context, project *config.Project, registry *config.Registry, version *config.Version, refetch bool) (*Source, error) {
+	dir := f.Path(project, registry, version)
+	if source, err := readMetadata(dir); err == nil {
+		if version.Integrity == source.Integrity || (version.Integrity == "" && !refetch) {
+			source.Project, source.Registry, source.Version = project, registry, version
+			return source, nil
+		}
+	}
+
+	// Sources are fetched next to their final location, and only moved into
+	// place once they have been verified, so that a failed or concurrent
+	// fetch never leaves partial sources behind.
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".fetch-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	source := &Source{Project: project, Registry: registry, Version: version, Dir: dir, Fetched: true}
+	root := filepath.Join(tmp, "src")
+	switch {
+	case project.Source.Git != "":
+		source.URL = resolveLocal(project.Source.Git, registry)
+		source.Commit, err = git.Snapshot(ctx, source.URL, version.GetRef(), root)
+	case project.Source.Archive != "":
+		source.URL = ArchiveURL(project, version)
+		err = f.fetchArchive(ctx, source.URL, registry, tmp, root)
+	default:
+		err = errors.New("project has no source")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetching project %s %s: %w", project.Name, version.Version, err)
+	}
+
+	if source.Integrity, err = DigestTree(root); err != nil {
+		return nil, err
+	}
+	if version.Integrity != "" && version.Integrity != source.Integrity {
+		return nil, &IntegrityError{
+			Project: project.Name,
+			Version: version.Version,
+			Want:    version.Integrity,
+			Got:     source.Integrity,
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(root, dir); err != nil {
+		return nil, err
+	}
+	if err := writeMetadata(source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// readMetadata reads the metadata recorded beside the sources fetched into
+// dir. Sources without metadata were not completely fetched.
+func readMetadata(dir string) (*Source, error) {
+	data, err := os.ReadFile(dir + metadataExt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	source := &Source{Dir: dir}
+	if err := json.Unmarshal(data, source); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func writeMetadata(source *Source) error {
+	data, err := json.MarshalIndent(source, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(source.Dir+metadataExt, data, 0o644)
+}
+
+// ArchiveURL returns the URL of the archive of the version of a project with
+// an archive source.
+func ArchiveURL(project *config.Project, version *config.Version) string {
+	return strings.NewReplacer(
+		"{version}", version.Version,
+		"{ref}", version.GetRef(),
+	).Replace(project.Source.Archive)
+}
+
+// fetchArchive downloads the archive at rawURL into tmp, and extracts it into
+// root.
+func (f *Fetcher) fetchArchive(ctx context.Context, rawURL string, registry *config.Registry, tmp, root string) error {
+	body, err := f.Open(ctx, rawURL, registry)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	archive, err := os.Create(filepath.Join(tmp, "archive"))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	if _, err := io.Copy(archive, body); err != nil {
+		return err
+	}
+	return Extract(archive, rawURL, root)
+}
+
+// Open opens the resource at rawURL, which may be an http, https, or file
+// URL, or a path relative to the registry.
+func (f *Fetcher) Open(ctx context.Context, rawURL string, registry *config.Registry) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Single letter schemes are Windows drive letters.
+		return os.Open(resolveLocal(rawURL, registry))
+	}
+	switch u.Scheme {
+	case "file":
+		return os.Open(filepath.FromSlash(u.Path))
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported url %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", u.Redacted(), resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// resolveLocal resolves a source that is a path relative to the registry,
+// which allows registries to refer to sources that are stored alongside them.
+// Any other source is returned unchanged.
+func resolveLocal(source string, registry *config.Registry) string {
+	if strings.Contains(source, "://") || filepath.IsAbs(source) {
+		return source
+	}
+	path := filepath.Join(registry.Dir(), source)
+	if _, err := os.Stat(path); err != nil {
+		return source
+	}
+	return path
+}
+
+// DigestTree returns the integrity digest of the regular files under dir, in
+// "sha256-<hex>" form.
+//
+// The digest is the SHA-256 of a listing of every file, sorted by path, where
+// each line is the hex SHA-256 of the contents of the file, two spaces, and
+// its slash-separated path relative to dir; this is the output of
+// "sha256sum" for the same files. Directories, symbolic links, and file
+// modes do not contribute to the digest.
+func DigestTree(dir string) (string, error) {
+	var lines []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		digest, err := digestFile(path)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, digest+"  "+filepath.ToSlash(rel)+"\n")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	slices.SortFunc(lines, func(lhs, rhs string) int {
+		return strings.Compare(lhs[66:], rhs[66:])
+	})
+	hash := sha256.New()
+	for _, line := range lines {
+		io.WriteString(hash, line)
+	}
+	return IntegrityPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func digestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
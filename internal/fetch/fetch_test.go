@@ -0,0 +1,366 @@
+package fetch_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+	"github.com/bitwizeshift/protobuild/internal/git"
+	"github.com/google/go-cmp/cmp"
+)
+
+// files are the sources of the project used by every test.
+var files = map[string]string{
+	"proto/google/fhir/r4.proto": `syntax = "proto3";`,
+	"README.md":                  "fhir",
+}
+
+// integrity is the integrity digest of files, as computed by:
+//
+//	sha256sum README.md proto/google/fhir/r4.proto | sha256sum
+const integrity = "sha256-e78f5e501bdab059c18c0bc6b1c5a06a9fb08e40fb4b586d90425db1e0f61bdf"
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	result := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		result[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir: unexpected error: %v", err)
+	}
+	return result
+}
+
+// gitProject creates a bare repository containing files at the tag "v1", and
+// returns a project whose source is the repository.
+func gitProject(t *testing.T) *config.Project {
+	t.Helper()
+	if _, err := exec.LookPath(git.Command); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	bare, work := filepath.Join(root, "fhir.git"), filepath.Join(root, "work")
+	ctx := context.Background()
+	for _, args := range [][]string{
+		{"init", "--quiet", "--bare", bare},
+		{"clone", "--quiet", bare, work},
+	} {
+		if _, err := git.Run(ctx, root, args...); err != nil {
+			t.Fatalf("git %v: unexpected error: %v", args, err)
+		}
+	}
+	writeFiles(t, work, files)
+	for _, args := range [][]string{
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "v1"},
+		{"tag", "v1"},
+		{"push", "--quiet", "origin", "HEAD", "v1"},
+	} {
+		if _, err := git.Run(ctx, work, args...); err != nil {
+			t.Fatalf("git %v: unexpected error: %v", args, err)
+		}
+	}
+	return &config.Project{
+		Name:     "google/fhir",
+		Source:   config.Source{Git: "file://" + filepath.ToSlash(bare)},
+		Versions: []config.Version{{Version: "v1"}},
+	}
+}
+
+// gitCommit returns the hash of the commit that ref refers to in the
+// repository of a project created by gitProject.
+func gitCommit(t *testing.T, project *config.Project, ref string) string {
+	t.Helper()
+	dir := strings.TrimPrefix(project.Source.Git, "file://")
+	out, err := git.Run(context.Background(), filepath.FromSlash(dir), "rev-parse", ref+"^{commit}")
+	if err != nil {
+		t.Fatalf("git rev-parse: unexpected error: %v", err)
+	}
+	return strings.TrimSpace(out)
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatalf("WriteHeader: unexpected error: %v", err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create: unexpected error: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// prefixed returns the files within a top-level directory, as in the
+// archives created by git hosts.
+func prefixed(prefix string) map[string]string {
+	result := make(map[string]string, len(files))
+	for name, content := range files {
+		result[prefix+"/"+name] = content
+	}
+	return result
+}
+
+func TestFetcherFetch_GitSource_FetchesRef(t *testing.T) {
+	project := gitProject(t)
+	registry := &config.Registry{Name: "public"}
+	fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+
+	source, err := fetcher.Fetch(context.Background(), project, registry, &project.Versions[0], false)
+	if err != nil {
+		t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+	}
+
+	if got := readFiles(t, source.Dir); !cmp.Equal(got, files) {
+		t.Errorf("Fetcher.Fetch: (-got +want):\n%s", cmp.Diff(got, files))
+	}
+	if want := filepath.Join(fetcher.Dir, "public", "google", "fhir", "v1"); source.Dir != want {
+		t.Errorf("Fetcher.Fetch: got dir %q, want %q", source.Dir, want)
+	}
+	if want := integrity; source.Integrity != want {
+		t.Errorf("Fetcher.Fetch: got integrity %q, want %q", source.Integrity, want)
+	}
+	if want := gitCommit(t, project, "v1"); source.Commit != want {
+		t.Errorf("Fetcher.Fetch: got commit %q, want %q", source.Commit, want)
+	}
+}
+
+func TestFetcherFetch_CommitRef_FetchesCommit(t *testing.T) {
+	project := gitProject(t)
+	commit := gitCommit(t, project, "v1")
+	version := &config.Version{Version: "v1", Ref: commit, Integrity: integrity}
+	fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+
+	source, err := fetcher.Fetch(context.Background(), project, &config.Registry{Name: "public"}, version, false)
+	if err != nil {
+		t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+	}
+
+	if source.Commit != commit {
+		t.Errorf("Fetcher.Fetch: got commit %q, want %q", source.Commit, commit)
+	}
+	if got := readFiles(t, source.Dir); !cmp.Equal(got, files) {
+		t.Errorf("Fetcher.Fetch: (-got +want):\n%s", cmp.Diff(got, files))
+	}
+}
+
+func TestFetcherFetch_ArchiveSource_ExtractsArchive(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		archive func(*testing.T, map[string]string) []byte
+	}{
+		{name: "tar.gz", path: "/fhir/{version}.tar.gz", archive: tarGz},
+		{name: "zip", path: "/fhir/{ref}.zip", archive: zipArchive},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := tc.archive(t, prefixed("fhir-1.0.0"))
+			var requested string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				w.Write(data)
+			}))
+			defer server.Close()
+			project := &config.Project{
+				Name:     "google/fhir",
+				Source:   config.Source{Archive: server.URL + tc.path},
+				Versions: []config.Version{{Version: "v1.0.0", Ref: "1.0.0", Integrity: integrity}},
+			}
+			fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+
+			source, err := fetcher.Fetch(context.Background(), project, &config.Registry{Name: "public"}, &project.Versions[0], false)
+			if err != nil {
+				t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+			}
+
+			if got := readFiles(t, source.Dir); !cmp.Equal(got, files) {
+				t.Errorf("Fetcher.Fetch: (-got +want):\n%s", cmp.Diff(got, files))
+			}
+			if requested != "/fhir/v1.0.0.tar.gz" && requested != "/fhir/1.0.0.zip" {
+				t.Errorf("Fetcher.Fetch: requested %q, want the expanded template", requested)
+			}
+		})
+	}
+}
+
+func TestFetcherFetch_IntegrityMismatch_ReturnsIntegrityError(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "fhir.tar.gz")
+	if err := os.WriteFile(archive, tarGz(t, files), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	want := "sha256-" + string(bytes.Repeat([]byte("0"), 64))
+	project := &config.Project{
+		Name:     "google/fhir",
+		Source:   config.Source{Archive: "file://" + filepath.ToSlash(archive)},
+		Versions: []config.Version{{Version: "v1", Integrity: want}},
+	}
+	fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+
+	_, err := fetcher.Fetch(context.Background(), project, &config.Registry{Name: "public"}, &project.Versions[0], false)
+
+	var ierr *fetch.IntegrityError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("Fetcher.Fetch: got err %v, want IntegrityError", err)
+	}
+	if ierr.Want != want || ierr.Got != integrity {
+		t.Errorf("Fetcher.Fetch: got mismatch %s != %s", ierr.Want, ierr.Got)
+	}
+	if _, err := os.Stat(filepath.Join(fetcher.Dir, "public", "google", "fhir", "v1")); err == nil {
+		t.Errorf("Fetcher.Fetch: sources kept after integrity mismatch")
+	}
+}
+
+func TestFetcherFetch_AlreadyFetched_DoesNotFetch(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "fhir.tar.gz")
+	if err := os.WriteFile(archive, tarGz(t, files), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	project := &config.Project{
+		Name:     "google/fhir",
+		Source:   config.Source{Archive: "file://" + filepath.ToSlash(archive)},
+		Versions: []config.Version{{Version: "v1"}},
+	}
+	registry := &config.Registry{Name: "public"}
+	fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+	ctx := context.Background()
+	if _, err := fetcher.Fetch(ctx, project, registry, &project.Versions[0], false); err != nil {
+		t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+	}
+	os.Remove(archive)
+
+	source, err := fetcher.Fetch(ctx, project, registry, &project.Versions[0], false)
+	if err != nil {
+		t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+	}
+
+	if source.Fetched {
+		t.Errorf("Fetcher.Fetch: fetched sources that were already fetched")
+	}
+	if want := integrity; source.Integrity != want {
+		t.Errorf("Fetcher.Fetch: got integrity %q, want %q", source.Integrity, want)
+	}
+}
+
+func TestFetcherFetch_Refetch_FetchesAgain(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "fhir.tar.gz")
+	if err := os.WriteFile(archive, tarGz(t, files), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	project := &config.Project{
+		Name:     "google/fhir",
+		Source:   config.Source{Archive: "file://" + filepath.ToSlash(archive)},
+		Versions: []config.Version{{Version: "v1"}},
+	}
+	registry := &config.Registry{Name: "public"}
+	fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+	ctx := context.Background()
+	if _, err := fetcher.Fetch(ctx, project, registry, &project.Versions[0], false); err != nil {
+		t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+	}
+	moved := map[string]string{"fhir.proto": "syntax = \"proto3\";\n"}
+	if err := os.WriteFile(archive, tarGz(t, moved), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+
+	source, err := fetcher.Fetch(ctx, project, registry, &project.Versions[0], true)
+	if err != nil {
+		t.Fatalf("Fetcher.Fetch: unexpected error: %v", err)
+	}
+
+	if !source.Fetched {
+		t.Errorf("Fetcher.Fetch: reused sources when refetching")
+	}
+	if got := readFiles(t, source.Dir); !cmp.Equal(got, moved) {
+		t.Errorf("Fetcher.Fetch: (-got +want):\n%s", cmp.Diff(got, moved))
+	}
+}
+
+func TestFetcherFetch_EntryOutsideArchive_ReturnsError(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar.gz")
+	if err := os.WriteFile(archive, tarGz(t, map[string]string{"../evil.proto": ""}), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	project := &config.Project{
+		Name:     "evil/project",
+		Source:   config.Source{Archive: "file://" + filepath.ToSlash(archive)},
+		Versions: []config.Version{{Version: "v1"}},
+	}
+	fetcher := &fetch.Fetcher{Dir: t.TempDir()}
+
+	_, err := fetcher.Fetch(context.Background(), project, &config.Registry{Name: "public"}, &project.Versions[0], false)
+
+	if err == nil {
+		t.Errorf("Fetcher.Fetch: got nil error, want error")
+	}
+}
+
+func TestDigestTree_Files_MatchesSha256sum(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+
+	got, err := fetch.DigestTree(dir)
+	if err != nil {
+		t.Fatalf("DigestTree: unexpected error: %v", err)
+	}
+
+	if got != integrity {
+		t.Errorf("DigestTree: got %q, want %q", got, integrity)
+	}
+}
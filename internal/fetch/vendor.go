@@ -0,0 +1,217 @@
+package fetch
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+)
+
+// VendorManifestName is the name of the file in a vendor directory that lists
+// the vendored projects.
+const VendorManifestName = "protobuild-vendor.json"
+
+// Vendor is a directory that the sources of projects are vendored into, so
+// that they are available without fetching.
+type Vendor struct {
+	// Dir is the vendor directory.
+	Dir string
+
+	// Projects are the vendored projects, sorted by registry and name.
+	Projects []Vendored
+}
+
+// Vendored is a single vendored project.
+type Vendored struct {
+	// Registry is the name of the registry that defines the project.
+	Registry string `json:"registry"`
+
+	// Project is the name of the project.
+	Project string `json:"project"`
+
+	// Version is the vendored version of the project.
+	Version string `json:"version"`
+
+	// URL is the location that the sources were fetched from.
+	URL string `json:"url,omitempty"`
+
+	// Commit is the hash of the commit that the sources were fetched at, if
+	// they were fetched from a git repository.
+	Commit string `json:"commit,omitempty"`
+
+	// Integrity is the integrity digest of the vendored sources.
+	Integrity string `json:"integrity"`
+}
+
+// vendorManifest is the encoded form of the vendor manifest.
+type vendorManifest struct {
+	Projects []Vendored `json:"projects"`
+}
+
+// LoadVendor loads the vendor directory dir. A directory without a vendor
+// manifest has no vendored projects.
+func LoadVendor(dir string) (*Vendor, error) {
+	vendor := &Vendor{Dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, VendorManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return vendor, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest vendorManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, &config.Error{File: filepath.Join(dir, VendorManifestName), Err: err}
+	}
+	vendor.Projects = manifest.Projects
+	return vendor, nil
+}
+
+// Path returns the directory that the project of the registry is vendored
+// into.
+func (v *Vendor) Path(project *config.Project, registry *config.Registry) string {
+	return filepath.Join(v.Dir, registry.Name, filepath.FromSlash(project.Name))
+}
+
+// Lookup returns the vendored copy of the project of the registry, or nil if
+// it is not vendored.
+func (v *Vendor) Lookup(project *config.Project, registry *config.Registry) *Vendored {
+	for i := range v.Projects {
+		if v.Projects[i].Registry == registry.Name && v.Projects[i].Project == project.Name {
+			return &v.Projects[i]
+		}
+	}
+	return nil
+}
+
+// Source returns the vendored sources of the version of the project of the
+// registry, or nil if that version is not vendored. If the version has an
+// integrity digest, the vendored sources must match it.
+func (v *Vendor) Source(project *config.Project, registry *config.Registry, version *config.Version) *Source {
+	vendored := v.Lookup(project, registry)
+	if vendored == nil || vendored.Version != version.Version {
+		return nil
+	}
+	if version.Integrity != "" && version.Integrity != vendored.Integrity {
+		return nil
+	}
+	return &Source{
+		Project:   project,
+		Registry:  registry,
+		Version:   version,
+		Dir:       v.Path(project, registry),
+		URL:       vendored.URL,
+		Commit:    vendored.Commit,
+		Integrity: vendored.Integrity,
+	}
+}
+
+// Write replaces the vendored projects with copies of the sources, and
+// removes every previously vendored project that is not among them.
+func (v *Vendor) Write(sources []*Source) error {
+	if err := os.MkdirAll(v.Dir, 0o755); err != nil {
+		return err
+	}
+	var projects []Vendored
+	for _, source := range sources {
+		dst := v.Path(source.Project, source.Registry)
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := copyTree(source.Dir, dst); err != nil {
+			return err
+		}
+		projects = append(projects, Vendored{
+			Registry:  source.Registry.Name,
+			Project:   source.Project.Name,
+			Version:   source.Version.Version,
+			URL:       source.URL,
+			Commit:    source.Commit,
+			Integrity: source.Integrity,
+		})
+	}
+	slices.SortFunc(projects, func(lhs, rhs Vendored) int {
+		if c := strings.Compare(lhs.Registry, rhs.Registry); c != 0 {
+			return c
+		}
+		return strings.Compare(lhs.Project, rhs.Project)
+	})
+
+	for _, old := range v.Projects {
+		if slices.ContainsFunc(projects, func(vendored Vendored) bool {
+			return vendored.Registry == old.Registry && vendored.Project == old.Project
+		}) {
+			continue
+		}
+		stale := filepath.Join(v.Dir, old.Registry, filepath.FromSlash(old.Project))
+		if err := os.RemoveAll(stale); err != nil {
+			return err
+		}
+		removeEmptyParents(filepath.Dir(stale), v.Dir)
+	}
+
+	data, err := json.MarshalIndent(vendorManifest{Projects: projects}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(v.Dir, VendorManifestName), append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	v.Projects = projects
+	return nil
+}
+
+// removeEmptyParents removes dir and each of its parents up to, but not
+// including, root for as long as they are empty.
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// copyTree copies the regular files under src into dst.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
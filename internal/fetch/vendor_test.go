@@ -0,0 +1,123 @@
+package fetch_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestVendorWrite_Sources_CopiesAndRecords(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, files)
+	project := &config.Project{Name: "google/fhir"}
+	registry := &config.Registry{Name: "public"}
+	version := &config.Version{Version: "v1"}
+	vendor, err := fetch.LoadVendor(filepath.Join(t.TempDir(), "vendor"))
+	if err != nil {
+		t.Fatalf("LoadVendor: unexpected error: %v", err)
+	}
+	want := []fetch.Vendored{{Registry: "public", Project: "google/fhir", Version: "v1", Integrity: integrity}}
+
+	err = vendor.Write([]*fetch.Source{{
+		Project:   project,
+		Registry:  registry,
+		Version:   version,
+		Dir:       src,
+		Integrity: integrity,
+	}})
+	if err != nil {
+		t.Fatalf("Vendor.Write: unexpected error: %v", err)
+	}
+
+	loaded, err := fetch.LoadVendor(vendor.Dir)
+	if err != nil {
+		t.Fatalf("LoadVendor: unexpected error: %v", err)
+	}
+	if !cmp.Equal(loaded.Projects, want) {
+		t.Errorf("LoadVendor: (-got +want):\n%s", cmp.Diff(loaded.Projects, want))
+	}
+	if got := loaded.Lookup(project, registry); got == nil || got.Version != "v1" {
+		t.Errorf("Vendor.Lookup: got %v, want version v1", got)
+	}
+	if got := readFiles(t, loaded.Path(project, registry)); !cmp.Equal(got, files) {
+		t.Errorf("Vendor.Write: (-got +want):\n%s", cmp.Diff(got, files))
+	}
+}
+
+func TestVendorWrite_StaleProject_RemovesProject(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, files)
+	registry := &config.Registry{Name: "public"}
+	fhir := &fetch.Source{
+		Project:  &config.Project{Name: "google/fhir"},
+		Registry: registry,
+		Version:  &config.Version{Version: "v1"},
+		Dir:      src,
+	}
+	protobuf := &fetch.Source{
+		Project:  &config.Project{Name: "protocolbuffers/protobuf"},
+		Registry: registry,
+		Version:  &config.Version{Version: "v27.0"},
+		Dir:      src,
+	}
+	vendor, err := fetch.LoadVendor(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadVendor: unexpected error: %v", err)
+	}
+	if err := vendor.Write([]*fetch.Source{fhir, protobuf}); err != nil {
+		t.Fatalf("Vendor.Write: unexpected error: %v", err)
+	}
+
+	if err := vendor.Write([]*fetch.Source{fhir}); err != nil {
+		t.Fatalf("Vendor.Write: unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(vendor.Dir, "public", "protocolbuffers")); !os.IsNotExist(err) {
+		t.Errorf("Vendor.Write: stale project was not removed")
+	}
+	if got := vendor.Lookup(protobuf.Project, registry); got != nil {
+		t.Errorf("Vendor.Lookup: got %v for a removed project, want nil", got)
+	}
+}
+
+func TestVendorSource(t *testing.T) {
+	project := &config.Project{Name: "google/fhir"}
+	registry := &config.Registry{Name: "public"}
+	vendor := &fetch.Vendor{
+		Dir: "vendor",
+		Projects: []fetch.Vendored{{
+			Registry:  "public",
+			Project:   "google/fhir",
+			Version:   "v1",
+			Commit:    "0123456789abcdef",
+			Integrity: integrity,
+		}},
+	}
+	testCases := []struct {
+		name    string
+		version *config.Version
+		want    bool
+	}{
+		{name: "same version", version: &config.Version{Version: "v1"}, want: true},
+		{name: "same integrity", version: &config.Version{Version: "v1", Integrity: integrity}, want: true},
+		{name: "other version", version: &config.Version{Version: "v2"}, want: false},
+		{name: "other integrity", version: &config.Version{Version: "v1", Integrity: "sha256-00"}, want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := vendor.Source(project, registry, tc.version)
+
+			if got := source != nil; got != tc.want {
+				t.Fatalf("Vendor.Source: got vendored %v, want %v", got, tc.want)
+			}
+			if source != nil && (source.Commit != "0123456789abcdef" || source.Dir != vendor.Path(project, registry)) {
+				t.Errorf("Vendor.Source: got %+v, want the vendored commit and directory", source)
+			}
+		})
+	}
+}
@@ -0,0 +1,9 @@
+/*
+Package git manages the git repositories that protobuild retrieves registries
+and project sources from.
+
+Repositories are managed with the git executable, so that every URL and
+credential helper that git supports -- including "file://" URLs and local
+bare repositories -- works without further configuration.
+*/
+package git
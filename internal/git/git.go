@@ -0,0 +1,118 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Command is the name or path of the git executable.
+const Command = "git"
+
+// Error is an error from a git command, which includes everything that the
+// command wrote to its standard error.
+type Error struct {
+	// Args are the arguments that git was run with.
+	Args []string
+
+	// Stderr is the trimmed standard error of the command.
+	Stderr string
+
+	// Err is the error from running the command.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	message := fmt.Sprintf("git %s: %v", strings.Join(e.Args, " "), e.Err)
+	if e.Stderr != "" {
+		message += "\n" + e.Stderr
+	}
+	return message
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var _ error = (*Error)(nil)
+
+// Run runs git with the arguments in dir, and returns its trimmed standard
+// output. Git is never allowed to prompt for credentials, since it may be
+// run without a terminal.
+func Run(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, Command, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", &Error{Args: args, Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Clone clones the repository at url into dir, which must either not exist
+// or be empty.
+func Clone(ctx context.Context, url, dir string) error {
+	_, err := Run(ctx, "", "clone", "--quiet", "--", url, dir)
+	return err
+}
+
+// Update fetches the default branch of the repository at url into the clone
+// in dir, and resets the working tree to it. Any local changes to the clone
+// are discarded.
+func Update(ctx context.Context, dir, url string) error {
+	if _, err := Run(ctx, dir, "fetch", "--quiet", "--force", "--", url, "HEAD"); err != nil {
+		return err
+	}
+	return Reset(ctx, dir, "FETCH_HEAD")
+}
+
+// Reset resets the working tree of the clone in dir to the revision.
+func Reset(ctx context.Context, dir, rev string) error {
+	_, err := Run(ctx, dir, "reset", "--quiet", "--hard", rev)
+	return err
+}
+
+// Head returns the commit hash that the clone in dir is checked out at.
+func Head(ctx context.Context, dir string) (string, error) {
+	return Run(ctx, dir, "rev-parse", "HEAD")
+}
+
+// Snapshot writes the files of the repository at url, as of ref, into dir
+// without any git metadata, and returns the hash of the commit that was
+// written. The ref may be a branch, tag, or commit hash; only the history
+// needed for the ref is fetched.
+func Snapshot(ctx context.Context, url, ref, dir string) (string, error) {
+	if _, err := Run(ctx, "", "init", "--quiet", dir); err != nil {
+		return "", err
+	}
+	if _, err := Run(ctx, dir, "fetch", "--quiet", "--depth=1", "--", url, ref); err != nil {
+		return "", err
+	}
+	if _, err := Run(ctx, dir, "checkout", "--quiet", "FETCH_HEAD"); err != nil {
+		return "", err
+	}
+	commit, err := Head(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	return commit, os.RemoveAll(filepath.Join(dir, ".git"))
+}
+
+// Reachable checks that the repository at url can be read from, without
+// fetching any of its contents.
+func Reachable(ctx context.Context, url string) error {
+	_, err := Run(ctx, "", "ls-remote", "--quiet", "--", url, "HEAD")
+	return err
+}
@@ -0,0 +1,123 @@
+package git_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/git"
+)
+
+// newRepository creates a bare repository, and a clone of it that commits
+// can be pushed from.
+func newRepository(t *testing.T) (bare, work string) {
+	t.Helper()
+	if _, err := exec.LookPath(git.Command); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	bare, work = filepath.Join(root, "origin.git"), filepath.Join(root, "work")
+	run(t, root, "init", "--quiet", "--bare", bare)
+	run(t, root, "clone", "--quiet", bare, work)
+	return bare, work
+}
+
+// commit writes a file in the working clone, and pushes a commit of it.
+func commit(t *testing.T, work, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	run(t, work, "add", name)
+	run(t, work, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "update "+name)
+	run(t, work, "push", "--quiet", "origin", "HEAD")
+}
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git.Run(context.Background(), dir, args...)
+	if err != nil {
+		t.Fatalf("git %v: unexpected error: %v", args, err)
+	}
+	return out
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	return string(data)
+}
+
+func TestClone_FileURL_ClonesRepository(t *testing.T) {
+	bare, work := newRepository(t)
+	commit(t, work, "README.md", "hello")
+	dir := filepath.Join(t.TempDir(), "clone")
+
+	if err := git.Clone(context.Background(), "file://"+filepath.ToSlash(bare), dir); err != nil {
+		t.Fatalf("Clone: unexpected error: %v", err)
+	}
+
+	if got, want := readFile(t, filepath.Join(dir, "README.md")), "hello"; got != want {
+		t.Errorf("Clone: README.md = %q, want %q", got, want)
+	}
+}
+
+func TestUpdate_NewCommit_ResetsToLatest(t *testing.T) {
+	bare, work := newRepository(t)
+	commit(t, work, "README.md", "hello")
+	dir := filepath.Join(t.TempDir(), "clone")
+	ctx := context.Background()
+	if err := git.Clone(ctx, bare, dir); err != nil {
+		t.Fatalf("Clone: unexpected error: %v", err)
+	}
+	commit(t, work, "README.md", "goodbye")
+	want := run(t, work, "rev-parse", "HEAD")
+
+	if err := git.Update(ctx, dir, bare); err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	got, err := git.Head(ctx, dir)
+	if err != nil {
+		t.Fatalf("Head: unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("Head: got %q, want %q", got, want)
+	}
+	if got, want := readFile(t, filepath.Join(dir, "README.md")), "goodbye"; got != want {
+		t.Errorf("Update: README.md = %q, want %q", got, want)
+	}
+}
+
+func TestClone_MissingRepository_ReturnsError(t *testing.T) {
+	_, _ = newRepository(t)
+	root := t.TempDir()
+
+	err := git.Clone(context.Background(), filepath.Join(root, "missing.git"), filepath.Join(root, "clone"))
+
+	var gerr *git.Error
+	if !errors.As(err, &gerr) {
+		t.Fatalf("Clone: got err %v, want git.Error", err)
+	}
+	if gerr.Stderr == "" {
+		t.Errorf("Clone: error has no stderr")
+	}
+}
+
+func TestReachable_MissingRepository_ReturnsError(t *testing.T) {
+	bare, work := newRepository(t)
+	commit(t, work, "README.md", "hello")
+
+	if err := git.Reachable(context.Background(), bare); err != nil {
+		t.Errorf("Reachable: unexpected error for an existing repository: %v", err)
+	}
+	if err := git.Reachable(context.Background(), filepath.Join(t.TempDir(), "missing.git")); err == nil {
+		t.Errorf("Reachable: got nil error for a missing repository")
+	}
+}
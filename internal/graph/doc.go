@@ -0,0 +1,10 @@
+/*
+Package graph resolves the dependency graph between the targets of a
+workspace and the registry projects that they depend on.
+
+The graph yields a deterministic topological order of any subset of its
+nodes, so that dependencies are always processed before the targets that
+depend on them, and reports dependency cycles with the full path of the
+cycle.
+*/
+package graph
@@ -0,0 +1,264 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/semver"
+)
+
+// Kind is the kind of a node in the graph.
+type Kind int
+
+const (
+	// KindTarget is a target that is defined in the workspace.
+	KindTarget Kind = iota
+
+	// KindProject is a project that is defined in a registry.
+	KindProject
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindTarget:
+		return "target"
+	case KindProject:
+		return "project"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Node is a single target or registry project in the graph.
+type Node struct {
+	// Name is the name of the target or project.
+	Name string
+
+	// Kind is whether the node is a target or a project.
+	Kind Kind
+
+	// Target is the target of the node, if it is a target.
+	Target *config.Target
+
+	// Project is the project of the node, if it is a project.
+	Project *config.Project
+
+	// Registry is the registry that defines the project, if it is a project.
+	Registry *config.Registry
+
+	// Dependencies are the names of the direct dependencies of the node,
+	// sorted by name.
+	Dependencies []string
+
+	// Requirements are the constraints that the nodes depending on a project
+	// place on its version, sorted by the name of the dependent. Dependencies
+	// without a constraint are not included.
+	Requirements []Requirement
+}
+
+// Requirement is a constraint that a node places on the version of a project
+// it depends on.
+type Requirement struct {
+	// Dependent is the node that depends on the project.
+	Dependent *Node
+
+	// Constraint is the range of versions of the project that the dependent
+	// accepts.
+	Constraint semver.Constraint
+}
+
+// Graph is the dependency graph between targets and registry projects.
+type Graph struct {
+	nodes map[string]*Node
+}
+
+// New creates the graph of the targets, and every registry project in the
+// index that they transitively depend on. Targets take precedence over
+// projects of the same name. Every unknown dependency is reported.
+//
+// Projects are named as they are by the index, so that a project that is
+// depended on both with and without a registry qualification is a single
+// node.
+func New(targets []*config.Target, index *config.Index) (*Graph, error) {
+	g := &Graph{nodes: make(map[string]*Node)}
+	for _, target := range targets {
+		if _, ok := g.nodes[target.Name]; ok {
+			return nil, fmt.Errorf("duplicate target %q", target.Name)
+		}
+		g.nodes[target.Name] = &Node{Name: target.Name, Kind: KindTarget, Target: target}
+	}
+
+	var pending []*Node
+	var unknown []string
+	var errs []error
+	resolve := func(dependent *Node, dependencies []string) []string {
+		names := make([]string, 0, len(dependencies))
+		for _, text := range dependencies {
+			dependency, err := config.ParseDependency(text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", dependent.Kind, dependent.Name, err))
+				continue
+			}
+			name := dependency.Name
+			if node, ok := g.nodes[name]; ok && node.Kind == KindTarget {
+				names = append(names, name)
+				continue
+			}
+			project, registry := index.Lookup(name)
+			if project == nil {
+				if !slices.Contains(unknown, name) {
+					unknown = append(unknown, name)
+				}
+				continue
+			}
+			name = index.Name(project, registry)
+			node, ok := g.nodes[name]
+			if !ok {
+				node = &Node{Name: name, Kind: KindProject, Project: project, Registry: registry}
+				g.nodes[name] = node
+				pending = append(pending, node)
+			}
+			if !dependency.Constraint.IsAny() {
+				node.Requirements = append(node.Requirements, Requirement{Dependent: dependent, Constraint: dependency.Constraint})
+			}
+			names = append(names, name)
+		}
+		return sorted(names)
+	}
+	for _, target := range targets {
+		node := g.nodes[target.Name]
+		node.Dependencies = resolve(node, target.Dependencies)
+	}
+	for len(pending) > 0 {
+		node := pending[0]
+		pending = pending[1:]
+		node.Dependencies = resolve(node, node.Project.Dependencies)
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		errs = append(errs, fmt.Errorf("unknown dependencies %s", quoteAll(unknown)))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	for _, node := range g.nodes {
+		slices.SortFunc(node.Requirements, func(lhs, rhs Requirement) int {
+			return strings.Compare(lhs.Dependent.Name, rhs.Dependent.Name)
+		})
+	}
+	return g, nil
+}
+
+// Node returns the node with the given name, or nil if there is none.
+func (g *Graph) Node(name string) *Node {
+	return g.nodes[name]
+}
+
+// Names returns the names of every node in the graph, sorted by name.
+func (g *Graph) Names() []string {
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Order returns the named nodes and all of their transitive dependencies in
+// topological order, such that every node comes after all of its
+// dependencies. If no names are given, every node in the graph is ordered.
+//
+// The order is deterministic: dependencies are visited by name, so the result
+// does not depend on the order that they were declared in.
+func (g *Graph) Order(names ...string) ([]*Node, error) {
+	if len(names) == 0 {
+		names = g.Names()
+	}
+	s := &sorter{graph: g, state: make(map[string]visitState)}
+	for _, name := range names {
+		if g.nodes[name] == nil {
+			return nil, fmt.Errorf("unknown target %q", name)
+		}
+		if err := s.visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.order, nil
+}
+
+// Dependencies returns the transitive dependencies of the named node in
+// topological order, excluding the node itself.
+func (g *Graph) Dependencies(name string) ([]*Node, error) {
+	order, err := g.Order(name)
+	if err != nil {
+		return nil, err
+	}
+	return order[:len(order)-1], nil
+}
+
+// CycleError is the error returned when the graph contains a dependency
+// cycle.
+type CycleError struct {
+	// Path is the path of the cycle, which starts and ends with the same node.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle detected: " + strings.Join(e.Path, " -> ")
+}
+
+type visitState int
+
+const (
+	unvisited visitState = iota
+	visiting
+	visited
+)
+
+type sorter struct {
+	graph *Graph
+	state map[string]visitState
+	stack []string
+	order []*Node
+}
+
+func (s *sorter) visit(name string) error {
+	switch s.state[name] {
+	case visited:
+		return nil
+	case visiting:
+		start := slices.Index(s.stack, name)
+		path := append(slices.Clone(s.stack[start:]), name)
+		return &CycleError{Path: path}
+	}
+	s.state[name] = visiting
+	s.stack = append(s.stack, name)
+
+	node := s.graph.nodes[name]
+	for _, dependency := range node.Dependencies {
+		if err := s.visit(dependency); err != nil {
+			return err
+		}
+	}
+
+	s.stack = s.stack[:len(s.stack)-1]
+	s.state[name] = visited
+	s.order = append(s.order, node)
+	return nil
+}
+
+func sorted(names []string) []string {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,167 @@
+package graph_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newIndex() *config.Index {
+	return config.NewIndex(&config.Registry{
+		Name: "public",
+		Projects: []config.Project{
+			{Name: "protocolbuffers/protobuf"},
+			{Name: "google/fhir", Dependencies: []string{"protocolbuffers/protobuf"}},
+			{Name: "unused/project"},
+		},
+	})
+}
+
+func names(nodes []*graph.Node) []string {
+	var result []string
+	for _, node := range nodes {
+		result = append(result, node.Name)
+	}
+	return result
+}
+
+func TestGraphOrder_TargetsAndProjects_ReturnsDependenciesFirst(t *testing.T) {
+	targets := []*config.Target{
+		{Name: "my-project", Dependencies: []string{"google/fhir", "common", "protocolbuffers/protobuf"}},
+		{Name: "common", Dependencies: []string{"protocolbuffers/protobuf"}},
+		{Name: "other"},
+	}
+	g, err := graph.New(targets, newIndex())
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+	want := []string{"protocolbuffers/protobuf", "common", "google/fhir", "my-project"}
+
+	got, err := g.Order("my-project")
+	if err != nil {
+		t.Fatalf("Graph.Order: unexpected error: %v", err)
+	}
+
+	if got := names(got); !cmp.Equal(got, want) {
+		t.Errorf("Graph.Order: got %v, want %v", got, want)
+	}
+	if got := g.Node("google/fhir"); got == nil || got.Kind != graph.KindProject {
+		t.Errorf("Graph.Node: got %v, want project node", got)
+	}
+	if got := g.Node("unused/project"); got != nil {
+		t.Errorf("Graph.Node: got %v, want nil for a project that is not depended on", got)
+	}
+}
+
+func TestGraphOrder_NoNames_OrdersEveryNode(t *testing.T) {
+	targets := []*config.Target{
+		{Name: "b", Dependencies: []string{"a"}},
+		{Name: "a"},
+		{Name: "c", Dependencies: []string{"google/fhir"}},
+	}
+	g, err := graph.New(targets, newIndex())
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+	want := []string{"a", "b", "protocolbuffers/protobuf", "google/fhir", "c"}
+
+	got, err := g.Order()
+	if err != nil {
+		t.Fatalf("Graph.Order: unexpected error: %v", err)
+	}
+
+	if got := names(got); !cmp.Equal(got, want) {
+		t.Errorf("Graph.Order: got %v, want %v", got, want)
+	}
+}
+
+func TestGraphOrder_Cycle_ReturnsCycleError(t *testing.T) {
+	targets := []*config.Target{
+		{Name: "a", Dependencies: []string{"b"}},
+		{Name: "b", Dependencies: []string{"c"}},
+		{Name: "c", Dependencies: []string{"a"}},
+		{Name: "d", Dependencies: []string{"a"}},
+	}
+	g, err := graph.New(targets, newIndex())
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+	want := []string{"a", "b", "c", "a"}
+
+	_, err = g.Order("d")
+
+	var cycleErr *graph.CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Graph.Order: got error %v, want CycleError", err)
+	}
+	if got := cycleErr.Path; !cmp.Equal(got, want) {
+		t.Errorf("Graph.Order: cycle path = %v, want %v", got, want)
+	}
+}
+
+func TestNew_UnknownDependency_ReturnsError(t *testing.T) {
+	targets := []*config.Target{
+		{Name: "a", Dependencies: []string{"missing/project"}},
+	}
+
+	_, err := graph.New(targets, newIndex())
+
+	if err == nil {
+		t.Errorf("graph.New: got nil error, want unknown dependency error")
+	}
+}
+
+func TestGraphDependencies_Target_ExcludesTarget(t *testing.T) {
+	targets := []*config.Target{
+		{Name: "my-project", Dependencies: []string{"google/fhir"}},
+	}
+	g, err := graph.New(targets, newIndex())
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+	want := []string{"protocolbuffers/protobuf", "google/fhir"}
+
+	got, err := g.Dependencies("my-project")
+	if err != nil {
+		t.Fatalf("Graph.Dependencies: unexpected error: %v", err)
+	}
+
+	if got := names(got); !cmp.Equal(got, want) {
+		t.Errorf("Graph.Dependencies: got %v, want %v", got, want)
+	}
+}
+
+func TestNew_QualifiedDependencies_ResolvesOneNodePerProject(t *testing.T) {
+	public := &config.Registry{
+		Name:     "public",
+		Projects: []config.Project{{Name: "google/fhir"}},
+	}
+	internal := &config.Registry{
+		Name:     "internal",
+		Projects: []config.Project{{Name: "google/fhir"}},
+	}
+	index := config.NewIndex(internal, public)
+	targets := []*config.Target{
+		{Name: "a", Dependencies: []string{"google/fhir", "public/google/fhir"}},
+		{Name: "b", Dependencies: []string{"internal/google/fhir"}},
+	}
+	want := []string{"a", "b", "google/fhir", "public/google/fhir"}
+
+	g, err := graph.New(targets, index)
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+
+	if got := g.Names(); !cmp.Equal(got, want) {
+		t.Errorf("Graph.Names: got %v, want %v", got, want)
+	}
+	if got := g.Node("b").Dependencies; !cmp.Equal(got, []string{"google/fhir"}) {
+		t.Errorf("Graph.Node: got dependencies %v, want [google/fhir]", got)
+	}
+	if got := g.Node("public/google/fhir").Registry; got != public {
+		t.Errorf("Graph.Node: got registry %v, want public", got.Name)
+	}
+}
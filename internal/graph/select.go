@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/semver"
+)
+
+// Strategy is how the version of a project is selected among the versions
+// that satisfy every requirement on it.
+type Strategy int
+
+const (
+	// Highest selects the highest version that satisfies every requirement.
+	Highest Strategy = iota
+
+	// Minimal selects the lowest version that satisfies every requirement,
+	// so that projects are only upgraded when a dependent requires it.
+	Minimal
+)
+
+// ConflictError is the error returned when no version of a project satisfies
+// every requirement on it.
+type ConflictError struct {
+	// Project is the name of the project.
+	Project string
+
+	// Requirements are the requirements on the project.
+	Requirements []Requirement
+
+	// Versions are the names of the versions of the project.
+	Versions []string
+}
+
+// Error implements the error interface.
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "no version of project %s satisfies every requirement: ", e.Project)
+	for i, requirement := range e.Requirements {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s %s requires %s", requirement.Dependent.Kind, requirement.Dependent.Name, requirement.Constraint)
+	}
+	fmt.Fprintf(&b, " (available versions: %s)", strings.Join(e.Versions, ", "))
+	return b.String()
+}
+
+var _ error = (*ConflictError)(nil)
+
+// Accepts returns whether the version of the project of the node satisfies
+// every requirement on it. Versions that are not named by semantic versions
+// only satisfy nodes without requirements.
+func (n *Node) Accepts(version *config.Version) bool {
+	if len(n.Requirements) == 0 {
+		return true
+	}
+	v, err := semver.Parse(version.Version)
+	if err != nil {
+		return false
+	}
+	for _, requirement := range n.Requirements {
+		if !requirement.Constraint.Match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// SelectVersions selects the version of every project in the graph, keyed by
+// the name of its node. Projects without requirements use the newest version
+// that their registry lists; every other project uses the version chosen by
+// the strategy among those that satisfy all of its requirements.
+//
+// Every project whose requirements cannot be satisfied is reported as a
+// ConflictError.
+func (g *Graph) SelectVersions(strategy Strategy) (map[string]*config.Version, error) {
+	selected := make(map[string]*config.Version)
+	var errs []error
+	for _, name := range g.Names() {
+		node := g.nodes[name]
+		if node.Kind != KindProject {
+			continue
+		}
+		if len(node.Requirements) == 0 {
+			selected[name] = &node.Project.Versions[0]
+			continue
+		}
+
+		var best *config.Version
+		var bestVersion semver.Version
+		for i := range node.Project.Versions {
+			version := &node.Project.Versions[i]
+			if !node.Accepts(version) {
+				continue
+			}
+			v, _ := semver.Parse(version.Version)
+			c := v.Compare(bestVersion)
+			if best == nil || (strategy == Highest && c > 0) || (strategy == Minimal && c < 0) {
+				best, bestVersion = version, v
+			}
+		}
+		if best == nil {
+			versions := make([]string, len(node.Project.Versions))
+			for i, version := range node.Project.Versions {
+				versions[i] = version.Version
+			}
+			errs = append(errs, &ConflictError{Project: name, Requirements: node.Requirements, Versions: versions})
+			continue
+		}
+		selected[name] = best
+	}
+	return selected, errors.Join(errs...)
+}
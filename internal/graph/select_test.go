@@ -0,0 +1,104 @@
+package graph_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/graph"
+)
+
+func versionedIndex() *config.Index {
+	return config.NewIndex(&config.Registry{
+		Name: "public",
+		Projects: []config.Project{
+			{
+				Name: "googleapis/googleapis",
+				Versions: []config.Version{
+					{Version: "v2.1.0"}, {Version: "v1.4.0"}, {Version: "v1.2.0"}, {Version: "v1.0.0"}, {Version: "main"},
+				},
+			},
+			{
+				Name:         "google/fhir",
+				Versions:     []config.Version{{Version: "v1"}},
+				Dependencies: []string{"googleapis/googleapis >= 1.2"},
+			},
+		},
+	})
+}
+
+func TestGraphSelectVersions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		dependencies []string
+		strategy     graph.Strategy
+		want         string
+	}{
+		{name: "no constraint", dependencies: []string{"googleapis/googleapis"}, want: "v2.1.0"},
+		{name: "highest", dependencies: []string{"googleapis/googleapis >= 1.2, < 2"}, strategy: graph.Highest, want: "v1.4.0"},
+		{name: "minimal", dependencies: []string{"googleapis/googleapis >= 1.2, < 2"}, strategy: graph.Minimal, want: "v1.2.0"},
+		{name: "transitive", dependencies: []string{"google/fhir", "googleapis/googleapis < 1.3"}, want: "v1.2.0"},
+		{name: "caret", dependencies: []string{"googleapis/googleapis ^1.0"}, strategy: graph.Minimal, want: "v1.0.0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			targets := []*config.Target{{Name: "a", Dependencies: tc.dependencies}}
+			g, err := graph.New(targets, versionedIndex())
+			if err != nil {
+				t.Fatalf("graph.New: unexpected error: %v", err)
+			}
+
+			got, err := g.SelectVersions(tc.strategy)
+
+			if err != nil {
+				t.Fatalf("Graph.SelectVersions: unexpected error: %v", err)
+			}
+			if version := got["googleapis/googleapis"]; version == nil || version.Version != tc.want {
+				t.Errorf("Graph.SelectVersions: got %v, want %s", version, tc.want)
+			}
+		})
+	}
+}
+
+func TestGraphSelectVersions_IncompatibleRanges_ReturnsConflictError(t *testing.T) {
+	targets := []*config.Target{
+		{Name: "a", Dependencies: []string{"googleapis/googleapis >= 1.2, < 2"}},
+		{Name: "b", Dependencies: []string{"googleapis/googleapis >= 2"}},
+		{Name: "c", Dependencies: []string{"googleapis/googleapis"}},
+	}
+	g, err := graph.New(targets, versionedIndex())
+	if err != nil {
+		t.Fatalf("graph.New: unexpected error: %v", err)
+	}
+
+	_, err = g.SelectVersions(graph.Highest)
+
+	var conflict *graph.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Graph.SelectVersions: got err %v, want ConflictError", err)
+	}
+	var dependents []string
+	for _, requirement := range conflict.Requirements {
+		dependents = append(dependents, requirement.Dependent.Name)
+	}
+	if got, want := strings.Join(dependents, ","), "a,b"; got != want {
+		t.Errorf("Graph.SelectVersions: got dependents %s, want %s", got, want)
+	}
+	for _, want := range []string{"target a requires >= 1.2, < 2", "target b requires >= 2", "v2.1.0"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Graph.SelectVersions: error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestNew_InvalidConstraint_ReturnsError(t *testing.T) {
+	targets := []*config.Target{{Name: "a", Dependencies: []string{"googleapis/googleapis >= main"}}}
+
+	_, err := graph.New(targets, versionedIndex())
+
+	if err == nil {
+		t.Errorf("graph.New: got nil error, want error")
+	}
+}
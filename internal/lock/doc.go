@@ -0,0 +1,11 @@
+/*
+Package lock reads and writes the lockfile of a workspace, which records the
+exact sources that each registry project was resolved to.
+
+Registries name versions by refs that may move, such as tags and branches, so
+two resolutions of the same workspace may otherwise produce different
+sources. The lockfile pins every project to a commit or archive and the
+integrity digest of its sources, so that every resolution is identical until
+the lockfile is deliberately updated.
+*/
+package lock
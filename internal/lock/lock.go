@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+)
+
+// FileName is the name of the lockfile, which is stored in the workspace
+// directory.
+const FileName = "protobuild.lock"
+
+// formatVersion is the version of the lockfile format.
+const formatVersion = 1
+
+// ErrStale is returned when the lockfile does not match the projects that the
+// workspace depends on.
+var ErrStale = errors.New(FileName + " is out of date")
+
+// File is the lockfile of a workspace.
+type File struct {
+	// Projects are the locked projects, sorted by registry and name.
+	Projects []Project
+
+	// Path is the path of the lockfile.
+	Path string
+}
+
+// Project is the exact sources that a registry project was resolved to.
+type Project struct {
+	// Name is the name of the project.
+	Name string `json:"name"`
+
+	// Registry is the name of the registry that defines the project.
+	Registry string `json:"registry"`
+
+	// Version is the version of the project that was selected.
+	Version string `json:"version"`
+
+	// Commit is the hash of the commit that the version resolved to, if the
+	// project is retrieved from a git repository.
+	Commit string `json:"commit,omitempty"`
+
+	// Archive is the URL of the archive that the version resolved to, if the
+	// project is retrieved from an archive.
+	Archive string `json:"archive,omitempty"`
+
+	// Integrity is the integrity digest of the sources.
+	Integrity string `json:"integrity"`
+}
+
+// file is the encoded form of the lockfile.
+type file struct {
+	Version  int       `json:"version"`
+	Projects []Project `json:"projects"`
+}
+
+// Path returns the path of the lockfile of the workspace.
+func Path(workspace *config.Workspace) string {
+	return filepath.Join(workspace.Dir(), FileName)
+}
+
+// Load reads the lockfile at path. A missing lockfile has no projects.
+func Load(path string) (*File, error) {
+	lockfile := &File{Path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lockfile, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var decoded file
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, &config.Error{File: path, Err: fmt.Errorf("%w: %w", config.ErrSyntax, err)}
+	}
+	if decoded.Version != formatVersion {
+		return nil, &config.Error{
+			File:  path,
+			Field: "version",
+			Err:   fmt.Errorf("unsupported lockfile version %d; must be %d", decoded.Version, formatVersion),
+		}
+	}
+	lockfile.Projects = decoded.Projects
+	return lockfile, nil
+}
+
+// Lookup returns the locked project with the given name from the registry,
+// or nil if it is not locked.
+func (f *File) Lookup(registry, name string) *Project {
+	for i := range f.Projects {
+		if f.Projects[i].Registry == registry && f.Projects[i].Name == name {
+			return &f.Projects[i]
+		}
+	}
+	return nil
+}
+
+// Set replaces the locked projects, and returns whether they changed.
+func (f *File) Set(projects []Project) bool {
+	projects = slices.Clone(projects)
+	slices.SortFunc(projects, func(lhs, rhs Project) int {
+		if c := strings.Compare(lhs.Registry, rhs.Registry); c != 0 {
+			return c
+		}
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	if slices.Equal(projects, f.Projects) {
+		return false
+	}
+	f.Projects = projects
+	return true
+}
+
+// Encode returns the contents of the lockfile. The encoding is deterministic,
+// so that lockfiles of the same projects are identical.
+func (f *File) Encode() ([]byte, error) {
+	projects := f.Projects
+	if projects == nil {
+		projects = []Project{}
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(file{Version: formatVersion, Projects: projects}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Save writes the lockfile to its path.
+func (f *File) Save() error {
+	data, err := f.Encode()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.Path, data, 0o644)
+}
@@ -0,0 +1,121 @@
+package lock_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/lock"
+	"github.com/google/go-cmp/cmp"
+)
+
+const integrity = "sha256-e78f5e501bdab059c18c0bc6b1c5a06a9fb08e40fb4b586d90425db1e0f61bdf"
+
+func TestLoad_MissingFile_ReturnsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lock.FileName)
+
+	lockfile, err := lock.Load(path)
+
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if len(lockfile.Projects) != 0 || lockfile.Path != path {
+		t.Errorf("Load: got %+v, want an empty lockfile at %s", lockfile, path)
+	}
+}
+
+func TestLoad_UnsupportedVersion_ReturnsError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lock.FileName)
+	if err := os.WriteFile(path, []byte(`{"version": 2, "projects": []}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+
+	_, err := lock.Load(path)
+
+	if err == nil {
+		t.Errorf("Load: got nil error, want error")
+	}
+}
+
+func TestFileSave_Projects_RoundTripsSorted(t *testing.T) {
+	fhir := lock.Project{
+		Name:      "google/fhir",
+		Registry:  "public",
+		Version:   "v1",
+		Commit:    "0123456789abcdef0123456789abcdef01234567",
+		Integrity: integrity,
+	}
+	protobuf := lock.Project{
+		Name:      "protocolbuffers/protobuf",
+		Registry:  "internal",
+		Version:   "v27.0",
+		Archive:   "https://example.com/protobuf-27.0.tar.gz",
+		Integrity: integrity,
+	}
+	lockfile := &lock.File{Path: filepath.Join(t.TempDir(), lock.FileName)}
+	lockfile.Set([]lock.Project{fhir, protobuf})
+
+	if err := lockfile.Save(); err != nil {
+		t.Fatalf("File.Save: unexpected error: %v", err)
+	}
+
+	loaded, err := lock.Load(lockfile.Path)
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if want := []lock.Project{protobuf, fhir}; !cmp.Equal(loaded.Projects, want) {
+		t.Errorf("Load: (-got +want):\n%s", cmp.Diff(loaded.Projects, want))
+	}
+	if got := loaded.Lookup("public", "google/fhir"); got == nil || *got != fhir {
+		t.Errorf("File.Lookup: got %v, want %v", got, fhir)
+	}
+	if got := loaded.Lookup("internal", "google/fhir"); got != nil {
+		t.Errorf("File.Lookup: got %v, want nil", got)
+	}
+}
+
+func TestFileEncode_SameProjects_IsDeterministic(t *testing.T) {
+	projects := []lock.Project{
+		{Name: "b/b", Registry: "public", Version: "v1", Integrity: integrity},
+		{Name: "a/a", Registry: "public", Version: "v1", Integrity: integrity},
+	}
+	lhs, rhs := &lock.File{}, &lock.File{}
+	lhs.Set(projects)
+	rhs.Set([]lock.Project{projects[1], projects[0]})
+
+	got, err := lhs.Encode()
+	if err != nil {
+		t.Fatalf("File.Encode: unexpected error: %v", err)
+	}
+	want, err := rhs.Encode()
+	if err != nil {
+		t.Fatalf("File.Encode: unexpected error: %v", err)
+	}
+
+	if string(got) != string(want) {
+		t.Errorf("File.Encode: (-got +want):\n%s", cmp.Diff(string(got), string(want)))
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	fhir := lock.Project{Name: "google/fhir", Registry: "public", Version: "v1", Integrity: integrity}
+	testCases := []struct {
+		name     string
+		projects []lock.Project
+		want     bool
+	}{
+		{name: "same projects", projects: []lock.Project{fhir}, want: false},
+		{name: "new version", projects: []lock.Project{{Name: "google/fhir", Registry: "public", Version: "v2", Integrity: integrity}}, want: true},
+		{name: "removed project", projects: nil, want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lockfile := &lock.File{Projects: []lock.Project{fhir}}
+
+			if got := lockfile.Set(tc.projects); got != tc.want {
+				t.Errorf("File.Set: got changed %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+/*
+Package plugin installs protoc plugins into the protobuild bin path from the
+recipes that registries define for them.
+
+A recipe installs a version of a plugin with "go install", by downloading a
+prebuilt executable for the current platform and verifying its checksum, or by
+running a build command from the registry. Each installed plugin is placed in
+its own versioned directory, and recorded in a manifest in the bin path so
+that generation can run the installed executable instead of searching the
+PATH for one.
+*/
+package plugin
@@ -0,0 +1,393 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+)
+
+// ManifestName is the name of the file in the bin path that lists the
+// installed plugins.
+const ManifestName = "plugins.json"
+
+// pluginsDir is the directory of the bin path that plugins are installed
+// into, as "<name>/<version>/protoc-gen-<name>".
+const pluginsDir = "plugins"
+
+// GoCommand is the name or path of the go executable that plugins with Go
+// recipes are installed with.
+const GoCommand = "go"
+
+// Installed is a plugin that has been installed.
+type Installed struct {
+	// Name is the name of the plugin.
+	Name string `json:"name"`
+
+	// Version is the installed version of the plugin.
+	Version string `json:"version"`
+
+	// Registry is the name of the registry whose recipe installed the
+	// plugin.
+	Registry string `json:"registry"`
+
+	// Path is the slash-separated path of the executable, relative to the
+	// bin path.
+	Path string `json:"path"`
+}
+
+// manifest is the encoded form of the manifest of installed plugins.
+type manifest struct {
+	Plugins []Installed `json:"plugins"`
+}
+
+// CommandError is the error returned when a command that installs a plugin
+// fails, which includes everything that the command wrote.
+type CommandError struct {
+	// Args are the program and arguments of the command.
+	Args []string
+
+	// Output is the trimmed combined output of the command.
+	Output string
+
+	// Err is the error from running the command.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *CommandError) Error() string {
+	message := fmt.Sprintf("%s: %v", strings.Join(e.Args, " "), e.Err)
+	if e.Output != "" {
+		message += "\n" + e.Output
+	}
+	return message
+}
+
+// Unwrap returns the underlying error.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+var _ error = (*CommandError)(nil)
+
+// Manager installs plugins into a bin path.
+type Manager struct {
+	// Dir is the bin path.
+	Dir string
+
+	// Fetcher downloads the prebuilt executables of plugins.
+	Fetcher *fetch.Fetcher
+
+	// Platform is the "<os>/<arch>" platform that prebuilt executables are
+	// installed for. Defaults to the platform that protobuild runs on.
+	Platform string
+
+	// Installed are the installed plugins, sorted by name.
+	Installed []Installed
+}
+
+// Load loads the plugins installed in the bin path dir. A bin path without a
+// manifest has no installed plugins.
+func Load(dir string) (*Manager, error) {
+	m := &Manager{Dir: dir, Fetcher: &fetch.Fetcher{}}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var decoded manifest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, &config.Error{File: filepath.Join(dir, ManifestName), Err: err}
+	}
+	m.Installed = decoded.Plugins
+	return m, nil
+}
+
+// Lookup returns the installed plugin with the given name, or nil if it is
+// not installed.
+func (m *Manager) Lookup(name string) *Installed {
+	for i := range m.Installed {
+		if m.Installed[i].Name == name {
+			return &m.Installed[i]
+		}
+	}
+	return nil
+}
+
+// Executable returns the path of the executable of the installed plugin.
+func (m *Manager) Executable(installed *Installed) string {
+	return filepath.Join(m.Dir, filepath.FromSlash(installed.Path))
+}
+
+// Executables returns the paths of the executables of every installed
+// plugin, keyed by the name of the plugin.
+func (m *Manager) Executables() map[string]string {
+	executables := make(map[string]string, len(m.Installed))
+	for i := range m.Installed {
+		executables[m.Installed[i].Name] = m.Executable(&m.Installed[i])
+	}
+	return executables
+}
+
+func (m *Manager) platform() string {
+	if m.Platform == "" {
+		return runtime.GOOS + "/" + runtime.GOARCH
+	}
+	return m.Platform
+}
+
+// Install installs the version of the plugin with its recipe from the
+// registry, replacing any other installed version of the plugin.
+func (m *Manager) Install(ctx context.Context, plugin *config.Plugin, version *config.PluginVersion, registry *config.Registry) (*Installed, error) {
+	root := filepath.Join(m.Dir, pluginsDir)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	// Plugins are installed next to their final location, and only moved
+	// into place once they have been built, so that a failed installation
+	// never replaces a working one.
+	tmp, err := os.MkdirTemp(root, ".install-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	executable := plugin.Executable()
+	if strings.HasPrefix(m.platform(), "windows/") {
+		executable += ".exe"
+	}
+	staged := filepath.Join(tmp, "bin")
+	if err := os.Mkdir(staged, 0o755); err != nil {
+		return nil, err
+	}
+	built := filepath.Join(staged, executable)
+	switch {
+	case version.Go != "":
+		err = m.installGo(ctx, version.Go+"@"+version.Version, tmp, built)
+	case len(version.Archives) > 0:
+		err = m.installArchive(ctx, plugin, version, registry, tmp, built)
+	case version.Build != nil:
+		err = m.build(ctx, version, registry, built)
+	default:
+		err = errors.New("no recipe")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("installing plugin %s %s: %w", plugin.Name, version.Version, err)
+	}
+	if err := os.Chmod(built, 0o755); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(root, plugin.Name, url.PathEscape(version.Version))
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staged, dir); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Rel(m.Dir, filepath.Join(dir, executable))
+	if err != nil {
+		return nil, err
+	}
+	installed := Installed{
+		Name:     plugin.Name,
+		Version:  version.Version,
+		Registry: registry.Name,
+		Path:     filepath.ToSlash(path),
+	}
+	if previous := m.Lookup(plugin.Name); previous != nil && previous.Version != installed.Version {
+		if err := os.RemoveAll(filepath.Dir(m.Executable(previous))); err != nil {
+			return nil, err
+		}
+	}
+	m.Installed = slices.DeleteFunc(m.Installed, func(other Installed) bool {
+		return other.Name == plugin.Name
+	})
+	m.Installed = append(m.Installed, installed)
+	slices.SortFunc(m.Installed, func(lhs, rhs Installed) int {
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	return m.Lookup(plugin.Name), nil
+}
+
+// Remove removes the installed plugin with the given name.
+func (m *Manager) Remove(name string) error {
+	installed := m.Lookup(name)
+	if installed == nil {
+		return fmt.Errorf("plugin %q is not installed", name)
+	}
+	if err := os.RemoveAll(filepath.Join(m.Dir, pluginsDir, name)); err != nil {
+		return err
+	}
+	m.Installed = slices.DeleteFunc(m.Installed, func(other Installed) bool {
+		return other.Name == name
+	})
+	return m.save()
+}
+
+// save writes the manifest of installed plugins.
+func (m *Manager) save() error {
+	installed := m.Installed
+	if installed == nil {
+		installed = []Installed{}
+	}
+	data, err := json.MarshalIndent(manifest{Plugins: installed}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.Dir, ManifestName), append(data, '\n'), 0o644)
+}
+
+// installGo installs the Go package at the version, given as
+// "<package>@<version>", into built.
+func (m *Manager) installGo(ctx context.Context, pkg, tmp, built string) error {
+	gobin := filepath.Join(tmp, "gobin")
+	if err := os.Mkdir(gobin, 0o755); err != nil {
+		return err
+	}
+	err := run(ctx, "", []string{"GOBIN=" + gobin}, GoCommand, "install", pkg)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(gobin)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		return fmt.Errorf("go install %s installed %d executables; want 1", pkg, len(entries))
+	}
+	return os.Rename(filepath.Join(gobin, entries[0].Name()), built)
+}
+
+// installArchive downloads the prebuilt executable of the plugin for the
+// platform of the manager into built, verifying its checksum.
+func (m *Manager) installArchive(ctx context.Context, plugin *config.Plugin, version *config.PluginVersion, registry *config.Registry, tmp, built string) error {
+	archive, ok := version.Archives[m.platform()]
+	if !ok {
+		platforms := make([]string, 0, len(version.Archives))
+		for platform := range version.Archives {
+			platforms = append(platforms, platform)
+		}
+		slices.Sort(platforms)
+		return fmt.Errorf("no prebuilt executable for %s; available for %s", m.platform(), strings.Join(platforms, ", "))
+	}
+	rawURL := strings.ReplaceAll(archive.URL, "{version}", version.Version)
+	body, err := m.Fetcher.Open(ctx, rawURL, registry)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	download, err := os.Create(filepath.Join(tmp, "download"))
+	if err != nil {
+		return err
+	}
+	defer download.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(download, hash), body); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != archive.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s: recipe has %s, but the download has %s", rawURL, archive.SHA256, got)
+	}
+	if archive.Path == "" {
+		if err := download.Close(); err != nil {
+			return err
+		}
+		return os.Rename(download.Name(), built)
+	}
+
+	path := filepath.FromSlash(archive.Path)
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf("path %q is outside of the archive", archive.Path)
+	}
+	extracted := filepath.Join(tmp, "extracted")
+	if err := fetch.Extract(download, rawURL, extracted); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(extracted, path), built); err != nil {
+		return fmt.Errorf("archive %s has no executable %q", rawURL, archive.Path)
+	}
+	return nil
+}
+
+// build runs the build command of the version, and copies the executable
+// that it builds into built.
+func (m *Manager) build(ctx context.Context, version *config.PluginVersion, registry *config.Registry, built string) error {
+	for _, path := range []string{version.Build.Dir, version.Build.Output} {
+		if path != "" && !filepath.IsLocal(filepath.FromSlash(path)) {
+			return fmt.Errorf("build path %q is outside of the registry", path)
+		}
+	}
+	dir := filepath.Join(registry.Dir(), filepath.FromSlash(version.Build.Dir))
+	env := []string{"PROTOBUILD_PLUGIN_VERSION=" + version.Version}
+	if err := run(ctx, dir, env, version.Build.Command[0], version.Build.Command[1:]...); err != nil {
+		return err
+	}
+	return copyFile(filepath.Join(dir, filepath.FromSlash(version.Build.Output)), built)
+}
+
+// run runs the program with the arguments in dir, with env added to the
+// environment of protobuild.
+func run(ctx context.Context, dir string, env []string, program string, args ...string) error {
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, program, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &CommandError{
+			Args:   append([]string{program}, args...),
+			Output: strings.TrimSpace(output.String()),
+			Err:    err,
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,279 @@
+package plugin_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/plugin"
+	"github.com/google/go-cmp/cmp"
+)
+
+// executable is the content of every plugin executable that is installed.
+const executable = "#!/bin/sh\n"
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serve serves the files, keyed by path, and returns the URL of the server.
+func serve(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// tarball returns a gzipped tar archive containing the files, keyed by path.
+func tarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader: unexpected error: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Write: unexpected error: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newManager(t *testing.T) *plugin.Manager {
+	t.Helper()
+	manager, err := plugin.Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	manager.Platform = "linux/amd64"
+	return manager
+}
+
+func newRegistry(t *testing.T) *config.Registry {
+	t.Helper()
+	dir := t.TempDir()
+	return &config.Registry{Name: "public", Path: filepath.Join(dir, "protobuild-registry.json")}
+}
+
+func readExecutable(t *testing.T, manager *plugin.Manager, installed *plugin.Installed) string {
+	t.Helper()
+	path := manager.Executable(installed)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: unexpected error: %v", err)
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("Manager.Install: %s is not executable", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	return string(data)
+}
+
+func TestManagerInstall_Archive_InstallsExecutable(t *testing.T) {
+	archive := tarball(t, map[string]string{
+		"protoc-gen-go-v1.2.0/bin/protoc-gen-go": executable,
+		"protoc-gen-go-v1.2.0/LICENSE":           "license",
+	})
+	url := serve(t, map[string][]byte{"/v1.2.0/linux-amd64.tar.gz": archive})
+	manager := newManager(t)
+	recipe := &config.Plugin{
+		Name: "go",
+		Versions: []config.PluginVersion{{
+			Version: "v1.2.0",
+			Archives: map[string]config.PluginArchive{
+				"linux/amd64": {URL: url + "/{version}/linux-amd64.tar.gz", SHA256: digest(archive), Path: "bin/protoc-gen-go"},
+			},
+		}},
+	}
+
+	installed, err := manager.Install(context.Background(), recipe, &recipe.Versions[0], newRegistry(t))
+	if err != nil {
+		t.Fatalf("Manager.Install: unexpected error: %v", err)
+	}
+
+	want := &plugin.Installed{Name: "go", Version: "v1.2.0", Registry: "public", Path: "plugins/go/v1.2.0/protoc-gen-go"}
+	if diff := cmp.Diff(want, installed); diff != "" {
+		t.Errorf("Manager.Install: mismatch (-want +got):\n%s", diff)
+	}
+	if got := readExecutable(t, manager, installed); got != executable {
+		t.Errorf("Manager.Install: executable = %q, want %q", got, executable)
+	}
+}
+
+func TestManagerInstall_ChecksumMismatch_ReturnsError(t *testing.T) {
+	url := serve(t, map[string][]byte{"/protoc-gen-go": []byte(executable)})
+	manager := newManager(t)
+	recipe := &config.Plugin{
+		Name: "go",
+		Versions: []config.PluginVersion{{
+			Version: "v1.2.0",
+			Archives: map[string]config.PluginArchive{
+				"linux/amd64": {URL: url + "/protoc-gen-go", SHA256: digest([]byte("other"))},
+			},
+		}},
+	}
+
+	_, err := manager.Install(context.Background(), recipe, &recipe.Versions[0], newRegistry(t))
+
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Manager.Install: got error %v, want checksum mismatch", err)
+	}
+	if manager.Lookup("go") != nil {
+		t.Errorf("Manager.Install: plugin is installed after a failed installation")
+	}
+}
+
+func TestManagerInstall_UnsupportedPlatform_ReturnsError(t *testing.T) {
+	manager := newManager(t)
+	recipe := &config.Plugin{
+		Name: "go",
+		Versions: []config.PluginVersion{{
+			Version: "v1.2.0",
+			Archives: map[string]config.PluginArchive{
+				"darwin/arm64": {URL: "https://example.com/protoc-gen-go", SHA256: digest(nil)},
+			},
+		}},
+	}
+
+	_, err := manager.Install(context.Background(), recipe, &recipe.Versions[0], newRegistry(t))
+
+	if err == nil || !strings.Contains(err.Error(), "available for darwin/arm64") {
+		t.Errorf("Manager.Install: got error %v, want no prebuilt executable", err)
+	}
+}
+
+func TestManagerInstall_Build_InstallsOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available to build with")
+	}
+	manager := newManager(t)
+	registry := newRegistry(t)
+	recipe := &config.Plugin{
+		Name: "echo",
+		Versions: []config.PluginVersion{{
+			Version: "v2",
+			Build: &config.PluginBuild{
+				Command: []string{"sh", "-c", `mkdir -p out && printf '#!/bin/sh\n# %s\n' "$PROTOBUILD_PLUGIN_VERSION" > out/plugin`},
+				Dir:     "tools",
+				Output:  "out/plugin",
+			},
+		}},
+	}
+	if err := os.Mkdir(filepath.Join(registry.Dir(), "tools"), 0o755); err != nil {
+		t.Fatalf("Mkdir: unexpected error: %v", err)
+	}
+
+	installed, err := manager.Install(context.Background(), recipe, &recipe.Versions[0], registry)
+	if err != nil {
+		t.Fatalf("Manager.Install: unexpected error: %v", err)
+	}
+
+	if got, want := readExecutable(t, manager, installed), "#!/bin/sh\n# v2\n"; got != want {
+		t.Errorf("Manager.Install: executable = %q, want %q", got, want)
+	}
+}
+
+func TestManagerInstall_NewVersion_ReplacesVersion(t *testing.T) {
+	url := serve(t, map[string][]byte{"/protoc-gen-go": []byte(executable)})
+	manager := newManager(t)
+	archives := map[string]config.PluginArchive{
+		"linux/amd64": {URL: url + "/protoc-gen-go", SHA256: digest([]byte(executable))},
+	}
+	recipe := &config.Plugin{
+		Name: "go",
+		Versions: []config.PluginVersion{
+			{Version: "v2", Archives: archives},
+			{Version: "v1", Archives: archives},
+		},
+	}
+	old, err := manager.Install(context.Background(), recipe, &recipe.Versions[1], newRegistry(t))
+	if err != nil {
+		t.Fatalf("Manager.Install: unexpected error: %v", err)
+	}
+	oldPath := manager.Executable(old)
+
+	if _, err := manager.Install(context.Background(), recipe, &recipe.Versions[0], newRegistry(t)); err != nil {
+		t.Fatalf("Manager.Install: unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("Manager.Install: previous version %s still exists", oldPath)
+	}
+	reloaded, err := plugin.Load(manager.Dir)
+	if err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	want := []plugin.Installed{{Name: "go", Version: "v2", Registry: "public", Path: "plugins/go/v2/protoc-gen-go"}}
+	if diff := cmp.Diff(want, reloaded.Installed); diff != "" {
+		t.Errorf("Load: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestManagerRemove_Installed_RemovesPlugin(t *testing.T) {
+	url := serve(t, map[string][]byte{"/protoc-gen-go": []byte(executable)})
+	manager := newManager(t)
+	recipe := &config.Plugin{
+		Name: "go",
+		Versions: []config.PluginVersion{{
+			Version: "v1",
+			Archives: map[string]config.PluginArchive{
+				"linux/amd64": {URL: url + "/protoc-gen-go", SHA256: digest([]byte(executable))},
+			},
+		}},
+	}
+	installed, err := manager.Install(context.Background(), recipe, &recipe.Versions[0], newRegistry(t))
+	if err != nil {
+		t.Fatalf("Manager.Install: unexpected error: %v", err)
+	}
+	path := manager.Executable(installed)
+
+	if err := manager.Remove("go"); err != nil {
+		t.Fatalf("Manager.Remove: unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Manager.Remove: executable %s still exists", path)
+	}
+	if diff := cmp.Diff(map[string]string{}, manager.Executables()); diff != "" {
+		t.Errorf("Manager.Executables: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestManagerRemove_NotInstalled_ReturnsError(t *testing.T) {
+	manager := newManager(t)
+
+	if err := manager.Remove("go"); err == nil {
+		t.Errorf("Manager.Remove: got nil error, want error for a plugin that is not installed")
+	}
+}
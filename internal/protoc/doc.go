@@ -0,0 +1,11 @@
+/*
+Package protoc installs the version of protoc that a workspace pins.
+
+Release archives of protoc are downloaded from a URL template, or taken from
+a local mirror directory, and verified against the checksum that the
+workspace pins for the current platform. Each version is unpacked into its own
+directory of the bin path, along with the well-known types that are included
+in the release, so that every machine generates with exactly the same
+compiler.
+*/
+package protoc
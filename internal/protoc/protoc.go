@@ -0,0 +1,253 @@
+package protoc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+)
+
+// toolchainsDir is the directory of the bin path that versions of protoc are
+// installed into, as "<version>/bin/protoc".
+const toolchainsDir = "protoc"
+
+// checksumName is the name of the file in the directory of an installed
+// version that records the checksum of the archive it was installed from.
+const checksumName = ".sha256"
+
+// releasePlatforms are the names that protoc releases give to the platforms
+// that they are built for, keyed by the "<os>/<arch>" platform.
+var releasePlatforms = map[string]string{
+	"darwin/amd64":  "osx-x86_64",
+	"darwin/arm64":  "osx-aarch_64",
+	"linux/386":     "linux-x86_32",
+	"linux/amd64":   "linux-x86_64",
+	"linux/arm64":   "linux-aarch_64",
+	"linux/ppc64le": "linux-ppcle_64",
+	"linux/s390x":   "linux-s390_64",
+	"windows/386":   "win32",
+	"windows/amd64": "win64",
+}
+
+// ReleasePlatform returns the name that protoc releases give to the
+// "<os>/<arch>" platform, such as "linux-x86_64" for "linux/amd64".
+func ReleasePlatform(platform string) (string, bool) {
+	name, ok := releasePlatforms[platform]
+	return name, ok
+}
+
+// Toolchain is an installed version of protoc.
+type Toolchain struct {
+	// Version is the version of protoc.
+	Version string
+
+	// Dir is the directory that the release archive was unpacked into.
+	Dir string
+}
+
+// Executable returns the path of the protoc executable.
+func (t *Toolchain) Executable() string {
+	name := "protoc"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return filepath.Join(t.Dir, "bin", name)
+}
+
+// Include returns the directory containing the well-known types, such as
+// google/protobuf/timestamp.proto.
+func (t *Toolchain) Include() string {
+	return filepath.Join(t.Dir, "include")
+}
+
+// DefaultIncludes returns the directories of well-known types that the
+// protoc executable searches implicitly, which is where releases and most
+// distributions install them: the include directory next to the executable,
+// or next to the directory containing it. The executable may be a name that
+// is searched for in the PATH. Directories that do not contain the
+// well-known types are not returned.
+func DefaultIncludes(executable string) []string {
+	path, err := exec.LookPath(executable)
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir := filepath.Dir(path)
+	var includes []string
+	for _, include := range []string{filepath.Join(dir, "include"), filepath.Join(filepath.Dir(dir), "include")} {
+		descriptor := filepath.Join(include, "google", "protobuf", "descriptor.proto")
+		if _, err := os.Stat(descriptor); err == nil {
+			includes = append(includes, include)
+		}
+	}
+	return includes
+}
+
+// Installer installs pinned versions of protoc into a bin path.
+type Installer struct {
+	// Dir is the bin path.
+	Dir string
+
+	// Fetcher downloads release archives.
+	Fetcher *fetch.Fetcher
+
+	// Mirror is a directory that release archives are taken from, by the
+	// name of the file in their URL, instead of being downloaded. If empty,
+	// archives are downloaded.
+	Mirror string
+
+	// Platform is the "<os>/<arch>" platform that protoc is installed for.
+	// Defaults to the platform that protobuild runs on.
+	Platform string
+}
+
+func (i *Installer) platform() string {
+	if i.Platform == "" {
+		return runtime.GOOS + "/" + runtime.GOARCH
+	}
+	return i.Platform
+}
+
+// Toolchain returns the toolchain of the version, whether or not it has been
+// installed.
+func (i *Installer) Toolchain(version string) *Toolchain {
+	return &Toolchain{
+		Version: version,
+		Dir:     filepath.Join(i.Dir, toolchainsDir, url.PathEscape(version)),
+	}
+}
+
+// Installed returns the toolchain of the pinned version if it has been
+// installed from an archive with the pinned checksum, or nil if it has not.
+func (i *Installer) Installed(pin *config.Protoc) *Toolchain {
+	toolchain := i.Toolchain(pin.Version)
+	checksum, err := os.ReadFile(filepath.Join(toolchain.Dir, checksumName))
+	if err != nil || strings.TrimSpace(string(checksum)) != pin.Checksums[i.platform()] {
+		return nil
+	}
+	if _, err := os.Stat(toolchain.Executable()); err != nil {
+		return nil
+	}
+	return toolchain
+}
+
+// URL returns the URL of the release archive of the pinned version for the
+// platform of the installer.
+func (i *Installer) URL(pin *config.Protoc) (string, error) {
+	name, ok := ReleasePlatform(i.platform())
+	if !ok {
+		return "", fmt.Errorf("protoc releases are not available for %s", i.platform())
+	}
+	return strings.NewReplacer("{version}", pin.Version, "{platform}", name).Replace(pin.GetURL()), nil
+}
+
+// Install installs the pinned version of protoc, unless it has already been
+// installed, and returns its toolchain. The archive must match the checksum
+// that is pinned for the platform of the installer.
+func (i *Installer) Install(ctx context.Context, pin *config.Protoc) (*Toolchain, error) {
+	if toolchain := i.Installed(pin); toolchain != nil {
+		return toolchain, nil
+	}
+	checksum, ok := pin.Checksums[i.platform()]
+	if !ok {
+		return nil, fmt.Errorf("protoc %s has no checksum for %s; add one to the protoc checksums of the workspace", pin.Version, i.platform())
+	}
+	rawURL, err := i.URL(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.Join(i.Dir, toolchainsDir)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(root, ".install-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	archive, err := os.Create(filepath.Join(tmp, "archive"))
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	got, err := i.download(ctx, rawURL, archive)
+	if err != nil {
+		return nil, fmt.Errorf("downloading protoc %s: %w", pin.Version, err)
+	}
+	if got != checksum {
+		return nil, fmt.Errorf("checksum mismatch for protoc %s from %s: the workspace pins %s, but the archive has %s", pin.Version, rawURL, checksum, got)
+	}
+
+	unpacked := filepath.Join(tmp, "protoc")
+	if err := fetch.Extract(archive, rawURL, unpacked); err != nil {
+		return nil, err
+	}
+	toolchain := &Toolchain{Version: pin.Version, Dir: unpacked}
+	if err := os.Chmod(toolchain.Executable(), 0o755); err != nil {
+		return nil, fmt.Errorf("archive %s does not contain protoc: %w", rawURL, err)
+	}
+	if err := os.WriteFile(filepath.Join(unpacked, checksumName), []byte(checksum+"\n"), 0o644); err != nil {
+		return nil, err
+	}
+
+	toolchain = i.Toolchain(pin.Version)
+	if err := os.RemoveAll(toolchain.Dir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(unpacked, toolchain.Dir); err != nil {
+		return nil, err
+	}
+	return toolchain, nil
+}
+
+// download writes the release archive at rawURL, or the file of the same
+// name in the mirror, into w, and returns its hex SHA-256 digest.
+func (i *Installer) download(ctx context.Context, rawURL string, w io.Writer) (string, error) {
+	var body io.ReadCloser
+	if i.Mirror != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(i.Mirror, path.Base(u.Path))
+		if body, err = os.Open(path); errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("mirror %s has no %s", i.Mirror, filepath.Base(path))
+		} else if err != nil {
+			return "", err
+		}
+	} else {
+		// Relative paths are resolved against a registry, which release
+		// archives do not come from.
+		u, err := url.Parse(rawURL)
+		if (err != nil || u.Scheme == "") && !filepath.IsAbs(rawURL) {
+			return "", fmt.Errorf("url %q must be absolute", rawURL)
+		}
+		if body, err = i.Fetcher.Open(ctx, rawURL, nil); err != nil {
+			return "", err
+		}
+	}
+	defer body.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,206 @@
+package protoc_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/fetch"
+	"github.com/bitwizeshift/protobuild/internal/protoc"
+)
+
+// release writes a protoc release archive for linux-x86_64 into dir, as
+// protoc releases are laid out, and returns a pin of the release.
+func release(t *testing.T, dir string) *config.Protoc {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"bin/protoc":                        "#!/bin/sh\necho libprotoc 27.1\n",
+		"include/google/protobuf/any.proto": `syntax = "proto3";`,
+		"readme.txt":                        "protoc",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create: unexpected error: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Write: unexpected error: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "protoc-27.1-linux-x86_64.zip"), buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return &config.Protoc{
+		Version:   "27.1",
+		URL:       "file://" + filepath.ToSlash(dir) + "/protoc-{version}-{platform}.zip",
+		Checksums: map[string]string{"linux/amd64": hex.EncodeToString(sum[:])},
+	}
+}
+
+func newInstaller(t *testing.T) *protoc.Installer {
+	t.Helper()
+	return &protoc.Installer{
+		Dir:      t.TempDir(),
+		Fetcher:  &fetch.Fetcher{},
+		Platform: "linux/amd64",
+	}
+}
+
+func TestInstallerInstall_Release_InstallsToolchain(t *testing.T) {
+	pin := release(t, t.TempDir())
+	installer := newInstaller(t)
+
+	toolchain, err := installer.Install(context.Background(), pin)
+	if err != nil {
+		t.Fatalf("Installer.Install: unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(toolchain.Executable()); err != nil {
+		t.Errorf("Installer.Install: executable: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(toolchain.Include(), "google", "protobuf", "any.proto")); err != nil {
+		t.Errorf("Installer.Install: well-known types: %v", err)
+	}
+	if installer.Installed(pin) == nil {
+		t.Errorf("Installer.Installed: got nil after installing")
+	}
+}
+
+func TestInstallerInstall_ChecksumMismatch_ReturnsError(t *testing.T) {
+	pin := release(t, t.TempDir())
+	pin.Checksums["linux/amd64"] = strings.Repeat("0", 64)
+	installer := newInstaller(t)
+
+	_, err := installer.Install(context.Background(), pin)
+
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Installer.Install: got error %v, want checksum mismatch", err)
+	}
+	if installer.Installed(pin) != nil {
+		t.Errorf("Installer.Installed: got toolchain after a failed installation")
+	}
+}
+
+func TestInstallerInstall_NoChecksum_ReturnsError(t *testing.T) {
+	pin := release(t, t.TempDir())
+	installer := newInstaller(t)
+	installer.Platform = "darwin/arm64"
+
+	_, err := installer.Install(context.Background(), pin)
+
+	if err == nil || !strings.Contains(err.Error(), "no checksum for darwin/arm64") {
+		t.Errorf("Installer.Install: got error %v, want no checksum", err)
+	}
+}
+
+func TestInstallerInstall_RelativeURL_ReturnsError(t *testing.T) {
+	pin := release(t, t.TempDir())
+	pin.URL = "protoc-{version}-{platform}.zip"
+	installer := newInstaller(t)
+
+	_, err := installer.Install(context.Background(), pin)
+
+	if err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("Installer.Install: got error %v, want a relative url error", err)
+	}
+}
+
+func TestInstallerInstall_Mirror_TakesArchiveFromMirror(t *testing.T) {
+	mirror := t.TempDir()
+	pin := release(t, mirror)
+	pin.URL = "https://example.invalid/v{version}/protoc-{version}-{platform}.zip"
+	installer := newInstaller(t)
+	installer.Mirror = mirror
+
+	toolchain, err := installer.Install(context.Background(), pin)
+	if err != nil {
+		t.Fatalf("Installer.Install: unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(toolchain.Executable()); err != nil {
+		t.Errorf("Installer.Install: executable: %v", err)
+	}
+}
+
+func TestInstallerInstalled_ChangedChecksum_ReturnsNil(t *testing.T) {
+	pin := release(t, t.TempDir())
+	installer := newInstaller(t)
+	if _, err := installer.Install(context.Background(), pin); err != nil {
+		t.Fatalf("Installer.Install: unexpected error: %v", err)
+	}
+
+	pin.Checksums["linux/amd64"] = strings.Repeat("0", 64)
+
+	if installer.Installed(pin) != nil {
+		t.Errorf("Installer.Installed: got toolchain installed from an archive with another checksum")
+	}
+}
+
+func TestInstallerURL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		platform string
+		want     string
+	}{
+		{name: "linux", platform: "linux/amd64", want: "https://github.com/protocolbuffers/protobuf/releases/download/v27.1/protoc-27.1-linux-x86_64.zip"},
+		{name: "macos", platform: "darwin/arm64", want: "https://github.com/protocolbuffers/protobuf/releases/download/v27.1/protoc-27.1-osx-aarch_64.zip"},
+		{name: "windows", platform: "windows/amd64", want: "https://github.com/protocolbuffers/protobuf/releases/download/v27.1/protoc-27.1-win64.zip"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			installer := &protoc.Installer{Platform: tc.platform}
+
+			got, err := installer.URL(&config.Protoc{Version: "27.1"})
+			if err != nil {
+				t.Fatalf("Installer.URL: unexpected error: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("Installer.URL: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDefaultIncludes_Distribution_ReturnsIncludeNextToBin(t *testing.T) {
+	// The temporary directory may itself be behind a symlink, which
+	// DefaultIncludes resolves.
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: unexpected error: %v", err)
+	}
+	executable := filepath.Join(dir, "bin", "protoc")
+	for name, content := range map[string]string{
+		"bin/protoc": "#!/bin/sh\n",
+		"include/google/protobuf/descriptor.proto": `syntax = "proto2";`,
+		"bin/include/unrelated.proto":              `syntax = "proto3";`,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o755); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+
+	got := protoc.DefaultIncludes(executable)
+
+	if want := []string{filepath.Join(dir, "include")}; !slices.Equal(got, want) {
+		t.Errorf("DefaultIncludes: got %v, want %v", got, want)
+	}
+}
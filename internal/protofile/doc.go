@@ -0,0 +1,17 @@
+/*
+Package protofile reads the declarations of proto files, and traces the
+imports between them, without running protoc.
+
+Files are tokenized by a [Lexer] that follows the lexical rules of protoc, and
+[Parse] reads the declarations that appear at the top level of a proto2,
+proto3, or editions file: its syntax or edition, its package, its imports,
+and its file options, such as go_package. Definitions of messages, enums,
+services, and extensions are skipped.
+
+A [Graph] is traced from a set of files and the include directories that
+imports are resolved against, in the same order that protoc searches them,
+and contains every file that they transitively import. Imports that cannot
+be resolved are reported with the position of the import statement, so that
+they can be flagged before protoc is run.
+*/
+package protofile
@@ -0,0 +1,137 @@
+package protofile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Node is a file of an import graph.
+type Node struct {
+	// File is the parsed declarations of the file. Its path is relative to
+	// the include directory that it was found in.
+	File *File
+
+	// Include is the include directory that the file was found in.
+	Include string
+}
+
+// Filename returns the path of the file on disk.
+func (n *Node) Filename() string {
+	return filepath.Join(n.Include, filepath.FromSlash(n.File.Path))
+}
+
+// Graph is the graph of the imports between a set of proto files, and every
+// file that they transitively import.
+type Graph struct {
+	nodes    map[string]*Node
+	notFound map[string]bool
+	missing  []*Error
+}
+
+// Trace parses the files, and every file that they transitively import, by
+// resolving their paths against the include directories. As with protoc, a
+// path is resolved to the file in the first include directory that contains
+// it. The paths of the files are relative to the include directories.
+//
+// Files and imports that cannot be found are recorded in the graph, rather
+// than returned as errors. Every file that cannot be read or parsed is
+// returned as an error, joined with errors.Join.
+func Trace(includes []string, files []string) (*Graph, error) {
+	g := &Graph{nodes: make(map[string]*Node), notFound: make(map[string]bool)}
+	var errs []error
+	for _, path := range files {
+		if _, err := g.load(includes, path); err != nil {
+			errs = append(errs, err)
+		} else if g.notFound[path] {
+			g.missing = append(g.missing, &Error{File: path, Message: "File not found."})
+		}
+	}
+	queue := g.Nodes()
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, imp := range node.File.Imports {
+			imported, seen := g.nodes[imp.Path]
+			if !seen {
+				var err error
+				if imported, err = g.load(includes, imp.Path); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if imported != nil {
+					queue = append(queue, imported)
+				}
+			}
+			if g.notFound[imp.Path] {
+				g.missing = append(g.missing, &Error{
+					File:     node.Filename(),
+					Position: imp.Position,
+					Message:  fmt.Sprintf("Import %q was not found.", imp.Path),
+				})
+			}
+		}
+	}
+	return g, errors.Join(errs...)
+}
+
+// load parses the file at the path, relative to the first include directory
+// that contains it, and adds it to the graph. Each path is only loaded once;
+// files that cannot be loaded are recorded as nil.
+func (g *Graph) load(includes []string, path string) (*Node, error) {
+	if node, ok := g.nodes[path]; ok {
+		return node, nil
+	}
+	g.nodes[path] = nil
+	for _, include := range includes {
+		filename := filepath.Join(include, filepath.FromSlash(path))
+		data, err := os.ReadFile(filename)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		file, err := Parse(filename, data)
+		if err != nil {
+			return nil, err
+		}
+		file.Path = path
+		node := &Node{File: file, Include: include}
+		g.nodes[path] = node
+		return node, nil
+	}
+	g.notFound[path] = true
+	return nil, nil
+}
+
+// Lookup returns the node of the file with the path, relative to the include
+// directories, or nil if it is not in the graph.
+func (g *Graph) Lookup(path string) *Node {
+	return g.nodes[path]
+}
+
+// Nodes returns every file of the graph, sorted by path.
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	slices.SortFunc(nodes, func(lhs, rhs *Node) int {
+		return strings.Compare(lhs.File.Path, rhs.File.Path)
+	})
+	return nodes
+}
+
+// Missing returns an error for every file that was traced but not found, and
+// for every import of a file that was not found, positioned at the import
+// statement.
+func (g *Graph) Missing() []*Error {
+	return g.missing
+}
@@ -0,0 +1,107 @@
+package protofile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+}
+
+func paths(nodes []*protofile.Node) []string {
+	var paths []string
+	for _, node := range nodes {
+		paths = append(paths, node.File.Path)
+	}
+	return paths
+}
+
+func TestTrace_Imports_ContainsTransitiveImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/a.proto":      `import "b.proto"; import public "c.proto";`,
+		"b/b.proto":      `import "c.proto"; import "d/d.proto";`,
+		"b/c.proto":      `message Shadowed {}`,
+		"c/c.proto":      `import "shadowed.proto";`,
+		"c/d/d.proto":    `syntax = "proto3";`,
+		"c/unused.proto": `syntax = "proto3";`,
+	})
+	includes := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")}
+
+	got, err := protofile.Trace(includes, []string{"a.proto"})
+	if err != nil {
+		t.Fatalf("Trace: unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"a.proto", "b.proto", "c.proto", "d/d.proto"}, paths(got.Nodes())); diff != "" {
+		t.Errorf("Graph.Nodes: mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := got.Lookup("c.proto").Include, includes[1]; got != want {
+		t.Errorf("Graph.Lookup: got include %q, want the first include that contains the file %q", got, want)
+	}
+	if missing := got.Missing(); len(missing) != 0 {
+		t.Errorf("Graph.Missing: got %v, want no missing files", missing)
+	}
+}
+
+func TestTrace_MissingImport_ReportsImport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.proto": "syntax = \"proto3\";\nimport \"missing.proto\";\nimport \"b.proto\";",
+		"b.proto": `import "missing.proto";`,
+	})
+
+	got, err := protofile.Trace([]string{dir}, []string{"a.proto", "nonexistent.proto"})
+	if err != nil {
+		t.Fatalf("Trace: unexpected error: %v", err)
+	}
+
+	var messages []string
+	for _, err := range got.Missing() {
+		messages = append(messages, err.Error())
+	}
+	want := []string{
+		"nonexistent.proto: File not found.",
+		filepath.Join(dir, "a.proto") + `:2:1: Import "missing.proto" was not found.`,
+		filepath.Join(dir, "b.proto") + `:1:1: Import "missing.proto" was not found.`,
+	}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("Graph.Missing: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTrace_SyntaxError_ReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.proto": `import "b.proto";`,
+		"b.proto": `syntax = "proto3"`,
+	})
+
+	got, err := protofile.Trace([]string{dir}, []string{"a.proto"})
+
+	var perr *protofile.Error
+	if !errors.As(err, &perr) {
+		t.Fatalf("Trace: got error %v, want *protofile.Error", err)
+	}
+	if want := filepath.Join(dir, "b.proto"); perr.File != want {
+		t.Errorf("Trace: got error in %q, want %q", perr.File, want)
+	}
+	if missing := got.Missing(); len(missing) != 0 {
+		t.Errorf("Graph.Missing: got %v, want files with errors to not be missing", missing)
+	}
+}
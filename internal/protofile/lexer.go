@@ -0,0 +1,400 @@
+package protofile
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is a location within a proto file. Lines and columns are counted
+// from one, and tabs advance the column to the next multiple of eight, as they
+// do in the errors reported by protoc.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid returns whether the position refers to a real location in a file.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String converts this position into a "line:column" string.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Error is an error in the contents of a proto file, which is formatted in
+// the same way as the errors of protoc.
+type Error struct {
+	// File is the path of the proto file.
+	File string
+
+	// Position is the location in the file where the error occurred. This
+	// may be the zero value if the error is not attributable to a location.
+	Position Position
+
+	// Message describes the error.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if !e.Position.IsValid() {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.File, e.Position, e.Message)
+}
+
+var _ error = (*Error)(nil)
+
+// TokenKind is the kind of a token.
+type TokenKind int
+
+const (
+	// TokenEOF is the end of the file.
+	TokenEOF TokenKind = iota
+
+	// TokenIdent is an identifier or keyword, such as message or int32.
+	TokenIdent
+
+	// TokenInt is an integer literal, in decimal, octal, or hexadecimal.
+	TokenInt
+
+	// TokenFloat is a floating point literal.
+	TokenFloat
+
+	// TokenString is a quoted string literal.
+	TokenString
+
+	// TokenSymbol is any other single character, such as "=" or "{".
+	TokenSymbol
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenEOF:
+		return "end of file"
+	case TokenIdent:
+		return "identifier"
+	case TokenInt:
+		return "integer"
+	case TokenFloat:
+		return "float"
+	case TokenString:
+		return "string"
+	case TokenSymbol:
+		return "symbol"
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// Token is a single token of a proto file.
+type Token struct {
+	// Kind is the kind of the token.
+	Kind TokenKind
+
+	// Text is the text of the token, as it appears in the file.
+	Text string
+
+	// Value is the decoded contents of a string literal, without its quotes
+	// and with its escape sequences replaced. For every other kind of token,
+	// it is the same as Text.
+	Value string
+
+	// Position is the location of the first character of the token.
+	Position Position
+}
+
+// Is reports whether the token is the symbol or identifier text.
+func (t Token) Is(text string) bool {
+	return (t.Kind == TokenSymbol || t.Kind == TokenIdent) && t.Text == text
+}
+
+// Lexer splits the contents of a proto file into tokens. Whitespace and
+// comments are skipped.
+type Lexer struct {
+	file   string
+	src    []byte
+	offset int
+	pos    Position
+}
+
+// NewLexer creates a lexer of the contents of a proto file. The file is only
+// used to report errors.
+func NewLexer(file string, src []byte) *Lexer {
+	return &Lexer{file: file, src: src, pos: Position{Line: 1, Column: 1}}
+}
+
+// Next returns the next token of the file, or a token of kind TokenEOF once
+// every token has been returned. Malformed tokens are returned as an *Error.
+func (l *Lexer) Next() (Token, error) {
+	if err := l.skipSpace(); err != nil {
+		return Token{}, err
+	}
+	start, pos := l.offset, l.pos
+	if l.offset == len(l.src) {
+		return Token{Kind: TokenEOF, Position: pos}, nil
+	}
+
+	kind := TokenSymbol
+	value := ""
+	var err error
+	switch c := l.src[l.offset]; {
+	case isLetter(c):
+		kind = TokenIdent
+		for l.offset < len(l.src) && (isLetter(l.src[l.offset]) || isDigit(l.src[l.offset])) {
+			l.advance()
+		}
+	case isDigit(c) || (c == '.' && isDigit(l.peek(1))):
+		kind, err = l.number()
+	case c == '"' || c == '\'':
+		kind = TokenString
+		value, err = l.string(c)
+	case c >= utf8.RuneSelf:
+		err = l.errorf(pos, "Interpreting non ascii codepoint %d.", c)
+	case c < ' ' || c == 0x7f:
+		err = l.errorf(pos, "Invalid control characters encountered in text.")
+	default:
+		l.advance()
+	}
+	if err != nil {
+		return Token{}, err
+	}
+
+	text := string(l.src[start:l.offset])
+	if kind != TokenString {
+		value = text
+	}
+	return Token{Kind: kind, Text: text, Value: value, Position: pos}, nil
+}
+
+// skipSpace skips the whitespace and comments before the next token.
+func (l *Lexer) skipSpace() error {
+	for l.offset < len(l.src) {
+		switch c := l.src[l.offset]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			l.advance()
+		case c == '/' && l.peek(1) == '/':
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' {
+				l.advance()
+			}
+		case c == '/' && l.peek(1) == '*':
+			pos := l.pos
+			l.advance()
+			l.advance()
+			for !(l.peek(0) == '*' && l.peek(1) == '/') {
+				if l.offset == len(l.src) {
+					return l.errorf(pos, "End-of-file inside block comment.")
+				}
+				l.advance()
+			}
+			l.advance()
+			l.advance()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// number reads an integer or floating point literal.
+func (l *Lexer) number() (TokenKind, error) {
+	start, pos := l.offset, l.pos
+	kind := TokenInt
+	if l.peek(0) == '0' && (l.peek(1) == 'x' || l.peek(1) == 'X') {
+		l.advance()
+		l.advance()
+		if !isHex(l.peek(0)) {
+			return 0, l.errorf(pos, "\"0x\" must be followed by hex digits.")
+		}
+		for isHex(l.peek(0)) {
+			l.advance()
+		}
+	} else {
+		octal := l.peek(0) == '0'
+		for isDigit(l.peek(0)) {
+			l.advance()
+		}
+		if l.peek(0) == '.' {
+			kind = TokenFloat
+			l.advance()
+			for isDigit(l.peek(0)) {
+				l.advance()
+			}
+		}
+		if c := l.peek(0); c == 'e' || c == 'E' {
+			kind = TokenFloat
+			l.advance()
+			if c := l.peek(0); c == '+' || c == '-' {
+				l.advance()
+			}
+			if !isDigit(l.peek(0)) {
+				return 0, l.errorf(l.pos, "\"e\" must be followed by exponent.")
+			}
+			for isDigit(l.peek(0)) {
+				l.advance()
+			}
+		}
+		if c := l.peek(0); kind == TokenFloat && (c == 'f' || c == 'F') {
+			l.advance()
+		}
+		if octal && kind == TokenInt && strings.ContainsAny(string(l.src[start:l.offset]), "89") {
+			return 0, l.errorf(pos, "Numbers starting with leading zero must be in octal.")
+		}
+	}
+	if isLetter(l.peek(0)) {
+		return 0, l.errorf(l.pos, "Need space between number and identifier.")
+	}
+	return kind, nil
+}
+
+// string reads a string literal that is quoted with quote, and returns its
+// decoded contents.
+func (l *Lexer) string(quote byte) (string, error) {
+	var sb strings.Builder
+	l.advance()
+	for {
+		pos := l.pos
+		switch c := l.peek(0); {
+		case l.offset == len(l.src):
+			return "", l.errorf(pos, "Unexpected end of string.")
+		case c == '\n':
+			return "", l.errorf(pos, "String literals cannot cross line boundaries.")
+		case c == quote:
+			l.advance()
+			return sb.String(), nil
+		case c == '\\':
+			l.advance()
+			if err := l.escape(&sb, pos); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			l.advance()
+		}
+	}
+}
+
+// escapes are the single character escape sequences of string literals.
+var escapes = map[byte]byte{
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'\\': '\\',
+	'?':  '?',
+	'\'': '\'',
+	'"':  '"',
+}
+
+// escape decodes the escape sequence following a backslash at pos.
+func (l *Lexer) escape(sb *strings.Builder, pos Position) error {
+	c := l.peek(0)
+	if decoded, ok := escapes[c]; ok {
+		l.advance()
+		sb.WriteByte(decoded)
+		return nil
+	}
+	switch {
+	case isOctal(c):
+		var value byte
+		for i := 0; i < 3 && isOctal(l.peek(0)); i++ {
+			value = value*8 + l.peek(0) - '0'
+			l.advance()
+		}
+		sb.WriteByte(value)
+	case c == 'x' || c == 'X':
+		l.advance()
+		if !isHex(l.peek(0)) {
+			return l.errorf(pos, "Expected hex digits for escape sequence.")
+		}
+		var value byte
+		for i := 0; i < 2 && isHex(l.peek(0)); i++ {
+			value = value*16 + hexValue(l.peek(0))
+			l.advance()
+		}
+		sb.WriteByte(value)
+	case c == 'u' || c == 'U':
+		digits := 4
+		if c == 'U' {
+			digits = 8
+		}
+		l.advance()
+		var value rune
+		for i := 0; i < digits; i++ {
+			if !isHex(l.peek(0)) {
+				return l.errorf(pos, "Expected %d hex digits for \\%c escape sequence.", digits, c)
+			}
+			value = value*16 + rune(hexValue(l.peek(0)))
+			l.advance()
+		}
+		if !utf8.ValidRune(value) {
+			return l.errorf(pos, "Invalid code point in \\%c escape sequence.", c)
+		}
+		sb.WriteRune(value)
+	default:
+		return l.errorf(pos, "Invalid escape sequence in string literal.")
+	}
+	return nil
+}
+
+// peek returns the byte n bytes after the current offset, or zero if it is
+// past the end of the file.
+func (l *Lexer) peek(n int) byte {
+	if l.offset+n >= len(l.src) {
+		return 0
+	}
+	return l.src[l.offset+n]
+}
+
+// advance moves past the byte at the current offset.
+func (l *Lexer) advance() {
+	switch l.src[l.offset] {
+	case '\n':
+		l.pos.Line++
+		l.pos.Column = 1
+	case '\t':
+		l.pos.Column += 8 - (l.pos.Column-1)%8
+	default:
+		l.pos.Column++
+	}
+	l.offset++
+}
+
+func (l *Lexer) errorf(pos Position, format string, args ...any) error {
+	return &Error{File: l.file, Position: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case isDigit(c):
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,111 @@
+package protofile_test
+
+import (
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"github.com/google/go-cmp/cmp"
+)
+
+func tokens(t *testing.T, src string) []protofile.Token {
+	t.Helper()
+	lexer := protofile.NewLexer("a.proto", []byte(src))
+	var tokens []protofile.Token
+	for {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatalf("Lexer.Next: unexpected error: %v", err)
+		}
+		if token.Kind == protofile.TokenEOF {
+			return tokens
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func TestLexerNext_Tokens_ReturnsTokens(t *testing.T) {
+	src := "message M { // comment\n\tint32 x = 0x1F; /* block\n */ double y = -1.5e3;\n}"
+
+	got := tokens(t, src)
+
+	want := []protofile.Token{
+		{Kind: protofile.TokenIdent, Text: "message", Value: "message", Position: protofile.Position{Line: 1, Column: 1}},
+		{Kind: protofile.TokenIdent, Text: "M", Value: "M", Position: protofile.Position{Line: 1, Column: 9}},
+		{Kind: protofile.TokenSymbol, Text: "{", Value: "{", Position: protofile.Position{Line: 1, Column: 11}},
+		{Kind: protofile.TokenIdent, Text: "int32", Value: "int32", Position: protofile.Position{Line: 2, Column: 9}},
+		{Kind: protofile.TokenIdent, Text: "x", Value: "x", Position: protofile.Position{Line: 2, Column: 15}},
+		{Kind: protofile.TokenSymbol, Text: "=", Value: "=", Position: protofile.Position{Line: 2, Column: 17}},
+		{Kind: protofile.TokenInt, Text: "0x1F", Value: "0x1F", Position: protofile.Position{Line: 2, Column: 19}},
+		{Kind: protofile.TokenSymbol, Text: ";", Value: ";", Position: protofile.Position{Line: 2, Column: 23}},
+		{Kind: protofile.TokenIdent, Text: "double", Value: "double", Position: protofile.Position{Line: 3, Column: 5}},
+		{Kind: protofile.TokenIdent, Text: "y", Value: "y", Position: protofile.Position{Line: 3, Column: 12}},
+		{Kind: protofile.TokenSymbol, Text: "=", Value: "=", Position: protofile.Position{Line: 3, Column: 14}},
+		{Kind: protofile.TokenSymbol, Text: "-", Value: "-", Position: protofile.Position{Line: 3, Column: 16}},
+		{Kind: protofile.TokenFloat, Text: "1.5e3", Value: "1.5e3", Position: protofile.Position{Line: 3, Column: 17}},
+		{Kind: protofile.TokenSymbol, Text: ";", Value: ";", Position: protofile.Position{Line: 3, Column: 22}},
+		{Kind: protofile.TokenSymbol, Text: "}", Value: "}", Position: protofile.Position{Line: 4, Column: 1}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Lexer.Next: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLexerNext_String(t *testing.T) {
+	testCases := []struct {
+		src  string
+		want string
+	}{
+		{src: `"plain"`, want: "plain"},
+		{src: `'single "quoted"'`, want: `single "quoted"`},
+		{src: `"tab\tnewline\n"`, want: "tab\tnewline\n"},
+		{src: `"\x41\101é\U0001F600"`, want: "AAé😀"},
+		{src: `"\\ \' \" \?"`, want: `\ ' " ?`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			got := tokens(t, tc.src)
+
+			if len(got) != 1 || got[0].Kind != protofile.TokenString {
+				t.Fatalf("Lexer.Next(%s): got %v, want a single string", tc.src, got)
+			}
+			if got[0].Value != tc.want {
+				t.Errorf("Lexer.Next(%s): got value %q, want %q", tc.src, got[0].Value, tc.want)
+			}
+		})
+	}
+}
+
+func TestLexerNext_Invalid_ReturnsError(t *testing.T) {
+	testCases := []struct {
+		src  string
+		want string
+	}{
+		{src: "\"unterminated", want: "a.proto:1:14: Unexpected end of string."},
+		{src: "\"multi\nline\"", want: "a.proto:1:7: String literals cannot cross line boundaries."},
+		{src: "x /* open", want: "a.proto:1:3: End-of-file inside block comment."},
+		{src: "0x", want: "a.proto:1:1: \"0x\" must be followed by hex digits."},
+		{src: "09", want: "a.proto:1:1: Numbers starting with leading zero must be in octal."},
+		{src: "1e", want: "a.proto:1:3: \"e\" must be followed by exponent."},
+		{src: "12abc", want: "a.proto:1:3: Need space between number and identifier."},
+		{src: `"\q"`, want: "a.proto:1:2: Invalid escape sequence in string literal."},
+		{src: "\x01", want: "a.proto:1:1: Invalid control characters encountered in text."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			lexer := protofile.NewLexer("a.proto", []byte(tc.src))
+			var err error
+			for err == nil {
+				var token protofile.Token
+				if token, err = lexer.Next(); token.Kind == protofile.TokenEOF && err == nil {
+					t.Fatalf("Lexer.Next(%q): got no error, want %q", tc.src, tc.want)
+				}
+			}
+
+			if got := err.Error(); got != tc.want {
+				t.Errorf("Lexer.Next(%q): got error %q, want %q", tc.src, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package protofile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImportKind is the kind of an import statement.
+type ImportKind int
+
+const (
+	// ImportDefault is a plain import, whose definitions are only visible to
+	// the importing file.
+	ImportDefault ImportKind = iota
+
+	// ImportPublic is an "import public", whose definitions are also visible
+	// to every file that imports the importing file.
+	ImportPublic
+
+	// ImportWeak is an "import weak", which is allowed to be missing when
+	// generated code is linked.
+	ImportWeak
+)
+
+func (k ImportKind) String() string {
+	switch k {
+	case ImportDefault:
+		return "default"
+	case ImportPublic:
+		return "public"
+	case ImportWeak:
+		return "weak"
+	}
+	return fmt.Sprintf("ImportKind(%d)", int(k))
+}
+
+// Import is an import statement of a proto file.
+type Import struct {
+	// Path is the path of the imported file, relative to an include
+	// directory.
+	Path string
+
+	// Kind is whether the import is public, weak, or neither.
+	Kind ImportKind
+
+	// Position is the location of the import statement.
+	Position Position
+}
+
+// File is the declarations of a proto file.
+type File struct {
+	// Path is the path that the file was parsed as.
+	Path string
+
+	// Syntax is the syntax that the file declares, which is "proto2" or
+	// "proto3". It is empty if the file declares an edition instead.
+	Syntax string
+
+	// Edition is the edition that the file declares, such as "2023", if
+	// any.
+	Edition string
+
+	// Package is the package that the file declares, if any.
+	Package string
+
+	// Imports are the import statements of the file, in the order that
+	// they appear.
+	Imports []Import
+
+	// Options are the file options whose values are scalars, such as
+	// go_package, keyed by the name of the option as it appears in the file,
+	// such as "(custom.option).field". Strings are decoded, and every other
+	// value is its source text.
+	Options map[string]string
+}
+
+// Parse reads the declarations of the contents of a proto file. A file that
+// declares neither a syntax nor an edition is a proto2 file, as it is to
+// protoc. The first syntax error is returned as an *Error.
+func Parse(path string, src []byte) (*File, error) {
+	p := &parser{lexer: NewLexer(path, src)}
+	file := &File{Path: path, Options: make(map[string]string)}
+	if err := p.parse(file); err != nil {
+		return nil, err
+	}
+	if file.Syntax == "" && file.Edition == "" {
+		file.Syntax = "proto2"
+	}
+	return file, nil
+}
+
+// parser reads the top-level declarations of a proto file from its tokens.
+type parser struct {
+	lexer  *Lexer
+	token  Token
+	peeked *Token
+}
+
+func (p *parser) parse(file *File) error {
+	first := true
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		token := p.token
+		if token.Kind == TokenEOF {
+			return nil
+		}
+		var err error
+		switch {
+		case token.Is(";"):
+		case token.Is("syntax") || token.Is("edition"):
+			err = p.syntax(file, first)
+		case token.Is("package"):
+			err = p.pkg(file)
+		case token.Is("import"):
+			err = p.imports(file)
+		case token.Is("option"):
+			err = p.option(file)
+		default:
+			err = p.skip()
+		}
+		if err != nil {
+			return err
+		}
+		first = false
+	}
+}
+
+// syntax reads a syntax or edition statement, which must be the first
+// statement of the file.
+func (p *parser) syntax(file *File, first bool) error {
+	keyword := p.token
+	if !first {
+		return p.errorf(keyword, "%s statement must be the first statement of the file.", keyword.Text)
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	value, err := p.str()
+	if err != nil {
+		return err
+	}
+	if keyword.Text == "edition" {
+		file.Edition = value
+	} else {
+		if value != "proto2" && value != "proto3" {
+			return p.errorf(keyword, "Unrecognized syntax identifier %q.  This parser only recognizes \"proto2\" and \"proto3\".", value)
+		}
+		file.Syntax = value
+	}
+	return p.expect(";")
+}
+
+// pkg reads a package statement.
+func (p *parser) pkg(file *File) error {
+	keyword := p.token
+	if file.Package != "" {
+		return p.errorf(keyword, "Multiple package definitions.")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	name, err := p.fullIdent()
+	if err != nil {
+		return err
+	}
+	file.Package = name
+	return p.expect(";")
+}
+
+// imports reads an import statement.
+func (p *parser) imports(file *File) error {
+	imp := Import{Position: p.token.Position}
+	if err := p.next(); err != nil {
+		return err
+	}
+	switch {
+	case p.token.Is("public"):
+		imp.Kind = ImportPublic
+	case p.token.Is("weak"):
+		imp.Kind = ImportWeak
+	}
+	if imp.Kind != ImportDefault {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	if p.token.Kind != TokenString {
+		return p.errorf(p.token, "Expected a string naming the file to import.")
+	}
+	path, err := p.strings()
+	if err != nil {
+		return err
+	}
+	imp.Path = path
+	file.Imports = append(file.Imports, imp)
+	return p.expect(";")
+}
+
+// option reads a file option statement.
+func (p *parser) option(file *File) error {
+	var name strings.Builder
+	for {
+		if err := p.next(); err != nil {
+			return err
+		}
+		switch {
+		case p.token.Is("("):
+			if err := p.next(); err != nil {
+				return err
+			}
+			if p.token.Is(".") {
+				name.WriteString("(.")
+				if err := p.next(); err != nil {
+					return err
+				}
+			} else {
+				name.WriteString("(")
+			}
+			extension, err := p.fullIdent()
+			if err != nil {
+				return err
+			}
+			name.WriteString(extension)
+			if err := p.expect(")"); err != nil {
+				return err
+			}
+			name.WriteString(")")
+		case p.token.Kind == TokenIdent:
+			name.WriteString(p.token.Text)
+		default:
+			return p.errorf(p.token, "Expected identifier.")
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.token.Is("=") {
+			break
+		}
+		if !p.token.Is(".") {
+			return p.errorf(p.token, "Expected \"=\".")
+		}
+		name.WriteString(".")
+	}
+
+	if err := p.next(); err != nil {
+		return err
+	}
+	switch {
+	case p.token.Is("{"):
+		// Aggregate values are only recorded as options of messages, which
+		// are not needed to trace imports.
+		return p.skip()
+	case p.token.Kind == TokenString:
+		value, err := p.strings()
+		if err != nil {
+			return err
+		}
+		file.Options[name.String()] = value
+		return p.expect(";")
+	case p.token.Is("-") || p.token.Is("+"):
+		sign := p.token.Text
+		if err := p.next(); err != nil {
+			return err
+		}
+		if p.token.Kind != TokenInt && p.token.Kind != TokenFloat && p.token.Kind != TokenIdent {
+			return p.errorf(p.token, "Expected number.")
+		}
+		if sign == "-" {
+			file.Options[name.String()] = sign + p.token.Text
+		} else {
+			file.Options[name.String()] = p.token.Text
+		}
+	case p.token.Kind == TokenIdent || p.token.Kind == TokenInt || p.token.Kind == TokenFloat:
+		file.Options[name.String()] = p.token.Text
+	default:
+		return p.errorf(p.token, "Expected option value.")
+	}
+	return p.expect(";")
+}
+
+// skip skips a statement that is not read, such as the definition of a
+// message, up to and including its terminating ";" or closing "}".
+func (p *parser) skip() error {
+	start := p.token
+	depth := 0
+	for {
+		switch token := p.token; {
+		case token.Is("{"):
+			depth++
+		case token.Is("}") && depth == 0:
+			return p.errorf(token, "Unmatched \"}\".")
+		case token.Is("}"):
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		case token.Is(";") && depth == 0:
+			return nil
+		case token.Kind == TokenEOF && depth > 0:
+			return p.errorf(token, "Reached end of input in %s definition (missing '}').", start.Text)
+		case token.Kind == TokenEOF:
+			return p.errorf(token, "Expected \";\".")
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+// fullIdent reads a dot-separated identifier, starting with the current
+// token. The token after the identifier becomes the current token.
+func (p *parser) fullIdent() (string, error) {
+	var name strings.Builder
+	for {
+		if p.token.Kind != TokenIdent {
+			return "", p.errorf(p.token, "Expected identifier.")
+		}
+		name.WriteString(p.token.Text)
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		if !p.token.Is(".") {
+			p.unread()
+			return name.String(), nil
+		}
+		name.WriteString(".")
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+}
+
+// str reads the next token, which must be a string literal, along with any
+// literals that are concatenated with it.
+func (p *parser) str() (string, error) {
+	if err := p.next(); err != nil {
+		return "", err
+	}
+	if p.token.Kind != TokenString {
+		return "", p.errorf(p.token, "Expected string.")
+	}
+	return p.strings()
+}
+
+// strings returns the value of the current string literal, concatenated
+// with the values of the string literals that directly follow it.
+func (p *parser) strings() (string, error) {
+	value := p.token.Value
+	for {
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		if p.token.Kind != TokenString {
+			p.unread()
+			return value, nil
+		}
+		value += p.token.Value
+	}
+}
+
+// expect reads the next token, which must be the symbol.
+func (p *parser) expect(symbol string) error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	if !p.token.Is(symbol) {
+		return p.errorf(p.token, "Expected %q.", symbol)
+	}
+	return nil
+}
+
+func (p *parser) next() error {
+	if p.peeked != nil {
+		p.token, p.peeked = *p.peeked, nil
+		return nil
+	}
+	token, err := p.lexer.Next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+// unread returns the current token to be read again by the next call to
+// next.
+func (p *parser) unread() {
+	token := p.token
+	p.peeked = &token
+}
+
+func (p *parser) errorf(token Token, format string, args ...any) error {
+	return p.lexer.errorf(token.Position, format, args...)
+}
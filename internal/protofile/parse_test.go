@@ -0,0 +1,115 @@
+package protofile_test
+
+import (
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse_File_ReturnsDeclarations(t *testing.T) {
+	src := `
+		// package commented.v1;
+		syntax = "proto3";
+
+		package acme.billing.v1;
+
+		import "acme/types/v1/money.proto";
+		import public "google/protobuf/" "timestamp.proto";
+		import weak "acme/legacy.proto";
+
+		option go_package = "example.com/acme/billing/v1;billingv1";
+		option optimize_for = SPEED;
+		option (acme.file).retention = -3;
+		option (acme.aggregate) = { name: "ignored" };
+
+		message Invoice {
+			option deprecated = true;
+			message Line { string sku = 1; }
+			repeated Line lines = 1 [(acme.field) = { import: "not.proto" }];
+		}
+
+		service Billing { rpc Pay(Invoice) returns (Invoice); }
+	`
+
+	got, err := protofile.Parse("invoice.proto", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+
+	want := &protofile.File{
+		Path:    "invoice.proto",
+		Syntax:  "proto3",
+		Package: "acme.billing.v1",
+		Imports: []protofile.Import{
+			{Path: "acme/types/v1/money.proto", Position: protofile.Position{Line: 7, Column: 17}},
+			{Path: "google/protobuf/timestamp.proto", Kind: protofile.ImportPublic, Position: protofile.Position{Line: 8, Column: 17}},
+			{Path: "acme/legacy.proto", Kind: protofile.ImportWeak, Position: protofile.Position{Line: 9, Column: 17}},
+		},
+		Options: map[string]string{
+			"go_package":            "example.com/acme/billing/v1;billingv1",
+			"optimize_for":          "SPEED",
+			"(acme.file).retention": "-3",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parse: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParse_Syntax(t *testing.T) {
+	testCases := []struct {
+		src         string
+		wantSyntax  string
+		wantEdition string
+	}{
+		{src: `syntax = "proto2";`, wantSyntax: "proto2"},
+		{src: `syntax = 'proto3';`, wantSyntax: "proto3"},
+		{src: `edition = "2023";`, wantEdition: "2023"},
+		{src: `message M {}`, wantSyntax: "proto2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			got, err := protofile.Parse("a.proto", []byte(tc.src))
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", tc.src, err)
+			}
+
+			if got.Syntax != tc.wantSyntax || got.Edition != tc.wantEdition {
+				t.Errorf("Parse(%q): got syntax %q and edition %q, want %q and %q",
+					tc.src, got.Syntax, got.Edition, tc.wantSyntax, tc.wantEdition)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid_ReturnsError(t *testing.T) {
+	testCases := []struct {
+		src  string
+		want string
+	}{
+		{src: `syntax = "proto4";`, want: `a.proto:1:1: Unrecognized syntax identifier "proto4".  This parser only recognizes "proto2" and "proto3".`},
+		{src: "package a;\nsyntax = \"proto3\";", want: "a.proto:2:1: syntax statement must be the first statement of the file."},
+		{src: "package a;\npackage b;", want: "a.proto:2:1: Multiple package definitions."},
+		{src: "package a.;", want: "a.proto:1:11: Expected identifier."},
+		{src: "import foo;", want: "a.proto:1:8: Expected a string naming the file to import."},
+		{src: `import "a.proto"`, want: `a.proto:1:17: Expected ";".`},
+		{src: "option go_package \"x\";", want: "a.proto:1:19: Expected \"=\"."},
+		{src: "message M {\n  int32 x = 1;\n", want: "a.proto:3:1: Reached end of input in message definition (missing '}')."},
+		{src: "}", want: "a.proto:1:1: Unmatched \"}\"."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.src, func(t *testing.T) {
+			_, err := protofile.Parse("a.proto", []byte(tc.src))
+			if err == nil {
+				t.Fatalf("Parse(%q): got nil error, want %q", tc.src, tc.want)
+			}
+
+			if got := err.Error(); got != tc.want {
+				t.Errorf("Parse(%q): got error %q, want %q", tc.src, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+/*
+Package registry manages the git-driven registries that external projects are
+resolved from.
+
+Each registry is a git repository containing a registry manifest. Adding a
+registry clones it into a sub-directory of the registry path and records where
+it came from in the user configuration, so that it can later be updated by
+fetching from the same location.
+*/
+package registry
@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/git"
+)
+
+// Manager adds, updates, and removes the registries under a registry path.
+type Manager struct {
+	// Dir is the registry path that registries are cloned into.
+	Dir string
+
+	// User is the user configuration that registries are recorded in. It is
+	// saved whenever a registry is added or removed.
+	User *config.User
+}
+
+// Add clones the registry at url into the registry path with the given name,
+// and records it in the user configuration. The registry must contain a valid
+// registry manifest; otherwise, nothing is added.
+func (m *Manager) Add(ctx context.Context, name, url string) (*config.Registry, error) {
+	if !config.IsRegistryName(name) {
+		return nil, fmt.Errorf("invalid registry name %q; must contain only letters, digits, '.', '_', and '-'", name)
+	}
+	if m.User.Registry(name) != nil {
+		return nil, fmt.Errorf("registry %q has already been added", name)
+	}
+	dir := m.dir(name)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("registry %q already exists at %s", name, dir)
+	}
+	if !strings.Contains(url, "://") {
+		// Local paths are recorded as absolute paths, since the registry is
+		// later updated from a different working directory.
+		if _, err := os.Stat(url); err == nil {
+			if url, err = filepath.Abs(url); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// The registry is cloned next to its final location, so that a failed or
+	// cancelled clone never leaves a partial registry behind.
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(m.Dir, ".clone-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := git.Clone(ctx, url, tmp); err != nil {
+		return nil, fmt.Errorf("cloning registry %q: %w", name, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return nil, err
+	}
+	registry, err := load(dir)
+	if err != nil {
+		return nil, errors.Join(err, os.RemoveAll(dir))
+	}
+
+	if err := m.User.AddRegistry(config.RegistryRef{Name: name, URL: url}); err != nil {
+		return nil, errors.Join(err, os.RemoveAll(dir))
+	}
+	if err := m.User.Save(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Update fetches the latest revision of the registry with the given name from
+// the location it was added from, and returns whether it changed. If the
+// latest revision does not contain a valid registry manifest, the registry is
+// left at its current revision.
+func (m *Manager) Update(ctx context.Context, name string) (bool, error) {
+	ref := m.User.Registry(name)
+	if ref == nil {
+		return false, fmt.Errorf("registry %q has not been added", name)
+	}
+	dir := m.dir(name)
+	before, err := git.Head(ctx, dir)
+	if err != nil {
+		return false, fmt.Errorf("updating registry %q: %w", name, err)
+	}
+	if err := git.Update(ctx, dir, ref.URL); err != nil {
+		return false, fmt.Errorf("updating registry %q: %w", name, err)
+	}
+	if _, err := load(dir); err != nil {
+		// Resetting uses a fresh context, since the registry must be restored
+		// even if the update was cancelled.
+		return false, errors.Join(err, git.Reset(context.WithoutCancel(ctx), dir, before))
+	}
+	after, err := git.Head(ctx, dir)
+	if err != nil {
+		return false, err
+	}
+	return before != after, nil
+}
+
+// Remove deletes the registry with the given name from the registry path, and
+// removes it from the user configuration. Only a directory directly within the
+// registry path is ever deleted.
+func (m *Manager) Remove(name string) error {
+	if !config.IsRegistryName(name) {
+		return fmt.Errorf("invalid registry name %q; must contain only letters, digits, '.', '_', and '-'", name)
+	}
+	dir := m.dir(name)
+	exists := false
+	if filepath.Dir(dir) == filepath.Clean(m.Dir) {
+		info, err := os.Lstat(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		exists = err == nil && info.IsDir()
+	}
+	if !m.User.RemoveRegistry(name) && !exists {
+		return fmt.Errorf("registry %q has not been added", name)
+	}
+	if exists {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return m.User.Save()
+}
+
+func (m *Manager) dir(name string) string {
+	return filepath.Join(m.Dir, name)
+}
+
+// load loads the registry manifest of the registry cloned into dir.
+func load(dir string) (*config.Registry, error) {
+	path, err := config.FindRegistry(dir)
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadRegistry(path)
+}
@@ -0,0 +1,241 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/git"
+	"github.com/bitwizeshift/protobuild/internal/registry"
+	"github.com/google/go-cmp/cmp"
+)
+
+const manifest = `{"projects": [{"name": "google/fhir", "source": {"git": "https://github.com/google/fhir.git"}, "versions": [{"version": "v0.7.4"}]}]}`
+
+// newRegistryRepository creates a bare repository containing the registry
+// manifest, and returns its "file://" URL and a clone that further commits
+// can be pushed from.
+func newRegistryRepository(t *testing.T, content string) (url, work string) {
+	t.Helper()
+	if _, err := exec.LookPath(git.Command); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	bare, work := filepath.Join(root, "registry.git"), filepath.Join(root, "work")
+	run(t, root, "init", "--quiet", "--bare", bare)
+	run(t, root, "clone", "--quiet", bare, work)
+	push(t, work, content)
+	return "file://" + filepath.ToSlash(bare), work
+}
+
+// push commits the registry manifest in the working clone, and pushes it.
+func push(t *testing.T, work, content string) {
+	t.Helper()
+	path := filepath.Join(work, config.RegistryBaseName+".json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	run(t, work, "add", ".")
+	run(t, work, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "update")
+	run(t, work, "push", "--quiet", "origin", "HEAD")
+}
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if _, err := git.Run(context.Background(), dir, args...); err != nil {
+		t.Fatalf("git %v: unexpected error: %v", args, err)
+	}
+}
+
+func newManager(t *testing.T) *registry.Manager {
+	t.Helper()
+	root := t.TempDir()
+	user, err := config.LoadUser(root)
+	if err != nil {
+		t.Fatalf("LoadUser: unexpected error: %v", err)
+	}
+	return &registry.Manager{Dir: filepath.Join(root, "registry"), User: user}
+}
+
+func projectNames(t *testing.T, dir string) []string {
+	t.Helper()
+	registries, err := config.LoadRegistries(dir)
+	if err != nil {
+		t.Fatalf("LoadRegistries: unexpected error: %v", err)
+	}
+	var names []string
+	for _, registry := range registries {
+		for _, project := range registry.Projects {
+			names = append(names, registry.Name+":"+project.Name)
+		}
+	}
+	return names
+}
+
+func TestManagerAdd_ValidRegistry_ClonesAndRecords(t *testing.T) {
+	url, _ := newRegistryRepository(t, manifest)
+	manager := newManager(t)
+	want := []config.RegistryRef{{Name: "public", URL: url}}
+
+	added, err := manager.Add(context.Background(), "public", url)
+	if err != nil {
+		t.Fatalf("Manager.Add: unexpected error: %v", err)
+	}
+
+	if got, want := added.Name, "public"; got != want {
+		t.Errorf("Manager.Add: got registry %q, want %q", got, want)
+	}
+	saved, err := config.LoadUser(filepath.Dir(manager.User.Path))
+	if err != nil {
+		t.Fatalf("LoadUser: unexpected error: %v", err)
+	}
+	if got := saved.Registries; !cmp.Equal(got, want) {
+		t.Errorf("Manager.Add: recorded registries (-got +want):\n%s", cmp.Diff(got, want))
+	}
+	if got, want := projectNames(t, manager.Dir), []string{"public:google/fhir"}; !cmp.Equal(got, want) {
+		t.Errorf("Manager.Add: got projects %v, want %v", got, want)
+	}
+}
+
+func TestManagerAdd_InvalidRegistry_AddsNothing(t *testing.T) {
+	url, _ := newRegistryRepository(t, `{"projects": [{"name": "fhir"}]}`)
+	manager := newManager(t)
+
+	_, err := manager.Add(context.Background(), "public", url)
+
+	if err == nil {
+		t.Fatalf("Manager.Add: got nil error, want error")
+	}
+	if _, err := os.Stat(filepath.Join(manager.Dir, "public")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Manager.Add: registry directory exists after failure")
+	}
+	if got := manager.User.Registries; len(got) != 0 {
+		t.Errorf("Manager.Add: recorded registries %v after failure", got)
+	}
+}
+
+func TestManagerAdd_DuplicateName_ReturnsError(t *testing.T) {
+	url, _ := newRegistryRepository(t, manifest)
+	manager := newManager(t)
+	ctx := context.Background()
+	if _, err := manager.Add(ctx, "public", url); err != nil {
+		t.Fatalf("Manager.Add: unexpected error: %v", err)
+	}
+
+	if _, err := manager.Add(ctx, "public", url); err == nil {
+		t.Errorf("Manager.Add: got nil error, want error")
+	}
+}
+
+func TestManagerUpdate_NewRevision_ReturnsChanged(t *testing.T) {
+	url, work := newRegistryRepository(t, manifest)
+	manager := newManager(t)
+	ctx := context.Background()
+	if _, err := manager.Add(ctx, "public", url); err != nil {
+		t.Fatalf("Manager.Add: unexpected error: %v", err)
+	}
+	push(t, work, `{"projects": [{"name": "google/api", "source": {"git": "https://github.com/googleapis/googleapis.git"}, "versions": [{"version": "v1"}]}]}`)
+
+	changed, err := manager.Update(ctx, "public")
+	if err != nil {
+		t.Fatalf("Manager.Update: unexpected error: %v", err)
+	}
+
+	if !changed {
+		t.Errorf("Manager.Update: got unchanged, want changed")
+	}
+	if got, want := projectNames(t, manager.Dir), []string{"public:google/api"}; !cmp.Equal(got, want) {
+		t.Errorf("Manager.Update: got projects %v, want %v", got, want)
+	}
+}
+
+func TestManagerUpdate_NoRevision_ReturnsUnchanged(t *testing.T) {
+	url, _ := newRegistryRepository(t, manifest)
+	manager := newManager(t)
+	ctx := context.Background()
+	if _, err := manager.Add(ctx, "public", url); err != nil {
+		t.Fatalf("Manager.Add: unexpected error: %v", err)
+	}
+
+	changed, err := manager.Update(ctx, "public")
+	if err != nil {
+		t.Fatalf("Manager.Update: unexpected error: %v", err)
+	}
+
+	if changed {
+		t.Errorf("Manager.Update: got changed, want unchanged")
+	}
+}
+
+func TestManagerUpdate_InvalidRevision_KeepsCurrentRevision(t *testing.T) {
+	url, work := newRegistryRepository(t, manifest)
+	manager := newManager(t)
+	ctx := context.Background()
+	if _, err := manager.Add(ctx, "public", url); err != nil {
+		t.Fatalf("Manager.Add: unexpected error: %v", err)
+	}
+	push(t, work, `{"projects": [{"name": "fhir"}]}`)
+
+	_, err := manager.Update(ctx, "public")
+
+	if err == nil {
+		t.Errorf("Manager.Update: got nil error, want error")
+	}
+	if got, want := projectNames(t, manager.Dir), []string{"public:google/fhir"}; !cmp.Equal(got, want) {
+		t.Errorf("Manager.Update: got projects %v, want %v", got, want)
+	}
+}
+
+func TestManagerRemove_AddedRegistry_RemovesRegistry(t *testing.T) {
+	url, _ := newRegistryRepository(t, manifest)
+	manager := newManager(t)
+	if _, err := manager.Add(context.Background(), "public", url); err != nil {
+		t.Fatalf("Manager.Add: unexpected error: %v", err)
+	}
+
+	if err := manager.Remove("public"); err != nil {
+		t.Fatalf("Manager.Remove: unexpected error: %v", err)
+	}
+
+	if got := projectNames(t, manager.Dir); len(got) != 0 {
+		t.Errorf("Manager.Remove: got projects %v, want none", got)
+	}
+	if got := manager.User.Registries; len(got) != 0 {
+		t.Errorf("Manager.Remove: recorded registries %v, want none", got)
+	}
+}
+
+func TestManagerRemove_UnknownRegistry_ReturnsError(t *testing.T) {
+	manager := newManager(t)
+
+	if err := manager.Remove("public"); err == nil {
+		t.Errorf("Manager.Remove: got nil error, want error")
+	}
+}
+
+func TestManagerRemove_PathOutsideRegistryPath_RemovesNothing(t *testing.T) {
+	manager := newManager(t)
+	victim := filepath.Join(filepath.Dir(manager.Dir), "x")
+	for _, dir := range []string{manager.Dir, victim} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("MkdirAll: unexpected error: %v", err)
+		}
+	}
+
+	for _, name := range []string{"..", "../x"} {
+		if err := manager.Remove(name); err == nil {
+			t.Errorf("Manager.Remove(%q): got nil error, want error", name)
+		}
+	}
+
+	for _, dir := range []string{manager.Dir, victim} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("Manager.Remove: %s was removed: %v", dir, err)
+		}
+	}
+}
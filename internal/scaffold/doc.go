@@ -0,0 +1,12 @@
+/*
+Package scaffold proposes the configuration of a new workspace from the proto
+files that already exist in a directory.
+
+The proto files are parsed for their packages, imports, and file options.
+The import root of each file is inferred from its package, since files are
+conventionally stored in directories that match their packages, and a target
+is proposed for each import root, with dependencies between targets that
+import from each other. The languages that the file options configure, such
+as go_package for Go, are proposed as the outputs of every target.
+*/
+package scaffold
@@ -0,0 +1,318 @@
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/jsonschema"
+)
+
+// DefaultOutput is the output directory of proposed workspaces.
+const DefaultOutput = "gen"
+
+// DefaultPlugin is the plugin that outputs are proposed for when no file
+// option configures a language.
+const DefaultPlugin = "cpp"
+
+// languageOptions are the file options that configure the code generated for
+// a language, and the plugins that generate the language, in the order that
+// the plugins are proposed.
+var languageOptions = []struct {
+	option string
+	plugin string
+}{
+	{option: "go_package", plugin: "go"},
+	{option: "java_package", plugin: "java"},
+	{option: "csharp_namespace", plugin: "csharp"},
+	{option: "objc_class_prefix", plugin: "objc"},
+	{option: "php_namespace", plugin: "php"},
+	{option: "ruby_package", plugin: "ruby"},
+	{option: "swift_prefix", plugin: "swift"},
+}
+
+// Proposal is the proposed configuration of a new workspace.
+type Proposal struct {
+	// Workspace is the proposed workspace.
+	Workspace *config.Workspace
+
+	// Targets are the proposed targets, sorted by the path of their files.
+	Targets []*Target
+
+	// Plugins are the plugins that every target has an output for.
+	Plugins []string
+
+	// Unresolved are the imports that no scanned file provides, other than
+	// the well-known types of protoc, keyed by the path of the importing
+	// file.
+	Unresolved map[string][]string
+}
+
+// Target is a proposed target, which is named by the import root of its
+// files.
+type Target struct {
+	*config.Target
+
+	// Root is the slash-separated import root of the target, relative to the
+	// workspace directory.
+	Root string
+
+	// Files are the slash-separated paths of the files of the target,
+	// relative to the workspace directory.
+	Files []string
+}
+
+// Propose proposes a workspace in dir, in the format, for the files that
+// were scanned from dir.
+func Propose(dir string, format config.Format, files []File) *Proposal {
+	ext := "." + string(format)
+	proposal := &Proposal{
+		Workspace: &config.Workspace{
+			Schema:  jsonschema.URL(jsonschema.Workspace),
+			Targets: glob.NewPatterns("**/" + config.TargetBaseName + ext),
+			Output:  DefaultOutput,
+			Path:    filepath.Join(dir, config.WorkspaceBaseName+ext),
+		},
+		Plugins:    proposePlugins(files),
+		Unresolved: make(map[string][]string),
+	}
+
+	// Every file belongs to the deepest import root that contains it, so
+	// that no file is a source of more than one target.
+	var roots []string
+	for _, file := range files {
+		if root := ImportRoot(file); !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	slices.Sort(roots)
+	owners := make(map[string]*Target, len(files))
+	targets := make(map[string]*Target, len(roots))
+	names := make(map[string]bool, len(roots))
+	for _, file := range files {
+		root := deepestRoot(roots, file.Path)
+		target, ok := targets[root]
+		if !ok {
+			target = &Target{
+				Target: &config.Target{
+					Schema: jsonschema.URL(jsonschema.Target),
+					Name:   uniqueName(names, targetName(dir, root)),
+					Path:   filepath.Join(dir, filepath.FromSlash(root), config.TargetBaseName+ext),
+				},
+				Root: root,
+			}
+			targets[root] = target
+			proposal.Targets = append(proposal.Targets, target)
+		}
+		target.Files = append(target.Files, file.Path)
+		owners[file.Path] = target
+	}
+
+	for _, target := range proposal.Targets {
+		var excluded []string
+		for root := range targets {
+			if root != target.Root && within(target.Root, root) {
+				excluded = append(excluded, "!"+relative(target.Root, root)+"/**")
+			}
+		}
+		slices.Sort(excluded)
+		target.Sources = glob.NewPatterns(append([]string{"**/*.proto"}, excluded...)...)
+		if target.Root != "." {
+			target.ImportRoots = []string{target.Root}
+		}
+	}
+
+	for _, file := range files {
+		target := owners[file.Path]
+		for _, imported := range file.Imports {
+			provider := resolveImport(roots, owners, imported)
+			switch {
+			case provider == nil && strings.HasPrefix(imported, "google/protobuf/"):
+			case provider == nil:
+				proposal.Unresolved[file.Path] = append(proposal.Unresolved[file.Path], imported)
+			case provider != target && !slices.Contains(target.Dependencies, provider.Name):
+				target.Dependencies = append(target.Dependencies, provider.Name)
+			}
+		}
+	}
+	for _, target := range proposal.Targets {
+		slices.Sort(target.Dependencies)
+	}
+	proposal.SetPlugins(proposal.Plugins)
+	return proposal
+}
+
+// ImportRoot infers the slash-separated import root of the file, relative
+// to the scanned directory, from its package. Files are conventionally
+// stored in directories that match their packages, so that the file of
+// package "acme.billing.v1" in "proto/acme/billing/v1" has the import root
+// "proto". Files whose directories do not match their packages are imported
+// relative to the scanned directory.
+func ImportRoot(file File) string {
+	dir := path.Dir(file.Path)
+	if file.Package == "" {
+		return dir
+	}
+	pkg := strings.ReplaceAll(file.Package, ".", "/")
+	if dir == pkg {
+		return "."
+	}
+	if root, ok := strings.CutSuffix(dir, "/"+pkg); ok {
+		return root
+	}
+	return "."
+}
+
+// SetPlugins replaces the outputs of every target with one for each plugin.
+// Outputs are written into a directory named by the plugin, unless there is
+// more than one target, in which case each target writes into its own
+// sub-directory of it.
+func (p *Proposal) SetPlugins(plugins []string) {
+	p.Plugins = plugins
+	for _, target := range p.Targets {
+		target.Outputs = nil
+		for _, plugin := range plugins {
+			out := plugin
+			if len(p.Targets) > 1 {
+				out = plugin + "/" + target.Name
+			}
+			target.Outputs = append(target.Outputs, config.Output{Plugin: plugin, Out: out})
+		}
+	}
+}
+
+// Remove removes the target with the given name from the proposal, along
+// with every dependency on it.
+func (p *Proposal) Remove(name string) {
+	p.Targets = slices.DeleteFunc(p.Targets, func(target *Target) bool {
+		return target.Name == name
+	})
+	for _, target := range p.Targets {
+		target.Dependencies = slices.DeleteFunc(target.Dependencies, func(dependency string) bool {
+			return dependency == name
+		})
+	}
+	p.SetPlugins(p.Plugins)
+}
+
+// Write writes the workspace and target files of the proposal. No file is
+// written if any of them already exists.
+func (p *Proposal) Write() error {
+	paths := []string{p.Workspace.Path}
+	for _, target := range p.Targets {
+		paths = append(paths, target.Path)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists", path)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if existing, err := config.FindWorkspace(p.Workspace.Dir()); err == nil && filepath.Dir(existing) == p.Workspace.Dir() {
+		return fmt.Errorf("%s already exists", existing)
+	}
+
+	if err := p.Workspace.Save(); err != nil {
+		return err
+	}
+	for _, target := range p.Targets {
+		if err := target.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proposePlugins returns the plugins of the languages that the file options
+// of any file configure, or DefaultPlugin if none do.
+func proposePlugins(files []File) []string {
+	var plugins []string
+	for _, language := range languageOptions {
+		if slices.ContainsFunc(files, func(file File) bool {
+			_, ok := file.Options[language.option]
+			return ok
+		}) {
+			plugins = append(plugins, language.plugin)
+		}
+	}
+	if len(plugins) == 0 {
+		return []string{DefaultPlugin}
+	}
+	return plugins
+}
+
+// resolveImport returns the target of the file that the import refers to,
+// searching the import roots in order, or nil if no file provides it.
+func resolveImport(roots []string, owners map[string]*Target, imported string) *Target {
+	for _, root := range roots {
+		if target, ok := owners[path.Join(root, imported)]; ok {
+			return target
+		}
+	}
+	return nil
+}
+
+// deepestRoot returns the deepest of the roots that contains the path.
+func deepestRoot(roots []string, file string) string {
+	deepest := "."
+	for _, root := range roots {
+		if within(root, file) && len(root) > len(deepest) {
+			deepest = root
+		}
+	}
+	return deepest
+}
+
+// within reports whether the slash-separated path is within the directory.
+func within(dir, file string) bool {
+	return dir == "." || strings.HasPrefix(file, dir+"/")
+}
+
+// relative returns the slash-separated path relative to the directory that
+// contains it.
+func relative(dir, file string) string {
+	if dir == "." {
+		return file
+	}
+	return strings.TrimPrefix(file, dir+"/")
+}
+
+// genericNames are the names of directories that conventionally contain the
+// proto files of a project, such as svc/proto, which do not name the project.
+var genericNames = []string{"api", "proto", "protobuf", "protos"}
+
+// targetName returns the name of the target of the import root, which is the
+// name of its directory, or of the nearest parent directory whose name is
+// not generic.
+func targetName(dir, root string) string {
+	if root == "." {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "protos"
+		}
+		return filepath.Base(abs)
+	}
+	for slices.Contains(genericNames, path.Base(root)) && path.Dir(root) != "." {
+		root = path.Dir(root)
+	}
+	return path.Base(root)
+}
+
+// uniqueName returns the name, with a numbered suffix if it has already been
+// used, and marks it as used.
+func uniqueName(used map[string]bool, name string) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
@@ -0,0 +1,285 @@
+package scaffold_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/config"
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+	"github.com/bitwizeshift/protobuild/internal/scaffold"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("MkdirAll: unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+}
+
+// repository is a repository with proto files under two import roots, where
+// the files of one import from the other.
+var repository = map[string]string{
+	"proto/acme/billing/v1/invoice.proto": `
+		// Invoices.
+		syntax = "proto3";
+		package acme.billing.v1;
+		import "acme/types/v1/money.proto";
+		import public "google/protobuf/timestamp.proto";
+		import "google/api/annotations.proto";
+		option go_package = "example.com/acme/billing/v1;billingv1";
+	`,
+	"proto/acme/types/v1/money.proto": `
+		syntax = "proto3";
+		/* package ignored.v1; */
+		package acme.types.v1;
+		option java_package = "com.acme.types.v1";
+	`,
+	"api/events/v1/event.proto": `
+		syntax = "proto3";
+		package api.events.v1;
+		import "acme/billing/v1/invoice.proto";
+	`,
+	".git/objects/stale.proto":         `package stale;`,
+	"third_party/protobuild/x/x.proto": `package x;`,
+}
+
+func TestScan_Repository_ReturnsDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, repository)
+
+	got, err := scaffold.Scan(dir, glob.NewPatterns("third_party/protobuild/**"))
+	if err != nil {
+		t.Fatalf("Scan: unexpected error: %v", err)
+	}
+
+	want := []scaffold.File{
+		{
+			Path:    "api/events/v1/event.proto",
+			Package: "api.events.v1",
+			Imports: []string{"acme/billing/v1/invoice.proto"},
+			Options: map[string]string{},
+		}, {
+			Path:    "proto/acme/billing/v1/invoice.proto",
+			Package: "acme.billing.v1",
+			Imports: []string{"acme/types/v1/money.proto", "google/protobuf/timestamp.proto", "google/api/annotations.proto"},
+			Options: map[string]string{"go_package": "example.com/acme/billing/v1;billingv1"},
+		}, {
+			Path:    "proto/acme/types/v1/money.proto",
+			Package: "acme.types.v1",
+			Options: map[string]string{"java_package": "com.acme.types.v1"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Scan: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestScan_MalformedFile_SkipsFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.proto":      `package acme;`,
+		"broken.proto": `import "unterminated`,
+	})
+
+	got, err := scaffold.Scan(dir, nil)
+
+	var perr *protofile.Error
+	if !errors.As(err, &perr) || perr.File != filepath.Join(dir, "broken.proto") {
+		t.Errorf("Scan: got err %v, want a *protofile.Error for broken.proto", err)
+	}
+	want := []scaffold.File{{Path: "a.proto", Package: "acme", Options: map[string]string{}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Scan: mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImportRoot(t *testing.T) {
+	testCases := []struct {
+		name string
+		file scaffold.File
+		want string
+	}{
+		{
+			name: "package under root",
+			file: scaffold.File{Path: "proto/acme/v1/a.proto", Package: "acme.v1"},
+			want: "proto",
+		}, {
+			name: "package at top level",
+			file: scaffold.File{Path: "acme/v1/a.proto", Package: "acme.v1"},
+			want: ".",
+		}, {
+			name: "package not matching directory",
+			file: scaffold.File{Path: "api/a.proto", Package: "acme.api.v1"},
+			want: ".",
+		}, {
+			name: "no package",
+			file: scaffold.File{Path: "protos/a.proto"},
+			want: "protos",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scaffold.ImportRoot(tc.file)
+
+			if got != tc.want {
+				t.Errorf("ImportRoot: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPropose_Repository_ProposesTargetPerImportRoot(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "acme")
+	writeFiles(t, dir, repository)
+	files, err := scaffold.Scan(dir, glob.NewPatterns("third_party/protobuild/**"))
+	if err != nil {
+		t.Fatalf("Scan: unexpected error: %v", err)
+	}
+
+	got := scaffold.Propose(dir, config.FormatJSON, files)
+
+	want := []*config.Target{
+		{
+			Schema:       "https://bitwizeshift.github.io/protobuild/jsonschema/protobuild-target-v1.json",
+			Name:         "acme",
+			Sources:      glob.NewPatterns("**/*.proto", "!proto/**"),
+			Dependencies: []string{"proto"},
+			Outputs: []config.Output{
+				{Plugin: "go", Out: "go/acme"},
+				{Plugin: "java", Out: "java/acme"},
+			},
+			Path: filepath.Join(dir, "protobuild-target.json"),
+		}, {
+			Schema:      "https://bitwizeshift.github.io/protobuild/jsonschema/protobuild-target-v1.json",
+			Name:        "proto",
+			Sources:     glob.NewPatterns("**/*.proto"),
+			ImportRoots: []string{"proto"},
+			Outputs: []config.Output{
+				{Plugin: "go", Out: "go/proto"},
+				{Plugin: "java", Out: "java/proto"},
+			},
+			Path: filepath.Join(dir, "proto", "protobuild-target.json"),
+		},
+	}
+	var targets []*config.Target
+	for _, target := range got.Targets {
+		targets = append(targets, target.Target)
+	}
+	if diff := cmp.Diff(want, targets, cmpopts.IgnoreUnexported(config.Target{})); diff != "" {
+		t.Errorf("Propose: Targets mismatch (-want +got):\n%s", diff)
+	}
+	wantUnresolved := map[string][]string{
+		"proto/acme/billing/v1/invoice.proto": {"google/api/annotations.proto"},
+	}
+	if diff := cmp.Diff(wantUnresolved, got.Unresolved); diff != "" {
+		t.Errorf("Propose: Unresolved mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPropose_GenericImportRoots_NamesTargetsByParent(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"svc-a/proto/acme/a/v1/a.proto":     `package acme.a.v1;`,
+		"svc-b/proto/acme/b/v1/b.proto":     `package acme.b.v1;`,
+		"svc-c/api/proto/acme/c/v1/c.proto": `package acme.c.v1;`,
+	})
+	files, err := scaffold.Scan(dir, nil)
+	if err != nil {
+		t.Fatalf("Scan: unexpected error: %v", err)
+	}
+
+	got := scaffold.Propose(dir, config.FormatJSON, files)
+
+	var names []string
+	for _, target := range got.Targets {
+		names = append(names, target.Name)
+	}
+	want := []string{"svc-a", "svc-b", "svc-c"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("Propose: target names mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestProposalWrite_Proposal_WritesValidWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, repository)
+	files, err := scaffold.Scan(dir, glob.NewPatterns("third_party/protobuild/**"))
+	if err != nil {
+		t.Fatalf("Scan: unexpected error: %v", err)
+	}
+	proposal := scaffold.Propose(dir, config.FormatYAML, files)
+	proposal.SetPlugins([]string{"python"})
+
+	if err := proposal.Write(); err != nil {
+		t.Fatalf("Proposal.Write: unexpected error: %v", err)
+	}
+
+	workspace, err := config.LoadWorkspace(filepath.Join(dir, "protobuild.yaml"))
+	if err != nil {
+		t.Fatalf("LoadWorkspace: unexpected error: %v", err)
+	}
+	targets, err := workspace.LoadTargets(config.NewIndex())
+	if err != nil {
+		t.Fatalf("LoadTargets: unexpected error: %v", err)
+	}
+	if got, want := len(targets), 2; got != want {
+		t.Errorf("LoadTargets: got %d targets, want %d", got, want)
+	}
+	for _, target := range targets {
+		if got, want := len(target.SourceFiles()), 1; target.Name == "acme" && got != want {
+			t.Errorf("Target.SourceFiles: got %d files of target %s, want %d", got, target.Name, want)
+		}
+	}
+}
+
+func TestProposalWrite_ExistingWorkspace_ReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"protobuild.toml": `targets = ["**/protobuild-target.toml"]`,
+		"a/v1/a.proto":    `package a.v1;`,
+	})
+	files, err := scaffold.Scan(dir, nil)
+	if err != nil {
+		t.Fatalf("Scan: unexpected error: %v", err)
+	}
+	proposal := scaffold.Propose(dir, config.FormatJSON, files)
+
+	if err := proposal.Write(); err == nil {
+		t.Errorf("Proposal.Write: got nil error, want error for an existing workspace")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "protobuild.json")); err == nil {
+		t.Errorf("Proposal.Write: wrote a workspace next to an existing one")
+	}
+}
+
+func TestProposalRemove_Dependency_RemovesDependency(t *testing.T) {
+	proposal := scaffold.Propose(t.TempDir(), config.FormatJSON, []scaffold.File{
+		{Path: "a/x/a.proto", Package: "x", Imports: []string{"y/b.proto"}},
+		{Path: "b/y/b.proto", Package: "y"},
+	})
+
+	proposal.Remove("b")
+
+	if got, want := len(proposal.Targets), 1; got != want {
+		t.Fatalf("Proposal.Remove: got %d targets, want %d", got, want)
+	}
+	if got := proposal.Targets[0].Dependencies; len(got) != 0 {
+		t.Errorf("Proposal.Remove: Dependencies = %v, want none", got)
+	}
+	want := []config.Output{{Plugin: "cpp", Out: "cpp"}}
+	if diff := cmp.Diff(want, proposal.Targets[0].Outputs); diff != "" {
+		t.Errorf("Proposal.Remove: Outputs mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,104 @@
+package scaffold
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bitwizeshift/protobuild/internal/glob"
+	"github.com/bitwizeshift/protobuild/internal/protofile"
+)
+
+// File is a proto file that was found by Scan.
+type File struct {
+	// Path is the slash-separated path of the file, relative to the
+	// directory that was scanned.
+	Path string
+
+	// Package is the package that the file declares, if any.
+	Package string
+
+	// Imports are the paths of the files that the file imports.
+	Imports []string
+
+	// Options are the scalar file options that the file sets, such as
+	// go_package, keyed by the name of the option.
+	Options map[string]string
+}
+
+// Scan finds every proto file under dir that is not excluded by the
+// patterns, which are relative to dir, and reads the declarations of each.
+// Files in hidden directories, such as .git, are never scanned. The files are
+// returned sorted by path.
+//
+// A file that cannot be read or parsed is skipped, so that one malformed file
+// does not prevent the others from being scanned; every such failure is
+// returned, joined with errors.Join, along with the files that were scanned.
+func Scan(dir string, exclude glob.Patterns) ([]File, error) {
+	patterns := glob.NewPatterns("**/*.proto")
+	for _, pattern := range exclude {
+		patterns = append(patterns, "!"+pattern)
+	}
+	var files []File
+	var errs []error
+	for _, path := range patterns.Glob(dir) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if hidden(rel) {
+			continue
+		}
+		file, err := scanFile(path, rel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if file != nil {
+			files = append(files, *file)
+		}
+	}
+	slices.SortFunc(files, func(lhs, rhs File) int {
+		return strings.Compare(lhs.Path, rhs.Path)
+	})
+	return files, errors.Join(errs...)
+}
+
+// scanFile reads the declarations of the proto file at path, whose path
+// relative to the scanned directory is rel. It returns nil if the path is not
+// a regular file.
+func scanFile(path, rel string) (*File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := protofile.Parse(path, data)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{Path: rel, Package: parsed.Package, Options: parsed.Options}
+	for _, imp := range parsed.Imports {
+		file.Imports = append(file.Imports, imp.Path)
+	}
+	return file, nil
+}
+
+// hidden reports whether any directory of the slash-separated path is
+// hidden.
+func hidden(path string) bool {
+	parts := strings.Split(path, "/")
+	return slices.ContainsFunc(parts[:len(parts)-1], func(part string) bool {
+		return strings.HasPrefix(part, ".")
+	})
+}
@@ -0,0 +1,17 @@
+/*
+Package schemagen generates JSON Schema definitions from Go types.
+
+Properties are derived from the `json` struct tags of each field, in the same
+way that encoding/json would encode them; a field without the `omitempty`
+option is required. Schemas are further refined with two additional tags:
+
+  - `description` provides the description of the property.
+  - `jsonschema` provides a comma-separated list of keywords, such as
+    `jsonschema:"minItems=1,uniqueItems,default=."`. Keywords prefixed with
+    `items.` apply to the items of an array rather than the array itself.
+
+The supported keywords are `default`, `enum` (with values separated by `|`),
+`pattern`, `minLength`, `minItems`, `uniqueItems`, `minProperties`, and
+`maxProperties`.
+*/
+package schemagen
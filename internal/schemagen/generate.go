@@ -0,0 +1,187 @@
+package schemagen
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Options configures the top-level metadata of a generated schema.
+type Options struct {
+	// ID is the `$id` of the schema, which should be the URL it is hosted at.
+	ID string
+
+	// Title is the title of the schema.
+	Title string
+
+	// Description is the description of the top-level value.
+	Description string
+}
+
+// Generate generates the schema of the Go type t, which must be a struct.
+func Generate(t reflect.Type, opts Options) (*Schema, error) {
+	schema, err := generate(t)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = Draft
+	schema.ID = opts.ID
+	schema.Title = opts.Title
+	schema.Description = opts.Description
+	return schema, nil
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func generate(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return &Schema{Type: "string"}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := generate(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		values, err := generate(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return generateStruct(t)
+	}
+	return nil, fmt.Errorf("unsupported type %v", t)
+}
+
+func generateStruct(t reflect.Type) (*Schema, error) {
+	schema := &Schema{
+		Type:                 "object",
+		Properties:           &Properties{},
+		AdditionalProperties: false,
+	}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property, err := generate(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%v.%s: %w", t, field.Name, err)
+		}
+		property.Description = field.Tag.Get("description")
+		if err := applyKeywords(property, field.Tag.Get("jsonschema")); err != nil {
+			return nil, fmt.Errorf("%v.%s: %w", t, field.Name, err)
+		}
+		*schema.Properties = append(*schema.Properties, Property{Name: name, Schema: property})
+		if !hasOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema, nil
+}
+
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// applyKeywords applies the keywords from a `jsonschema` struct tag to the
+// schema.
+func applyKeywords(schema *Schema, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, keyword := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(keyword, "=")
+		target := schema
+		if rest, ok := strings.CutPrefix(key, "items."); ok {
+			if schema.Items == nil {
+				return fmt.Errorf("keyword %q applied to non-array", key)
+			}
+			target, key = schema.Items, rest
+		}
+		if err := applyKeyword(target, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyKeyword(schema *Schema, key, value string) error {
+	var err error
+	switch key {
+	case "default":
+		schema.Default = parseValue(value)
+	case "enum":
+		for _, v := range strings.Split(value, "|") {
+			schema.Enum = append(schema.Enum, parseValue(v))
+		}
+	case "pattern":
+		schema.Pattern = value
+	case "uniqueItems":
+		schema.UniqueItems = true
+	case "minLength":
+		schema.MinLength, err = parseInt(value)
+	case "minItems":
+		schema.MinItems, err = parseInt(value)
+	case "minProperties":
+		schema.MinProperties, err = parseInt(value)
+	case "maxProperties":
+		schema.MaxProperties, err = parseInt(value)
+	default:
+		return fmt.Errorf("unknown keyword %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("keyword %q: %w", key, err)
+	}
+	return nil
+}
+
+// parseValue parses a keyword value as JSON if possible, and otherwise treats
+// it as a string.
+func parseValue(value string) any {
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err == nil {
+		return v
+	}
+	return value
+}
+
+func parseInt(value string) (*int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
@@ -0,0 +1,94 @@
+package schemagen_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/schemagen"
+	"github.com/google/go-cmp/cmp"
+)
+
+type item struct {
+	Name string `json:"name" jsonschema:"pattern=^[a-z]+$" description:"The name."`
+}
+
+type document struct {
+	Mode    string            `json:"mode,omitempty" jsonschema:"enum=fast|slow,default=fast"`
+	Items   []item            `json:"items" jsonschema:"minItems=1,uniqueItems"`
+	Tags    []string          `json:"tags,omitempty" jsonschema:"items.minLength=1"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Count   int               `json:"count,omitempty"`
+	Ignored string            `json:"-"`
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestGenerate_Struct_ReturnsSchema(t *testing.T) {
+	want := &schemagen.Schema{
+		Schema: schemagen.Draft,
+		ID:     "https://example.com/document.json",
+		Title:  "Document",
+		Type:   "object",
+		Properties: &schemagen.Properties{
+			{Name: "mode", Schema: &schemagen.Schema{
+				Type:    "string",
+				Enum:    []any{"fast", "slow"},
+				Default: "fast",
+			}},
+			{Name: "items", Schema: &schemagen.Schema{
+				Type: "array",
+				Items: &schemagen.Schema{
+					Type: "object",
+					Properties: &schemagen.Properties{
+						{Name: "name", Schema: &schemagen.Schema{
+							Description: "The name.",
+							Type:        "string",
+							Pattern:     "^[a-z]+$",
+						}},
+					},
+					Required:             []string{"name"},
+					AdditionalProperties: false,
+				},
+				MinItems:    intPtr(1),
+				UniqueItems: true,
+			}},
+			{Name: "tags", Schema: &schemagen.Schema{
+				Type:  "array",
+				Items: &schemagen.Schema{Type: "string", MinLength: intPtr(1)},
+			}},
+			{Name: "labels", Schema: &schemagen.Schema{
+				Type:                 "object",
+				AdditionalProperties: &schemagen.Schema{Type: "string"},
+			}},
+			{Name: "count", Schema: &schemagen.Schema{Type: "integer"}},
+		},
+		Required:             []string{"items"},
+		AdditionalProperties: false,
+	}
+
+	got, err := schemagen.Generate(reflect.TypeFor[document](), schemagen.Options{
+		ID:    "https://example.com/document.json",
+		Title: "Document",
+	})
+	if err != nil {
+		t.Fatalf("Generate: unexpected error: %v", err)
+	}
+
+	if !cmp.Equal(got, want) {
+		t.Errorf("Generate: (-got +want):\n%s", cmp.Diff(got, want))
+	}
+}
+
+func TestGenerate_UnknownKeyword_ReturnsError(t *testing.T) {
+	type invalid struct {
+		Name string `json:"name" jsonschema:"maxLength=3"`
+	}
+
+	_, err := schemagen.Generate(reflect.TypeFor[invalid](), schemagen.Options{})
+
+	if err == nil {
+		t.Errorf("Generate: got nil error, want unknown keyword error")
+	}
+}
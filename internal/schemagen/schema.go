@@ -0,0 +1,78 @@
+package schemagen
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Draft is the JSON Schema dialect of generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema definition. Fields are ordered the way they are
+// conventionally written, so that generated files read naturally.
+type Schema struct {
+	Schema               string      `json:"$schema,omitempty"`
+	ID                   string      `json:"$id,omitempty"`
+	Title                string      `json:"title,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	Type                 string      `json:"type,omitempty"`
+	Enum                 []any       `json:"enum,omitempty"`
+	Pattern              string      `json:"pattern,omitempty"`
+	MinLength            *int        `json:"minLength,omitempty"`
+	Properties           *Properties `json:"properties,omitempty"`
+	Required             []string    `json:"required,omitempty"`
+	MinProperties        *int        `json:"minProperties,omitempty"`
+	MaxProperties        *int        `json:"maxProperties,omitempty"`
+	AdditionalProperties any         `json:"additionalProperties,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+	MinItems             *int        `json:"minItems,omitempty"`
+	UniqueItems          bool        `json:"uniqueItems,omitempty"`
+	Default              any         `json:"default,omitempty"`
+}
+
+// Property is a single named property of an object schema.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties is an ordered collection of object properties.
+type Properties []Property
+
+// MarshalJSON encodes the properties as a JSON object, preserving their order.
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, property := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(property.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(property.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var _ json.Marshaler = (*Properties)(nil)
+
+// Marshal encodes the schema as indented JSON, terminated by a newline.
+func (s *Schema) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
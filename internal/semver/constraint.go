@@ -0,0 +1,166 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operator is the comparison of a single term of a constraint.
+type operator string
+
+const (
+	opEqual        operator = "="
+	opNotEqual     operator = "!="
+	opGreater      operator = ">"
+	opGreaterEqual operator = ">="
+	opLess         operator = "<"
+	opLessEqual    operator = "<="
+	opTilde        operator = "~"
+	opCaret        operator = "^"
+)
+
+// operators are the supported operators, with every operator listed before
+// the operators that are its prefix.
+var operators = []operator{
+	opGreaterEqual, opLessEqual, opNotEqual,
+	opGreater, opLess, opEqual, opTilde, opCaret,
+}
+
+// term is a single comparison of a constraint.
+type term struct {
+	op      operator
+	version Version
+}
+
+// Constraint is a range of versions. The zero value matches every version.
+type Constraint struct {
+	terms []term
+	text  string
+}
+
+// ParseConstraint parses a constraint, such as ">= 1.2, < 2". An empty
+// constraint, or "*", matches every version.
+func ParseConstraint(s string) (Constraint, error) {
+	text := strings.TrimSpace(s)
+	if text == "" || text == "*" {
+		return Constraint{}, nil
+	}
+	constraint := Constraint{text: text}
+	for _, field := range strings.Split(text, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: empty comparison", s)
+		}
+		op := opEqual
+		for _, candidate := range operators {
+			if rest, ok := strings.CutPrefix(field, string(candidate)); ok {
+				op, field = candidate, rest
+				break
+			}
+		}
+		version, err := Parse(field)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		constraint.terms = append(constraint.terms, term{op: op, version: version})
+	}
+	return constraint, nil
+}
+
+// IsAny returns whether the constraint matches every version.
+func (c Constraint) IsAny() bool {
+	return len(c.terms) == 0
+}
+
+// String returns the constraint as it was written.
+func (c Constraint) String() string {
+	if c.IsAny() {
+		return "*"
+	}
+	return c.text
+}
+
+// Match returns whether the version satisfies every comparison of the
+// constraint. Pre-releases only match constraints that refer to a
+// pre-release of the same major, minor, and patch version, so that ranges do
+// not unintentionally select unstable versions.
+func (c Constraint) Match(v Version) bool {
+	if v.Prerelease != "" && !c.allowsPrerelease(v) {
+		return false
+	}
+	for _, t := range c.terms {
+		if !t.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Constraint) allowsPrerelease(v Version) bool {
+	for _, t := range c.terms {
+		if t.version.Prerelease != "" && t.version.Major == v.Major && t.version.Minor == v.Minor && t.version.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns whether the version satisfies the term. A partial version,
+// such as "1.2", stands for every version that it is a prefix of, so that
+// "= 1.2" matches 1.2.5, "<= 1.2" means "< 1.3.0", and "> 1.2" means
+// ">= 1.3.0".
+func (t term) match(v Version) bool {
+	c := v.Compare(t.version)
+	switch t.op {
+	case opEqual:
+		return t.covers(v)
+	case opNotEqual:
+		return !t.covers(v)
+	case opGreater:
+		return c > 0 && !t.covers(v)
+	case opGreaterEqual:
+		return c >= 0
+	case opLess:
+		return c < 0
+	case opLessEqual:
+		return c <= 0 || t.covers(v)
+	case opTilde:
+		return c >= 0 && v.Compare(t.tildeLimit()) < 0
+	case opCaret:
+		return c >= 0 && v.Compare(t.caretLimit()) < 0
+	}
+	return false
+}
+
+// covers returns whether the version is one that the version of the term
+// stands for: the version itself, or, if it is partial, any version that it
+// is a prefix of.
+func (t term) covers(v Version) bool {
+	if t.version.parts == 3 {
+		return v.Compare(t.version) == 0
+	}
+	return v.Compare(t.version) >= 0 && v.Compare(t.tildeLimit()) < 0
+}
+
+// tildeLimit returns the least version excluded by a "~" term: "~1.2.3" and
+// "~1.2" allow patch updates, and "~1" allows minor updates.
+func (t term) tildeLimit() Version {
+	if t.version.parts == 1 {
+		return Version{Major: t.version.Major + 1, Prerelease: "0"}
+	}
+	return Version{Major: t.version.Major, Minor: t.version.Minor + 1, Prerelease: "0"}
+}
+
+// caretLimit returns the least version excluded by a "^" term, which allows
+// updates that do not change the leftmost non-zero component.
+func (t term) caretLimit() Version {
+	v := t.version
+	switch {
+	case v.Major > 0 || v.parts == 1:
+		return Version{Major: v.Major + 1, Prerelease: "0"}
+	case v.Minor > 0 || v.parts == 2:
+		return Version{Minor: v.Minor + 1, Prerelease: "0"}
+	default:
+		return Version{Patch: v.Patch + 1, Prerelease: "0"}
+	}
+}
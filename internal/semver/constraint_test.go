@@ -0,0 +1,89 @@
+package semver_test
+
+import (
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/semver"
+)
+
+func TestConstraintMatch(t *testing.T) {
+	testCases := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "", version: "0.1.0", want: true},
+		{constraint: "*", version: "3.0.0", want: true},
+		{constraint: ">= 1.2, < 2", version: "v1.2.0", want: true},
+		{constraint: ">= 1.2, < 2", version: "v1.9.9", want: true},
+		{constraint: ">= 1.2, < 2", version: "v1.1.9", want: false},
+		{constraint: ">= 1.2, < 2", version: "v2.0.0", want: false},
+		{constraint: ">= 1.2, < 2", version: "v1.5.0-rc.1", want: false},
+		{constraint: ">= 1.5.0-rc.1", version: "v1.5.0-rc.2", want: true},
+		{constraint: "1.2.3", version: "1.2.3", want: true},
+		{constraint: "=1.2", version: "1.2.1", want: true},
+		{constraint: "=1.2", version: "1.3.0", want: false},
+		{constraint: "= 1", version: "1.9.9", want: true},
+		{constraint: "!= 1.2", version: "1.2.5", want: false},
+		{constraint: "!= 1.2", version: "1.3.0", want: true},
+		{constraint: "<= 1.2", version: "1.2.5", want: true},
+		{constraint: "<= 1.2", version: "1.3.0", want: false},
+		{constraint: "<= 1", version: "1.9.0", want: true},
+		{constraint: "> 1.2", version: "1.2.1", want: false},
+		{constraint: "> 1.2", version: "1.3.0", want: true},
+		{constraint: "> 1", version: "1.9.0", want: false},
+		{constraint: "> 1", version: "2.0.0", want: true},
+		{constraint: ">= 1.2", version: "1.2.0", want: true},
+		{constraint: "< 1.2", version: "1.2.0", want: false},
+		{constraint: "!= 1.2.3", version: "1.2.3", want: false},
+		{constraint: "> 1.2.3", version: "1.2.4", want: true},
+		{constraint: "<= 1.2.3", version: "1.2.3", want: true},
+		{constraint: "~1.2.3", version: "1.2.9", want: true},
+		{constraint: "~1.2.3", version: "1.3.0", want: false},
+		{constraint: "~1", version: "1.9.0", want: true},
+		{constraint: "^1.2", version: "1.9.0", want: true},
+		{constraint: "^1.2", version: "2.0.0", want: false},
+		{constraint: "^1.2", version: "2.0.0-rc.1", want: false},
+		{constraint: "^0.2.3", version: "0.2.9", want: true},
+		{constraint: "^0.2.3", version: "0.3.0", want: false},
+		{constraint: "^0.0.3", version: "0.0.4", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.constraint+" "+tc.version, func(t *testing.T) {
+			constraint, err := semver.ParseConstraint(tc.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q): unexpected error: %v", tc.constraint, err)
+			}
+			version, err := semver.Parse(tc.version)
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", tc.version, err)
+			}
+
+			if got := constraint.Match(version); got != tc.want {
+				t.Errorf("Constraint(%q).Match(%q): got %v, want %v", tc.constraint, tc.version, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_Invalid_ReturnsError(t *testing.T) {
+	for _, input := range []string{">= 1.2,", ">= main", "=> 1.2", ">= 1, , < 2"} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := semver.ParseConstraint(input); err == nil {
+				t.Errorf("ParseConstraint(%q): got nil error, want error", input)
+			}
+		})
+	}
+}
+
+func TestConstraintString(t *testing.T) {
+	constraint, err := semver.ParseConstraint(" >= 1.2, < 2 ")
+	if err != nil {
+		t.Fatalf("ParseConstraint: unexpected error: %v", err)
+	}
+
+	if got, want := constraint.String(), ">= 1.2, < 2"; got != want {
+		t.Errorf("Constraint.String: got %q, want %q", got, want)
+	}
+}
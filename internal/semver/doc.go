@@ -0,0 +1,12 @@
+/*
+Package semver parses semantic versions, and the constraints that dependencies
+place on the versions of registry projects, such as ">= 1.2, < 2".
+
+Versions may be prefixed with "v", as git tags usually are, and may omit their
+minor and patch components, which are then zero. Constraints are
+comma-separated comparisons that must all hold; the supported operators are
+"=", "!=", ">", ">=", "<", "<=", "~" (patch updates), and "^" (updates that do
+not change the leftmost non-zero component). A version without an operator
+must match exactly.
+*/
+package semver
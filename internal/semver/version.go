@@ -0,0 +1,114 @@
+package semver
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version.
+type Version struct {
+	// Major, Minor, and Patch are the numeric components of the version.
+	Major, Minor, Patch int
+
+	// Prerelease is the pre-release identifiers of the version, such as
+	// "rc.1", or empty for a release.
+	Prerelease string
+
+	// parts is the number of numeric components that were given when the
+	// version was parsed, which determines the range of versions that it
+	// stands for in a constraint.
+	parts int
+}
+
+// Parse parses a semantic version, such as "v1.2.3" or "1.2". Build metadata
+// following a "+" is ignored.
+func Parse(s string) (Version, error) {
+	text := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	text, _, _ = strings.Cut(text, "+")
+	text, prerelease, hasPrerelease := strings.Cut(text, "-")
+	if hasPrerelease && prerelease == "" {
+		return Version{}, fmt.Errorf("invalid version %q: empty pre-release", s)
+	}
+
+	fields := strings.Split(text, ".")
+	if len(fields) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q: more than three components", s)
+	}
+	var numbers [3]int
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 || field != strconv.Itoa(n) {
+			return Version{}, fmt.Errorf("invalid version %q: component %q is not a number", s, field)
+		}
+		numbers[i] = n
+	}
+	return Version{
+		Major:      numbers[0],
+		Minor:      numbers[1],
+		Patch:      numbers[2],
+		Prerelease: prerelease,
+		parts:      len(fields),
+	}, nil
+}
+
+// String returns the version in "major.minor.patch" form.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0, or +1 depending on whether v is less than, equal
+// to, or greater than other. Pre-releases are less than the release of the
+// same version.
+func (v Version) Compare(other Version) int {
+	if c := cmp.Compare(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease compares pre-release identifiers by the rules of
+// semantic versioning: numeric identifiers compare numerically and are less
+// than alphanumeric ones, and a longer list of identifiers is greater than a
+// prefix of it.
+func comparePrerelease(lhs, rhs string) int {
+	switch {
+	case lhs == rhs:
+		return 0
+	case lhs == "":
+		return 1
+	case rhs == "":
+		return -1
+	}
+	left, right := strings.Split(lhs, "."), strings.Split(rhs, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		l, lerr := strconv.Atoi(left[i])
+		r, rerr := strconv.Atoi(right[i])
+		var c int
+		switch {
+		case lerr == nil && rerr == nil:
+			c = cmp.Compare(l, r)
+		case lerr == nil:
+			c = -1
+		case rerr == nil:
+			c = 1
+		default:
+			c = strings.Compare(left[i], right[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(left), len(right))
+}
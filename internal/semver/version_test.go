@@ -0,0 +1,79 @@
+package semver_test
+
+import (
+	"testing"
+
+	"github.com/bitwizeshift/protobuild/internal/semver"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "full version", input: "1.2.3", want: "1.2.3"},
+		{name: "v prefix", input: "v1.2.3", want: "1.2.3"},
+		{name: "major and minor", input: "v1.2", want: "1.2.0"},
+		{name: "major only", input: "2", want: "2.0.0"},
+		{name: "pre-release", input: "1.0.0-rc.1", want: "1.0.0-rc.1"},
+		{name: "build metadata", input: "1.0.0+build.5", want: "1.0.0"},
+		{name: "not a number", input: "main", wantErr: true},
+		{name: "too many components", input: "1.2.3.4", wantErr: true},
+		{name: "leading zero", input: "01.2", wantErr: true},
+		{name: "empty pre-release", input: "1.0.0-", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := semver.Parse(tc.input)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Parse(%q): got nil error, want error", tc.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", tc.input, err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("Parse(%q): got %s, want %s", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	testCases := []struct {
+		lhs, rhs string
+		want     int
+	}{
+		{lhs: "1.2.3", rhs: "1.2.3", want: 0},
+		{lhs: "v1.2", rhs: "1.2.0", want: 0},
+		{lhs: "1.2.3", rhs: "1.10.0", want: -1},
+		{lhs: "2.0.0", rhs: "1.99.99", want: 1},
+		{lhs: "1.0.0-rc.1", rhs: "1.0.0", want: -1},
+		{lhs: "1.0.0-rc.2", rhs: "1.0.0-rc.10", want: -1},
+		{lhs: "1.0.0-alpha", rhs: "1.0.0-1", want: 1},
+		{lhs: "1.0.0-rc", rhs: "1.0.0-rc.1", want: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.lhs+" "+tc.rhs, func(t *testing.T) {
+			lhs, err := semver.Parse(tc.lhs)
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", tc.lhs, err)
+			}
+			rhs, err := semver.Parse(tc.rhs)
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", tc.rhs, err)
+			}
+
+			if got := lhs.Compare(rhs); got != tc.want {
+				t.Errorf("Version.Compare: got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+/*
+Package jsonschema embeds the JSON Schema definitions of the protobuild
+configuration files, so that they may be used by the binary for validation.
+
+These are the same schemas that are published alongside the documentation.
+*/
+package jsonschema
+
+import (
+	"embed"
+)
+
+// BaseURL is the URL that the schemas are hosted under.
+const BaseURL = "https://bitwizeshift.github.io/protobuild/jsonschema/"
+
+// Names of each embedded schema file.
+const (
+	Workspace = "protobuild-workspace-v1.json"
+	Target    = "protobuild-target-v1.json"
+	Registry  = "protobuild-registry-v1.json"
+	User      = "protobuild-user-v1.json"
+)
+
+// FS contains every embedded schema file.
+//
+//go:embed *.json
+var FS embed.FS
+
+// URL returns the hosted URL of the schema with the given name. This is also
+// the `$id` of the schema.
+func URL(name string) string {
+	return BaseURL + name
+}
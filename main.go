@@ -1,4 +1,34 @@
 package main
 
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/bitwizeshift/protobuild/internal/cli"
+	"github.com/bitwizeshift/protobuild/internal/cmd"
+)
+
+// version is set at link-time by release builds.
+var version = ""
+
 func main() {
+	os.Exit(run())
+}
+
+// run runs the command, and returns the exit code of the process. The process
+// is only exited once run returns, so that its deferred cleanup always runs.
+func run() int {
+	defer cli.HandlePanic()
+
+	// Interrupts cancel the context rather than killing the process, so that
+	// running commands may stop any work they have started.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := cmd.New(version).ExecuteContext(ctx); err != nil {
+		cli.Error(err)
+		return 1
+	}
+	return 0
 }
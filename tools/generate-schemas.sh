@@ -0,0 +1,14 @@
+#!/usr/bin/env bash
+
+# Regenerates the JSON Schemas under jsonschema/ from the Go configuration
+# types, so that the hosted schemas and the binary stay in lockstep.
+
+set -euo pipefail
+
+root=$(git rev-parse --show-toplevel)
+(
+  cd "${root}"
+  for kind in workspace target registry user; do
+    go run . schema print "${kind}" > "jsonschema/protobuild-${kind}-v1.json"
+  done
+)